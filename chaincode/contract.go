@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// 链码接口版本，与后端 invoke_fabric.ChaincodeVersion 一致。
+// 主版本不同表示不兼容，增加交易函数时升级次版本
+const ChaincodeVersion = "3.2"
+
+// 查询结果以 JSON 字符串返回，后端按 JSON 解析；
+// 不直接返回结构体，避免 contractapi 按结构校验时拒绝值为 null 的空列表
+type SmartContract struct {
+	contractapi.Contract
+}
+
+// 链码的版本和已实现的函数
+type ChaincodeInfo struct {
+	Version   string   `json:"version"`
+	Functions []string `json:"functions"`
+}
+
+// 查询链码版本和已实现的交易函数，后端启动时据此检查链码是否匹配
+func (s *SmartContract) GetChaincodeInfo(ctx contractapi.TransactionContextInterface) (string, error) {
+	return marshal(ChaincodeInfo{Version: ChaincodeVersion, Functions: transactionNames()})
+}
+
+// 初始化账本。账本从空开始，用户通过 CreateUser 注册，不预置任何数据
+func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
+	return nil
+}
+
+// SmartContract 上定义的交易函数，不包括 contractapi.Contract 自带的方法
+func transactionNames() []string {
+	builtin := make(map[string]bool)
+	base := reflect.TypeOf(&contractapi.Contract{})
+	for i := 0; i < base.NumMethod(); i++ {
+		builtin[base.Method(i).Name] = true
+	}
+
+	var names []string
+	contract := reflect.TypeOf(&SmartContract{})
+	for i := 0; i < contract.NumMethod(); i++ {
+		if name := contract.Method(i).Name; !builtin[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
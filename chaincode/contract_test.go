@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+// GetChaincodeInfo 列出交易函数，不包括 contractapi.Contract 自带的方法
+func TestGetChaincodeInfo(t *testing.T) {
+	l := newTestLedger(t)
+
+	var info ChaincodeInfo
+	if err := json.Unmarshal([]byte(l.mustInvoke("GetChaincodeInfo")), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != ChaincodeVersion {
+		t.Errorf("版本为 %s，应为 %s", info.Version, ChaincodeVersion)
+	}
+	for _, name := range []string{"GetChaincodeInfo", "InitLedger", "CreateUser", "TransferTokens"} {
+		if !slices.Contains(info.Functions, name) {
+			t.Errorf("函数列表缺少 %s", name)
+		}
+	}
+	for _, name := range []string{"GetName", "GetInfo", "GetTransactionContextHandler"} {
+		if slices.Contains(info.Functions, name) {
+			t.Errorf("函数列表不应包含 %s", name)
+		}
+	}
+}
+
+func readJSON[T any](t *testing.T, l *testLedger, fn string, args ...string) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal([]byte(l.mustInvoke(fn, args...)), &v); err != nil {
+		t.Fatalf("解析 %s 的结果失败: %v", fn, err)
+	}
+	return v
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// 解析 RFC3339 时间，空字符串表示不限
+func parseDeadline(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s格式错误，应为 RFC3339: %s", name, value)
+	}
+	return t, nil
+}
+
+// 设置未结束任务的接受和提交截止时间，空字符串表示不限，其他字段保持不变
+func (s *SmartContract) SetTaskDeadlines(ctx contractapi.TransactionContextInterface,
+	taskID string,
+	acceptDeadline string,
+	submitDeadline string,
+) error {
+	l := open(ctx)
+
+	accept, err := parseDeadline("接受截止时间", acceptDeadline)
+	if err != nil {
+		return err
+	}
+	submit, err := parseDeadline("提交截止时间", submitDeadline)
+	if err != nil {
+		return err
+	}
+	if !accept.IsZero() && !submit.IsZero() && submit.Before(accept) {
+		return fmt.Errorf("提交截止时间不能早于接受截止时间")
+	}
+	task, err := l.openTask(taskID)
+	if err != nil {
+		return err
+	}
+
+	task.AcceptDeadline = acceptDeadline
+	task.SubmitDeadline = submitDeadline
+	return l.putTask(task)
+}
+
+// 设置聚合截止时间，只能在 aggregating 状态设置，其他字段保持不变
+func (s *SmartContract) SetAggregateDeadline(ctx contractapi.TransactionContextInterface,
+	taskID string,
+	aggregateDeadline string,
+) error {
+	l := open(ctx)
+
+	if aggregateDeadline == "" {
+		return fmt.Errorf("聚合截止时间不能为空")
+	}
+	if _, err := parseDeadline("聚合截止时间", aggregateDeadline); err != nil {
+		return err
+	}
+	task, err := l.openTask(taskID)
+	if err != nil {
+		return err
+	}
+	if task.state() != taskAggregating {
+		return fmt.Errorf("任务 %s 处于 %s 状态，不能设置聚合截止时间", taskID, task.state())
+	}
+
+	task.AggregateDeadline = aggregateDeadline
+	return l.putTask(task)
+}
+
+// 租约，到期前只有持有者可以续期
+type Lease struct {
+	Holder    string `json:"holder"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+// 获取或续期租约：租约空闲、已过期或已由 holder 持有时按交易时间续期并返回 true
+func (s *SmartContract) AcquireLease(ctx contractapi.TransactionContextInterface,
+	name string,
+	holder string,
+	ttlSeconds int,
+) (bool, error) {
+	l := open(ctx)
+
+	if name == "" || holder == "" {
+		return false, fmt.Errorf("租约名称和持有者不能为空")
+	}
+	if ttlSeconds <= 0 {
+		return false, fmt.Errorf("租约时长必须大于 0")
+	}
+	now, err := l.time()
+	if err != nil {
+		return false, err
+	}
+
+	var lease Lease
+	ok, err := l.get(leaseType, name, &lease)
+	if err != nil {
+		return false, err
+	}
+	if ok && lease.Holder != holder {
+		expires, err := time.Parse(time.RFC3339, lease.ExpiresAt)
+		if err == nil && now.Before(expires) {
+			return false, nil
+		}
+	}
+
+	lease = Lease{Holder: holder, ExpiresAt: now.Add(time.Duration(ttlSeconds) * time.Second).Format(time.RFC3339)}
+	return true, l.put(leaseType, name, &lease)
+}
@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestSetTaskDeadlines(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 0)
+	taskID := l.mustInvoke("CreateTask", "", "0", "", "alice", "1", "", "")
+
+	l.mustInvoke("SetTaskDeadlines", taskID, "2026-02-01T00:00:00Z", "2026-03-01T00:00:00Z")
+	task := readJSON[Task](t, l, "ReadTask", taskID)
+	if task.AcceptDeadline != "2026-02-01T00:00:00Z" || task.SubmitDeadline != "2026-03-01T00:00:00Z" || task.Status != taskOpen {
+		t.Errorf("截止时间不正确: %+v", task)
+	}
+	l.mustInvoke("SetTaskDeadlines", taskID, "", "")
+	if task := readJSON[Task](t, l, "ReadTask", taskID); task.AcceptDeadline != "" || task.SubmitDeadline != "" {
+		t.Errorf("空截止时间应清除原值: %+v", task)
+	}
+
+	l.mustFail("SetTaskDeadlines", taskID, "2026-03-01T00:00:00Z", "2026-02-01T00:00:00Z")
+	l.mustFail("SetTaskDeadlines", taskID, "明天", "")
+	l.mustFail("SetTaskDeadlines", "missing", "", "")
+
+	// 聚合截止时间只能在 aggregating 状态设置
+	l.mustFail("SetAggregateDeadline", taskID, "2026-04-01T00:00:00Z")
+	task.Status = taskAggregating
+	putState(t, l, taskType, taskID, task)
+	l.mustInvoke("SetAggregateDeadline", taskID, "2026-04-01T00:00:00Z")
+	if task := readJSON[Task](t, l, "ReadTask", taskID); task.AggregateDeadline != "2026-04-01T00:00:00Z" {
+		t.Errorf("聚合截止时间不正确: %+v", task)
+	}
+	l.mustFail("SetAggregateDeadline", taskID, "")
+}
+
+// 租约到期前只有持有者可以续期，到期后其他实例可以取得
+func TestAcquireLease(t *testing.T) {
+	l := newTestLedger(t)
+
+	steps := []struct {
+		holder string
+		ttl    string
+		want   string
+	}{
+		{"a", "120", "true"},
+		{"b", "120", "false"}, // 每个交易晚一分钟，租约还剩一分钟
+		{"a", "60", "true"},   // 持有者续期
+		{"b", "120", "true"},  // 已到期
+		{"a", "60", "false"},
+	}
+	for i, step := range steps {
+		if got := l.mustInvoke("AcquireLease", "expiry", step.holder, step.ttl); got != step.want {
+			t.Errorf("第 %d 步 %s 获取租约为 %s，应为 %s", i+1, step.holder, got, step.want)
+		}
+	}
+	l.mustFail("AcquireLease", "expiry", "a", "0")
+	l.mustFail("AcquireLease", "", "a", "60")
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// 检查操作人存在且未被删除
+func (l *ledger) actor(username string) error {
+	user, err := l.user(username)
+	if err != nil {
+		return err
+	}
+	if user.Deleted() {
+		return fmt.Errorf("用户 %s 已被删除", username)
+	}
+	return nil
+}
+
+// 软删除用户。用户参与的任务必须都已结束，未结束的任务由后端先取消或退出
+func (s *SmartContract) SoftDeleteUser(ctx contractapi.TransactionContextInterface,
+	username string,
+	actor string,
+	reason string,
+) error {
+	l := open(ctx)
+
+	if err := l.actor(actor); err != nil {
+		return err
+	}
+	user, err := l.user(username)
+	if err != nil {
+		return err
+	}
+	if user.Deleted() {
+		return fmt.Errorf("用户 %s 已于 %s 被删除", username, user.DeletedAt)
+	}
+	for _, taskID := range user.Accepted {
+		var task Task
+		ok, err := l.get(taskType, taskID, &task)
+		if err != nil {
+			return err
+		}
+		if ok && !task.Deleted() && !taskClosed(task.state()) {
+			return fmt.Errorf("用户 %s 还在参与未结束的任务 %s", username, taskID)
+		}
+	}
+	now, err := l.now()
+	if err != nil {
+		return err
+	}
+
+	user.Tombstone = Tombstone{DeletedAt: now, DeletedBy: actor, DeleteReason: reason}
+	return l.putUser(user)
+}
+
+// 恢复已删除的用户
+func (s *SmartContract) RestoreUser(ctx contractapi.TransactionContextInterface, username string, actor string) error {
+	l := open(ctx)
+
+	if err := l.actor(actor); err != nil {
+		return err
+	}
+	user, err := l.user(username)
+	if err != nil {
+		return err
+	}
+	if !user.Deleted() {
+		return fmt.Errorf("用户 %s 没有被删除", username)
+	}
+	user.Tombstone = Tombstone{}
+	return l.putUser(user)
+}
+
+// 软删除已结束的任务，在同一交易中把任务从参与者的 Accepted 列表移除，任务自身的 acceptedUsers 保留作为历史
+func (s *SmartContract) SoftDeleteTask(ctx contractapi.TransactionContextInterface,
+	taskID string,
+	actor string,
+	reason string,
+) error {
+	l := open(ctx)
+
+	if err := l.actor(actor); err != nil {
+		return err
+	}
+	task, err := l.task(taskID)
+	if err != nil {
+		return err
+	}
+	if task.Deleted() {
+		return fmt.Errorf("任务 %s 已于 %s 被删除", taskID, task.DeletedAt)
+	}
+	if !taskClosed(task.state()) {
+		return fmt.Errorf("任务 %s 处于 %s 状态，需要先取消", taskID, task.state())
+	}
+	now, err := l.now()
+	if err != nil {
+		return err
+	}
+
+	task.Tombstone = Tombstone{DeletedAt: now, DeletedBy: actor, DeleteReason: reason}
+	if err := l.putTask(task); err != nil {
+		return err
+	}
+	for _, username := range task.AcceptedUsers {
+		user, err := l.user(username)
+		if err != nil {
+			continue // 参与者记录缺失由引用修复处理
+		}
+		if !slices.Contains(user.Accepted, taskID) {
+			continue
+		}
+		user.Accepted = slices.DeleteFunc(user.Accepted, func(id string) bool { return id == taskID })
+		if err := l.putUser(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 恢复已删除的任务，任务保持删除前的状态，在同一交易中把任务重新加入参与者的 Accepted 列表
+func (s *SmartContract) RestoreTask(ctx contractapi.TransactionContextInterface, taskID string, actor string) error {
+	l := open(ctx)
+
+	if err := l.actor(actor); err != nil {
+		return err
+	}
+	task, err := l.task(taskID)
+	if err != nil {
+		return err
+	}
+	if !task.Deleted() {
+		return fmt.Errorf("任务 %s 没有被删除", taskID)
+	}
+
+	task.Tombstone = Tombstone{}
+	if err := l.putTask(task); err != nil {
+		return err
+	}
+	for _, username := range task.AcceptedUsers {
+		user, err := l.user(username)
+		if err != nil {
+			continue
+		}
+		if slices.Contains(user.Accepted, taskID) {
+			continue
+		}
+		user.Accepted = append(user.Accepted, taskID)
+		if err := l.putUser(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSoftDeleteUser(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("root", 0)
+	l.createUser("alice", 0)
+	l.createUser("bob", 0)
+	taskID := l.mustInvoke("CreateTask", "", "0", "", "alice", "1", "", "")
+	l.mustInvoke("AcceptTask", taskID, "bob", taskOpen)
+
+	// 还在参与未结束的任务时不能删除
+	l.mustFail("SoftDeleteUser", "bob", "root", "违规")
+	l.mustInvoke("WithdrawFromTask", taskID, "bob", "0")
+	l.mustFail("SoftDeleteUser", "bob", "carol", "违规")
+	l.mustInvoke("SoftDeleteUser", "bob", "root", "违规")
+
+	bob := readJSON[User](t, l, "ReadUser", "bob")
+	if !bob.Deleted() || bob.DeletedBy != "root" || bob.DeleteReason != "违规" {
+		t.Errorf("删除标记不正确: %+v", bob.Tombstone)
+	}
+	l.mustFail("SoftDeleteUser", "bob", "root", "")
+	l.mustFail("SoftDeleteUser", "alice", "bob", "")
+	l.mustFail("AcceptTask", taskID, "bob", taskOpen)
+
+	l.mustInvoke("RestoreUser", "bob", "root")
+	if bob := readJSON[User](t, l, "ReadUser", "bob"); bob.Deleted() || bob.DeleteReason != "" {
+		t.Errorf("恢复后仍有删除标记: %+v", bob.Tombstone)
+	}
+	l.mustFail("RestoreUser", "bob", "root")
+}
+
+// 删除和恢复任务时同步参与者的 Accepted 列表
+func TestSoftDeleteTask(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustInvoke("CreateUser", "root", "pw", "org1", "", "0", "true", "true", "true")
+	l.createUser("alice", 0)
+	l.createUser("bob", 0)
+	taskID := l.mustInvoke("CreateTask", "", "0", "", "alice", "1", "", "")
+	l.mustInvoke("AcceptTask", taskID, "bob", taskOpen)
+
+	// 未结束的任务需要先取消
+	l.mustFail("SoftDeleteTask", taskID, "root", "")
+	l.mustInvoke("TransitionTask", taskID, taskInProgress, taskCancelled, "root", "删除任务")
+	l.mustInvoke("SoftDeleteTask", taskID, "root", "重复发布")
+
+	task := readJSON[Task](t, l, "ReadTask", taskID)
+	if !task.Deleted() || !slices.Equal(task.AcceptedUsers, []string{"bob"}) {
+		t.Errorf("删除后的任务记录不正确: %+v", task)
+	}
+	if bob := readJSON[User](t, l, "ReadUser", "bob"); len(bob.Accepted) != 0 {
+		t.Errorf("删除任务后参与者的接受列表应移除该任务: %+v", bob.Accepted)
+	}
+	l.mustFail("SoftDeleteTask", taskID, "root", "")
+	l.mustFail("TransitionTask", taskID, taskCancelled, taskOpen, "root", "")
+
+	l.mustInvoke("RestoreTask", taskID, "root")
+	if task := readJSON[Task](t, l, "ReadTask", taskID); task.Deleted() || task.Status != taskCancelled {
+		t.Errorf("恢复后的任务应保持删除前的状态: %+v", task)
+	}
+	if bob := readJSON[User](t, l, "ReadUser", "bob"); !slices.Equal(bob.Accepted, []string{taskID}) {
+		t.Errorf("恢复任务后参与者的接受列表不正确: %+v", bob.Accepted)
+	}
+	l.mustFail("RestoreTask", taskID, "root")
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// 下载审计记录中链码需要的字段，其余字段原样保存
+type downloadEntry struct {
+	ModelID  string `json:"modelId"`
+	Username string `json:"username"`
+}
+
+// 记录一次模型下载，以交易时间和交易 ID 为键保存，只追加不修改
+func (s *SmartContract) RecordDownload(ctx contractapi.TransactionContextInterface, entryJSON string) error {
+	l := open(ctx)
+
+	var entry downloadEntry
+	if err := json.Unmarshal([]byte(entryJSON), &entry); err != nil {
+		return fmt.Errorf("下载记录格式错误: %w", err)
+	}
+	if entry.ModelID == "" || entry.Username == "" {
+		return fmt.Errorf("下载记录缺少模型 ID 或用户名")
+	}
+	t, err := l.time()
+	if err != nil {
+		return err
+	}
+	order := fmt.Sprintf("%020d", t.UnixNano())
+	txID := l.stub.GetTxID()
+	existing, err := l.getRaw(downloadType, order, txID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("交易 %s 已记录过下载", txID)
+	}
+	return l.putRaw([]byte(entryJSON), downloadType, order, txID)
+}
+
+// 按模型和用户查询下载记录，按时间倒序返回最多 limit 条；modelID 和 username 为空时不过滤，limit 不大于 0 时不限
+func (s *SmartContract) QueryDownloads(ctx contractapi.TransactionContextInterface,
+	modelID string,
+	username string,
+	limit int,
+) (string, error) {
+	l := open(ctx)
+
+	values, err := l.list(downloadType)
+	if err != nil {
+		return "", err
+	}
+	var matched [][]byte
+	for i := len(values) - 1; i >= 0; i-- {
+		if limit > 0 && len(matched) >= limit {
+			break
+		}
+		var entry downloadEntry
+		if err := json.Unmarshal(values[i], &entry); err != nil {
+			return "", fmt.Errorf("解析下载记录失败: %w", err)
+		}
+		if modelID != "" && entry.ModelID != modelID || username != "" && entry.Username != username {
+			continue
+		}
+		matched = append(matched, values[i])
+	}
+	return jsonArray(matched), nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// 下载记录只追加，按模型和用户过滤，按时间倒序返回
+func TestDownloads(t *testing.T) {
+	l := newTestLedger(t)
+
+	record := func(modelID, username string) {
+		l.t.Helper()
+		entry := fmt.Sprintf(`{"modelId":%q,"username":%q,"result":"ok","bytes":42}`, modelID, username)
+		l.mustInvoke("RecordDownload", entry)
+	}
+	record("model-1", "alice")
+	record("model-2", "alice")
+	record("model-1", "bob")
+	record("model-1", "alice")
+
+	l.mustFail("RecordDownload", "not json")
+	l.mustFail("RecordDownload", `{"modelId":"model-1"}`)
+
+	tests := []struct {
+		modelID, username string
+		limit             int
+		want              []string
+	}{
+		{"", "", 0, []string{"model-1/alice", "model-1/bob", "model-2/alice", "model-1/alice"}},
+		{"", "", 2, []string{"model-1/alice", "model-1/bob"}},
+		{"model-1", "", 0, []string{"model-1/alice", "model-1/bob", "model-1/alice"}},
+		{"", "alice", 0, []string{"model-1/alice", "model-2/alice", "model-1/alice"}},
+		{"model-1", "alice", 1, []string{"model-1/alice"}},
+		{"model-3", "", 0, nil},
+	}
+	for _, tt := range tests {
+		result := l.mustInvoke("QueryDownloads", tt.modelID, tt.username, fmt.Sprint(tt.limit))
+		var entries []map[string]any
+		if err := json.Unmarshal([]byte(result), &entries); err != nil {
+			t.Fatalf("解析 %s 失败: %v", result, err)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, fmt.Sprintf("%s/%s", e["modelId"], e["username"]))
+			if e["bytes"] != float64(42) {
+				t.Errorf("记录中的其他字段应原样保存: %v", e)
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("QueryDownloads(%q, %q, %d) = %v，期望 %v", tt.modelID, tt.username, tt.limit, got, tt.want)
+		}
+	}
+}
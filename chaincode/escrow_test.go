@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestEscrowAccounts(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 100)
+	l.createUser("bob", 0)
+	taskID := l.mustInvoke("CreateTask", "", "30", "", "alice", "1", "", "")
+	escrow := escrowPrefix + taskID
+
+	l.mustInvoke("TransferTokens", "alice", escrow, "60", "托管")
+	if balance := l.mustInvoke("GetAccountBalance", escrow); balance != "60" {
+		t.Errorf("托管余额为 %s，应为 60", balance)
+	}
+	if balance := l.mustInvoke("GetAccountBalance", "alice"); balance != "40" {
+		t.Errorf("发布者余额为 %s，应为 40", balance)
+	}
+	if balance := l.mustInvoke("GetAccountBalance", "nobody"); balance != "0" {
+		t.Errorf("不存在的账户余额为 %s，应为 0", balance)
+	}
+
+	// 不存在的任务没有托管账户，托管余额不足时失败
+	l.mustFail("TransferTokens", "alice", escrowPrefix+"missing", "1", "")
+	l.mustFail("TransferTokens", escrow, "bob", "61", "")
+	l.mustFail("TransferTokens", "alice", "system:other", "1", "")
+
+	// 托管账户有余额时不能彻底删除任务
+	l.mustFail("DeleteTask", taskID)
+
+	// 任务未结束时托管余额转出不记录释放时间
+	l.mustInvoke("TransferTokens", escrow, "bob", "60", "支付")
+	if task := readJSON[Task](t, l, "ReadTask", taskID); task.EscrowReleasedAt != "" {
+		t.Errorf("未结束的任务不应记录 escrowReleasedAt")
+	}
+}
+
+// 已结束任务的托管和罚金账户都转出到 0 时记录 escrowReleasedAt
+func TestEscrowReleasedAt(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 100)
+	taskID := l.mustInvoke("CreateTask", "", "30", "", "alice", "1", "", "")
+	escrow := escrowPrefix + taskID
+	penalty := escrow + penaltySuffix
+	l.mustInvoke("TransferTokens", "alice", escrow, "30", "托管")
+	l.mustInvoke("TransferTokens", "alice", penalty, "5", "罚金")
+
+	// 直接修改状态模拟任务结束
+	task := readJSON[Task](t, l, "ReadTask", taskID)
+	task.Status = taskCancelled
+	putState(t, l, taskType, taskID, task)
+
+	l.mustInvoke("TransferTokens", escrow, "alice", "30", "退还")
+	if task := readJSON[Task](t, l, "ReadTask", taskID); task.EscrowReleasedAt != "" {
+		t.Errorf("罚金账户还有余额时不应记录 escrowReleasedAt")
+	}
+	l.mustInvoke("TransferTokens", penalty, "alice", "5", "退还罚金")
+	if task := readJSON[Task](t, l, "ReadTask", taskID); task.EscrowReleasedAt == "" {
+		t.Errorf("托管余额全部转出后应记录 escrowReleasedAt")
+	}
+}
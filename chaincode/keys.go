@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// 更换用户公钥：以交易时间结束当前公钥的有效期并追加新公钥，pubkeyhash 更新为新公钥。
+// 旧用户没有公钥历史时先把原 pubkeyhash 作为第一条记录保留；指纹已在历史中出现时失败，旧公钥不能重新启用
+func (s *SmartContract) RotatePublicKey(ctx contractapi.TransactionContextInterface,
+	username string,
+	publicKey string,
+	fingerprint string,
+	algorithm string,
+) error {
+	l := open(ctx)
+
+	if publicKey == "" || fingerprint == "" {
+		return fmt.Errorf("公钥和指纹不能为空")
+	}
+	user, err := l.user(username)
+	if err != nil {
+		return err
+	}
+	if user.Deleted() {
+		return fmt.Errorf("用户 %s 已被删除", username)
+	}
+	for _, key := range user.Keys {
+		if key.Fingerprint == fingerprint {
+			return fmt.Errorf("公钥 %s 已登记过，不能重新启用", fingerprint)
+		}
+	}
+	now, err := l.now()
+	if err != nil {
+		return err
+	}
+
+	if len(user.Keys) == 0 && user.Pubkeyhash != "" {
+		user.Keys = []PublicKeyRecord{{PublicKey: user.Pubkeyhash}}
+	}
+	for i := range user.Keys {
+		if user.Keys[i].ValidTo == "" {
+			user.Keys[i].ValidTo = now
+		}
+	}
+	user.Keys = append(user.Keys, PublicKeyRecord{
+		Fingerprint: fingerprint,
+		PublicKey:   publicKey,
+		Algorithm:   algorithm,
+		ValidFrom:   now,
+	})
+	user.Pubkeyhash = publicKey
+	return l.putUser(user)
+}
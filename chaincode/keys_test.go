@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestRotatePublicKey(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustInvoke("CreateUser", "alice", "pw", "org1", "old-key", "0", "false", "true", "true")
+
+	l.mustInvoke("RotatePublicKey", "alice", "key-1", "fp-1", "Ed25519")
+	l.mustInvoke("RotatePublicKey", "alice", "key-2", "fp-2", "ECDSA-P256-SHA256")
+
+	alice := readJSON[User](t, l, "ReadUser", "alice")
+	if alice.Pubkeyhash != "key-2" || len(alice.Keys) != 3 {
+		t.Fatalf("公钥历史不正确: %+v", alice.Keys)
+	}
+	legacy, first, current := alice.Keys[0], alice.Keys[1], alice.Keys[2]
+	if legacy.PublicKey != "old-key" || legacy.ValidFrom != "" || legacy.ValidTo == "" {
+		t.Errorf("原公钥应作为第一条记录保留: %+v", legacy)
+	}
+	if first.ValidTo != current.ValidFrom || first.ValidFrom != legacy.ValidTo {
+		t.Errorf("有效期不连续: %+v", alice.Keys)
+	}
+	if current.ValidTo != "" || current.Algorithm != "ECDSA-P256-SHA256" {
+		t.Errorf("当前公钥记录不正确: %+v", current)
+	}
+
+	l.mustFail("RotatePublicKey", "alice", "key-1", "fp-1", "Ed25519")
+	l.mustFail("RotatePublicKey", "alice", "", "fp-3", "Ed25519")
+	l.mustFail("RotatePublicKey", "bob", "key-3", "fp-3", "Ed25519")
+}
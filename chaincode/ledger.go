@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// 账本中的记录类型，每类记录以 (类型, ID) 组合键保存
+const (
+	userType     = "user"
+	modelType    = "model"
+	taskType     = "task"
+	accountType  = "account"  // 用户以外的账户余额（托管账户、系统账户）
+	transferType = "transfer" // 转账记录
+	historyIndex = "account~transfer"
+
+	idempotencyType = "idempotency" // 幂等键 -> 转账 ID
+	settlementType  = "settlement"
+	leaseType       = "lease"    // 后端实例之间的租约
+	cidType         = "cid"      // 模型 CID -> 登记者
+	downloadType    = "download" // 模型下载审计记录，键为 (交易时间, 交易 ID)
+	envelopeType    = "envelope" // 加密模型的信封，键为密文 CID
+)
+
+// 一个交易内的账本读写。Fabric 在交易中读不到本交易的写入，
+// 这里缓存本交易写入的记录，同一交易多次修改同一条记录时后一次能看到前一次的结果
+type ledger struct {
+	stub   shim.ChaincodeStubInterface
+	writes map[string][]byte // 值为 nil 表示本交易中已删除
+	seq    int               // 本交易中已生成的 ID 数
+}
+
+func open(ctx contractapi.TransactionContextInterface) *ledger {
+	return &ledger{stub: ctx.GetStub(), writes: make(map[string][]byte)}
+}
+
+func (l *ledger) key(objectType string, attrs ...string) (string, error) {
+	key, err := l.stub.CreateCompositeKey(objectType, attrs)
+	if err != nil {
+		return "", fmt.Errorf("生成 %s 的键失败: %w", objectType, err)
+	}
+	return key, nil
+}
+
+// 读取原始数据，记录不存在时返回 nil
+func (l *ledger) getRaw(objectType string, attrs ...string) ([]byte, error) {
+	key, err := l.key(objectType, attrs...)
+	if err != nil {
+		return nil, err
+	}
+	if data, ok := l.writes[key]; ok {
+		return data, nil
+	}
+	data, err := l.stub.GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("读取账本失败: %w", err)
+	}
+	return data, nil
+}
+
+// 读取记录，记录不存在时返回 false
+func (l *ledger) get(objectType, id string, v any) (bool, error) {
+	data, err := l.getRaw(objectType, id)
+	if err != nil || data == nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("解析 %s %s 失败: %w", objectType, id, err)
+	}
+	return true, nil
+}
+
+func (l *ledger) putRaw(data []byte, objectType string, attrs ...string) error {
+	key, err := l.key(objectType, attrs...)
+	if err != nil {
+		return err
+	}
+	if err := l.stub.PutState(key, data); err != nil {
+		return fmt.Errorf("写入账本失败: %w", err)
+	}
+	l.writes[key] = data
+	return nil
+}
+
+func (l *ledger) put(objectType, id string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("序列化 %s %s 失败: %w", objectType, id, err)
+	}
+	return l.putRaw(data, objectType, id)
+}
+
+func (l *ledger) del(objectType string, attrs ...string) error {
+	key, err := l.key(objectType, attrs...)
+	if err != nil {
+		return err
+	}
+	if err := l.stub.DelState(key); err != nil {
+		return fmt.Errorf("删除记录失败: %w", err)
+	}
+	l.writes[key] = nil
+	return nil
+}
+
+// 按组合键前缀列出已提交的记录，只用于查询交易
+func (l *ledger) list(objectType string, attrs ...string) ([][]byte, error) {
+	iter, err := l.stub.GetStateByPartialCompositeKey(objectType, attrs)
+	if err != nil {
+		return nil, fmt.Errorf("查询 %s 失败: %w", objectType, err)
+	}
+	defer iter.Close()
+
+	var values [][]byte
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("查询 %s 失败: %w", objectType, err)
+		}
+		values = append(values, kv.Value)
+	}
+	return values, nil
+}
+
+// 按组合键前缀列出已提交记录的键属性
+func (l *ledger) listKeys(objectType string, attrs ...string) ([][]string, error) {
+	iter, err := l.stub.GetStateByPartialCompositeKey(objectType, attrs)
+	if err != nil {
+		return nil, fmt.Errorf("查询 %s 失败: %w", objectType, err)
+	}
+	defer iter.Close()
+
+	var keys [][]string
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("查询 %s 失败: %w", objectType, err)
+		}
+		_, parts, err := l.stub.SplitCompositeKey(kv.Key)
+		if err != nil {
+			return nil, fmt.Errorf("解析 %s 的键失败: %w", objectType, err)
+		}
+		keys = append(keys, parts)
+	}
+	return keys, nil
+}
+
+// 交易时间，所有背书节点得到相同的结果
+func (l *ledger) time() (time.Time, error) {
+	ts, err := l.stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("读取交易时间失败: %w", err)
+	}
+	return ts.AsTime().UTC(), nil
+}
+
+// 交易时间（RFC3339）
+func (l *ledger) now() (string, error) {
+	t, err := l.time()
+	if err != nil {
+		return "", err
+	}
+	return t.Format(time.RFC3339), nil
+}
+
+// 由交易 ID 生成新记录的 ID，同一交易中生成多个时依次加序号
+func (l *ledger) newID(prefix string) string {
+	id := l.stub.GetTxID()
+	if len(id) > 16 {
+		id = id[:16]
+	}
+	l.seq++
+	if l.seq > 1 {
+		return fmt.Sprintf("%s-%s-%d", prefix, id, l.seq)
+	}
+	return prefix + "-" + id
+}
+
+// 把记录列表序列化为 JSON 数组，没有记录时返回 []
+func jsonArray(values [][]byte) string {
+	if len(values) == 0 {
+		return "[]"
+	}
+	data := []byte{'['}
+	for i, v := range values {
+		if i > 0 {
+			data = append(data, ',')
+		}
+		data = append(data, v...)
+	}
+	return string(append(data, ']'))
+}
+
+func marshal(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("序列化结果失败: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,20 @@
+// 后端使用的链码，部署名称默认为 mycc（见 go-backend/fabric-go/network/connect_fabric.go）。
+// 交易函数的参数和语义与 go-backend/fabric-go/call 中各调用处的注释一致
+package main
+
+import (
+	"log"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+func main() {
+	chaincode, err := contractapi.NewChaincode(&SmartContract{})
+	if err != nil {
+		log.Panicf("创建链码失败: %v", err)
+	}
+
+	if err := chaincode.Start(); err != nil {
+		log.Panicf("启动链码失败: %v", err)
+	}
+}
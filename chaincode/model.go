@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// 模型记录，字段与后端 invoke_fabric.Model 一致
+type Model struct {
+	Modelid        string `json:"Modelid"`
+	Modelowner     string `json:"Modelowner"`
+	Modelhash      string `json:"Modelhash"` // 模型文件的 CID
+	Modelsign      string `json:"Modelsign"`
+	SignAlg        string `json:"signAlg"`
+	KeyFingerprint string `json:"keyFingerprint"`
+	CreatedAt      string `json:"createdAt"`
+	Storage        string `json:"storage"`
+	StorageCID     string `json:"storageCid"`
+
+	ArchFormat      string `json:"archFormat"`
+	ArchFingerprint string `json:"archFingerprint"`
+}
+
+// 读取模型，不存在时返回错误
+func (l *ledger) model(modelID string) (*Model, error) {
+	var model Model
+	ok, err := l.get(modelType, modelID, &model)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("模型 %s 不存在", modelID)
+	}
+	return &model, nil
+}
+
+func (l *ledger) putModel(model *Model) error {
+	return l.put(modelType, model.Modelid, model)
+}
+
+// 登记过该 CID 的用户，未登记时为空字符串
+func (l *ledger) cidOwner(cid string) (string, error) {
+	data, err := l.getRaw(cidType, cid)
+	return string(data), err
+}
+
+// 创建模型并加入上传者的 Posted 列表，返回模型 ID。
+// CID 由第一个上传者登记，其他用户不能再用同一个 CID 创建模型
+func (s *SmartContract) CreateModel(ctx contractapi.TransactionContextInterface,
+	modelowner string,
+	modelhash string,
+	modelsign string,
+	signAlg string,
+	keyFingerprint string,
+	storage string,
+	archFormat string,
+	archFingerprint string,
+) (string, error) {
+	l := open(ctx)
+
+	if modelhash == "" {
+		return "", fmt.Errorf("模型 CID 不能为空")
+	}
+	owner, err := l.user(modelowner)
+	if err != nil {
+		return "", err
+	}
+	registered, err := l.cidOwner(modelhash)
+	if err != nil {
+		return "", err
+	}
+	if registered != "" && registered != modelowner {
+		return "", fmt.Errorf("模型 CID %s 已由用户 %s 登记", modelhash, registered)
+	}
+	now, err := l.now()
+	if err != nil {
+		return "", err
+	}
+
+	model := &Model{
+		Modelid:         l.newID("model"),
+		Modelowner:      modelowner,
+		Modelhash:       modelhash,
+		Modelsign:       modelsign,
+		SignAlg:         signAlg,
+		KeyFingerprint:  keyFingerprint,
+		CreatedAt:       now,
+		Storage:         storage,
+		ArchFormat:      archFormat,
+		ArchFingerprint: archFingerprint,
+	}
+	if err := l.putModel(model); err != nil {
+		return "", err
+	}
+
+	owner.Posted = append(owner.Posted, model.Modelid)
+	if err := l.putUser(owner); err != nil {
+		return "", err
+	}
+	if registered == "" {
+		if err := l.putRaw([]byte(modelowner), cidType, modelhash); err != nil {
+			return "", err
+		}
+	}
+	return model.Modelid, nil
+}
+
+// 查询登记过该 CID 的用户，未登记时返回空字符串
+func (s *SmartContract) ReadModelCIDOwner(ctx contractapi.TransactionContextInterface, cid string) (string, error) {
+	return open(ctx).cidOwner(cid)
+}
+
+// 查询模型
+func (s *SmartContract) ReadModel(ctx contractapi.TransactionContextInterface, modelID string) (string, error) {
+	data, err := open(ctx).getRaw(modelType, modelID)
+	if err != nil {
+		return "", err
+	}
+	if data == nil {
+		return "", fmt.Errorf("模型 %s 不存在", modelID)
+	}
+	return string(data), nil
+}
+
+// 模型文件迁移到其他存储后更新存储方式和新存储中的 CID，Modelhash 和签名不变。
+// storageCID 与 Modelhash 相同时保存为空
+func (s *SmartContract) SetModelStorage(ctx contractapi.TransactionContextInterface,
+	modelID string,
+	storage string,
+	storageCID string,
+) error {
+	l := open(ctx)
+
+	if storage == "" {
+		return fmt.Errorf("存储方式不能为空")
+	}
+	model, err := l.model(modelID)
+	if err != nil {
+		return err
+	}
+	if storageCID == model.Modelhash {
+		storageCID = ""
+	}
+	model.Storage = storage
+	model.StorageCID = storageCID
+	return l.putModel(model)
+}
+
+// 为旧模型补记结构格式和指纹，已有指纹时失败，上传时记录的结构不能被改写
+func (s *SmartContract) SetModelArchitecture(ctx contractapi.TransactionContextInterface,
+	modelID string,
+	archFormat string,
+	archFingerprint string,
+) error {
+	l := open(ctx)
+
+	if archFormat == "" || archFingerprint == "" {
+		return fmt.Errorf("结构格式和指纹不能为空")
+	}
+	model, err := l.model(modelID)
+	if err != nil {
+		return err
+	}
+	if model.ArchFingerprint != "" {
+		return fmt.Errorf("模型 %s 的结构已记录为 %s", modelID, model.ArchFingerprint)
+	}
+	model.ArchFormat = archFormat
+	model.ArchFingerprint = archFingerprint
+	return l.putModel(model)
+}
+
+// 保存加密模型的信封，按密文 CID 索引。信封已存在时返回 false 且不修改，否则已加密的文件将无法解密
+func (s *SmartContract) CreateModelEnvelope(ctx contractapi.TransactionContextInterface, cid string, envelopeJSON string) (bool, error) {
+	l := open(ctx)
+
+	if cid == "" {
+		return false, fmt.Errorf("密文 CID 不能为空")
+	}
+	if !json.Valid([]byte(envelopeJSON)) {
+		return false, fmt.Errorf("信封不是有效的 JSON")
+	}
+	existing, err := l.getRaw(envelopeType, cid)
+	if err != nil {
+		return false, err
+	}
+	if existing != nil {
+		return false, nil
+	}
+	if err := l.putRaw([]byte(envelopeJSON), envelopeType, cid); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// 查询加密模型的信封，模型未加密时返回空字符串
+func (s *SmartContract) ReadModelEnvelope(ctx contractapi.TransactionContextInterface, cid string) (string, error) {
+	data, err := open(ctx).getRaw(envelopeType, cid)
+	return string(data), err
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// CID 由第一个上传者登记，同一用户可以重复使用，其他用户不能使用
+func TestCreateModelCIDOwner(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 0)
+	l.createUser("bob", 0)
+
+	if owner := l.mustInvoke("ReadModelCIDOwner", "cid"); owner != "" {
+		t.Errorf("未登记的 CID 登记者为 %q", owner)
+	}
+	first := l.mustInvoke("CreateModel", "alice", "cid", "sig", "", "", "", "", "")
+	second := l.mustInvoke("CreateModel", "alice", "cid", "sig", "", "", "", "", "")
+	if owner := l.mustInvoke("ReadModelCIDOwner", "cid"); owner != "alice" {
+		t.Errorf("CID 登记者为 %q，应为 alice", owner)
+	}
+	if alice := readJSON[User](t, l, "ReadUser", "alice"); !slices.Equal(alice.Posted, []string{first, second}) {
+		t.Errorf("上传列表不正确: %+v", alice.Posted)
+	}
+
+	if err := l.mustFail("CreateModel", "bob", "cid", "sig", "", "", "", "", ""); !strings.Contains(err.Error(), "alice") {
+		t.Errorf("错误信息不正确: %v", err)
+	}
+	if bob := readJSON[User](t, l, "ReadUser", "bob"); len(bob.Posted) != 0 {
+		t.Errorf("失败的创建修改了上传列表: %+v", bob.Posted)
+	}
+	l.mustFail("CreateModel", "alice", "", "sig", "", "", "", "", "")
+	l.mustFail("CreateModel", "carol", "cid2", "sig", "", "", "", "", "")
+}
+
+// 迁移存储只修改存储方式和存储 CID
+func TestSetModelStorage(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 0)
+	modelID := l.mustInvoke("CreateModel", "alice", "cid", "sig", "Ed25519", "fp", "ipfs", "", "")
+
+	l.mustInvoke("SetModelStorage", modelID, "s3", "s3-key")
+	model := readJSON[Model](t, l, "ReadModel", modelID)
+	if model.Storage != "s3" || model.StorageCID != "s3-key" || model.Modelhash != "cid" || model.Modelsign != "sig" {
+		t.Errorf("迁移后的模型记录不正确: %+v", model)
+	}
+	l.mustInvoke("SetModelStorage", modelID, "ipfs", "cid")
+	if model := readJSON[Model](t, l, "ReadModel", modelID); model.Storage != "ipfs" || model.StorageCID != "" {
+		t.Errorf("存储 CID 与 Modelhash 相同时应保存为空: %+v", model)
+	}
+	l.mustFail("SetModelStorage", modelID, "", "")
+	l.mustFail("SetModelStorage", "missing", "s3", "")
+}
+
+// 旧模型可以补记一次结构，已有指纹的模型不能改写
+func TestSetModelArchitecture(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 0)
+	legacy := l.mustInvoke("CreateModel", "alice", "cid-1", "sig", "", "", "", "", "")
+	recorded := l.mustInvoke("CreateModel", "alice", "cid-2", "sig", "", "", "", "onnx", "fp-1")
+
+	l.mustFail("SetModelArchitecture", legacy, "", "")
+	l.mustInvoke("SetModelArchitecture", legacy, "safetensors", "fp-2")
+	if model := readJSON[Model](t, l, "ReadModel", legacy); model.ArchFormat != "safetensors" || model.ArchFingerprint != "fp-2" {
+		t.Errorf("补记后的结构不正确: %+v", model)
+	}
+	l.mustFail("SetModelArchitecture", legacy, "onnx", "fp-3")
+	l.mustFail("SetModelArchitecture", recorded, "onnx", "fp-3")
+	if model := readJSON[Model](t, l, "ReadModel", recorded); model.ArchFingerprint != "fp-1" {
+		t.Errorf("上传时记录的结构被改写: %+v", model)
+	}
+	l.mustFail("SetModelArchitecture", "missing", "onnx", "fp")
+}
+
+// 信封只能保存一次，已存在时返回 false 且保留原信封
+func TestModelEnvelope(t *testing.T) {
+	l := newTestLedger(t)
+
+	if env := l.mustInvoke("ReadModelEnvelope", "cid"); env != "" {
+		t.Errorf("未加密的模型返回信封 %q", env)
+	}
+	if created := l.mustInvoke("CreateModelEnvelope", "cid", `{"cid":"cid","owner":"alice"}`); created != "true" {
+		t.Fatalf("第一次保存返回 %s", created)
+	}
+	if created := l.mustInvoke("CreateModelEnvelope", "cid", `{"cid":"cid","owner":"bob"}`); created != "false" {
+		t.Errorf("信封已存在时返回 %s", created)
+	}
+	if env := l.mustInvoke("ReadModelEnvelope", "cid"); env != `{"cid":"cid","owner":"alice"}` {
+		t.Errorf("信封被覆盖: %s", env)
+	}
+	l.mustFail("CreateModelEnvelope", "", "{}")
+	l.mustFail("CreateModelEnvelope", "cid-2", "not json")
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// 用户记录中引用其他对象的字段，与后端 invoke_fabric.UserRefs 一致
+type UserRefs struct {
+	Accepted []string `json:"accepted"`
+	Posted   []string `json:"posted"`
+}
+
+// 任务记录中引用其他对象的字段，与后端 invoke_fabric.TaskRefs 一致
+type TaskRefs struct {
+	AcceptedUsers []string `json:"acceptedUsers"`
+	Models        []string `json:"models"`
+}
+
+// 查询所有模型
+func (s *SmartContract) GetAllModels(ctx contractapi.TransactionContextInterface) (string, error) {
+	values, err := open(ctx).list(modelType)
+	if err != nil {
+		return "", err
+	}
+	return jsonArray(values), nil
+}
+
+// 解析修复前后的引用
+func parseRefs(beforeJSON, afterJSON string, before, after any) error {
+	if err := json.Unmarshal([]byte(beforeJSON), before); err != nil {
+		return fmt.Errorf("解析修复前的引用失败: %w", err)
+	}
+	if err := json.Unmarshal([]byte(afterJSON), after); err != nil {
+		return fmt.Errorf("解析修复后的引用失败: %w", err)
+	}
+	return nil
+}
+
+// 修复后新增的引用
+func added(before, after []string) []string {
+	var refs []string
+	for _, ref := range after {
+		if !slices.Contains(before, ref) {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// 修复用户的 accepted 和 posted 字段。before 与链上当前值不一致时交易失败，
+// 新增的引用必须存在，其他字段保持不变
+func (s *SmartContract) RepairUserRefs(ctx contractapi.TransactionContextInterface,
+	username string,
+	beforeJSON string,
+	afterJSON string,
+) error {
+	l := open(ctx)
+
+	var before, after UserRefs
+	if err := parseRefs(beforeJSON, afterJSON, &before, &after); err != nil {
+		return err
+	}
+	user, err := l.user(username)
+	if err != nil {
+		return err
+	}
+	if !slices.Equal(user.Accepted, before.Accepted) || !slices.Equal(user.Posted, before.Posted) {
+		return fmt.Errorf("用户 %s 的引用已变化，请重新检查", username)
+	}
+	for _, taskID := range added(before.Accepted, after.Accepted) {
+		if _, err := l.task(taskID); err != nil {
+			return err
+		}
+	}
+	for _, modelID := range added(before.Posted, after.Posted) {
+		if _, err := l.model(modelID); err != nil {
+			return err
+		}
+	}
+
+	user.Accepted = append([]string{}, after.Accepted...)
+	user.Posted = append([]string{}, after.Posted...)
+	return l.putUser(user)
+}
+
+// 修复任务的 acceptedUsers 和 models 字段。before 与链上当前值不一致时交易失败，
+// 新增的引用必须存在，其他字段保持不变
+func (s *SmartContract) RepairTaskRefs(ctx contractapi.TransactionContextInterface,
+	taskID string,
+	beforeJSON string,
+	afterJSON string,
+) error {
+	l := open(ctx)
+
+	var before, after TaskRefs
+	if err := parseRefs(beforeJSON, afterJSON, &before, &after); err != nil {
+		return err
+	}
+	task, err := l.task(taskID)
+	if err != nil {
+		return err
+	}
+	if !slices.Equal(task.AcceptedUsers, before.AcceptedUsers) || !slices.Equal(task.Models, before.Models) {
+		return fmt.Errorf("任务 %s 的引用已变化，请重新检查", taskID)
+	}
+	for _, username := range added(before.AcceptedUsers, after.AcceptedUsers) {
+		if _, err := l.user(username); err != nil {
+			return err
+		}
+	}
+	for _, modelID := range added(before.Models, after.Models) {
+		if _, err := l.model(modelID); err != nil {
+			return err
+		}
+	}
+
+	task.AcceptedUsers = append([]string{}, after.AcceptedUsers...)
+	task.Models = append([]string{}, after.Models...)
+	return l.putTask(task)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGetAllModels(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 0)
+	if models := readJSON[[]Model](t, l, "GetAllModels"); len(models) != 0 {
+		t.Errorf("应没有模型，实际 %d", len(models))
+	}
+	l.mustInvoke("CreateModel", "alice", "cid1", "sig", "", "", "", "", "")
+	l.mustInvoke("CreateModel", "alice", "cid2", "sig", "", "", "", "", "")
+	if models := readJSON[[]Model](t, l, "GetAllModels"); len(models) != 2 {
+		t.Errorf("应有 2 个模型，实际 %d", len(models))
+	}
+}
+
+// 修复前的值必须与链上一致，新增的引用必须存在
+func TestRepairRefs(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 0)
+	modelID := l.mustInvoke("CreateModel", "alice", "cid", "sig", "", "", "", "", "")
+	taskID := l.mustInvoke("CreateTask", "", "0", "", "alice", "1", "", "")
+	alice := readJSON[User](t, l, "ReadUser", "alice")
+	alice.Accepted = []string{"missing"}
+	alice.Posted = []string{}
+	putState(t, l, userType, "alice", alice)
+
+	l.mustFail("RepairUserRefs", "alice", `{"accepted":[],"posted":[]}`, `{"accepted":[],"posted":[]}`)
+	l.mustFail("RepairUserRefs", "alice", `{"accepted":["missing"],"posted":[]}`, `{"accepted":[],"posted":["other"]}`)
+	l.mustInvoke("RepairUserRefs", "alice", `{"accepted":["missing"],"posted":[]}`, `{"accepted":[],"posted":["`+modelID+`"]}`)
+	alice = readJSON[User](t, l, "ReadUser", "alice")
+	if len(alice.Accepted) != 0 || !slices.Equal(alice.Posted, []string{modelID}) || alice.Organization != "org1" {
+		t.Errorf("修复后的用户记录不正确: %+v", alice)
+	}
+
+	task := readJSON[Task](t, l, "ReadTask", taskID)
+	task.Models = []string{"missing"}
+	putState(t, l, taskType, taskID, task)
+	l.mustFail("RepairTaskRefs", taskID, `{"acceptedUsers":[],"models":[]}`, `{"acceptedUsers":[],"models":[]}`)
+	l.mustFail("RepairTaskRefs", taskID, `{"acceptedUsers":[],"models":["missing"]}`, `{"acceptedUsers":["bob"],"models":[]}`)
+	l.mustInvoke("RepairTaskRefs", taskID, `{"acceptedUsers":[],"models":["missing"]}`, `{"acceptedUsers":[],"models":[]}`)
+	if task := readJSON[Task](t, l, "ReadTask", taskID); len(task.Models) != 0 || task.Status != taskOpen {
+		t.Errorf("修复后的任务记录不正确: %+v", task)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// 设置任务的奖励分配策略，已有用户接受后不能修改
+func (s *SmartContract) SetRewardStrategy(ctx contractapi.TransactionContextInterface,
+	taskID string,
+	strategy string,
+	topK int,
+) error {
+	l := open(ctx)
+
+	task, err := l.openTask(taskID)
+	if err != nil {
+		return err
+	}
+	if len(task.AcceptedUsers) > 0 {
+		return fmt.Errorf("任务 %s 已有用户接受，奖励分配策略不能再修改", taskID)
+	}
+	if topK < 0 {
+		return fmt.Errorf("K 值不能为负数")
+	}
+	task.RewardStrategy = strategy
+	task.RewardTopK = topK
+	return l.putTask(task)
+}
+
+// 记录参与者的评估分数，只能在聚合阶段给参与者打分，已记录的分数不能修改
+func (s *SmartContract) SetTaskScores(ctx contractapi.TransactionContextInterface, taskID string, scoresJSON string) error {
+	l := open(ctx)
+
+	var scores map[string]float64
+	if err := json.Unmarshal([]byte(scoresJSON), &scores); err != nil {
+		return fmt.Errorf("解析评估分数失败: %w", err)
+	}
+	task, err := l.openTask(taskID)
+	if err != nil {
+		return err
+	}
+	if task.state() != taskAggregating {
+		return fmt.Errorf("任务 %s 处于 %s 状态，停止提交后才能评分", taskID, task.state())
+	}
+
+	if task.Scores == nil {
+		task.Scores = make(map[string]float64)
+	}
+	for user, score := range scores {
+		if !slices.Contains(task.AcceptedUsers, user) {
+			return fmt.Errorf("用户 %s 没有参与任务 %s", user, taskID)
+		}
+		if _, ok := task.Scores[user]; ok {
+			return fmt.Errorf("用户 %s 的分数已记录，不能修改", user)
+		}
+		if score < 0 {
+			return fmt.Errorf("评估分数不能为负数")
+		}
+		task.Scores[user] = score
+	}
+	return l.putTask(task)
+}
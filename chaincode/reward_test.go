@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestSetRewardStrategy(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 0)
+	l.createUser("bob", 0)
+	taskID := l.mustInvoke("CreateTask", "", "10", "", "alice", "1", "", "")
+
+	l.mustInvoke("SetRewardStrategy", taskID, "top_k", "2")
+	if task := readJSON[Task](t, l, "ReadTask", taskID); task.RewardStrategy != "top_k" || task.RewardTopK != 2 || task.Bonus != 10 {
+		t.Errorf("奖励分配策略记录不正确: %+v", task)
+	}
+	l.mustFail("SetRewardStrategy", taskID, "top_k", "-1")
+
+	// 有参与者后不能再修改
+	task := readJSON[Task](t, l, "ReadTask", taskID)
+	task.AcceptedUsers = []string{"bob"}
+	putState(t, l, taskType, taskID, task)
+	l.mustFail("SetRewardStrategy", taskID, "equal_split", "0")
+}
+
+func TestSetTaskScores(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 0)
+	taskID := l.mustInvoke("CreateTask", "", "10", "", "alice", "1", "", "")
+	task := readJSON[Task](t, l, "ReadTask", taskID)
+	task.AcceptedUsers = []string{"bob", "carol"}
+	task.Status = taskInProgress
+	putState(t, l, taskType, taskID, task)
+
+	// 聚合阶段之前不能评分
+	l.mustFail("SetTaskScores", taskID, `{"bob":1}`)
+
+	task.Status = taskAggregating
+	putState(t, l, taskType, taskID, task)
+	l.mustInvoke("SetTaskScores", taskID, `{"bob":0.5}`)
+	l.mustInvoke("SetTaskScores", taskID, `{"carol":0}`)
+	l.mustFail("SetTaskScores", taskID, `{"bob":0.9}`)
+	l.mustFail("SetTaskScores", taskID, `{"dave":1}`)
+
+	scores := readJSON[Task](t, l, "ReadTask", taskID).Scores
+	if len(scores) != 2 || scores["bob"] != 0.5 || scores["carol"] != 0 {
+		t.Errorf("评估分数不正确: %v", scores)
+	}
+}
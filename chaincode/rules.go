@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// 检查限制条件，与后端 TaskRules.Validate 一致
+func (r *TaskRules) validate() error {
+	if r.MaxParticipants < 0 || r.MinReputation < 0 || r.MinBalance < 0 || (r.LeavePenalty != nil && *r.LeavePenalty < 0) {
+		return fmt.Errorf("参与人数上限、最低信誉、最低余额和退出罚金不能为负数")
+	}
+	if r.InviteOnly && len(r.Invitees) == 0 {
+		return fmt.Errorf("仅限邀请的任务需要填写邀请名单")
+	}
+	return nil
+}
+
+// 设置未结束任务的接受限制，其他字段保持不变。人数上限不能低于已接受的人数
+func (s *SmartContract) SetTaskRules(ctx contractapi.TransactionContextInterface, taskID string, rulesJSON string) error {
+	l := open(ctx)
+
+	var rules TaskRules
+	decoder := json.NewDecoder(bytes.NewReader([]byte(rulesJSON)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rules); err != nil {
+		return fmt.Errorf("解析接受限制失败: %w", err)
+	}
+	if err := rules.validate(); err != nil {
+		return err
+	}
+	task, err := l.openTask(taskID)
+	if err != nil {
+		return err
+	}
+	if rules.MaxParticipants > 0 && len(task.AcceptedUsers) > rules.MaxParticipants {
+		return fmt.Errorf("任务 %s 已有 %d 个参与者，超过人数上限 %d", taskID, len(task.AcceptedUsers), rules.MaxParticipants)
+	}
+
+	task.Rules = rules
+	return l.putTask(task)
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestSetTaskRules(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 0)
+	taskID := l.mustInvoke("CreateTask", "", "5", "", "alice", "1", "", "")
+
+	l.mustInvoke("SetTaskRules", taskID, `{"maxParticipants":2,"allowedOrgs":["org1"],"leavePenalty":3}`)
+	task := readJSON[Task](t, l, "ReadTask", taskID)
+	if task.Rules.MaxParticipants != 2 || *task.Rules.LeavePenalty != 3 || task.Bonus != 5 || task.Status != taskOpen {
+		t.Errorf("接受限制不正确: %+v", task)
+	}
+
+	l.mustFail("SetTaskRules", taskID, `{"maxParticipants":-1}`)
+	l.mustFail("SetTaskRules", taskID, `{"inviteOnly":true}`)
+	l.mustFail("SetTaskRules", taskID, `{"maxParticipant":2}`)
+	l.mustFail("SetTaskRules", taskID, `不是 JSON`)
+
+	// 人数上限不能低于已接受的人数
+	task.AcceptedUsers = []string{"bob", "carol"}
+	task.Status = taskInProgress
+	putState(t, l, taskType, taskID, task)
+	l.mustFail("SetTaskRules", taskID, `{"maxParticipants":1}`)
+	l.mustInvoke("SetTaskRules", taskID, `{"maxParticipants":0}`)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// 单个收款人的支付状态
+const payoutPaid = "paid"
+
+// 结算记录中链码需要检查的字段，其余字段按后端写入的 JSON 原样保存
+type settlementJob struct {
+	TaskID  string `json:"taskId"`
+	Payouts []struct {
+		Username       string `json:"username"`
+		Amount         int    `json:"amount"`
+		IdempotencyKey string `json:"idempotencyKey"`
+		State          string `json:"state"`
+		Attempts       int    `json:"attempts"`
+	} `json:"payouts"`
+}
+
+func parseSettlement(taskID, jobJSON string) (*settlementJob, error) {
+	var job settlementJob
+	if err := json.Unmarshal([]byte(jobJSON), &job); err != nil {
+		return nil, fmt.Errorf("解析结算数据失败: %w", err)
+	}
+	if job.TaskID != taskID {
+		return nil, fmt.Errorf("结算数据属于任务 %s，不是 %s", job.TaskID, taskID)
+	}
+	for _, p := range job.Payouts {
+		if p.Username == "" || p.Amount <= 0 || p.IdempotencyKey == "" {
+			return nil, fmt.Errorf("结算数据中的支付缺少收款人、金额或幂等键")
+		}
+	}
+	return &job, nil
+}
+
+// 创建任务的结算记录，记录已存在时返回 false，不修改已有记录
+func (s *SmartContract) CreateSettlement(ctx contractapi.TransactionContextInterface, taskID string, jobJSON string) (bool, error) {
+	l := open(ctx)
+
+	if _, err := l.task(taskID); err != nil {
+		return false, err
+	}
+	if _, err := parseSettlement(taskID, jobJSON); err != nil {
+		return false, err
+	}
+	existing, err := l.getRaw(settlementType, taskID)
+	if err != nil {
+		return false, err
+	}
+	if existing != nil {
+		return false, nil
+	}
+	return true, l.putRaw([]byte(jobJSON), settlementType, taskID)
+}
+
+// 更新结算记录，只能修改状态、支付结果和重试次数；已支付的记录不能改回未支付
+func (s *SmartContract) UpdateSettlement(ctx contractapi.TransactionContextInterface, taskID string, jobJSON string) error {
+	l := open(ctx)
+
+	data, err := l.getRaw(settlementType, taskID)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return fmt.Errorf("任务 %s 没有结算记录", taskID)
+	}
+	before, err := parseSettlement(taskID, string(data))
+	if err != nil {
+		return err
+	}
+	after, err := parseSettlement(taskID, jobJSON)
+	if err != nil {
+		return err
+	}
+
+	if len(before.Payouts) != len(after.Payouts) {
+		return fmt.Errorf("任务 %s 的支付计划不能修改", taskID)
+	}
+	for i, p := range after.Payouts {
+		old := before.Payouts[i]
+		if p.Username != old.Username || p.Amount != old.Amount || p.IdempotencyKey != old.IdempotencyKey {
+			return fmt.Errorf("任务 %s 的支付计划不能修改", taskID)
+		}
+		if old.State == payoutPaid && p.State != payoutPaid {
+			return fmt.Errorf("已支付给 %s 的奖励不能改回未支付", p.Username)
+		}
+		if p.Attempts < old.Attempts {
+			return fmt.Errorf("支付给 %s 的重试次数不能减少", p.Username)
+		}
+	}
+	return l.putRaw([]byte(jobJSON), settlementType, taskID)
+}
+
+// 查询任务的结算记录，不存在时返回空字符串
+func (s *SmartContract) ReadSettlement(ctx contractapi.TransactionContextInterface, taskID string) (string, error) {
+	data, err := open(ctx).getRaw(settlementType, taskID)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// 查询全部结算记录
+func (s *SmartContract) GetAllSettlements(ctx contractapi.TransactionContextInterface) (string, error) {
+	values, err := open(ctx).list(settlementType)
+	if err != nil {
+		return "", err
+	}
+	return jsonArray(values), nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func settlementJSON(taskID, state string, attempts int) string {
+	return fmt.Sprintf(`{"taskId":%q,"round":1,"status":"running","payouts":[`+
+		`{"username":"bob","amount":10,"idempotencyKey":"payout:%s:1:bob","state":%q,"attempts":%d}]}`,
+		taskID, taskID, state, attempts)
+}
+
+func TestSettlement(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 0)
+	taskID := l.mustInvoke("CreateTask", "", "10", "", "alice", "1", "", "")
+
+	if got := l.mustInvoke("ReadSettlement", taskID); got != "" {
+		t.Fatalf("没有结算记录时应返回空字符串，实际 %s", got)
+	}
+	if created := l.mustInvoke("CreateSettlement", taskID, settlementJSON(taskID, "pending", 0)); created != "true" {
+		t.Fatalf("第一次创建应返回 true")
+	}
+	// 已存在时不覆盖
+	if created := l.mustInvoke("CreateSettlement", taskID, settlementJSON(taskID, "paid", 5)); created != "false" {
+		t.Fatalf("重复创建应返回 false")
+	}
+	if got := l.mustInvoke("ReadSettlement", taskID); !strings.Contains(got, `"state":"pending"`) {
+		t.Errorf("已有记录被覆盖: %s", got)
+	}
+	l.mustFail("CreateSettlement", "missing", settlementJSON("missing", "pending", 0))
+
+	l.mustInvoke("UpdateSettlement", taskID, settlementJSON(taskID, "failed", 1))
+	l.mustInvoke("UpdateSettlement", taskID, settlementJSON(taskID, "paid", 2))
+	l.mustFail("UpdateSettlement", taskID, settlementJSON(taskID, "pending", 3))
+	l.mustFail("UpdateSettlement", taskID, settlementJSON(taskID, "paid", 1))
+	l.mustFail("UpdateSettlement", taskID, strings.Replace(settlementJSON(taskID, "paid", 2), `"amount":10`, `"amount":11`, 1))
+
+	if all := l.mustInvoke("GetAllSettlements"); !strings.HasPrefix(all, "[") || !strings.Contains(all, taskID) {
+		t.Errorf("GetAllSettlements 结果不正确: %s", all)
+	}
+}
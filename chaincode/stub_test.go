@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/queryresult"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// 测试用的链码桩：与 Fabric 一样，交易中读不到本交易的写入，交易失败时不提交任何写入
+type fakeStub struct {
+	shim.ChaincodeStubInterface
+
+	state   map[string][]byte
+	pending map[string][]byte // 值为 nil 表示删除
+	txID    string
+	txTime  time.Time
+	fn      string
+	args    []string
+}
+
+func (s *fakeStub) GetFunctionAndParameters() (string, []string) {
+	return s.fn, s.args
+}
+
+func (s *fakeStub) GetCreator() ([]byte, error) {
+	return nil, errors.New("测试中没有调用者身份")
+}
+
+func (s *fakeStub) GetTxID() string {
+	return s.txID
+}
+
+func (s *fakeStub) GetTxTimestamp() (*timestamppb.Timestamp, error) {
+	return timestamppb.New(s.txTime), nil
+}
+
+func (s *fakeStub) GetState(key string) ([]byte, error) {
+	return s.state[key], nil
+}
+
+func (s *fakeStub) PutState(key string, value []byte) error {
+	if value == nil {
+		return errors.New("值不能为 nil")
+	}
+	s.pending[key] = value
+	return nil
+}
+
+func (s *fakeStub) DelState(key string) error {
+	s.pending[key] = nil
+	return nil
+}
+
+func (s *fakeStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	key := "\x00" + objectType + "\x00"
+	for _, attr := range attributes {
+		key += attr + "\x00"
+	}
+	return key, nil
+}
+
+func (s *fakeStub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	parts := strings.Split(strings.Trim(compositeKey, "\x00"), "\x00")
+	return parts[0], parts[1:], nil
+}
+
+func (s *fakeStub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	prefix, _ := s.CreateCompositeKey(objectType, keys)
+	iter := &fakeIterator{}
+	for key, value := range s.state {
+		if strings.HasPrefix(key, prefix) {
+			iter.kvs = append(iter.kvs, &queryresult.KV{Key: key, Value: value})
+		}
+	}
+	sort.Slice(iter.kvs, func(i, j int) bool { return iter.kvs[i].Key < iter.kvs[j].Key })
+	return iter, nil
+}
+
+type fakeIterator struct {
+	kvs []*queryresult.KV
+}
+
+func (it *fakeIterator) HasNext() bool {
+	return len(it.kvs) > 0
+}
+
+func (it *fakeIterator) Next() (*queryresult.KV, error) {
+	if len(it.kvs) == 0 {
+		return nil, errors.New("没有更多记录")
+	}
+	kv := it.kvs[0]
+	it.kvs = it.kvs[1:]
+	return kv, nil
+}
+
+func (it *fakeIterator) Close() error {
+	return nil
+}
+
+// 通过 contractapi 调用链码，与背书节点一样做参数转换和结果序列化
+type testLedger struct {
+	t    *testing.T
+	cc   *contractapi.ContractChaincode
+	stub *fakeStub
+	tx   int
+}
+
+func newTestLedger(t *testing.T) *testLedger {
+	t.Helper()
+	cc, err := contractapi.NewChaincode(&SmartContract{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testLedger{
+		t:  t,
+		cc: cc,
+		stub: &fakeStub{
+			state:  make(map[string][]byte),
+			txTime: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+}
+
+// 调用一个交易函数，成功时提交写入；交易 ID 与 Fabric 一样为哈希，每个交易的时间比上一个晚一分钟
+func (l *testLedger) invoke(fn string, args ...string) (string, error) {
+	l.tx++
+	l.stub.fn = fn
+	l.stub.args = args
+	l.stub.txID = fmt.Sprintf("%x", sha256.Sum256([]byte(fmt.Sprint(l.tx))))
+	l.stub.txTime = l.stub.txTime.Add(time.Minute)
+	l.stub.pending = make(map[string][]byte)
+
+	resp := l.cc.Invoke(l.stub)
+	if resp.Status != shim.OK {
+		return "", errors.New(resp.Message)
+	}
+	for key, value := range l.stub.pending {
+		if value == nil {
+			delete(l.stub.state, key)
+		} else {
+			l.stub.state[key] = value
+		}
+	}
+	return string(resp.Payload), nil
+}
+
+func (l *testLedger) mustInvoke(fn string, args ...string) string {
+	l.t.Helper()
+	result, err := l.invoke(fn, args...)
+	if err != nil {
+		l.t.Fatalf("%s%v 失败: %v", fn, args, err)
+	}
+	return result
+}
+
+func (l *testLedger) mustFail(fn string, args ...string) error {
+	l.t.Helper()
+	_, err := l.invoke(fn, args...)
+	if err == nil {
+		l.t.Fatalf("%s%v 应该失败", fn, args)
+	}
+	return err
+}
+
+// 创建已通过审核的用户
+func (l *testLedger) createUser(username string, token int) {
+	l.t.Helper()
+	l.mustInvoke("CreateUser", username, "pw", "org1", "", fmt.Sprint(token), "false", "true", "true")
+}
+
+// 直接写入一条已提交的记录，用于构造测试数据
+func putState(t *testing.T, l *testLedger, objectType, id string, v any) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := l.stub.CreateCompositeKey(objectType, []string{id})
+	l.stub.state[key] = data
+}
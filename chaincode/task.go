@@ -0,0 +1,527 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// 任务记录，字段与后端 invoke_fabric.Task 一致
+type Task struct {
+	TaskID          string   `json:"ID"`
+	Bonus           int      `json:"bonus"`
+	RootModelId     string   `json:"rootModelHash"`
+	PostedUser      string   `json:"postedUser"`
+	AcceptedUsers   []string `json:"acceptedUsers"`
+	Models          []string `json:"models"`
+	IsComplete      bool     `json:"isComplete"`
+	Round           int      `json:"round"`
+	NextRoundTaskID string   `json:"nextRoundTaskID"`
+	Status          string   `json:"status"`
+
+	CreatedAt      string `json:"createdAt"`
+	AcceptDeadline string `json:"acceptDeadline"`
+	SubmitDeadline string `json:"submitDeadline"`
+
+	AggregateDeadline string `json:"aggregateDeadline"`
+	EscrowReleasedAt  string `json:"escrowReleasedAt"`
+
+	Rules TaskRules `json:"rules"`
+
+	Tombstone
+
+	RewardStrategy string             `json:"rewardStrategy"`
+	RewardTopK     int                `json:"rewardTopK"`
+	Scores         map[string]float64 `json:"scores"`
+}
+
+// 接受任务的限制条件，字段与后端 invoke_fabric.TaskRules 一致
+type TaskRules struct {
+	MaxParticipants int      `json:"maxParticipants"`
+	MinReputation   int      `json:"minReputation"`
+	MinBalance      int      `json:"minBalance"`
+	AllowedOrgs     []string `json:"allowedOrgs"`
+	InviteOnly      bool     `json:"inviteOnly"`
+	Invitees        []string `json:"invitees"`
+	LeavePenalty    *int     `json:"leavePenalty"` // 为空表示没有罚金
+}
+
+// 任务状态
+const (
+	taskDraft       = "draft"
+	taskOpen        = "open"
+	taskInProgress  = "in-progress"
+	taskAggregating = "aggregating"
+	taskRoundClosed = "round-closed"
+	taskCompleted   = "completed"
+	taskCancelled   = "cancelled"
+	taskExpired     = "expired"
+)
+
+// 触发状态变更的角色
+const (
+	rolePoster      = "poster"
+	roleAdmin       = "admin"
+	roleParticipant = "participant"
+	roleSystem      = "system" // 后端自动触发，actor 为 system
+)
+
+// 允许的状态变更以及可以触发的角色，与后端 invoke_fabric.taskTransitions 一致
+var taskTransitions = map[string]map[string][]string{
+	taskDraft: {
+		taskOpen:      {rolePoster, roleAdmin, roleSystem},
+		taskCancelled: {rolePoster, roleAdmin},
+	},
+	taskOpen: {
+		taskInProgress: {roleSystem},
+		taskCancelled:  {rolePoster, roleAdmin},
+		taskExpired:    {roleSystem},
+	},
+	taskInProgress: {
+		taskOpen:        {roleSystem},
+		taskAggregating: {rolePoster, roleAdmin, roleSystem},
+		taskRoundClosed: {rolePoster, roleAdmin},
+		taskCompleted:   {roleAdmin},
+		taskCancelled:   {roleAdmin},
+		taskExpired:     {roleSystem},
+	},
+	taskAggregating: {
+		taskInProgress:  {rolePoster, roleAdmin},
+		taskRoundClosed: {rolePoster, roleAdmin},
+		taskCompleted:   {roleAdmin},
+		taskCancelled:   {roleAdmin},
+		taskExpired:     {roleSystem},
+	},
+}
+
+// 任务当前状态，兼容没有 status 字段的旧任务
+func (t *Task) state() string {
+	if t.Status != "" {
+		return t.Status
+	}
+	switch {
+	case t.IsComplete && t.NextRoundTaskID != "":
+		return taskRoundClosed
+	case t.IsComplete:
+		return taskCompleted
+	case len(t.AcceptedUsers) > 0:
+		return taskInProgress
+	default:
+		return taskOpen
+	}
+}
+
+// 任务是否已经结束
+func taskClosed(state string) bool {
+	switch state {
+	case taskRoundClosed, taskCompleted, taskCancelled, taskExpired:
+		return true
+	}
+	return false
+}
+
+// 读取任务，不存在时返回错误
+func (l *ledger) task(taskID string) (*Task, error) {
+	var task Task
+	ok, err := l.get(taskType, taskID, &task)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("任务 %s 不存在", taskID)
+	}
+	return &task, nil
+}
+
+func (l *ledger) putTask(task *Task) error {
+	return l.put(taskType, task.TaskID, task)
+}
+
+// 读取可以修改的任务：存在、未删除且未结束
+func (l *ledger) openTask(taskID string) (*Task, error) {
+	task, err := l.task(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task.Deleted() {
+		return nil, fmt.Errorf("任务 %s 已被删除", taskID)
+	}
+	if taskClosed(task.state()) {
+		return nil, fmt.Errorf("任务 %s 处于 %s 状态，不能修改", taskID, task.state())
+	}
+	return task, nil
+}
+
+// 创建任务，taskID 为空时由链码生成，返回任务 ID；status 为 draft 或 open，为空时为 open
+func (s *SmartContract) CreateTask(ctx contractapi.TransactionContextInterface,
+	taskID string,
+	bonus int,
+	rootModelId string,
+	postedUser string,
+	round int,
+	nextRoundTaskID string,
+	status string,
+) (string, error) {
+	l := open(ctx)
+
+	if status == "" {
+		status = taskOpen
+	}
+	if status != taskDraft && status != taskOpen {
+		return "", fmt.Errorf("任务只能以 %s 或 %s 状态创建", taskDraft, taskOpen)
+	}
+	if bonus < 0 {
+		return "", fmt.Errorf("奖励不能为负数")
+	}
+	poster, err := l.user(postedUser)
+	if err != nil {
+		return "", err
+	}
+	if poster.Deleted() {
+		return "", fmt.Errorf("用户 %s 已被删除", postedUser)
+	}
+	if rootModelId != "" {
+		if _, err := l.model(rootModelId); err != nil {
+			return "", err
+		}
+	}
+
+	if taskID == "" {
+		taskID = l.newID("task")
+	}
+	if data, err := l.getRaw(taskType, taskID); err != nil {
+		return "", err
+	} else if data != nil {
+		return "", fmt.Errorf("任务 %s 已存在", taskID)
+	}
+	now, err := l.now()
+	if err != nil {
+		return "", err
+	}
+
+	task := &Task{
+		TaskID:          taskID,
+		Bonus:           bonus,
+		RootModelId:     rootModelId,
+		PostedUser:      postedUser,
+		AcceptedUsers:   []string{},
+		Models:          []string{},
+		Round:           round,
+		NextRoundTaskID: nextRoundTaskID,
+		Status:          status,
+		CreatedAt:       now,
+	}
+	if err := l.putTask(task); err != nil {
+		return "", err
+	}
+	return taskID, nil
+}
+
+// 查询任务
+func (s *SmartContract) ReadTask(ctx contractapi.TransactionContextInterface, taskID string) (string, error) {
+	data, err := open(ctx).getRaw(taskType, taskID)
+	if err != nil {
+		return "", err
+	}
+	if data == nil {
+		return "", fmt.Errorf("任务 %s 不存在", taskID)
+	}
+	return string(data), nil
+}
+
+// 查询所有任务，包括已删除的任务
+func (s *SmartContract) GetAllTasks(ctx contractapi.TransactionContextInterface) (string, error) {
+	values, err := open(ctx).list(taskType)
+	if err != nil {
+		return "", err
+	}
+	return jsonArray(values), nil
+}
+
+// 彻底删除任务，只用于回滚刚创建的任务：没有参与者、没有提交的模型
+func (s *SmartContract) DeleteTask(ctx contractapi.TransactionContextInterface, taskID string) error {
+	l := open(ctx)
+
+	task, err := l.task(taskID)
+	if err != nil {
+		return err
+	}
+	if len(task.AcceptedUsers) > 0 || len(task.Models) > 0 {
+		return fmt.Errorf("任务 %s 已有参与者或模型，不能彻底删除", taskID)
+	}
+	// 托管账户有余额时删除任务会使余额无法转出
+	for _, account := range []string{escrowPrefix + taskID, escrowPrefix + taskID + penaltySuffix} {
+		balance, err := l.balance(account)
+		if err != nil {
+			return err
+		}
+		if balance != 0 {
+			return fmt.Errorf("任务 %s 的账户 %s 还有余额 %d，不能彻底删除", taskID, account, balance)
+		}
+	}
+	return l.del(taskType, taskID)
+}
+
+// 只修改任务的下一轮任务 ID
+func (s *SmartContract) SetNextRoundTask(ctx contractapi.TransactionContextInterface, taskID string, nextRoundTaskID string) error {
+	l := open(ctx)
+
+	task, err := l.task(taskID)
+	if err != nil {
+		return err
+	}
+	if nextRoundTaskID != "" {
+		if _, err := l.task(nextRoundTaskID); err != nil {
+			return err
+		}
+	}
+	task.NextRoundTaskID = nextRoundTaskID
+	return l.putTask(task)
+}
+
+// 参与者向进行中的任务提交自己上传的模型
+func (s *SmartContract) AddModelToTask(ctx contractapi.TransactionContextInterface, taskID string, modelID string) error {
+	l := open(ctx)
+
+	task, err := l.openTask(taskID)
+	if err != nil {
+		return err
+	}
+	if task.state() != taskInProgress {
+		return fmt.Errorf("任务 %s 处于 %s 状态，不能提交模型", taskID, task.state())
+	}
+	model, err := l.model(modelID)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(task.AcceptedUsers, model.Modelowner) {
+		return fmt.Errorf("模型 %s 的上传者 %s 没有接受任务 %s", modelID, model.Modelowner, taskID)
+	}
+	if slices.Contains(task.Models, modelID) {
+		return fmt.Errorf("模型 %s 已提交到任务 %s", modelID, taskID)
+	}
+
+	task.Models = append(task.Models, modelID)
+	return l.putTask(task)
+}
+
+// 操作人在任务中的角色
+func (l *ledger) taskRoles(task *Task, actor string) ([]string, error) {
+	if actor == roleSystem {
+		return []string{roleSystem}, nil
+	}
+	user, err := l.user(actor)
+	if err != nil {
+		return nil, err
+	}
+	if user.Deleted() {
+		return nil, fmt.Errorf("用户 %s 已被删除", actor)
+	}
+	var roles []string
+	if task.PostedUser == actor {
+		roles = append(roles, rolePoster)
+	}
+	if user.IsAdmin {
+		roles = append(roles, roleAdmin)
+	}
+	if slices.Contains(task.AcceptedUsers, actor) {
+		roles = append(roles, roleParticipant)
+	}
+	return roles, nil
+}
+
+// 检查状态变更是否允许，roles 中任意一个角色有权限即可
+func checkTransition(from, to string, roles []string) error {
+	targets, ok := taskTransitions[from]
+	if !ok {
+		return fmt.Errorf("任务处于 %s 状态，不能再变更", from)
+	}
+	allowed, ok := targets[to]
+	if !ok {
+		return fmt.Errorf("任务不能从 %s 变更为 %s", from, to)
+	}
+	for _, role := range roles {
+		if slices.Contains(allowed, role) {
+			return nil
+		}
+	}
+	return fmt.Errorf("无权将任务从 %s 变更为 %s", from, to)
+}
+
+// 按状态机变更任务状态。fromStatus 为后端读取到的状态，与链上状态不同时交易失败；
+// 进入结束状态时设置 isComplete，托管账户已经清空时同时记录 escrowReleasedAt
+func (s *SmartContract) TransitionTask(ctx contractapi.TransactionContextInterface,
+	taskID string,
+	fromStatus string,
+	toStatus string,
+	actor string,
+	reason string,
+) error {
+	l := open(ctx)
+
+	task, err := l.task(taskID)
+	if err != nil {
+		return err
+	}
+	if task.Deleted() {
+		return fmt.Errorf("任务 %s 已被删除", taskID)
+	}
+	if task.state() != fromStatus {
+		return fmt.Errorf("任务 %s 的状态已变为 %s", taskID, task.state())
+	}
+	roles, err := l.taskRoles(task, actor)
+	if err != nil {
+		return err
+	}
+	if err := checkTransition(fromStatus, toStatus, roles); err != nil {
+		return err
+	}
+	if toStatus == taskInProgress && fromStatus == taskOpen && len(task.AcceptedUsers) == 0 {
+		return fmt.Errorf("任务 %s 还没有参与者", taskID)
+	}
+	if toStatus == taskOpen && fromStatus == taskInProgress && len(task.AcceptedUsers) > 0 {
+		return fmt.Errorf("任务 %s 还有参与者", taskID)
+	}
+
+	task.Status = toStatus
+	task.IsComplete = taskClosed(toStatus)
+	if err := l.putTask(task); err != nil {
+		return err
+	}
+	return l.markEscrowReleased(taskID)
+}
+
+// 参与者退出进行中或聚合中的任务，罚金在同一交易中转入 escrow:<taskID>:penalty。
+// 聚合中已提交模型的参与者不能退出；进行中的任务最后一个参与者退出后重新开放
+func (s *SmartContract) WithdrawFromTask(ctx contractapi.TransactionContextInterface,
+	taskID string,
+	username string,
+	penalty int,
+) error {
+	l := open(ctx)
+
+	if penalty < 0 {
+		return fmt.Errorf("罚金不能为负数")
+	}
+	task, err := l.openTask(taskID)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(task.AcceptedUsers, username) {
+		return fmt.Errorf("用户 %s 没有参与任务 %s", username, taskID)
+	}
+	state := task.state()
+	switch state {
+	case taskInProgress:
+	case taskAggregating:
+		for _, modelID := range task.Models {
+			model, err := l.model(modelID)
+			if err != nil {
+				return err
+			}
+			if model.Modelowner == username {
+				return fmt.Errorf("本轮已停止提交，已提交模型的用户不能退出")
+			}
+		}
+	default:
+		return fmt.Errorf("任务 %s 处于 %s 状态，不能退出", taskID, state)
+	}
+	user, err := l.user(username)
+	if err != nil {
+		return err
+	}
+
+	task.AcceptedUsers = slices.DeleteFunc(task.AcceptedUsers, func(name string) bool { return name == username })
+	task.Status = state
+	if state == taskInProgress && len(task.AcceptedUsers) == 0 {
+		task.Status = taskOpen
+	}
+	if err := l.putTask(task); err != nil {
+		return err
+	}
+	user.Accepted = slices.DeleteFunc(user.Accepted, func(id string) bool { return id == taskID })
+	if err := l.putUser(user); err != nil {
+		return err
+	}
+
+	if penalty > 0 {
+		memo := fmt.Sprintf("用户 %s 退出任务 %s 的罚金", username, taskID)
+		if _, err := l.transfer(transferKindTransfer, username, escrowPrefix+taskID+penaltySuffix, penalty, memo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 用户接受任务，在同一交易中更新用户和任务。fromStatus 为后端读取到的状态，与链上状态不同时交易失败；
+// 链码按链上记录再次检查接受限制，避免并发接受超出人数上限。第一个参与者加入时任务从 open 变为 in-progress
+func (s *SmartContract) AcceptTask(ctx contractapi.TransactionContextInterface,
+	taskID string,
+	username string,
+	fromStatus string,
+) error {
+	l := open(ctx)
+
+	task, err := l.openTask(taskID)
+	if err != nil {
+		return err
+	}
+	state := task.state()
+	if state != fromStatus {
+		return fmt.Errorf("任务 %s 的状态已变为 %s", taskID, state)
+	}
+	if state != taskOpen && state != taskInProgress {
+		return fmt.Errorf("任务 %s 处于 %s 状态，不接受新的参与者", taskID, state)
+	}
+	user, err := l.user(username)
+	if err != nil {
+		return err
+	}
+	if err := checkAccept(task, user); err != nil {
+		return err
+	}
+	if deadline, err := parseDeadline("接受截止时间", task.AcceptDeadline); err != nil {
+		return err
+	} else if !deadline.IsZero() {
+		now, err := l.time()
+		if err != nil {
+			return err
+		}
+		if now.After(deadline) {
+			return fmt.Errorf("任务 %s 已于 %s 停止接受", taskID, task.AcceptDeadline)
+		}
+	}
+
+	task.AcceptedUsers = append(task.AcceptedUsers, username)
+	task.Status = taskInProgress
+	if err := l.putTask(task); err != nil {
+		return err
+	}
+	user.Accepted = append(user.Accepted, taskID)
+	return l.putUser(user)
+}
+
+// 检查用户能否接受任务，信誉等需要查询流水的条件由后端检查
+func checkAccept(task *Task, user *User) error {
+	rules := task.Rules
+	switch {
+	case user.Deleted():
+		return fmt.Errorf("用户 %s 已被删除", user.Username)
+	case !user.IsVerified || !user.IsAccepted:
+		return fmt.Errorf("用户 %s 未通过验证或未被接受", user.Username)
+	case task.PostedUser == user.Username:
+		return fmt.Errorf("不能接受自己发布的任务")
+	case slices.Contains(task.AcceptedUsers, user.Username) || slices.Contains(user.Accepted, task.TaskID):
+		return fmt.Errorf("用户 %s 已经接受了任务 %s", user.Username, task.TaskID)
+	case rules.MaxParticipants > 0 && len(task.AcceptedUsers) >= rules.MaxParticipants:
+		return fmt.Errorf("参与人数已达上限 %d", rules.MaxParticipants)
+	case len(rules.AllowedOrgs) > 0 && !slices.Contains(rules.AllowedOrgs, user.Organization):
+		return fmt.Errorf("组织 %s 不在允许范围内", user.Organization)
+	case rules.InviteOnly && !slices.Contains(rules.Invitees, user.Username):
+		return fmt.Errorf("任务仅限受邀用户参与")
+	case rules.MinBalance > 0 && user.Token < rules.MinBalance:
+		return fmt.Errorf("账户余额 %d 低于要求的 %d", user.Token, rules.MinBalance)
+	}
+	return nil
+}
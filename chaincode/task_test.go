@@ -0,0 +1,160 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCreateTask(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 0)
+	root := l.mustInvoke("CreateModel", "alice", "cid", "sig", "", "", "", "", "")
+
+	taskID := l.mustInvoke("CreateTask", "", "10", root, "alice", "1", "", "")
+	task := readJSON[Task](t, l, "ReadTask", taskID)
+	if task.Status != taskOpen || task.CreatedAt == "" || task.AcceptedUsers == nil {
+		t.Errorf("新任务记录不正确: %+v", task)
+	}
+
+	l.mustFail("CreateTask", "", "10", root, "alice", "1", "", taskInProgress)
+	l.mustFail("CreateTask", "", "10", "missing", "alice", "1", "", "")
+	l.mustFail("CreateTask", "", "10", root, "bob", "1", "", "")
+	l.mustFail("CreateTask", taskID, "10", root, "alice", "1", "", "")
+
+	next := l.mustInvoke("CreateTask", "", "10", root, "alice", "2", "", taskDraft)
+	l.mustInvoke("SetNextRoundTask", taskID, next)
+	if task := readJSON[Task](t, l, "ReadTask", taskID); task.NextRoundTaskID != next || task.Bonus != 10 {
+		t.Errorf("SetNextRoundTask 结果不正确: %+v", task)
+	}
+	l.mustFail("SetNextRoundTask", taskID, "missing")
+
+	l.mustInvoke("DeleteTask", next)
+	l.mustFail("ReadTask", next)
+	if tasks := readJSON[[]Task](t, l, "GetAllTasks"); len(tasks) != 1 {
+		t.Errorf("应有 1 个任务，实际 %d", len(tasks))
+	}
+}
+
+// 状态变更检查链上状态、状态机和操作人的角色
+func TestTransitionTask(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 0)
+	l.createUser("bob", 0)
+	l.mustInvoke("CreateUser", "root", "pw", "org1", "", "0", "true", "true", "true")
+	taskID := l.mustInvoke("CreateTask", "", "0", "", "alice", "1", "", taskDraft)
+
+	cases := []struct {
+		name    string
+		from    string
+		to      string
+		actor   string
+		wantErr bool
+	}{
+		{"非发布者不能发布", taskDraft, taskOpen, "bob", true},
+		{"读取到的状态已过期", taskOpen, taskCancelled, "alice", true},
+		{"不存在的用户", taskDraft, taskOpen, "carol", true},
+		{"发布者发布草稿", taskDraft, taskOpen, "alice", false},
+		{"没有参与者时不能进入进行中", taskOpen, taskInProgress, roleSystem, true},
+		{"状态机不允许的变更", taskOpen, taskCompleted, "root", true},
+		{"管理员取消任务", taskOpen, taskCancelled, "root", false},
+		{"结束后不能再变更", taskCancelled, taskOpen, roleSystem, true},
+	}
+	for _, tc := range cases {
+		_, err := l.invoke("TransitionTask", taskID, tc.from, tc.to, tc.actor, tc.name)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: 错误为 %v", tc.name, err)
+		}
+	}
+
+	task := readJSON[Task](t, l, "ReadTask", taskID)
+	if task.Status != taskCancelled || !task.IsComplete || task.EscrowReleasedAt == "" {
+		t.Errorf("取消后的任务记录不正确: %+v", task)
+	}
+}
+
+// 退出任务时在同一交易中更新任务和用户并转入罚金，最后一个参与者退出后任务重新开放
+func TestWithdrawFromTask(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 0)
+	l.createUser("bob", 10)
+	l.createUser("carol", 0)
+	taskID := l.mustInvoke("CreateTask", "", "0", "", "alice", "1", "", "")
+	task := readJSON[Task](t, l, "ReadTask", taskID)
+	task.AcceptedUsers = []string{"bob", "carol"}
+	task.Status = taskInProgress
+	putState(t, l, taskType, taskID, task)
+	for _, name := range []string{"bob", "carol"} {
+		user := readJSON[User](t, l, "ReadUser", name)
+		user.Accepted = []string{taskID}
+		putState(t, l, userType, name, user)
+	}
+
+	l.mustFail("WithdrawFromTask", taskID, "bob", "11")
+	l.mustFail("WithdrawFromTask", taskID, "bob", "-1")
+	l.mustFail("WithdrawFromTask", taskID, "alice", "0")
+
+	l.mustInvoke("WithdrawFromTask", taskID, "bob", "4")
+	if balance := l.mustInvoke("GetAccountBalance", escrowPrefix+taskID+penaltySuffix); balance != "4" {
+		t.Errorf("罚金账户余额为 %s，应为 4", balance)
+	}
+	if bob := readJSON[User](t, l, "ReadUser", "bob"); bob.Token != 6 || len(bob.Accepted) != 0 {
+		t.Errorf("退出后的用户记录不正确: %+v", bob)
+	}
+	if task := readJSON[Task](t, l, "ReadTask", taskID); task.Status != taskInProgress || len(task.AcceptedUsers) != 1 {
+		t.Errorf("还有参与者时任务应保持进行中: %+v", task)
+	}
+
+	l.mustInvoke("WithdrawFromTask", taskID, "carol", "0")
+	if task := readJSON[Task](t, l, "ReadTask", taskID); task.Status != taskOpen || len(task.AcceptedUsers) != 0 {
+		t.Errorf("最后一个参与者退出后任务应重新开放: %+v", task)
+	}
+	l.mustFail("WithdrawFromTask", taskID, "carol", "0")
+}
+
+// 接受任务时链码按链上记录检查状态和接受限制
+func TestAcceptTask(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 0)
+	l.createUser("bob", 0)
+	l.createUser("carol", 0)
+	l.createUser("dave", 0)
+	l.mustInvoke("CreateUser", "erin", "pw", "org1", "", "0", "false", "false", "false")
+	taskID := l.mustInvoke("CreateTask", "", "0", "", "alice", "1", "", "")
+	l.mustInvoke("SetTaskRules", taskID, `{"maxParticipants":2}`)
+
+	steps := []struct {
+		user    string
+		from    string
+		wantErr bool
+	}{
+		{"alice", taskOpen, true},        // 发布者
+		{"erin", taskOpen, true},         // 未通过审核
+		{"frank", taskOpen, true},        // 不存在
+		{"bob", taskInProgress, true},    // 读取到的状态已过期
+		{"bob", taskOpen, false},         // 第一个参与者，任务进入进行中
+		{"bob", taskInProgress, true},    // 重复接受
+		{"carol", taskOpen, true},        // 读取到的状态已过期
+		{"carol", taskInProgress, false}, // 达到上限
+		{"dave", taskInProgress, true},   // 超过上限
+	}
+	for i, step := range steps {
+		if _, err := l.invoke("AcceptTask", taskID, step.user, step.from); (err != nil) != step.wantErr {
+			t.Errorf("第 %d 步 %s 接受任务的错误为 %v", i+1, step.user, err)
+		}
+	}
+
+	task := readJSON[Task](t, l, "ReadTask", taskID)
+	if task.Status != taskInProgress || len(task.AcceptedUsers) != 2 {
+		t.Errorf("任务记录不正确: %+v", task)
+	}
+	if carol := readJSON[User](t, l, "ReadUser", "carol"); len(carol.Accepted) != 1 || carol.Accepted[0] != taskID {
+		t.Errorf("用户的接受列表不正确: %+v", carol)
+	}
+
+	// 超过接受截止时间后不能接受
+	expired := l.mustInvoke("CreateTask", "", "0", "", "alice", "1", "", "")
+	l.mustInvoke("SetTaskDeadlines", expired, "2026-01-01T00:00:00Z", "")
+	if err := l.mustFail("AcceptTask", expired, "dave", taskOpen); !strings.Contains(err.Error(), "停止接受") {
+		t.Errorf("错误信息不正确: %v", err)
+	}
+}
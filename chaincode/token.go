@@ -0,0 +1,373 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// 账户名前缀，用户名不能使用
+const (
+	escrowPrefix  = "escrow:"
+	systemPrefix  = "system:"
+	penaltySuffix = ":penalty"
+)
+
+// 增发和销毁的对手方系统账户，余额可以为负，表示流通中的代币总量
+const mintAccount = systemPrefix + "mint"
+
+// 代币流水类型
+const (
+	transferKindTransfer = "transfer"
+	transferKindMint     = "mint"
+	transferKindBurn     = "burn"
+)
+
+func isReservedAccount(name string) bool {
+	return strings.HasPrefix(name, escrowPrefix) || strings.HasPrefix(name, systemPrefix)
+}
+
+// 转账记录中的一条分录，Amount 为负表示转出
+type LedgerPosting struct {
+	Account string `json:"account"`
+	Amount  int    `json:"amount"`
+	Balance int    `json:"balance"` // 记账后的账户余额
+}
+
+// 一笔转账，写入后不可修改
+type TokenTransfer struct {
+	TransferID string          `json:"transferId"`
+	Kind       string          `json:"kind"`
+	Memo       string          `json:"memo"`
+	Timestamp  string          `json:"timestamp"`
+	Postings   []LedgerPosting `json:"postings"`
+}
+
+// 用户以外账户的余额
+type Account struct {
+	Balance int `json:"balance"`
+}
+
+// 托管账户或罚金账户所属的任务
+func escrowTaskID(account string) (string, bool) {
+	taskID, ok := strings.CutPrefix(account, escrowPrefix)
+	if !ok {
+		return "", false
+	}
+	return strings.TrimSuffix(taskID, penaltySuffix), true
+}
+
+// 检查账户能否记账：用户账户必须存在，托管账户所属的任务必须存在
+func (l *ledger) checkAccount(account string) error {
+	if account == mintAccount {
+		return nil
+	}
+	if taskID, ok := escrowTaskID(account); ok {
+		_, err := l.task(taskID)
+		return err
+	}
+	if isReservedAccount(account) {
+		return fmt.Errorf("账户 %s 不存在", account)
+	}
+	_, err := l.user(account)
+	return err
+}
+
+// 已结束任务的托管账户和罚金账户余额都为 0 时记录 escrowReleasedAt
+func (l *ledger) markEscrowReleased(taskID string) error {
+	task, err := l.task(taskID)
+	if err != nil {
+		return err
+	}
+	if task.EscrowReleasedAt != "" || !taskClosed(task.state()) {
+		return nil
+	}
+	for _, account := range []string{escrowPrefix + taskID, escrowPrefix + taskID + penaltySuffix} {
+		balance, err := l.balance(account)
+		if err != nil || balance != 0 {
+			return err
+		}
+	}
+	now, err := l.now()
+	if err != nil {
+		return err
+	}
+	task.EscrowReleasedAt = now
+	return l.putTask(task)
+}
+
+// 检查收款用户未被删除，托管账户和系统账户不检查
+func (l *ledger) checkReceiver(account string) error {
+	if isReservedAccount(account) {
+		return nil
+	}
+	user, err := l.user(account)
+	if err != nil {
+		return err
+	}
+	if user.Deleted() {
+		return fmt.Errorf("用户 %s 已被删除", account)
+	}
+	return nil
+}
+
+// 账户余额，账户不存在时为 0
+func (l *ledger) balance(account string) (int, error) {
+	if !isReservedAccount(account) {
+		var user User
+		if _, err := l.get(userType, account, &user); err != nil {
+			return 0, err
+		}
+		return user.Token, nil
+	}
+	var acct Account
+	if _, err := l.get(accountType, account, &acct); err != nil {
+		return 0, err
+	}
+	return acct.Balance, nil
+}
+
+// 给账户记一笔金额，返回记账后的余额；只有 system:mint 的余额可以为负
+func (l *ledger) post(account string, amount int) (int, error) {
+	if !isReservedAccount(account) {
+		user, err := l.user(account)
+		if err != nil {
+			return 0, err
+		}
+		if user.Token+amount < 0 {
+			return 0, fmt.Errorf("账户 %s 余额不足: 当前 %d，需要 %d", account, user.Token, -amount)
+		}
+		user.Token += amount
+		return user.Token, l.putUser(user)
+	}
+
+	var acct Account
+	if _, err := l.get(accountType, account, &acct); err != nil {
+		return 0, err
+	}
+	if acct.Balance+amount < 0 && account != mintAccount {
+		return 0, fmt.Errorf("账户 %s 余额不足: 当前 %d，需要 %d", account, acct.Balance, -amount)
+	}
+	acct.Balance += amount
+	return acct.Balance, l.put(accountType, account, &acct)
+}
+
+// 在当前交易中从 sender 转账给 receiver 并写入转账记录
+func (l *ledger) transfer(kind, sender, receiver string, amount int, memo string) (*TokenTransfer, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("转账金额必须大于 0")
+	}
+	if sender == receiver {
+		return nil, fmt.Errorf("不能向自己转账")
+	}
+	for _, account := range []string{sender, receiver} {
+		if err := l.checkAccount(account); err != nil {
+			return nil, err
+		}
+	}
+	t, err := l.time()
+	if err != nil {
+		return nil, err
+	}
+
+	senderBalance, err := l.post(sender, -amount)
+	if err != nil {
+		return nil, err
+	}
+	receiverBalance, err := l.post(receiver, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := &TokenTransfer{
+		TransferID: l.newID("transfer"),
+		Kind:       kind,
+		Memo:       memo,
+		Timestamp:  t.Format(time.RFC3339),
+		Postings: []LedgerPosting{
+			{Account: sender, Amount: -amount, Balance: senderBalance},
+			{Account: receiver, Amount: amount, Balance: receiverBalance},
+		},
+	}
+	if err := l.put(transferType, transfer.TransferID, transfer); err != nil {
+		return nil, err
+	}
+	// 按账户和时间索引，查询流水时按时间顺序返回
+	order := fmt.Sprintf("%020d", t.UnixNano())
+	for _, account := range []string{sender, receiver} {
+		if err := l.putRaw([]byte{0}, historyIndex, account, order, transfer.TransferID); err != nil {
+			return nil, err
+		}
+	}
+	if taskID, ok := escrowTaskID(sender); ok {
+		if err := l.markEscrowReleased(taskID); err != nil {
+			return nil, err
+		}
+	}
+	return transfer, nil
+}
+
+// 账户之间转账，余额不足时整笔交易失败
+func (s *SmartContract) TransferTokens(ctx contractapi.TransactionContextInterface,
+	sender string,
+	receiver string,
+	amount int,
+	memo string,
+) (string, error) {
+	l := open(ctx)
+
+	if sender == mintAccount || receiver == mintAccount {
+		return "", fmt.Errorf("不能直接与 %s 转账", mintAccount)
+	}
+	if err := l.checkReceiver(receiver); err != nil {
+		return "", err
+	}
+	transfer, err := l.transfer(transferKindTransfer, sender, receiver, amount, memo)
+	if err != nil {
+		return "", err
+	}
+	return marshal(transfer)
+}
+
+// 幂等键对应的转账，键不存在时返回 nil；已存在的键只能用于同一转出方、转入方和金额
+func (l *ledger) transferOnce(key, sender, receiver string, amount int) (*TokenTransfer, error) {
+	id, err := l.getRaw(idempotencyType, key)
+	if err != nil || id == nil {
+		return nil, err
+	}
+	var transfer TokenTransfer
+	ok, err := l.get(transferType, string(id), &transfer)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("幂等键 %s 对应的转账 %s 不存在", key, id)
+	}
+	if len(transfer.Postings) != 2 || transfer.Postings[0].Account != sender ||
+		transfer.Postings[1].Account != receiver || transfer.Postings[1].Amount != amount {
+		return nil, fmt.Errorf("幂等键 %s 已用于其他转账 %s", key, transfer.TransferID)
+	}
+	return &transfer, nil
+}
+
+// 记录幂等键对应的转账
+func (l *ledger) recordOnce(key string, transfer *TokenTransfer) error {
+	return l.putRaw([]byte(transfer.TransferID), idempotencyType, key)
+}
+
+// 幂等转账：同一个幂等键只会转账一次，重复提交时返回第一次的转账记录
+func (s *SmartContract) TransferTokensOnce(ctx contractapi.TransactionContextInterface,
+	idempotencyKey string,
+	sender string,
+	receiver string,
+	amount int,
+	memo string,
+) (string, error) {
+	l := open(ctx)
+
+	if idempotencyKey == "" {
+		return "", fmt.Errorf("幂等键不能为空")
+	}
+	if sender == mintAccount || receiver == mintAccount {
+		return "", fmt.Errorf("不能直接与 %s 转账", mintAccount)
+	}
+	transfer, err := l.transferOnce(idempotencyKey, sender, receiver, amount)
+	if err != nil {
+		return "", err
+	}
+	if transfer == nil {
+		if transfer, err = l.transfer(transferKindTransfer, sender, receiver, amount, memo); err != nil {
+			return "", err
+		}
+		if err := l.recordOnce(idempotencyKey, transfer); err != nil {
+			return "", err
+		}
+	}
+	return marshal(transfer)
+}
+
+// 增发代币：从 system:mint 转入用户账户，已删除的用户不能增发。
+// 幂等键不为空时同一个键只会增发一次
+func (s *SmartContract) MintTokens(ctx contractapi.TransactionContextInterface,
+	idempotencyKey string,
+	account string,
+	amount int,
+	memo string,
+) (string, error) {
+	l := open(ctx)
+
+	if isReservedAccount(account) {
+		return "", fmt.Errorf("不能对系统账户 %s 增发", account)
+	}
+	if err := l.checkReceiver(account); err != nil {
+		return "", err
+	}
+	return l.adjust(transferKindMint, idempotencyKey, mintAccount, account, amount, memo)
+}
+
+// 销毁代币：从用户账户转回 system:mint，余额不足时失败。
+// 幂等键不为空时同一个键只会销毁一次
+func (s *SmartContract) BurnTokens(ctx contractapi.TransactionContextInterface,
+	idempotencyKey string,
+	account string,
+	amount int,
+	memo string,
+) (string, error) {
+	l := open(ctx)
+
+	if isReservedAccount(account) {
+		return "", fmt.Errorf("不能对系统账户 %s 销毁", account)
+	}
+	return l.adjust(transferKindBurn, idempotencyKey, account, mintAccount, amount, memo)
+}
+
+// 增发或销毁，幂等键为空时每次都执行
+func (l *ledger) adjust(kind, idempotencyKey, sender, receiver string, amount int, memo string) (string, error) {
+	if idempotencyKey != "" {
+		transfer, err := l.transferOnce(idempotencyKey, sender, receiver, amount)
+		if err != nil {
+			return "", err
+		}
+		if transfer != nil {
+			return marshal(transfer)
+		}
+	}
+	transfer, err := l.transfer(kind, sender, receiver, amount, memo)
+	if err != nil {
+		return "", err
+	}
+	if idempotencyKey != "" {
+		if err := l.recordOnce(idempotencyKey, transfer); err != nil {
+			return "", err
+		}
+	}
+	return marshal(transfer)
+}
+
+// 查询账户余额，用户账户和托管账户都适用，账户不存在时返回 0
+func (s *SmartContract) GetAccountBalance(ctx contractapi.TransactionContextInterface, account string) (int, error) {
+	return open(ctx).balance(account)
+}
+
+// 查询账户的全部转账记录，按时间顺序返回
+func (s *SmartContract) GetTransferHistory(ctx contractapi.TransactionContextInterface, account string) (string, error) {
+	l := open(ctx)
+
+	keys, err := l.listKeys(historyIndex, account)
+	if err != nil {
+		return "", err
+	}
+	var values [][]byte
+	for _, parts := range keys {
+		data, err := l.getRaw(transferType, parts[len(parts)-1])
+		if err != nil {
+			return "", err
+		}
+		if data != nil {
+			values = append(values, data)
+		}
+	}
+	return jsonArray(values), nil
+}
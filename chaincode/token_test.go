@@ -0,0 +1,125 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTransferTokens(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 100)
+	l.createUser("bob", 0)
+
+	transfer := readJSON[TokenTransfer](t, l, "TransferTokens", "alice", "bob", "30", "测试")
+	if len(transfer.Postings) != 2 || transfer.Postings[0].Balance != 70 || transfer.Postings[1].Balance != 30 {
+		t.Errorf("分录不正确: %+v", transfer.Postings)
+	}
+
+	// 余额不足时整笔交易不写入
+	if err := l.mustFail("TransferTokens", "bob", "alice", "31", ""); !strings.Contains(err.Error(), "余额不足") {
+		t.Errorf("错误信息不正确: %v", err)
+	}
+	if bob := readJSON[User](t, l, "ReadUser", "bob"); bob.Token != 30 {
+		t.Errorf("失败的转账修改了余额: %d", bob.Token)
+	}
+
+	l.mustFail("TransferTokens", "alice", "alice", "1", "")
+	l.mustFail("TransferTokens", "alice", "bob", "0", "")
+	l.mustFail("TransferTokens", "alice", "carol", "1", "")
+	l.mustFail("TransferTokens", "system:mint", "alice", "1", "")
+}
+
+// 流水按时间顺序返回，初始余额记为 system:mint 的增发
+func TestGetTransferHistory(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 100)
+	l.createUser("bob", 0)
+	l.mustInvoke("TransferTokens", "alice", "bob", "10", "第一笔")
+	l.mustInvoke("TransferTokens", "bob", "alice", "5", "第二笔")
+
+	history := readJSON[[]TokenTransfer](t, l, "GetTransferHistory", "alice")
+	if len(history) != 3 {
+		t.Fatalf("alice 应有 3 条流水，实际 %d", len(history))
+	}
+	if history[0].Kind != transferKindMint || history[1].Memo != "第一笔" || history[2].Memo != "第二笔" {
+		t.Errorf("流水顺序不正确: %+v", history)
+	}
+	for _, transfer := range history {
+		sum := 0
+		for _, p := range transfer.Postings {
+			sum += p.Amount
+		}
+		if sum != 0 {
+			t.Errorf("转账 %s 借贷不平衡", transfer.TransferID)
+		}
+	}
+	if history := readJSON[[]TokenTransfer](t, l, "GetTransferHistory", "carol"); len(history) != 0 {
+		t.Errorf("不存在的账户应没有流水")
+	}
+}
+
+// 同一个幂等键只转账一次，重复提交返回第一次的记录
+func TestTransferTokensOnce(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 100)
+	l.createUser("bob", 0)
+
+	first := readJSON[TokenTransfer](t, l, "TransferTokensOnce", "k1", "alice", "bob", "10", "")
+	again := readJSON[TokenTransfer](t, l, "TransferTokensOnce", "k1", "alice", "bob", "10", "")
+	if first.TransferID != again.TransferID {
+		t.Errorf("重复提交返回了新的转账 %s", again.TransferID)
+	}
+	if balance := l.mustInvoke("GetAccountBalance", "bob"); balance != "10" {
+		t.Errorf("bob 余额为 %s，应为 10", balance)
+	}
+	l.mustFail("TransferTokensOnce", "k1", "alice", "bob", "20", "")
+	l.mustFail("TransferTokensOnce", "", "alice", "bob", "10", "")
+}
+
+// 增发和销毁的对手方为 system:mint，幂等键不为空时只执行一次
+func TestMintAndBurnTokens(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 0)
+
+	first := readJSON[TokenTransfer](t, l, "MintTokens", "grant:b1:alice", "alice", "50", "发放")
+	again := readJSON[TokenTransfer](t, l, "MintTokens", "grant:b1:alice", "alice", "50", "发放")
+	if first.TransferID != again.TransferID || first.Kind != transferKindMint {
+		t.Errorf("重复增发返回了新的转账: %+v", again)
+	}
+	l.mustInvoke("MintTokens", "", "alice", "10", "无幂等键")
+	burn := readJSON[TokenTransfer](t, l, "BurnTokens", "", "alice", "20", "销毁")
+	if burn.Kind != transferKindBurn || burn.Postings[1].Account != mintAccount {
+		t.Errorf("销毁记录不正确: %+v", burn)
+	}
+	if balance := l.mustInvoke("GetAccountBalance", "alice"); balance != "40" {
+		t.Errorf("alice 余额为 %s，应为 40", balance)
+	}
+	if balance := l.mustInvoke("GetAccountBalance", mintAccount); balance != "-40" {
+		t.Errorf("流通总量为 %s，应为 -40", balance)
+	}
+
+	l.mustFail("BurnTokens", "", "alice", "41", "")
+	l.mustFail("MintTokens", "grant:b1:alice", "alice", "60", "")
+	l.mustFail("MintTokens", "", "carol", "1", "")
+	l.mustFail("MintTokens", "", "system:mint", "1", "")
+	l.mustFail("BurnTokens", "", "escrow:t1", "1", "")
+}
+
+// 已删除的用户不能收到增发和转账
+func TestDeletedReceiver(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 100)
+	l.createUser("bob", 0)
+	bob := readJSON[User](t, l, "ReadUser", "bob")
+	bob.DeletedAt = "2026-01-01T00:00:00Z"
+	putState(t, l, userType, "bob", bob)
+
+	for _, err := range []error{
+		l.mustFail("MintTokens", "", "bob", "1", ""),
+		l.mustFail("TransferTokens", "alice", "bob", "1", ""),
+	} {
+		if !strings.Contains(err.Error(), "已被删除") {
+			t.Errorf("错误信息不正确: %v", err)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// 用户记录，字段与后端 invoke_fabric.User 一致
+type User struct {
+	Username     string   `json:"username"`
+	Password     string   `json:"password"`
+	Organization string   `json:"organization"`
+	Pubkeyhash   string   `json:"pubkeyhash"`
+	Token        int      `json:"token"` // 账户余额，只能通过转账修改
+	Posted       []string `json:"posted"`
+	Accepted     []string `json:"accepted"`
+	IsAdmin      bool     `json:"isAdmin"`
+	IsVerified   bool     `json:"isVerified"`
+	IsAccepted   bool     `json:"isAccepted"`
+	ReviewStatus string   `json:"reviewStatus"` // 注册审核状态：pending、approved、rejected
+	ReviewedBy   string   `json:"reviewedBy"`
+	ReviewedAt   string   `json:"reviewedAt"`
+	ReviewReason string   `json:"reviewReason"`
+
+	Keys []PublicKeyRecord `json:"keys"` // 公钥历史，最后一个为当前公钥
+
+	Tombstone
+}
+
+// 用户登记过的公钥
+type PublicKeyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	PublicKey   string `json:"publicKey"`
+	Algorithm   string `json:"algorithm"`
+	ValidFrom   string `json:"validFrom"`
+	ValidTo     string `json:"validTo"`
+}
+
+// 软删除标记，删除后记录仍保留在账本中
+type Tombstone struct {
+	DeletedAt    string `json:"deletedAt,omitempty"`
+	DeletedBy    string `json:"deletedBy,omitempty"`
+	DeleteReason string `json:"deleteReason,omitempty"`
+}
+
+func (t Tombstone) Deleted() bool {
+	return t.DeletedAt != ""
+}
+
+// 注册审核状态
+const (
+	reviewPending  = "pending"
+	reviewApproved = "approved"
+	reviewRejected = "rejected"
+)
+
+// 读取用户，不存在时返回错误
+func (l *ledger) user(username string) (*User, error) {
+	var user User
+	ok, err := l.get(userType, username, &user)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("用户 %s 不存在", username)
+	}
+	return &user, nil
+}
+
+func (l *ledger) userExists(username string) (bool, error) {
+	data, err := l.getRaw(userType, username)
+	return data != nil, err
+}
+
+func (l *ledger) putUser(user *User) error {
+	return l.put(userType, user.Username, user)
+}
+
+// 创建用户，token 大于 0 时从 system:mint 转入初始余额
+func (s *SmartContract) CreateUser(ctx contractapi.TransactionContextInterface,
+	username string,
+	password string,
+	organization string,
+	pubkeyhash string,
+	token int,
+	isAdmin bool,
+	isVerified bool,
+	isAccepted bool,
+) error {
+	l := open(ctx)
+
+	if strings.TrimSpace(username) == "" {
+		return fmt.Errorf("用户名不能为空")
+	}
+	if isReservedAccount(username) {
+		return fmt.Errorf("用户名不能以 %s 或 %s 开头", escrowPrefix, systemPrefix)
+	}
+	if token < 0 {
+		return fmt.Errorf("初始余额不能为负数")
+	}
+	exists, err := l.userExists(username)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("用户 %s 已存在", username)
+	}
+
+	// 直接创建为已验证并接受的用户（如初始管理员）视为已通过审核
+	status := reviewPending
+	if isVerified && isAccepted {
+		status = reviewApproved
+	}
+	user := &User{
+		Username:     username,
+		Password:     password,
+		Organization: organization,
+		Pubkeyhash:   pubkeyhash,
+		Posted:       []string{},
+		Accepted:     []string{},
+		IsAdmin:      isAdmin,
+		IsVerified:   isVerified,
+		IsAccepted:   isAccepted,
+		ReviewStatus: status,
+	}
+	if err := l.putUser(user); err != nil {
+		return err
+	}
+
+	if token > 0 {
+		if _, err := l.transfer(transferKindMint, mintAccount, username, token, fmt.Sprintf("用户 %s 的初始余额", username)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 查询用户
+func (s *SmartContract) ReadUser(ctx contractapi.TransactionContextInterface, username string) (string, error) {
+	data, err := open(ctx).getRaw(userType, username)
+	if err != nil {
+		return "", err
+	}
+	if data == nil {
+		return "", fmt.Errorf("用户 %s 不存在", username)
+	}
+	return string(data), nil
+}
+
+// 查询所有用户
+func (s *SmartContract) GetAllUsers(ctx contractapi.TransactionContextInterface) (string, error) {
+	values, err := open(ctx).list(userType)
+	if err != nil {
+		return "", err
+	}
+	return jsonArray(values), nil
+}
+
+// 只修改管理员、验证和接受标记，其他字段保持不变
+func (s *SmartContract) SetUserFlags(ctx contractapi.TransactionContextInterface,
+	username string,
+	isAdmin bool,
+	isVerified bool,
+	isAccepted bool,
+) error {
+	l := open(ctx)
+
+	user, err := l.user(username)
+	if err != nil {
+		return err
+	}
+	user.IsAdmin = isAdmin
+	user.IsVerified = isVerified
+	user.IsAccepted = isAccepted
+	return l.putUser(user)
+}
+
+// 审核用户注册：通过时 isVerified、isAccepted 置为 true，拒绝时置为 false，拒绝须填写原因
+func (s *SmartContract) ReviewUser(ctx contractapi.TransactionContextInterface,
+	username string,
+	approved bool,
+	reviewer string,
+	reason string,
+) error {
+	l := open(ctx)
+
+	if !approved && strings.TrimSpace(reason) == "" {
+		return fmt.Errorf("拒绝注册时必须填写原因")
+	}
+	admin, err := l.user(reviewer)
+	if err != nil {
+		return err
+	}
+	if !admin.IsAdmin || admin.Deleted() {
+		return fmt.Errorf("用户 %s 不是管理员，不能审核注册", reviewer)
+	}
+	user, err := l.user(username)
+	if err != nil {
+		return err
+	}
+	now, err := l.now()
+	if err != nil {
+		return err
+	}
+
+	user.ReviewStatus = reviewRejected
+	if approved {
+		user.ReviewStatus = reviewApproved
+	}
+	user.IsVerified = approved
+	user.IsAccepted = approved
+	user.ReviewedBy = reviewer
+	user.ReviewedAt = now
+	user.ReviewReason = reason
+	return l.putUser(user)
+}
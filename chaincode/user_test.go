@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestCreateUser(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustInvoke("CreateUser", "alice", "pw", "org1", "test", "0", "false", "false", "false")
+
+	alice := readJSON[User](t, l, "ReadUser", "alice")
+	if alice.ReviewStatus != reviewPending || alice.Posted == nil || alice.Accepted == nil {
+		t.Errorf("新用户记录不正确: %+v", alice)
+	}
+
+	l.mustFail("CreateUser", "alice", "pw", "org1", "test", "0", "false", "false", "false")
+	l.mustFail("CreateUser", "escrow:x", "pw", "org1", "test", "0", "false", "false", "false")
+	l.mustFail("CreateUser", "system:mint", "pw", "org1", "test", "0", "false", "false", "false")
+
+	if users := readJSON[[]User](t, l, "GetAllUsers"); len(users) != 1 {
+		t.Errorf("应有 1 个用户，实际 %d", len(users))
+	}
+}
+
+// SetUserFlags 只修改三个标记，其他字段保持不变
+func TestSetUserFlagsKeepsFields(t *testing.T) {
+	l := newTestLedger(t)
+	l.createUser("alice", 10)
+	l.mustInvoke("CreateModel", "alice", "cid", "sig", "ECDSA-SHA256", "fp", "ipfs", "", "")
+
+	l.mustInvoke("SetUserFlags", "alice", "true", "true", "false")
+	alice := readJSON[User](t, l, "ReadUser", "alice")
+	if !alice.IsAdmin || alice.IsAccepted || alice.Token != 10 || len(alice.Posted) != 1 || alice.ReviewStatus != reviewApproved {
+		t.Errorf("SetUserFlags 修改了其他字段: %+v", alice)
+	}
+}
+
+func TestReviewUser(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustInvoke("CreateUser", "admin", "pw", "org1", "", "0", "true", "true", "true")
+	l.mustInvoke("CreateUser", "alice", "pw", "org1", "test", "0", "false", "false", "false")
+	l.mustInvoke("CreateUser", "bob", "pw", "org1", "test", "0", "false", "false", "false")
+
+	l.mustFail("ReviewUser", "alice", "false", "admin", "")
+	l.mustFail("ReviewUser", "alice", "true", "bob", "")
+
+	l.mustInvoke("ReviewUser", "alice", "false", "admin", "资料不全")
+	alice := readJSON[User](t, l, "ReadUser", "alice")
+	if alice.ReviewStatus != reviewRejected || alice.IsVerified || alice.ReviewReason != "资料不全" || alice.ReviewedAt == "" {
+		t.Errorf("拒绝后的记录不正确: %+v", alice)
+	}
+
+	l.mustInvoke("ReviewUser", "alice", "true", "admin", "")
+	alice = readJSON[User](t, l, "ReadUser", "alice")
+	if alice.ReviewStatus != reviewApproved || !alice.IsVerified || !alice.IsAccepted || alice.ReviewedBy != "admin" {
+		t.Errorf("通过后的记录不正确: %+v", alice)
+	}
+}
@@ -0,0 +1,77 @@
+package audit
+
+import (
+	invoke_fabric "backend/fabric-go/call"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 下载结果
+const (
+	DownloadOK     = "ok"
+	DownloadDenied = "denied"
+	DownloadFailed = "failed"
+)
+
+// 一次模型下载记录
+type Download struct {
+	Time     time.Time `json:"time"`
+	Username string    `json:"username"`
+	ModelID  string    `json:"modelId"`
+	CID      string    `json:"cid,omitempty"`
+	Relation string    `json:"relation,omitempty"` // 下载者与模型的关系：owner、poster、participant、recipient 或 admin
+	Via      string    `json:"via"`                // direct 或 signed-url
+	IP       string    `json:"ip"`
+	Result   string    `json:"result"`
+	Bytes    int64     `json:"bytes"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// 查询条件，为空的字段不过滤
+type Filter struct {
+	ModelID  string
+	Username string
+	Limit    int
+}
+
+// 下载审计日志
+type Log interface {
+	Append(entry Download) error
+	// 按时间倒序返回符合条件的记录
+	Query(filter Filter) ([]Download, error)
+}
+
+// 保存在账本上的下载审计日志，所有后端实例共用
+type LedgerLog struct {
+	contract func() *client.Contract
+}
+
+func NewLedgerLog(contract func() *client.Contract) *LedgerLog {
+	return &LedgerLog{contract: contract}
+}
+
+func (l *LedgerLog) Append(entry Download) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return invoke_fabric.RecordDownload(l.contract(), data)
+}
+
+func (l *LedgerLog) Query(filter Filter) ([]Download, error) {
+	data, err := invoke_fabric.QueryDownloads(l.contract(), filter.ModelID, filter.Username, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	entries := []Download{}
+	if len(data) == 0 {
+		return entries, nil
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("解析下载审计记录失败: %w", err)
+	}
+	return entries, nil
+}
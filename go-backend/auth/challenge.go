@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 登录挑战
+type Challenge struct {
+	Username  string    `json:"username"`
+	Nonce     string    `json:"nonce"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// 客户端需要签名的内容，绑定用户名防止挑战被挪用
+func (c *Challenge) Message() []byte {
+	return []byte("fabric-vue-login:" + c.Username + ":" + c.Nonce)
+}
+
+// 登记公钥时需要签名的内容，绑定用户名和新公钥的指纹，证明持有对应私钥
+func (c *Challenge) KeyProofMessage(fingerprint string) []byte {
+	return []byte("fabric-vue-key:" + c.Username + ":" + fingerprint + ":" + c.Nonce)
+}
+
+// 挑战存储，每个 nonce 只能使用一次。
+// dir 为空时保存在内存中，只在签发挑战的实例上有效，多实例部署需要会话保持（sticky session）；
+// dir 不为空时每个挑战保存为目录中的一个文件，多实例共享同一个目录即可，删除文件成功的请求才能使用挑战
+type ChallengeStore struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	dir        string
+	challenges map[string]*Challenge
+}
+
+func NewChallengeStore(ttl time.Duration) *ChallengeStore {
+	return &ChallengeStore{
+		ttl:        ttl,
+		challenges: make(map[string]*Challenge),
+	}
+}
+
+// 保存在共享目录中的挑战存储
+func NewSharedChallengeStore(dir string, ttl time.Duration) (*ChallengeStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("创建挑战目录失败: %w", err)
+	}
+	return &ChallengeStore{ttl: ttl, dir: dir}, nil
+}
+
+// 为用户签发新的挑战
+func (s *ChallengeStore) Issue(username string) (*Challenge, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("生成 nonce 失败: %w", err)
+	}
+
+	now := time.Now()
+	challenge := &Challenge{
+		Username:  username,
+		Nonce:     hex.EncodeToString(buf),
+		ExpiresAt: now.Add(s.ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 顺便清理已过期的挑战
+	s.sweep(now)
+	if s.dir == "" {
+		s.challenges[challenge.Nonce] = challenge
+		return challenge, nil
+	}
+
+	data, err := json.Marshal(challenge)
+	if err != nil {
+		return nil, fmt.Errorf("保存挑战失败: %w", err)
+	}
+	path := s.path(challenge.Nonce)
+	if err := os.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return nil, fmt.Errorf("保存挑战失败: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return nil, fmt.Errorf("保存挑战失败: %w", err)
+	}
+	return challenge, nil
+}
+
+// 清理已过期的挑战，调用方需持有锁
+func (s *ChallengeStore) sweep(now time.Time) {
+	if s.dir == "" {
+		for nonce, c := range s.challenges {
+			if now.After(c.ExpiresAt) {
+				delete(s.challenges, nonce)
+			}
+		}
+		return
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		// 过期时间之后还未删除的文件（包括写到一半的临时文件）一并清理
+		if info, err := entry.Info(); err == nil && now.Sub(info.ModTime()) > s.ttl {
+			os.Remove(filepath.Join(s.dir, entry.Name()))
+		}
+	}
+}
+
+func (s *ChallengeStore) path(nonce string) string {
+	return filepath.Join(s.dir, nonce+".json")
+}
+
+// 读取挑战，remove 为 true 时同时作废；找不到时返回 nil
+func (s *ChallengeStore) load(nonce string, remove bool) *Challenge {
+	if s.dir == "" {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		challenge := s.challenges[nonce]
+		if remove {
+			delete(s.challenges, nonce)
+		}
+		return challenge
+	}
+
+	// nonce 来自客户端，只接受签发时的十六进制格式，避免拼出其他路径
+	if _, err := hex.DecodeString(nonce); err != nil || len(nonce) != 64 {
+		return nil
+	}
+	data, err := os.ReadFile(s.path(nonce))
+	if err != nil {
+		return nil
+	}
+	// 多个实例同时使用同一个挑战时只有一个能删除成功
+	if remove && os.Remove(s.path(nonce)) != nil {
+		return nil
+	}
+	var challenge Challenge
+	if err := json.Unmarshal(data, &challenge); err != nil {
+		return nil
+	}
+	return &challenge
+}
+
+// 取出并作废挑战，无论验证结果如何都不能再次使用
+func (s *ChallengeStore) Consume(username, nonce string) (*Challenge, error) {
+	challenge := s.load(nonce, true)
+	if challenge == nil {
+		return nil, fmt.Errorf("挑战不存在或已被使用")
+	}
+	if challenge.Username != username {
+		return nil, fmt.Errorf("挑战与用户不匹配")
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		return nil, fmt.Errorf("挑战已过期")
+	}
+	return challenge, nil
+}
+
+// 校验挑战但不作废，用于多步流程中的中间步骤
+func (s *ChallengeStore) Check(username, nonce string) error {
+	challenge := s.load(nonce, false)
+	if challenge == nil || challenge.Username != username {
+		return fmt.Errorf("挑战不存在或已被使用")
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		return fmt.Errorf("挑战已过期")
+	}
+	return nil
+}
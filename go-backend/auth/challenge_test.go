@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// 共享目录中的挑战可以由其他实例校验，但只能使用一次
+func TestSharedChallengeStore(t *testing.T) {
+	dir := t.TempDir()
+	a, err := NewSharedChallengeStore(dir, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewSharedChallengeStore(dir, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge, err := a.Issue("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Check("alice", challenge.Nonce); err != nil {
+		t.Fatalf("其他实例校验挑战失败: %v", err)
+	}
+	if err := b.Check("bob", challenge.Nonce); err == nil {
+		t.Error("挑战不应对其他用户有效")
+	}
+	if _, err := b.Consume("alice", challenge.Nonce); err != nil {
+		t.Fatalf("其他实例使用挑战失败: %v", err)
+	}
+	if _, err := a.Consume("alice", challenge.Nonce); err == nil {
+		t.Error("挑战被使用了两次")
+	}
+	if _, err := a.Consume("alice", "../../etc/passwd"); err == nil {
+		t.Error("非法 nonce 应返回错误")
+	}
+}
+
+func TestChallengeExpires(t *testing.T) {
+	for _, store := range []*ChallengeStore{NewChallengeStore(-time.Second), mustShared(t, -time.Second)} {
+		challenge, err := store.Issue("alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Consume("alice", challenge.Nonce); err == nil {
+			t.Error("过期的挑战应返回错误")
+		}
+	}
+}
+
+func mustShared(t *testing.T, ttl time.Duration) *ChallengeStore {
+	store, err := NewSharedChallengeStore(t.TempDir(), ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// 下载链接签名密钥，未配置 DOWNLOAD_URL_SECRET 时与令牌共用密钥并区分用途
+func downloadSecret() []byte {
+	if s := os.Getenv("DOWNLOAD_URL_SECRET"); s != "" {
+		return []byte(s)
+	}
+	return append([]byte("download:"), secret()...)
+}
+
+func downloadSignature(path, username, relation string, expires int64) string {
+	mac := hmac.New(sha256.New, downloadSecret())
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%d", path, username, relation, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// 为 path 签发有效期到 expires 的下载链接参数，链接绑定申请下载的用户及其签发时通过的访问关系
+func SignDownload(path, username, relation string, expires time.Time) url.Values {
+	exp := expires.Unix()
+	return url.Values{
+		"user": {username},
+		"rel":  {relation},
+		"exp":  {strconv.FormatInt(exp, 10)},
+		"sig":  {downloadSignature(path, username, relation, exp)},
+	}
+}
+
+// 校验下载链接，返回申请下载的用户和访问关系
+func VerifyDownload(path string, query url.Values, now time.Time) (username, relation string, err error) {
+	username, relation = query.Get("user"), query.Get("rel")
+	exp, err := strconv.ParseInt(query.Get("exp"), 10, 64)
+	if err != nil || username == "" || relation == "" {
+		return "", "", fmt.Errorf("下载链接参数不完整")
+	}
+	expected := downloadSignature(path, username, relation, exp)
+	if !hmac.Equal([]byte(expected), []byte(query.Get("sig"))) {
+		return "", "", fmt.Errorf("下载链接签名无效")
+	}
+	if now.Unix() > exp {
+		return "", "", fmt.Errorf("下载链接已过期")
+	}
+	return username, relation, nil
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// 解析用户登记的公钥，支持 PEM、JWK、Base64 DER 以及原始 Ed25519 公钥
+func ParsePublicKey(s string) (crypto.PublicKey, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("公钥为空")
+	}
+	if strings.HasPrefix(s, "{") {
+		return parseJWK(s)
+	}
+
+	if block, _ := pem.Decode([]byte(s)); block != nil {
+		switch block.Type {
+		case "PUBLIC KEY":
+			return checkPublicKey(x509.ParsePKIXPublicKey(block.Bytes))
+		case "RSA PUBLIC KEY":
+			return checkPublicKey(x509.ParsePKCS1PublicKey(block.Bytes))
+		default:
+			return nil, fmt.Errorf("不支持的 PEM 类型: %s", block.Type)
+		}
+	}
+
+	// 原始 Ed25519 公钥为 32 字节，其他公钥为 PKIX DER
+	der, err := decodeBytes(s, func(b []byte) bool {
+		if len(b) == ed25519.PublicKeySize {
+			return true
+		}
+		_, err := x509.ParsePKIXPublicKey(b)
+		return err == nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("无法解析公钥编码: %w", err)
+	}
+	if len(der) == ed25519.PublicKeySize {
+		return ed25519.PublicKey(der), nil
+	}
+	return checkPublicKey(x509.ParsePKIXPublicKey(der))
+}
+
+// JWK 格式的公钥（RFC 7517），坐标和模数使用 Base64 URL 编码
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func parseJWK(s string) (crypto.PublicKey, error) {
+	var key jwk
+	if err := json.Unmarshal([]byte(s), &key); err != nil {
+		return nil, fmt.Errorf("解析 JWK 失败: %w", err)
+	}
+	field := func(name, v string) ([]byte, error) {
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(v, "="))
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("JWK 字段 %s 无效", name)
+		}
+		return b, nil
+	}
+
+	switch key.Kty {
+	case "EC":
+		if key.Crv != "P-256" {
+			return nil, fmt.Errorf("仅支持 P-256 曲线的 ECDSA 公钥")
+		}
+		x, err := field("x", key.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := field("y", key.Y)
+		if err != nil {
+			return nil, err
+		}
+		// 转成未压缩点格式，由标准库检查点是否在曲线上
+		point := append([]byte{4}, append(make([]byte, 32-min(len(x), 32)), x...)...)
+		point = append(point, append(make([]byte, 32-min(len(y), 32)), y...)...)
+		pub, err := ecdh.P256().NewPublicKey(point)
+		if err != nil {
+			return nil, fmt.Errorf("JWK 不是有效的 P-256 公钥")
+		}
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return nil, fmt.Errorf("编码公钥失败: %w", err)
+		}
+		return checkPublicKey(x509.ParsePKIXPublicKey(der))
+	case "OKP":
+		if key.Crv != "Ed25519" {
+			return nil, fmt.Errorf("仅支持 Ed25519 的 OKP 公钥")
+		}
+		x, err := field("x", key.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("Ed25519 公钥长度错误")
+		}
+		return ed25519.PublicKey(x), nil
+	case "RSA":
+		n, err := field("n", key.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := field("e", key.E)
+		if err != nil {
+			return nil, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("RSA 公钥指数过大")
+		}
+		return checkPublicKey(&rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil)
+	default:
+		return nil, fmt.Errorf("不支持的 JWK 类型: %s", key.Kty)
+	}
+}
+
+// 只接受 ECDSA P-256、Ed25519 和 RSA 公钥
+func checkPublicKey(key any, err error) (crypto.PublicKey, error) {
+	if err != nil {
+		return nil, fmt.Errorf("解析公钥失败: %w", err)
+	}
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("仅支持 P-256 曲线的 ECDSA 公钥")
+		}
+		return k, nil
+	case ed25519.PublicKey:
+		return k, nil
+	case *rsa.PublicKey:
+		if k.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA 公钥长度不能小于 2048 位")
+		}
+		return k, nil
+	default:
+		return nil, fmt.Errorf("不支持的公钥类型 %T", key)
+	}
+}
+
+// 解码 Base64（标准或 URL 编码）或十六进制字符串。只由 [0-9a-f] 组成的字符串两种解码都可能成功，
+// 先尝试 Base64，解码结果的长度不符合 fits 时再尝试十六进制
+func decodeBytes(s string, fits func([]byte) bool) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	decoded := false
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(s); err == nil {
+			if fits(b) {
+				return b, nil
+			}
+			decoded = true
+		}
+	}
+	if b, err := hex.DecodeString(s); err == nil {
+		if fits(b) {
+			return b, nil
+		}
+		decoded = true
+	}
+	if decoded {
+		return nil, fmt.Errorf("解码后的长度不正确")
+	}
+	return nil, fmt.Errorf("既不是 Base64 也不是十六进制编码")
+}
+
+// 公钥指纹：PKIX DER 编码的 SHA-256，十六进制表示
+func KeyFingerprint(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("编码公钥失败: %w", err)
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// 公钥统一保存为 PEM 格式
+func EncodePublicKeyPEM(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("编码公钥失败: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
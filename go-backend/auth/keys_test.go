@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// 只由 [0-9a-f] 组成的 Base64 签名按 Base64 解码，十六进制签名仍按十六进制解码
+func TestDecodeSignatureEncoding(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hexLike := strings.Repeat("0123456789abcdef", 6)[:86]
+	want, err := base64.RawStdEncoding.DecodeString(hexLike)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeSignature(pub, hexLike)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Base64 签名被按十六进制解码: %d 字节", len(got))
+	}
+
+	message := []byte("fabric-vue-login:alice:nonce")
+	sig := ed25519.Sign(priv, message)
+	for _, encoded := range []string{hex.EncodeToString(sig), base64.StdEncoding.EncodeToString(sig), base64.RawURLEncoding.EncodeToString(sig)} {
+		decoded, err := DecodeSignature(pub, encoded)
+		if err != nil {
+			t.Fatalf("解码 %s 失败: %v", encoded, err)
+		}
+		if _, err := VerifySignature(pub, message, decoded); err != nil {
+			t.Errorf("%s: %v", encoded, err)
+		}
+	}
+
+	if _, err := DecodeSignature(pub, hex.EncodeToString(sig[:32])); err == nil {
+		t.Error("长度不符的签名应返回错误")
+	}
+}
+
+// 原始 Ed25519 公钥的十六进制和 Base64 编码都能解析
+func TestParsePublicKeyRawEd25519(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, encoded := range []string{hex.EncodeToString(pub), base64.StdEncoding.EncodeToString(pub)} {
+		key, err := ParsePublicKey(encoded)
+		if err != nil {
+			t.Fatalf("解析 %s 失败: %v", encoded, err)
+		}
+		if !pub.Equal(key) {
+			t.Errorf("%s 解析结果不一致", encoded)
+		}
+	}
+}
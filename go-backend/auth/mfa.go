@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"backend/jsonfile"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// 恢复码数量
+const recoveryCodeCount = 10
+
+var ErrMFANotEnrolled = errors.New("未绑定二次验证")
+
+// 用户的二次验证信息，密钥只保存在后端，不上链，加密后写入文件
+type MFARecord struct {
+	Username      string    `json:"username"`
+	Secret        string    `json:"secret"`
+	Confirmed     bool      `json:"confirmed"`
+	LastStep      int64     `json:"lastStep"`
+	RecoveryCodes []string  `json:"recoveryCodes"` // 恢复码的 SHA-256 摘要
+	EnrolledAt    time.Time `json:"enrolledAt"`
+}
+
+// 二次验证信息存储
+type MFAStore interface {
+	Get(username string) (*MFARecord, error)
+	Put(record *MFARecord) error
+}
+
+// 基于 JSON 文件的存储
+type FileMFAStore struct {
+	file *jsonfile.Store[MFARecord]
+}
+
+func NewFileMFAStore(path string) *FileMFAStore {
+	return &FileMFAStore{file: jsonfile.New[MFARecord](path, "二次验证数据")}
+}
+
+func (s *FileMFAStore) Get(username string) (*MFARecord, error) {
+	record, ok, err := s.file.Get(username)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrMFANotEnrolled
+	}
+	if record.Secret, err = openMFASecret(record.Username, record.Secret); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// 密钥加密后保存，调用方的记录不变
+func (s *FileMFAStore) Put(record *MFARecord) error {
+	sealed, err := sealMFASecret(record.Username, record.Secret)
+	if err != nil {
+		return err
+	}
+	stored := *record
+	stored.Secret = sealed
+	return s.file.Put(record.Username, &stored)
+}
+
+// 加密保存的 TOTP 密钥前缀，没有前缀的是加密之前保存的明文密钥，下次保存时加密
+const mfaSecretPrefix = "enc:v1:"
+
+// TOTP 密钥的加密密钥，未配置 MFA_SECRET_KEY 时由令牌签名密钥派生并区分用途
+func mfaKey() []byte {
+	if s := os.Getenv("MFA_SECRET_KEY"); s != "" {
+		sum := sha256.Sum256([]byte(s))
+		return sum[:]
+	}
+	sum := sha256.Sum256(append([]byte("mfa:"), secret()...))
+	return sum[:]
+}
+
+func mfaCipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(mfaKey())
+	if err != nil {
+		return nil, fmt.Errorf("初始化二次验证密钥加密失败: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// 使用 AES-GCM 加密 TOTP 密钥，用户名作为附加数据，密文不能挪给其他用户使用
+func sealMFASecret(username, plain string) (string, error) {
+	aead, err := mfaCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("加密二次验证密钥失败: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plain), []byte(username))
+	return mfaSecretPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func openMFASecret(username, stored string) (string, error) {
+	encoded, ok := strings.CutPrefix(stored, mfaSecretPrefix)
+	if !ok {
+		return stored, nil
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("二次验证密钥格式错误: %w", err)
+	}
+	aead, err := mfaCipher()
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("二次验证密钥格式错误")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(username))
+	if err != nil {
+		return "", fmt.Errorf("解密二次验证密钥失败，MFA_SECRET_KEY 或 JWT_SECRET 可能已更换")
+	}
+	return string(plain), nil
+}
+
+// 生成一组恢复码，返回明文（只展示一次）和摘要
+func GenerateRecoveryCodes() ([]string, []string, error) {
+	plain := make([]string, 0, recoveryCodeCount)
+	hashed := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, fmt.Errorf("生成恢复码失败: %w", err)
+		}
+		code := hex.EncodeToString(buf)
+		code = code[:5] + "-" + code[5:]
+		plain = append(plain, code)
+		hashed = append(hashed, hashRecoveryCode(code))
+	}
+	return plain, hashed, nil
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// 使用一个恢复码，成功后该恢复码作废
+func (r *MFARecord) UseRecoveryCode(code string) error {
+	hashed := hashRecoveryCode(code)
+	for i, stored := range r.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hashed)) == 1 {
+			r.RecoveryCodes = append(r.RecoveryCodes[:i], r.RecoveryCodes[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("恢复码错误或已被使用")
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TOTP 密钥加密后写入文件，读取时解密；加密之前保存的明文密钥仍可读取
+func TestFileMFAStoreEncryptsSecret(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-0123456789abcdef0123456789")
+	path := filepath.Join(t.TempDir(), "mfa.json")
+	store := NewFileMFAStore(path)
+
+	record := &MFARecord{Username: "admin", Secret: rfcSecret, Confirmed: true}
+	if err := store.Put(record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Secret != rfcSecret {
+		t.Error("保存时修改了调用方的记录")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), rfcSecret) {
+		t.Fatal("文件中保存了明文密钥")
+	}
+	got, err := store.Get("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Secret != rfcSecret {
+		t.Errorf("解密后的密钥 %s", got.Secret)
+	}
+
+	// 密文不能挪给其他用户
+	sealed, err := sealMFASecret("admin", rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openMFASecret("other", sealed); err == nil {
+		t.Error("其他用户解密成功")
+	}
+
+	// 更换密钥后无法解密
+	t.Setenv("MFA_SECRET_KEY", "another-secret-0123456789abcdef0123")
+	if _, err := store.Get("admin"); err == nil {
+		t.Error("更换密钥后应返回错误")
+	}
+
+	if plain, err := openMFASecret("admin", rfcSecret); err != nil || plain != rfcSecret {
+		t.Errorf("明文密钥读取为 %q, %v", plain, err)
+	}
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+// 签名算法名称
+const (
+	AlgECDSAP256 = "ECDSA-P256-SHA256"
+	AlgEd25519   = "Ed25519"
+	AlgRSAPSS    = "RSA-PSS-SHA256"
+
+	AlgRSAPKCS1v15 = "RSA-PKCS1v15-SHA256"
+)
+
+// 解码客户端提交的签名（Base64 或十六进制），按公钥类型检查签名长度以确定编码
+func DecodeSignature(pub crypto.PublicKey, s string) ([]byte, error) {
+	sig, err := decodeBytes(s, func(b []byte) bool { return signatureFits(pub, b) })
+	if err != nil {
+		return nil, fmt.Errorf("无法解析签名编码: %w", err)
+	}
+	return sig, nil
+}
+
+// 签名长度是否符合公钥类型：ECDSA 为 r||s 的 64 字节或 ASN.1 SEQUENCE，Ed25519 为 64 字节，RSA 与模数等长
+func signatureFits(pub crypto.PublicKey, sig []byte) bool {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		return len(sig) == 64 || len(sig) >= 8 && len(sig) <= 72 && sig[0] == 0x30
+	case ed25519.PublicKey:
+		return len(sig) == ed25519.SignatureSize
+	case *rsa.PublicKey:
+		return len(sig) == k.Size()
+	default:
+		return len(sig) > 0
+	}
+}
+
+// 使用公钥验证签名，返回所用的签名算法
+func VerifySignature(pub crypto.PublicKey, message, sig []byte) (string, error) {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		// WebCrypto 生成的是 r||s 原始格式，OpenSSL 生成的是 ASN.1 格式，两者都接受
+		if len(sig) == 64 {
+			r := new(big.Int).SetBytes(sig[:32])
+			s := new(big.Int).SetBytes(sig[32:])
+			if ecdsa.Verify(k, digest[:], r, s) {
+				return AlgECDSAP256, nil
+			}
+		}
+		if ecdsa.VerifyASN1(k, digest[:], sig) {
+			return AlgECDSAP256, nil
+		}
+		return "", fmt.Errorf("ECDSA 签名验证失败")
+	case ed25519.PublicKey:
+		if ed25519.Verify(k, message, sig) {
+			return AlgEd25519, nil
+		}
+		return "", fmt.Errorf("Ed25519 签名验证失败")
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(message)
+		// WebCrypto 的 RSASSA-PKCS1-v1_5 和 openssl dgst -sign 的默认填充是 PKCS#1 v1.5，两种填充都接受
+		opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto, Hash: crypto.SHA256}
+		if err := rsa.VerifyPSS(k, crypto.SHA256, digest[:], sig, opts); err == nil {
+			return AlgRSAPSS, nil
+		}
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig); err == nil {
+			return AlgRSAPKCS1v15, nil
+		}
+		return "", fmt.Errorf("RSA 签名验证失败，支持 PSS 和 PKCS#1 v1.5 填充")
+	default:
+		return "", fmt.Errorf("不支持的公钥类型 %T", pub)
+	}
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"testing"
+)
+
+// 各类公钥的签名都能验证，并返回实际使用的签名算法
+func TestVerifySignature(t *testing.T) {
+	message := []byte("bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi")
+	digest := sha256.Sum256(message)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecASN1, _ := ecdsa.SignASN1(rand.Reader, ecKey, digest[:])
+	r, s, _ := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+	ecRaw := make([]byte, 64)
+	r.FillBytes(ecRaw[:32])
+	s.FillBytes(ecRaw[32:])
+	pss, _ := rsa.SignPSS(rand.Reader, rsaKey, crypto.SHA256, digest[:], nil)
+	pkcs1, _ := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+
+	cases := []struct {
+		name string
+		pub  crypto.PublicKey
+		sig  []byte
+		want string
+	}{
+		{"ECDSA ASN.1", &ecKey.PublicKey, ecASN1, AlgECDSAP256},
+		{"ECDSA r||s", &ecKey.PublicKey, ecRaw, AlgECDSAP256},
+		{"Ed25519", edPub, ed25519.Sign(edPriv, message), AlgEd25519},
+		{"RSA-PSS", &rsaKey.PublicKey, pss, AlgRSAPSS},
+		{"RSA PKCS#1 v1.5", &rsaKey.PublicKey, pkcs1, AlgRSAPKCS1v15},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			alg, err := VerifySignature(tc.pub, message, tc.sig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if alg != tc.want {
+				t.Errorf("签名算法为 %s，应为 %s", alg, tc.want)
+			}
+			if !signatureFits(tc.pub, tc.sig) {
+				t.Errorf("签名长度不符合公钥类型")
+			}
+			// 签名内容不同时验证失败
+			if _, err := VerifySignature(tc.pub, append([]byte("x"), message...), tc.sig); err == nil {
+				t.Error("修改后的消息应验证失败")
+			}
+		})
+	}
+
+	// 其他 RSA 公钥的签名不能通过
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifySignature(&other.PublicKey, message, pkcs1); err == nil {
+		t.Error("其他公钥的 PKCS#1 v1.5 签名应验证失败")
+	}
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// 令牌有效期
+const TokenTTL = 8 * time.Hour
+
+// 令牌中携带的用户信息
+type TokenUser struct {
+	Username     string `json:"username"`
+	Organization string `json:"organization"`
+	IsAdmin      bool   `json:"isAdmin"`
+}
+
+type Claims struct {
+	User TokenUser `json:"user"`
+	MFA  bool      `json:"mfa,omitempty"` // 本次登录是否通过了二次验证
+	jwt.RegisteredClaims
+}
+
+// HMAC 密钥的最小长度
+const MinSecretSize = 32
+
+// 签名密钥来自 JWT_SECRET，启动时由 CheckSecrets 校验
+func secret() []byte {
+	return []byte(os.Getenv("JWT_SECRET"))
+}
+
+// 启动时检查签名密钥，未配置或长度不足时拒绝启动，避免使用可猜测的密钥签发令牌和下载链接
+func CheckSecrets() error {
+	if n := len(secret()); n < MinSecretSize {
+		return fmt.Errorf("JWT_SECRET 未配置或长度不足 %d 字节（当前 %d 字节）", MinSecretSize, n)
+	}
+	for _, name := range []string{"DOWNLOAD_URL_SECRET", "MFA_SECRET_KEY"} {
+		if s := os.Getenv(name); s != "" && len(s) < MinSecretSize {
+			return fmt.Errorf("%s 长度不足 %d 字节", name, MinSecretSize)
+		}
+	}
+	return nil
+}
+
+// 签发 JWT
+func IssueToken(user TokenUser, mfa bool) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		User: user,
+		MFA:  mfa,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.Username,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(TokenTTL)),
+		},
+	})
+	signed, err := token.SignedString(secret())
+	if err != nil {
+		return "", fmt.Errorf("签发令牌失败: %w", err)
+	}
+	return signed, nil
+}
+
+// 解析并校验 JWT
+func ParseToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("不支持的签名算法 %v", t.Header["alg"])
+		}
+		return secret(), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("令牌无效: %w", err)
+	}
+	return claims, nil
+}
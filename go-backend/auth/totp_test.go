@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录 B 的 SHA1 测试向量，取后 6 位
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // "12345678901234567890"
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	cases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, c := range cases {
+		code, err := totpCode(rfcSecret, c.unix/totpPeriod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != c.code {
+			t.Errorf("T=%d: 验证码 %s, 期望 %s", c.unix, code, c.code)
+		}
+	}
+}
+
+// 前后各一个步长内的验证码有效，超出窗口或已使用的步长无效
+func TestVerifyTOTPWindow(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	current := now.Unix() / totpPeriod
+	cases := []struct {
+		name     string
+		offset   int64
+		lastStep int64
+		ok       bool
+	}{
+		{"当前步长", 0, 0, true},
+		{"上一个步长", -1, 0, true},
+		{"下一个步长", 1, 0, true},
+		{"早两个步长", -2, 0, false},
+		{"晚两个步长", 2, 0, false},
+		{"已使用的步长", 0, current, false},
+		{"早于已使用的步长", -1, current, false},
+		{"晚于已使用的步长", 1, current, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			code, err := totpCode(rfcSecret, current+c.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			step, err := VerifyTOTP(rfcSecret, code, now, c.lastStep)
+			if c.ok {
+				if err != nil {
+					t.Fatalf("验证失败: %v", err)
+				}
+				if step != current+c.offset {
+					t.Errorf("返回步长 %d, 期望 %d", step, current+c.offset)
+				}
+			} else if err == nil {
+				t.Error("应返回错误")
+			}
+		})
+	}
+
+	for _, code := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, err := VerifyTOTP(rfcSecret, code, now, 0); err == nil {
+			t.Errorf("验证码 %q 应返回错误", code)
+		}
+	}
+}
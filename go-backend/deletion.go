@@ -0,0 +1,183 @@
+package main
+
+import (
+	invoke_fabric "backend/fabric-go/call"
+	connect_fabric "backend/fabric-go/network"
+	"backend/middleware"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 链码返回的原始记录是否已被删除
+func isDeletedRecord(record map[string]interface{}) bool {
+	deletedAt, _ := record["deletedAt"].(string)
+	return deletedAt != ""
+}
+
+// 删除用户时的关联清理结果
+type userCascade struct {
+	CancelledTasks []string `json:"cancelledTasks"` // 取消的发布任务
+	RefundedTasks  []string `json:"refundedTasks"`  // 已退还托管余额的发布任务
+	WithdrawnTasks []string `json:"withdrawnTasks"` // 退出的参与任务，不收取罚金
+	PendingTasks   []string `json:"pendingTasks"`   // 出错时尚未处理完的任务，重新删除时从链上状态继续
+}
+
+// 需要清理的任务：用户发布的未结束或未退款的任务，以及用户参与的未结束任务
+func cascadeTasks(tasks []invoke_fabric.Task, username string) []*invoke_fabric.Task {
+	var pending []*invoke_fabric.Task
+	for i := range tasks {
+		task := &tasks[i]
+		if task.Deleted() {
+			continue
+		}
+		closed := invoke_fabric.TaskClosed(task.State())
+		switch {
+		case task.PostedUser == username:
+			if !closed || task.EscrowReleasedAt == "" {
+				pending = append(pending, task)
+			}
+		case !closed && slices.Contains(task.AcceptedUsers, username):
+			pending = append(pending, task)
+		}
+	}
+	return pending
+}
+
+// 取消用户发布的未结束任务并退还托管，与删除任务使用相同的结算检查和退款规则；
+// 退出参与的未结束任务，已结束的任务保留参与记录作为历史。
+// 每个任务的操作都按链上当前状态决定，中途失败时已完成的部分不会回滚，
+// 返回的 PendingTasks 列出尚未处理完的任务，重新删除会跳过已完成的步骤继续处理
+func cascadeUserDeletion(contract *client.Contract, username, admin string) (*userCascade, error) {
+	cascade := &userCascade{CancelledTasks: []string{}, RefundedTasks: []string{}, WithdrawnTasks: []string{}, PendingTasks: []string{}}
+	tasks, err := invoke_fabric.ListTasks(contract)
+	if err != nil {
+		return cascade, err
+	}
+	pending := cascadeTasks(tasks, username)
+	for _, task := range pending {
+		cascade.PendingTasks = append(cascade.PendingTasks, task.TaskID)
+	}
+
+	// 先检查全部发布的任务，有任务未结清时不做任何修改
+	for _, task := range pending {
+		if task.PostedUser != username {
+			continue
+		}
+		if err := checkTaskDeletable(contract, task); err != nil {
+			return cascade, err
+		}
+		state := task.State()
+		if !invoke_fabric.TaskClosed(state) {
+			if err := invoke_fabric.CheckTaskTransition(state, invoke_fabric.TaskCancelled, invoke_fabric.TaskRoleAdmin); err != nil {
+				return cascade, fmt.Errorf("任务 %s: %w", task.TaskID, err)
+			}
+		}
+	}
+
+	for _, task := range pending {
+		if err := cascadeTask(contract, cascade, task, username, admin); err != nil {
+			return cascade, fmt.Errorf("任务 %s: %w", task.TaskID, err)
+		}
+		cascade.PendingTasks = cascade.PendingTasks[1:]
+	}
+	return cascade, nil
+}
+
+// 清理一个任务
+func cascadeTask(contract *client.Contract, cascade *userCascade, task *invoke_fabric.Task, username, admin string) error {
+	state := task.State()
+	if task.PostedUser != username {
+		// 进行中的任务最后一个参与者退出后由链码在同一交易中重新开放
+		if err := invoke_fabric.WithdrawFromTask(contract, task.TaskID, username, 0); err != nil {
+			return err
+		}
+		cascade.WithdrawnTasks = append(cascade.WithdrawnTasks, task.TaskID)
+		return nil
+	}
+
+	if !invoke_fabric.TaskClosed(state) {
+		if err := invoke_fabric.TransitionTask(contract, task.TaskID, state, invoke_fabric.TaskCancelled, admin, "发布者已被删除"); err != nil {
+			return err
+		}
+		task.Status = invoke_fabric.TaskCancelled
+		cascade.CancelledTasks = append(cascade.CancelledTasks, task.TaskID)
+	}
+	// 之前取消或过期但退款未完成的任务一并退还
+	switch task.State() {
+	case invoke_fabric.TaskCancelled, invoke_fabric.TaskExpired:
+		if err := refundDeletedTask(contract, task); err != nil {
+			return err
+		}
+		cascade.RefundedTasks = append(cascade.RefundedTasks, task.TaskID)
+	}
+	return nil
+}
+
+// 恢复已删除的用户，删除时取消的任务和退出的参与不会恢复
+func restore_user(ctx *gin.Context) {
+	var request struct {
+		Username string `json:"username"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	user, err := invoke_fabric.Get_one_User(contract, request.Username)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("查询用户失败: %s", err.Error())})
+		return
+	}
+	if !user.Deleted() {
+		ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("用户 %s 没有被删除", user.Username)})
+		return
+	}
+
+	if err := invoke_fabric.RestoreUser(contract, user.Username, middleware.CurrentUser(ctx).User.Username); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("用户 %s 已恢复", user.Username)})
+}
+
+// 恢复已删除的任务，任务保持删除前的状态
+func restore_task(ctx *gin.Context) {
+	var request struct {
+		TaskID string `json:"taskId"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	task, err := invoke_fabric.QueryTask(contract, request.TaskID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("获取任务数据失败: %s", err.Error())})
+		return
+	}
+	if !task.Deleted() {
+		ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("任务 %s 没有被删除", task.TaskID)})
+		return
+	}
+
+	if err := invoke_fabric.RestoreTask(contract, task.TaskID, middleware.CurrentUser(ctx).User.Username); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("任务 %s 已恢复", task.TaskID),
+		"status":  task.State(),
+	})
+}
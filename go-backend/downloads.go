@@ -0,0 +1,319 @@
+package main
+
+import (
+	"backend/audit"
+	"backend/auth"
+	"backend/envelope"
+	invoke_fabric "backend/fabric-go/call"
+	connect_fabric "backend/fabric-go/network"
+	"backend/middleware"
+	"backend/storage"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 模型下载审计日志，保存在账本上
+var downloadAudit audit.Log = audit.NewLedgerLog(func() *client.Contract { return connect_fabric.GetContract(defaultConfig) })
+
+// 下载者与模型的关系
+const (
+	relationOwner       = "owner"       // 模型上传者
+	relationAdmin       = "admin"       // 完成 MFA 的管理员
+	relationPoster      = "poster"      // 模型所属任务的发布者（聚合方）
+	relationParticipant = "participant" // 以该模型为初始模型的任务的参与者
+	relationRecipient   = "recipient"   // 加密模型的接收者，如上传者指定的聚合方
+)
+
+// 下载方式
+const (
+	downloadDirect    = "direct"     // download_model 直接下载
+	downloadSignedURL = "signed-url" // 通过签名下载链接
+	downloadCID       = "cid"        // get_model_cid 查询 CID
+)
+
+// 判断用户能否下载模型，返回访问关系，无权访问时返回空字符串。
+// 提交到任务的模型只有上传者、任务发布者和管理员可见；任务的初始模型对参与者可见；
+// 加密模型对信封中的接收者可见，否则指定的聚合方拿到数据密钥也下载不了密文
+func modelAccess(contract *client.Contract, claims *auth.Claims, model *invoke_fabric.Model) (string, error) {
+	username := claims.User.Username
+	if model.Modelowner == username {
+		return relationOwner, nil
+	}
+	if claims.User.IsAdmin && claims.MFA {
+		return relationAdmin, nil
+	}
+
+	tasks, err := invoke_fabric.ListTasks(contract)
+	if err != nil {
+		return "", fmt.Errorf("查询任务失败: %w", err)
+	}
+	relation := ""
+	for _, task := range tasks {
+		if task.Deleted() {
+			continue
+		}
+		isRoot := task.RootModelId == model.Modelid
+		if task.PostedUser == username && (isRoot || slices.Contains(task.Models, model.Modelid)) {
+			return relationPoster, nil
+		}
+		if isRoot && slices.Contains(task.AcceptedUsers, username) {
+			relation = relationParticipant
+		}
+	}
+	if relation != "" {
+		return relation, nil
+	}
+
+	env, err := modelKeys.Get(model.Modelhash)
+	if errors.Is(err, envelope.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("查询模型信封失败: %w", err)
+	}
+	if len(env.For(username)) > 0 {
+		return relationRecipient, nil
+	}
+	return "", nil
+}
+
+// 记录一次下载，审计日志写入失败只打印错误，不影响已经完成的响应
+func recordDownload(entry audit.Download) {
+	entry.Time = time.Now().UTC()
+	if err := downloadAudit.Append(entry); err != nil {
+		fmt.Printf("记录模型下载失败: %s\n", err.Error())
+	}
+}
+
+// 读取模型并检查当前用户的访问权限，失败时已写入响应并记录审计
+func authorizeModel(ctx *gin.Context, modelID, via string) (*invoke_fabric.Model, string, bool) {
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+	claims := middleware.CurrentUser(ctx)
+	entry := audit.Download{Username: claims.User.Username, ModelID: modelID, Via: via, IP: ctx.ClientIP()}
+
+	model, err := invoke_fabric.ReadModel(contract, modelID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("获取模型失败: %s", err.Error())})
+		return nil, "", false
+	}
+	relation, err := modelAccess(contract, claims, model)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, "", false
+	}
+	if relation == "" {
+		entry.CID, entry.Result, entry.Error = model.Modelhash, audit.DownloadDenied, "无访问关系"
+		recordDownload(entry)
+		ctx.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("无权下载模型 %s", modelID)})
+		return nil, "", false
+	}
+	return model, relation, true
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// 从模型所在存储读取文件写入响应，结束后记录审计
+func streamModel(ctx *gin.Context, model *invoke_fabric.Model, entry audit.Download) {
+	entry.ModelID, entry.CID = model.Modelid, model.Modelhash
+	fail := func(status int, msg string) {
+		entry.Result, entry.Error = audit.DownloadFailed, msg
+		recordDownload(entry)
+		ctx.JSON(status, gin.H{"error": msg})
+	}
+
+	backend, err := modelStorage.Get(model.Storage)
+	if err != nil {
+		fail(http.StatusInternalServerError, err.Error())
+		return
+	}
+	body, err := backend.Get(ctx.Request.Context(), model.ContentCID())
+	if errors.Is(err, storage.ErrNotFound) {
+		fail(http.StatusNotFound, fmt.Sprintf("模型 %s 的文件不在 %s 存储中", model.Modelid, backend.Scheme()))
+		return
+	}
+	if err != nil {
+		fail(http.StatusBadGateway, fmt.Sprintf("读取模型文件失败: %s", err.Error()))
+		return
+	}
+	defer body.Close()
+
+	headers := map[string]string{
+		"Content-Disposition": fmt.Sprintf(`attachment; filename="%s"`, model.Modelid),
+		"Cache-Control":       "no-store",
+		"X-Model-CID":         model.Modelhash,
+		"X-Model-Storage":     backend.Scheme(),
+	}
+	// 加密模型返回密文，接收者用 get_model_key 取得数据密钥后解密
+	if env, err := modelKeys.Get(model.Modelhash); err == nil {
+		headers["X-Model-Encryption"] = env.Cipher
+	}
+	counter := &countingReader{r: body}
+	ctx.DataFromReader(http.StatusOK, -1, "application/octet-stream", counter, headers)
+
+	// 响应头已发出，中途失败只能记录
+	entry.Bytes = counter.n
+	entry.Result = audit.DownloadOK
+	if err := ctx.Request.Context().Err(); err != nil {
+		entry.Result, entry.Error = audit.DownloadFailed, err.Error()
+	}
+	recordDownload(entry)
+}
+
+// 下载模型文件，只有模型上传者、相关任务的发布者和参与者以及管理员可以下载
+func download_model(ctx *gin.Context) {
+	var request struct {
+		ModelID string `json:"modelID"`
+	}
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	model, relation, ok := authorizeModel(ctx, request.ModelID, downloadDirect)
+	if !ok {
+		return
+	}
+	streamModel(ctx, model, audit.Download{
+		Username: middleware.CurrentUser(ctx).User.Username,
+		Relation: relation,
+		Via:      downloadDirect,
+		IP:       ctx.ClientIP(),
+	})
+}
+
+func signedDownloadPath(modelID string) string {
+	return "/download/" + url.PathEscape(modelID)
+}
+
+// 签发短期有效的模型下载链接，链接可以直接交给浏览器或下载工具使用
+func download_url(ctx *gin.Context) {
+	var request struct {
+		ModelID string `json:"modelID"`
+	}
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	model, relation, ok := authorizeModel(ctx, request.ModelID, downloadSignedURL)
+	if !ok {
+		return
+	}
+	path := signedDownloadPath(model.Modelid)
+	expiresAt := time.Now().Add(modelTransfer.DownloadURLTTL)
+	query := auth.SignDownload(path, middleware.CurrentUser(ctx).User.Username, relation, expiresAt)
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":   "下载链接已生成",
+		"url":       path + "?" + query.Encode(),
+		"expiresAt": expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// 通过签名链接下载模型，权限在签发链接时已经检查
+func download_signed(ctx *gin.Context) {
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	modelID := ctx.Param("modelID")
+	username, relation, err := auth.VerifyDownload(signedDownloadPath(modelID), ctx.Request.URL.Query(), time.Now())
+	if err != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	model, err := invoke_fabric.ReadModel(contract, modelID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("获取模型失败: %s", err.Error())})
+		return
+	}
+	streamModel(ctx, model, audit.Download{
+		Username: username,
+		Relation: relation,
+		Via:      downloadSignedURL,
+		IP:       ctx.ClientIP(),
+	})
+}
+
+// 获取模型的 CID，拿到 CID 即可从 IPFS 取回文件，因此与下载使用相同的权限检查
+func get_model_cid(ctx *gin.Context) {
+	var request struct {
+		ModelID string `json:"modelID"`
+	}
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	model, relation, ok := authorizeModel(ctx, request.ModelID, downloadCID)
+	if !ok {
+		return
+	}
+	recordDownload(audit.Download{
+		Username: middleware.CurrentUser(ctx).User.Username,
+		ModelID:  model.Modelid,
+		CID:      model.Modelhash,
+		Relation: relation,
+		Via:      downloadCID,
+		IP:       ctx.ClientIP(),
+		Result:   audit.DownloadOK,
+	})
+
+	// 返回模型的 CID
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":   "模型获取成功",
+		"cid":       model.Modelhash,
+		"storage":   model.StorageScheme(),
+		"encrypted": isEncrypted(model.Modelhash),
+	})
+}
+
+// 查询模型下载审计记录，按时间倒序
+func get_download_audit(ctx *gin.Context) {
+	var request struct {
+		ModelID  string `json:"modelID"`
+		Username string `json:"username"`
+		Limit    int    `json:"limit"`
+	}
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+	if request.Limit <= 0 || request.Limit > 1000 {
+		request.Limit = 100
+	}
+
+	entries, err := downloadAudit.Query(audit.Filter{ModelID: request.ModelID, Username: request.Username, Limit: request.Limit})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":   "查询成功",
+		"downloads": entries,
+	})
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	invoke_fabric "backend/fabric-go/call"
+	connect_fabric "backend/fabric-go/network"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 用户的信誉：在已完成的任务中从托管账户获得过奖励的任务数，按链上转账记录计算
+func reputationOf(contract *client.Contract, username string) (int, error) {
+	transfers, err := invoke_fabric.GetTransferHistory(contract, username)
+	if err != nil {
+		return 0, err
+	}
+	rewarded := make(map[string]bool)
+	for _, t := range transfers {
+		credit, ok := t.PostingFor(username)
+		if !ok || credit.Amount <= 0 {
+			continue
+		}
+		for _, p := range t.Postings {
+			if taskID, ok := invoke_fabric.EscrowTaskID(p.Account); ok && p.Amount < 0 {
+				rewarded[taskID] = true
+			}
+		}
+	}
+
+	reputation := 0
+	for taskID := range rewarded {
+		task, err := invoke_fabric.QueryTask(contract, taskID)
+		if err != nil {
+			return 0, err
+		}
+		// 发布者收到的是退款，不计入信誉
+		if task.State() == invoke_fabric.TaskCompleted && task.PostedUser != username && slices.Contains(task.AcceptedUsers, username) {
+			reputation++
+		}
+	}
+	return reputation, nil
+}
+
+// 检查用户能否接受任务，返回所有不满足的条件
+func acceptRejections(contract *client.Contract, task *invoke_fabric.Task, user *invoke_fabric.User, now time.Time) ([]string, error) {
+	var reasons []string
+	rules := task.Rules
+
+	if user.Deleted() {
+		reasons = append(reasons, "账号已被删除")
+	}
+	if !invoke_fabric.TaskAcceptsParticipants(task.State()) {
+		reasons = append(reasons, fmt.Sprintf("任务处于 %s 状态，不接受新的参与者", task.State()))
+	}
+	if task.AcceptClosed(now) {
+		reasons = append(reasons, fmt.Sprintf("任务已于 %s 停止接受", task.AcceptDeadline))
+	}
+	if task.PostedUser == user.Username {
+		reasons = append(reasons, "不能接受自己发布的任务")
+	}
+	if slices.Contains(task.AcceptedUsers, user.Username) || slices.Contains(user.Accepted, task.TaskID) {
+		reasons = append(reasons, "已经接受了该任务")
+	}
+	if rules.MaxParticipants > 0 && len(task.AcceptedUsers) >= rules.MaxParticipants {
+		reasons = append(reasons, fmt.Sprintf("参与人数已达上限 %d", rules.MaxParticipants))
+	}
+	if len(rules.AllowedOrgs) > 0 && !slices.Contains(rules.AllowedOrgs, user.Organization) {
+		reasons = append(reasons, fmt.Sprintf("组织 %s 不在允许范围内", user.Organization))
+	}
+	if rules.InviteOnly && !slices.Contains(rules.Invitees, user.Username) {
+		reasons = append(reasons, "任务仅限受邀用户参与")
+	}
+	if rules.MinBalance > 0 && user.Token < rules.MinBalance {
+		reasons = append(reasons, fmt.Sprintf("账户余额 %d 低于要求的 %d", user.Token, rules.MinBalance))
+	}
+	if rules.MinReputation > 0 {
+		reputation, err := reputationOf(contract, user.Username)
+		if err != nil {
+			return nil, fmt.Errorf("查询信誉失败: %w", err)
+		}
+		if reputation < rules.MinReputation {
+			reasons = append(reasons, fmt.Sprintf("信誉 %d 低于要求的 %d", reputation, rules.MinReputation))
+		}
+	}
+	return reasons, nil
+}
+
+// 任务发布者修改接受限制
+func set_task_rules(ctx *gin.Context) {
+	var request struct {
+		TaskID string                  `json:"taskId"`
+		Rules  invoke_fabric.TaskRules `json:"rules"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	if err := request.Rules.Validate(); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	task, ok := posterTask(ctx, contract, request.TaskID)
+	if !ok {
+		return
+	}
+	// 未填写退出罚金时保留原值；参与者按接受时的罚金加入任务，之后不能修改
+	if request.Rules.LeavePenalty == nil {
+		request.Rules.LeavePenalty = task.Rules.LeavePenalty
+	}
+	if len(task.AcceptedUsers) > 0 && request.Rules.Penalty() != task.Rules.Penalty() {
+		ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("任务 %s 已有用户接受，退出罚金不能再修改", task.TaskID)})
+		return
+	}
+	if request.Rules.MaxParticipants > 0 && request.Rules.MaxParticipants < len(task.AcceptedUsers) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("已有 %d 人参与，人数上限不能低于该值", len(task.AcceptedUsers))})
+		return
+	}
+
+	if err := invoke_fabric.SetTaskRules(contract, task.TaskID, request.Rules); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("任务 %s 的接受限制已更新", task.TaskID),
+		"rules":   request.Rules,
+	})
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"backend/audit"
+	"backend/auth"
+	"backend/envelope"
+	invoke_fabric "backend/fabric-go/call"
+	connect_fabric "backend/fabric-go/network"
+	"backend/middleware"
+	"backend/storage"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 加密模型的信封，按密文 CID 在账本上保存封装后的数据密钥
+var modelKeys envelope.Store = envelope.NewLedgerStore(func() *client.Contract { return connect_fabric.GetContract(defaultConfig) })
+
+// 查询模型数据密钥的审计标记
+const downloadKey = "key"
+
+// 加密选项，来自 upload_model_file 的查询参数或可续传上传的元数据
+type modelEncryption struct {
+	TaskID      string   // 加密给该任务的发布者
+	Aggregators []string // 额外的接收者
+}
+
+func parseModelEncryption(taskID, aggregators string) *modelEncryption {
+	taskID = strings.TrimSpace(taskID)
+	if taskID == "" {
+		return nil
+	}
+	enc := &modelEncryption{TaskID: taskID}
+	for _, name := range strings.Split(aggregators, ",") {
+		if name = strings.TrimSpace(name); name != "" && !slices.Contains(enc.Aggregators, name) {
+			enc.Aggregators = append(enc.Aggregators, name)
+		}
+	}
+	return enc
+}
+
+type modelRecipient struct {
+	Username string
+	Key      *invoke_fabric.PublicKeyRecord
+}
+
+// 确定加密模型的接收者：上传者本人、任务发布者和指定的聚合方，各自使用当前公钥
+func modelRecipients(contract *client.Contract, owner string, enc *modelEncryption) ([]modelRecipient, error) {
+	task, err := invoke_fabric.QueryTask(contract, enc.TaskID)
+	if err != nil {
+		return nil, fmt.Errorf("查询任务失败: %w", err)
+	}
+	if task.Deleted() {
+		return nil, fmt.Errorf("任务 %s 已删除", enc.TaskID)
+	}
+	if task.PostedUser != owner && !slices.Contains(task.AcceptedUsers, owner) {
+		return nil, fmt.Errorf("只能为自己发布或参与的任务加密模型")
+	}
+
+	var recipients []modelRecipient
+	for _, username := range append([]string{owner, task.PostedUser}, enc.Aggregators...) {
+		if slices.ContainsFunc(recipients, func(r modelRecipient) bool { return r.Username == username }) {
+			continue
+		}
+		user, err := invoke_fabric.Get_one_User(contract, username)
+		if err != nil {
+			return nil, fmt.Errorf("查询用户 %s 失败: %w", username, err)
+		}
+		fillKeyFingerprints(user)
+		key := user.CurrentKey()
+		if key == nil || key.Fingerprint == "" {
+			return nil, fmt.Errorf("用户 %s 未登记有效公钥，无法为其加密", username)
+		}
+		recipients = append(recipients, modelRecipient{Username: username, Key: key})
+	}
+	return recipients, nil
+}
+
+// 生成数据密钥并为每个接收者封装，返回信封（CID 在写入存储后填写）和明文数据密钥
+func newModelEnvelope(owner string, enc *modelEncryption, recipients []modelRecipient) (*envelope.Envelope, []byte, error) {
+	key, err := envelope.GenerateDataKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	env := &envelope.Envelope{
+		Owner:       owner,
+		TaskID:      enc.TaskID,
+		Aggregators: enc.Aggregators,
+		Cipher:      envelope.Cipher,
+		CreatedAt:   time.Now().UTC(),
+	}
+	for _, r := range recipients {
+		pub, err := auth.ParsePublicKey(r.Key.PublicKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("用户 %s 的公钥无效: %w", r.Username, err)
+		}
+		wrapped, err := envelope.Wrap(pub, r.Key.Fingerprint, key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("为用户 %s 封装数据密钥失败: %w", r.Username, err)
+		}
+		wrapped.Username = r.Username
+		env.Recipients = append(env.Recipients, *wrapped)
+	}
+	return env, key, nil
+}
+
+// 用 put 把模型写入加密后的 backend 并保存信封，数据密钥用完即清除
+func putEncrypted(contract *client.Contract, owner string, enc *modelEncryption, backend storage.Backend, put func(storage.Backend) (*storage.Object, error)) (*storage.Object, error) {
+	recipients, err := modelRecipients(contract, owner, enc)
+	if err != nil {
+		return nil, err
+	}
+	env, key, err := newModelEnvelope(owner, enc, recipients)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(key)
+
+	obj, err := put(envelope.Encrypting(backend, key))
+	if err != nil {
+		return nil, err
+	}
+	// 并发完成同一个上传时，另一个请求已经加密并保存了信封
+	env.CID, env.Architecture = obj.CID, obj.Architecture
+	if err := modelKeys.Put(env); errors.Is(err, envelope.ErrExists) {
+		return obj, nil
+	} else if err != nil {
+		return nil, err
+	}
+	fmt.Printf("模型 %s 已加密给任务 %s 的 %d 个接收者\n", obj.CID, enc.TaskID, len(env.Recipients))
+	return obj, nil
+}
+
+// 模型文件是否加密保存
+func isEncrypted(cid string) bool {
+	_, err := modelKeys.Get(cid)
+	return err == nil
+}
+
+// 查询封装给当前用户的数据密钥，用自己的私钥解开后即可解密下载的模型文件
+func get_model_key(ctx *gin.Context) {
+	var request struct {
+		ModelID string `json:"modelID"`
+	}
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	model, relation, ok := authorizeModel(ctx, request.ModelID, downloadKey)
+	if !ok {
+		return
+	}
+	env, err := modelKeys.Get(model.Modelhash)
+	if errors.Is(err, envelope.ErrNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("模型 %s 未加密", model.Modelid)})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	username := middleware.CurrentUser(ctx).User.Username
+	entry := audit.Download{
+		Username: username,
+		ModelID:  model.Modelid,
+		CID:      model.Modelhash,
+		Relation: relation,
+		Via:      downloadKey,
+		IP:       ctx.ClientIP(),
+		Result:   audit.DownloadOK,
+	}
+	keys := env.For(username)
+	if len(keys) == 0 {
+		entry.Result, entry.Error = audit.DownloadDenied, "不是加密接收者"
+		recordDownload(entry)
+		ctx.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("模型 %s 没有加密给你", model.Modelid)})
+		return
+	}
+	recordDownload(entry)
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":    "查询成功",
+		"modelId":    model.Modelid,
+		"cid":        env.CID,
+		"cipher":     env.Cipher,
+		"recipients": keys,
+	})
+}
+
+// 命令行解密模型文件：decrypt-model -key 私钥.pem -envelope get_model_key的返回.json -in 密文 -out 明文
+func runDecryptModelCommand(args []string) int {
+	flags := flag.NewFlagSet("decrypt-model", flag.ContinueOnError)
+	keyPath := flags.String("key", "", "私钥 PEM 文件")
+	envPath := flags.String("envelope", "", "get_model_key 返回的 JSON")
+	in := flags.String("in", "", "下载的加密模型文件")
+	out := flags.String("out", "", "解密后的输出文件")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if err := decryptModelFile(*keyPath, *envPath, *in, *out); err != nil {
+		fmt.Fprintf(os.Stderr, "解密失败: %v\n", err)
+		return 1
+	}
+	fmt.Printf("已解密到 %s\n", *out)
+	return 0
+}
+
+func decryptModelFile(keyPath, envPath, in, out string) error {
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return err
+	}
+	priv, pub, err := envelope.ParsePrivateKey(keyPEM)
+	if err != nil {
+		return err
+	}
+	fingerprint, err := auth.KeyFingerprint(pub)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(envPath)
+	if err != nil {
+		return err
+	}
+	var env struct {
+		Recipients []envelope.WrappedKey `json:"recipients"`
+	}
+	if err := json.Unmarshal(data, &env); err != nil {
+		return fmt.Errorf("解析信封失败: %w", err)
+	}
+	i := slices.IndexFunc(env.Recipients, func(k envelope.WrappedKey) bool { return k.Fingerprint == fingerprint })
+	if i < 0 {
+		return fmt.Errorf("信封中没有封装给公钥 %s 的数据密钥", fingerprint)
+	}
+	key, err := envelope.Unwrap(priv, &env.Recipients[i])
+	if err != nil {
+		return err
+	}
+	defer clear(key)
+
+	src, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := envelope.Decrypt(dst, src, key); err != nil {
+		dst.Close()
+		os.Remove(out)
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,107 @@
+package envelope
+
+import (
+	invoke_fabric "backend/fabric-go/call"
+	"backend/inspect"
+	"backend/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+var (
+	ErrNotFound = errors.New("模型未加密")
+	ErrExists   = errors.New("模型的信封已存在")
+)
+
+// 加密模型的信封：数据密钥只以封装形式保存，后端不保留明文密钥
+type Envelope struct {
+	CID         string       `json:"cid"` // 密文的 CID
+	Owner       string       `json:"owner"`
+	TaskID      string       `json:"taskId"`                // 加密给该任务的发布者
+	Aggregators []string     `json:"aggregators,omitempty"` // 上传者指定的额外接收者
+	Cipher      string       `json:"cipher"`
+	Recipients  []WrappedKey `json:"recipients"`
+	CreatedAt   time.Time    `json:"createdAt"`
+
+	Architecture *inspect.Architecture `json:"architecture,omitempty"` // 上传时解析的明文结构，加密后无法再解析
+}
+
+// 封装给某个用户的数据密钥，用户更换过公钥时可能有多条
+func (e *Envelope) For(username string) []WrappedKey {
+	var keys []WrappedKey
+	for _, k := range e.Recipients {
+		if k.Username == username {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// 信封存储，按密文 CID 索引；模型迁移到其他存储后 CID 不变，信封仍然有效
+type Store interface {
+	// 模型未加密时返回 ErrNotFound
+	Get(cid string) (*Envelope, error)
+	// 同一 CID 的信封不会被覆盖，已存在时返回 ErrExists
+	Put(envelope *Envelope) error
+}
+
+// 保存在账本上的信封，所有后端实例共用
+type LedgerStore struct {
+	contract func() *client.Contract
+}
+
+func NewLedgerStore(contract func() *client.Contract) *LedgerStore {
+	return &LedgerStore{contract: contract}
+}
+
+func (s *LedgerStore) Get(cid string) (*Envelope, error) {
+	data, err := invoke_fabric.ReadModelEnvelope(s.contract(), cid)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, ErrNotFound
+	}
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("解析模型信封失败: %w", err)
+	}
+	return &envelope, nil
+}
+
+func (s *LedgerStore) Put(envelope *Envelope) error {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("序列化模型信封失败: %w", err)
+	}
+	created, err := invoke_fabric.CreateModelEnvelope(s.contract(), envelope.CID, data)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return ErrExists
+	}
+	return nil
+}
+
+// 写入时加密的存储，读取和查询返回密文
+type encryptingBackend struct {
+	storage.Backend
+	key []byte
+}
+
+func Encrypting(backend storage.Backend, key []byte) storage.Backend {
+	return encryptingBackend{Backend: backend, key: key}
+}
+
+func (b encryptingBackend) Put(ctx context.Context, r io.Reader, name string) (*storage.Object, error) {
+	ciphertext := EncryptReader(r, b.key)
+	defer ciphertext.Close()
+	return b.Backend.Put(ctx, ciphertext, name)
+}
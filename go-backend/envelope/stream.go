@@ -0,0 +1,172 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 模型文件的加密格式：文件头 + 按 64KiB 分块的 AES-256-GCM 密文。
+// 文件头为 6 字节魔数和 7 字节随机 nonce 前缀，每块的 nonce 为前缀、4 字节块序号和 1 字节末块标记，
+// 文件头作为每块的附加数据，分块被调换、截断或追加时解密失败
+const (
+	Cipher    = "AES-256-GCM-STREAM-64K"
+	ChunkSize = 64 * 1024
+	KeySize   = 32
+
+	prefixSize = 7
+	tagSize    = 16
+)
+
+var magic = []byte("FVENC\x01")
+
+var ErrCorrupted = errors.New("模型密文已损坏或密钥错误")
+
+// 生成随机数据密钥
+func GenerateDataKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("生成数据密钥失败: %w", err)
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("数据密钥长度应为 %d 字节", KeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[prefixSize:], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// 按块读取，同时预读下一块以确定当前块是否为最后一块
+type chunkReader struct {
+	r         io.Reader
+	cur, next []byte
+	n         int
+	eof       bool
+}
+
+func newChunkReader(r io.Reader, size int) (*chunkReader, error) {
+	c := &chunkReader{r: r, cur: make([]byte, size), next: make([]byte, size)}
+	n, err := io.ReadFull(r, c.next)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	c.n, c.eof = n, n < size
+	return c, nil
+}
+
+// 返回下一块及其是否为最后一块
+func (c *chunkReader) chunk() ([]byte, bool, error) {
+	c.cur, c.next = c.next, c.cur
+	data := c.cur[:c.n]
+	if c.eof {
+		return data, true, nil
+	}
+	n, err := io.ReadFull(c.r, c.next)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, false, err
+	}
+	c.n, c.eof = n, n < len(c.next)
+	return data, n == 0, nil
+}
+
+// 用数据密钥加密 src 写入 dst
+func Encrypt(dst io.Writer, src io.Reader, key []byte) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, len(magic)+prefixSize)
+	copy(header, magic)
+	if _, err := rand.Read(header[len(magic):]); err != nil {
+		return fmt.Errorf("生成 nonce 失败: %w", err)
+	}
+	if _, err := dst.Write(header); err != nil {
+		return err
+	}
+
+	chunks, err := newChunkReader(src, ChunkSize)
+	if err != nil {
+		return err
+	}
+	out := make([]byte, 0, ChunkSize+tagSize)
+	for counter := uint32(0); ; counter++ {
+		data, last, err := chunks.chunk()
+		if err != nil {
+			return err
+		}
+		out = gcm.Seal(out[:0], chunkNonce(header[len(magic):], counter, last), data, header)
+		if _, err := dst.Write(out); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+		if counter == 1<<32-1 {
+			return fmt.Errorf("模型文件过大，无法加密")
+		}
+	}
+}
+
+// 用数据密钥解密 src 写入 dst，密文被篡改或截断时返回 ErrCorrupted
+func Decrypt(dst io.Writer, src io.Reader, key []byte) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, len(magic)+prefixSize)
+	if _, err := io.ReadFull(src, header); err != nil || !bytes.Equal(header[:len(magic)], magic) {
+		return fmt.Errorf("不是加密的模型文件")
+	}
+
+	chunks, err := newChunkReader(src, ChunkSize+tagSize)
+	if err != nil {
+		return err
+	}
+	out := make([]byte, 0, ChunkSize)
+	for counter := uint32(0); ; counter++ {
+		data, last, err := chunks.chunk()
+		if err != nil {
+			return err
+		}
+		out, err = gcm.Open(out[:0], chunkNonce(header[len(magic):], counter, last), data, header)
+		if err != nil {
+			return ErrCorrupted
+		}
+		if _, err := dst.Write(out); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// 返回 src 加密后的流，读取方提前结束时需要 Close
+func EncryptReader(src io.Reader, key []byte) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(Encrypt(pw, src, key))
+	}()
+	return pr
+}
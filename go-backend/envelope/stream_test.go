@@ -0,0 +1,114 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+func encrypt(t *testing.T, plaintext, key []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Encrypt(&buf, bytes.NewReader(plaintext), key); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// 各种长度的明文加密后都能解密还原，包括空文件和恰好为整数块的文件
+func TestEncryptDecrypt(t *testing.T) {
+	key, err := GenerateDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := len(magic) + prefixSize
+
+	tests := []struct {
+		name   string
+		size   int
+		chunks int
+	}{
+		{"空文件", 0, 1},
+		{"不足一块", 100, 1},
+		{"恰好一块", ChunkSize, 1},
+		{"恰好两块", 2 * ChunkSize, 2},
+		{"两块多", 2*ChunkSize + 1, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext := make([]byte, tt.size)
+			rand.Read(plaintext)
+			ciphertext := encrypt(t, plaintext, key)
+			if want := header + tt.size + tt.chunks*tagSize; len(ciphertext) != want {
+				t.Errorf("密文长度 %d，期望 %d", len(ciphertext), want)
+			}
+
+			var out bytes.Buffer
+			if err := Decrypt(&out, bytes.NewReader(ciphertext), key); err != nil {
+				t.Fatalf("解密失败: %v", err)
+			}
+			if !bytes.Equal(out.Bytes(), plaintext) {
+				t.Error("解密结果与明文不一致")
+			}
+
+			stream := EncryptReader(bytes.NewReader(plaintext), key)
+			defer stream.Close()
+			streamed, err := io.ReadAll(stream)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out.Reset()
+			if err := Decrypt(&out, bytes.NewReader(streamed), key); err != nil || !bytes.Equal(out.Bytes(), plaintext) {
+				t.Errorf("EncryptReader 的结果无法解密: %v", err)
+			}
+		})
+	}
+}
+
+// 密文被截断、调换分块、追加数据或密钥错误时解密失败
+func TestDecryptTampered(t *testing.T) {
+	key, err := GenerateDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := make([]byte, 3*ChunkSize)
+	rand.Read(plaintext)
+	ciphertext := encrypt(t, plaintext, key)
+	header := len(magic) + prefixSize
+	chunk := ChunkSize + tagSize
+	chunkAt := func(i int) []byte { return ciphertext[header+i*chunk : header+(i+1)*chunk] }
+
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+	otherKey, _ := GenerateDataKey()
+	flipped := bytes.Clone(ciphertext)
+	flipped[header+10] ^= 1
+
+	tests := []struct {
+		name       string
+		ciphertext []byte
+		key        []byte
+	}{
+		{"截断最后一块", ciphertext[:len(ciphertext)-chunk], key},
+		{"截断到块中间", ciphertext[:len(ciphertext)-10], key},
+		{"只剩文件头", ciphertext[:header], key},
+		{"调换分块", join(ciphertext[:header], chunkAt(1), chunkAt(0), chunkAt(2)), key},
+		{"重复分块", join(ciphertext[:header], chunkAt(0), chunkAt(0), chunkAt(1), chunkAt(2)), key},
+		{"追加一块", join(ciphertext, chunkAt(2)), key},
+		{"追加字节", join(ciphertext, []byte{0}), key},
+		{"改动一个字节", flipped, key},
+		{"密钥错误", ciphertext, otherKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Decrypt(io.Discard, bytes.NewReader(tt.ciphertext), tt.key); !errors.Is(err, ErrCorrupted) {
+				t.Errorf("应返回 ErrCorrupted，实际 %v", err)
+			}
+		})
+	}
+
+	if err := Decrypt(io.Discard, bytes.NewReader([]byte("not encrypted")), key); err == nil || errors.Is(err, ErrCorrupted) {
+		t.Errorf("不是加密文件时应返回格式错误，实际 %v", err)
+	}
+}
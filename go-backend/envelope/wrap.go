@@ -0,0 +1,225 @@
+package envelope
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+)
+
+// 数据密钥的封装算法，按接收者登记的公钥类型选择
+const (
+	AlgX25519 = "ECIES-X25519-HKDF-SHA256-A256GCM" // Ed25519 公钥转换为 X25519
+	AlgP256   = "ECIES-P256-HKDF-SHA256-A256GCM"
+	AlgRSA    = "RSA-OAEP-SHA256"
+)
+
+const hkdfInfo = "fabric-vue model data key"
+
+// 为一个接收者封装的数据密钥
+type WrappedKey struct {
+	Username     string `json:"username"`
+	Fingerprint  string `json:"fingerprint"` // 封装所用公钥的指纹
+	Algorithm    string `json:"algorithm"`
+	EphemeralKey string `json:"ephemeralKey,omitempty"` // ECIES 临时公钥，Base64
+	WrappedKey   string `json:"wrappedKey"`             // Base64
+}
+
+// 用接收者公钥封装数据密钥，指纹作为附加数据绑定到封装结果
+func Wrap(pub crypto.PublicKey, fingerprint string, key []byte) (*WrappedKey, error) {
+	switch k := pub.(type) {
+	case ed25519.PublicKey:
+		x, err := ed25519ToX25519(k)
+		if err != nil {
+			return nil, err
+		}
+		return wrapECIES(AlgX25519, x, fingerprint, key)
+	case *ecdsa.PublicKey:
+		p, err := k.ECDH()
+		if err != nil {
+			return nil, fmt.Errorf("转换 P-256 公钥失败: %w", err)
+		}
+		return wrapECIES(AlgP256, p, fingerprint, key)
+	case *rsa.PublicKey:
+		wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, k, key, []byte(fingerprint))
+		if err != nil {
+			return nil, fmt.Errorf("封装数据密钥失败: %w", err)
+		}
+		return &WrappedKey{Fingerprint: fingerprint, Algorithm: AlgRSA, WrappedKey: base64.StdEncoding.EncodeToString(wrapped)}, nil
+	default:
+		return nil, fmt.Errorf("不支持用 %T 公钥封装数据密钥", pub)
+	}
+}
+
+// 用接收者私钥解开数据密钥
+func Unwrap(priv crypto.PrivateKey, w *WrappedKey) ([]byte, error) {
+	wrapped, err := base64.StdEncoding.DecodeString(w.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("封装的数据密钥编码无效")
+	}
+	switch k := priv.(type) {
+	case ed25519.PrivateKey:
+		if w.Algorithm != AlgX25519 {
+			break
+		}
+		h := sha512.Sum512(k.Seed())
+		x, err := ecdh.X25519().NewPrivateKey(h[:32])
+		if err != nil {
+			return nil, err
+		}
+		return unwrapECIES(x, w, wrapped)
+	case *ecdsa.PrivateKey:
+		if w.Algorithm != AlgP256 {
+			break
+		}
+		p, err := k.ECDH()
+		if err != nil {
+			return nil, fmt.Errorf("转换 P-256 私钥失败: %w", err)
+		}
+		return unwrapECIES(p, w, wrapped)
+	case *rsa.PrivateKey:
+		if w.Algorithm != AlgRSA {
+			break
+		}
+		key, err := rsa.DecryptOAEP(sha256.New(), nil, k, wrapped, []byte(w.Fingerprint))
+		if err != nil {
+			return nil, ErrCorrupted
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("不支持的私钥类型 %T", priv)
+	}
+	return nil, fmt.Errorf("私钥类型与封装算法 %s 不匹配", w.Algorithm)
+}
+
+// 临时密钥与接收者公钥协商出共享密钥，经 HKDF 派生封装密钥；
+// 每次封装使用新的临时密钥，封装密钥只用一次，因此 nonce 固定为零
+func wrapECIES(alg string, recipient *ecdh.PublicKey, fingerprint string, key []byte) (*WrappedKey, error) {
+	ephemeral, err := recipient.Curve().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("生成临时密钥失败: %w", err)
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, fmt.Errorf("密钥协商失败: %w", err)
+	}
+	gcm, err := eciesGCM(shared, ephemeral.PublicKey(), recipient)
+	if err != nil {
+		return nil, err
+	}
+	wrapped := gcm.Seal(nil, make([]byte, gcm.NonceSize()), key, []byte(fingerprint))
+	return &WrappedKey{
+		Fingerprint:  fingerprint,
+		Algorithm:    alg,
+		EphemeralKey: base64.StdEncoding.EncodeToString(ephemeral.PublicKey().Bytes()),
+		WrappedKey:   base64.StdEncoding.EncodeToString(wrapped),
+	}, nil
+}
+
+func unwrapECIES(priv *ecdh.PrivateKey, w *WrappedKey, wrapped []byte) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(w.EphemeralKey)
+	if err != nil {
+		return nil, fmt.Errorf("临时公钥编码无效")
+	}
+	ephemeral, err := priv.Curve().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("临时公钥无效: %w", err)
+	}
+	shared, err := priv.ECDH(ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("密钥协商失败: %w", err)
+	}
+	gcm, err := eciesGCM(shared, ephemeral, priv.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	key, err := gcm.Open(nil, make([]byte, gcm.NonceSize()), wrapped, []byte(w.Fingerprint))
+	if err != nil {
+		return nil, ErrCorrupted
+	}
+	return key, nil
+}
+
+// 封装密钥 = HKDF-SHA256(共享密钥, salt = 临时公钥 || 接收者公钥)
+func eciesGCM(shared []byte, ephemeral, recipient *ecdh.PublicKey) (cipher.AEAD, error) {
+	salt := append(append([]byte{}, ephemeral.Bytes()...), recipient.Bytes()...)
+	kek, err := hkdf.Key(sha256.New, shared, salt, hkdfInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Ed25519 公钥按 RFC 7748 的双有理映射转换为 X25519 公钥：u = (1 + y) / (1 - y) mod p
+func ed25519ToX25519(pub ed25519.PublicKey) (*ecdh.PublicKey, error) {
+	p := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+	le := make([]byte, len(pub))
+	for i := range pub {
+		le[len(pub)-1-i] = pub[i]
+	}
+	le[0] &= 0x7f // 最高位是 x 的符号位
+	y := new(big.Int).SetBytes(le)
+
+	one := big.NewInt(1)
+	den := new(big.Int).Mod(new(big.Int).Sub(one, y), p)
+	if den.ModInverse(den, p) == nil {
+		return nil, fmt.Errorf("Ed25519 公钥无法转换为 X25519")
+	}
+	u := new(big.Int).Mul(new(big.Int).Add(one, y), den)
+	u.Mod(u, p)
+
+	be := u.FillBytes(make([]byte, 32))
+	for i, j := 0, len(be)-1; i < j; i, j = i+1, j-1 {
+		be[i], be[j] = be[j], be[i]
+	}
+	return ecdh.X25519().NewPublicKey(be)
+}
+
+// 解析 PEM 格式的私钥（PKCS#8、SEC 1 或 PKCS#1），用于命令行解密
+func ParsePrivateKey(data []byte) (crypto.PrivateKey, crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, fmt.Errorf("私钥不是 PEM 格式")
+	}
+	var key any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, nil, fmt.Errorf("不支持的 PEM 类型: %s", block.Type)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析私钥失败: %w", err)
+	}
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return k, k.Public(), nil
+	case *ecdsa.PrivateKey:
+		return k, k.Public(), nil
+	case *rsa.PrivateKey:
+		return k, k.Public(), nil
+	default:
+		return nil, nil, fmt.Errorf("不支持的私钥类型 %T", key)
+	}
+}
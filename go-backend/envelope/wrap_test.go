@@ -0,0 +1,109 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"errors"
+	"testing"
+)
+
+type testKey struct {
+	name string
+	alg  string
+	priv crypto.PrivateKey
+	pub  crypto.PublicKey
+}
+
+func testKeys(t *testing.T) []testKey {
+	t.Helper()
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []testKey{
+		{"Ed25519", AlgX25519, edPriv, edPub},
+		{"P-256", AlgP256, ecPriv, ecPriv.Public()},
+		{"RSA", AlgRSA, rsaPriv, rsaPriv.Public()},
+	}
+}
+
+// 三种公钥封装的数据密钥都能用对应私钥解开，指纹被改动或私钥不对时失败
+func TestWrapUnwrap(t *testing.T) {
+	keys := testKeys(t)
+	dataKey, err := GenerateDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, k := range keys {
+		t.Run(k.name, func(t *testing.T) {
+			w, err := Wrap(k.pub, "fp-1", dataKey)
+			if err != nil {
+				t.Fatalf("封装失败: %v", err)
+			}
+			if w.Algorithm != k.alg || w.Fingerprint != "fp-1" {
+				t.Errorf("封装结果 %s/%s，期望 %s/fp-1", w.Algorithm, w.Fingerprint, k.alg)
+			}
+			got, err := Unwrap(k.priv, w)
+			if err != nil {
+				t.Fatalf("解开失败: %v", err)
+			}
+			if !bytes.Equal(got, dataKey) {
+				t.Error("解开的数据密钥与原密钥不一致")
+			}
+
+			// 指纹作为附加数据绑定到封装结果
+			tampered := *w
+			tampered.Fingerprint = "fp-2"
+			if _, err := Unwrap(k.priv, &tampered); !errors.Is(err, ErrCorrupted) {
+				t.Errorf("改动指纹后应返回 ErrCorrupted，实际 %v", err)
+			}
+
+			// 同类型的其他私钥解不开，不同类型的私钥与算法不匹配
+			other := testKeys(t)[i]
+			if _, err := Unwrap(other.priv, w); !errors.Is(err, ErrCorrupted) {
+				t.Errorf("其他私钥应返回 ErrCorrupted，实际 %v", err)
+			}
+			if _, err := Unwrap(keys[(i+1)%len(keys)].priv, w); err == nil {
+				t.Error("私钥类型与封装算法不匹配时应返回错误")
+			}
+		})
+	}
+}
+
+// 由 Ed25519 公钥转换的 X25519 公钥与由私钥种子派生的 X25519 私钥对应
+func TestEd25519ToX25519(t *testing.T) {
+	for range 20 {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ed25519ToX25519(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := sha512.Sum512(priv.Seed())
+		x, err := ecdh.X25519().NewPrivateKey(h[:32])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(x.PublicKey()) {
+			t.Fatalf("公钥 %x 转换结果 %x，期望 %x", pub, got.Bytes(), x.PublicKey().Bytes())
+		}
+	}
+}
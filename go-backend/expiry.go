@@ -0,0 +1,185 @@
+package main
+
+import (
+	invoke_fabric "backend/fabric-go/call"
+	connect_fabric "backend/fabric-go/network"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 任务截止时间调度配置
+type SchedulerConfig struct {
+	Interval        time.Duration // 扫描间隔，为 0 时不启动调度
+	LeaseTTL        time.Duration // 租约有效期，持有者宕机后其他实例在过期后接管
+	ReplicaID       string        // 当前后端实例的标识
+	AggregateWindow time.Duration // 进入聚合阶段后完成任务的时限
+}
+
+const schedulerLease = "task-scheduler"
+
+// 从环境变量读取调度配置
+func loadSchedulerConfig() SchedulerConfig {
+	cfg := SchedulerConfig{Interval: time.Minute, AggregateWindow: 7 * 24 * time.Hour}
+	if v, err := time.ParseDuration(os.Getenv("TASK_SCHEDULER_INTERVAL")); err == nil && v >= 0 {
+		cfg.Interval = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("TASK_AGGREGATE_WINDOW")); err == nil && v > 0 {
+		cfg.AggregateWindow = v
+	}
+	cfg.LeaseTTL = 3 * cfg.Interval
+	if v, err := time.ParseDuration(os.Getenv("TASK_SCHEDULER_LEASE_TTL")); err == nil && v > 0 {
+		cfg.LeaseTTL = v
+	}
+	cfg.ReplicaID = os.Getenv("REPLICA_ID")
+	if cfg.ReplicaID == "" {
+		host, _ := os.Hostname()
+		cfg.ReplicaID = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	return cfg
+}
+
+// 启动后台调度：关闭过期的任务（包括聚合超时的任务）并退还托管奖励，同时重试之前失败的退款和结转
+func startTaskScheduler(cfg SchedulerConfig) {
+	if cfg.Interval <= 0 {
+		fmt.Println("任务调度已关闭")
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			runTaskScheduler(cfg)
+		}
+	}()
+}
+
+func runTaskScheduler(cfg SchedulerConfig) {
+	// 链码调用出错时可能 panic，不能让调度协程退出
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("任务调度异常: %v\n", r)
+		}
+	}()
+
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 多个实例只有持有租约的一个执行扫描
+	held, err := invoke_fabric.AcquireLease(contract, schedulerLease, cfg.ReplicaID, cfg.LeaseTTL)
+	if err != nil {
+		fmt.Printf("任务调度: %v\n", err)
+		return
+	}
+	if !held {
+		return
+	}
+
+	tasks, err := invoke_fabric.ListTasks(contract)
+	if err != nil {
+		fmt.Printf("任务调度: %v\n", err)
+		return
+	}
+	now := time.Now()
+	for i := range tasks {
+		// 已删除的任务在删除时已结清托管
+		if tasks[i].Deleted() {
+			continue
+		}
+		if err := applyDeadlines(contract, &tasks[i], now, cfg.AggregateWindow); err != nil {
+			fmt.Printf("任务调度: 任务 %s: %v\n", tasks[i].TaskID, err)
+		}
+	}
+}
+
+// 按截止时间推进单个任务的状态
+func applyDeadlines(contract *client.Contract, task *invoke_fabric.Task, now time.Time, aggregateWindow time.Duration) error {
+	switch task.State() {
+	case invoke_fabric.TaskOpen:
+		// 截止前没有人接受，任务过期
+		if task.AcceptClosed(now) || task.SubmitClosed(now) {
+			return expireTask(contract, task, "截止前没有用户接受任务")
+		}
+	case invoke_fabric.TaskInProgress:
+		if !task.SubmitClosed(now) {
+			return nil
+		}
+		// 截止前没有提交任何模型，任务过期；否则停止提交进入聚合
+		if len(task.Models) == 0 {
+			return expireTask(contract, task, "截止前没有提交模型")
+		}
+		return systemTransition(contract, task, invoke_fabric.TaskAggregating, "提交截止")
+	case invoke_fabric.TaskAggregating:
+		// 进入聚合阶段后开始计算聚合截止时间，截止前未完成的任务过期
+		if task.AggregateDeadline == "" {
+			return invoke_fabric.SetAggregateDeadline(contract, task.TaskID, now.Add(aggregateWindow))
+		}
+		if task.AggregateClosed(now) {
+			return expireTask(contract, task, "聚合截止前未完成")
+		}
+	case invoke_fabric.TaskExpired, invoke_fabric.TaskCancelled:
+		// 上次退款失败时重试，托管和罚金已全部转出的任务跳过
+		if task.EscrowReleasedAt != "" {
+			return nil
+		}
+		return refundClosed(contract, task)
+	case invoke_fabric.TaskRoundClosed:
+		// 上次结转托管余额失败时重试
+		if task.NextRoundTaskID == "" || task.EscrowReleasedAt != "" {
+			return nil
+		}
+		if err := invoke_fabric.ReleasePenalties(contract, task); err != nil {
+			return err
+		}
+		_, err := invoke_fabric.DrainEscrow(contract, task.TaskID, invoke_fabric.EscrowAccount(task.NextRoundTaskID),
+			fmt.Sprintf("任务 %s 托管余额结转到 %s", task.TaskID, task.NextRoundTaskID))
+		return err
+	}
+	return nil
+}
+
+// 任务过期并退还托管奖励；状态变更带有原状态校验，多个实例同时执行时只有一个成功
+func expireTask(contract *client.Contract, task *invoke_fabric.Task, reason string) error {
+	if err := systemTransition(contract, task, invoke_fabric.TaskExpired, reason); err != nil {
+		return err
+	}
+	return refundClosed(contract, task)
+}
+
+// 分配罚金并把托管余额退还发布者
+func refundClosed(contract *client.Contract, task *invoke_fabric.Task) error {
+	if err := invoke_fabric.ReleasePenalties(contract, task); err != nil {
+		return fmt.Errorf("分配退出罚金失败: %w", err)
+	}
+	if _, err := invoke_fabric.RefundEscrow(contract, task.TaskID, task.PostedUser); err != nil {
+		return fmt.Errorf("退还托管奖励失败: %w", err)
+	}
+	return nil
+}
+
+// 解析并检查截止时间，提交截止不能早于接受截止
+func parseTaskDeadlines(acceptDeadline, submitDeadline string, now time.Time) (time.Time, time.Time, error) {
+	var accept, submit time.Time
+	var err error
+	if acceptDeadline != "" {
+		if accept, err = time.Parse(time.RFC3339, acceptDeadline); err != nil {
+			return accept, submit, fmt.Errorf("接受截止时间格式错误，应为 RFC3339")
+		}
+		if !accept.After(now) {
+			return accept, submit, fmt.Errorf("接受截止时间必须晚于当前时间")
+		}
+	}
+	if submitDeadline != "" {
+		if submit, err = time.Parse(time.RFC3339, submitDeadline); err != nil {
+			return accept, submit, fmt.Errorf("提交截止时间格式错误，应为 RFC3339")
+		}
+		if !submit.After(now) {
+			return accept, submit, fmt.Errorf("提交截止时间必须晚于当前时间")
+		}
+	}
+	if !accept.IsZero() && !submit.IsZero() && submit.Before(accept) {
+		return accept, submit, fmt.Errorf("提交截止时间不能早于接受截止时间")
+	}
+	return accept, submit, nil
+}
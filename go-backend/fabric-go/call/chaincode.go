@@ -0,0 +1,91 @@
+package invoke_fabric
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 后端要求的链码接口版本，主版本不同表示不兼容，链码增加函数时升级次版本
+const ChaincodeVersion = "3.2"
+
+// 链码的版本和已实现的函数，由链码的 GetChaincodeInfo 返回
+type ChaincodeInfo struct {
+	Version   string   `json:"version"`
+	Functions []string `json:"functions"`
+}
+
+// 后端调用的全部链码函数，参数和语义见各调用处的注释，链码实现在仓库根目录的 chaincode 模块。
+// 只修改部分字段的函数（SetNextRoundTask、SetUserFlags、TransitionTask、SetTaskRules 等）
+// 必须读取已有记录后只改动对应字段，不能用参数重建整条记录，否则会清空状态、规则、公钥、审核和删除标记
+var ChaincodeFunctions = []string{
+	"GetChaincodeInfo",
+	"InitLedger",
+	// 用户
+	"CreateUser", "ReadUser", "GetAllUsers", "SetUserFlags", "ReviewUser", "RotatePublicKey",
+	"SoftDeleteUser", "RestoreUser", "RepairUserRefs",
+	// 模型
+	"CreateModel", "ReadModel", "ReadModelCIDOwner", "GetAllModels", "SetModelStorage", "SetModelArchitecture",
+	"CreateModelEnvelope", "ReadModelEnvelope",
+	// 任务
+	"CreateTask", "ReadTask", "GetAllTasks", "DeleteTask", "SetNextRoundTask", "AcceptTask",
+	"WithdrawFromTask", "AddModelToTask", "TransitionTask", "SetTaskDeadlines", "SetAggregateDeadline", "SetTaskRules",
+	"SetRewardStrategy", "SetTaskScores", "SoftDeleteTask", "RestoreTask", "RepairTaskRefs",
+	// 代币
+	"TransferTokens", "TransferTokensOnce", "MintTokens", "BurnTokens",
+	"GetAccountBalance", "GetTransferHistory",
+	// 结算
+	"CreateSettlement", "UpdateSettlement", "ReadSettlement", "GetAllSettlements",
+	// 下载审计
+	"RecordDownload", "QueryDownloads",
+	// 调度
+	"AcquireLease",
+}
+
+// 查询链码的版本和函数列表
+func GetChaincodeInfo(contract *client.Contract) (*ChaincodeInfo, error) {
+	fmt.Printf("\n--> Evaluate Transaction: GetChaincodeInfo, 查询链码版本\n")
+
+	/*
+		GetChaincodeInfo(ctx contractapi.TransactionContextInterface) (*ChaincodeInfo, error)
+	*/
+	result, err := contract.EvaluateTransaction("GetChaincodeInfo")
+	if err != nil {
+		return nil, fmt.Errorf("查询链码版本失败，链码可能过旧: %w", err)
+	}
+
+	var info ChaincodeInfo
+	if err := json.Unmarshal(result, &info); err != nil {
+		return nil, fmt.Errorf("解析链码版本失败: %w", err)
+	}
+	return &info, nil
+}
+
+// 启动时检查部署的链码与后端是否匹配：主版本一致且实现了后端调用的全部函数
+func CheckChaincode(contract *client.Contract) error {
+	info, err := GetChaincodeInfo(contract)
+	if err != nil {
+		return err
+	}
+
+	want, _, _ := strings.Cut(ChaincodeVersion, ".")
+	got, _, _ := strings.Cut(info.Version, ".")
+	if got != want {
+		return fmt.Errorf("链码版本 %s 与后端要求的 %s 不兼容", info.Version, ChaincodeVersion)
+	}
+	var missing []string
+	for _, name := range ChaincodeFunctions {
+		if !slices.Contains(info.Functions, name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("链码 %s 缺少函数: %s", info.Version, strings.Join(missing, ", "))
+	}
+
+	fmt.Printf("*** 链码版本 %s 检查通过\n", info.Version)
+	return nil
+}
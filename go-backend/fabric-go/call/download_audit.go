@@ -0,0 +1,39 @@
+package invoke_fabric
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 记录一次模型下载，entry 为审计记录的 JSON；所有后端实例写入同一份账本记录
+func RecordDownload(contract *client.Contract, entry []byte) error {
+	fmt.Printf("\n--> Submit Transaction: RecordDownload, 记录模型下载\n")
+
+	/*
+		RecordDownload(ctx contractapi.TransactionContextInterface, entryJSON string)
+		以交易 ID 为键保存，只追加不修改
+	*/
+	if _, err := contract.SubmitTransaction("RecordDownload", string(entry)); err != nil {
+		return fmt.Errorf("记录模型下载失败: %w", err)
+	}
+	return nil
+}
+
+// 按条件查询下载记录，按时间倒序返回 JSON 数组；modelID 和 username 为空时不过滤
+func QueryDownloads(contract *client.Contract, modelID, username string, limit int) ([]byte, error) {
+	fmt.Printf("\n--> Evaluate Transaction: QueryDownloads, 查询模型下载记录\n")
+
+	/*
+		QueryDownloads(ctx contractapi.TransactionContextInterface,
+			modelID string,
+			username string,
+			limit int
+		) (string, error)
+	*/
+	result, err := contract.EvaluateTransaction("QueryDownloads", modelID, username, fmt.Sprintf("%d", limit))
+	if err != nil {
+		return nil, fmt.Errorf("查询模型下载记录失败: %w", err)
+	}
+	return result, nil
+}
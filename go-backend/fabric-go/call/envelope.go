@@ -0,0 +1,49 @@
+package invoke_fabric
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 保存加密模型的信封，envelope 为信封的 JSON，按密文 CID 索引；
+// 信封已存在时不覆盖并返回 false，否则已加密的文件将无法解密
+func CreateModelEnvelope(contract *client.Contract, cid string, envelope []byte) (bool, error) {
+	fmt.Printf("\n--> Submit Transaction: CreateModelEnvelope, 保存模型 %s 的信封\n", cid)
+
+	/*
+		CreateModelEnvelope(ctx contractapi.TransactionContextInterface,
+			cid string,
+			envelopeJSON string
+		) (bool, error)
+		信封中只有封装后的数据密钥；记录已存在时返回 false，不修改已有记录
+	*/
+	result, err := contract.SubmitTransaction("CreateModelEnvelope", cid, string(envelope))
+	if err != nil {
+		return false, fmt.Errorf("保存模型信封失败: %w", err)
+	}
+
+	created := string(result) == "true"
+	if created {
+		fmt.Printf("*** 模型 %s 的信封已保存\n", cid)
+	}
+	return created, nil
+}
+
+// 查询加密模型的信封，模型未加密时返回 nil
+func ReadModelEnvelope(contract *client.Contract, cid string) ([]byte, error) {
+	fmt.Printf("\n--> Evaluate Transaction: ReadModelEnvelope, 查询模型 %s 的信封\n", cid)
+
+	/*
+		ReadModelEnvelope(ctx contractapi.TransactionContextInterface, cid string) (string, error)
+		记录不存在时返回空字符串
+	*/
+	result, err := contract.EvaluateTransaction("ReadModelEnvelope", cid)
+	if err != nil {
+		return nil, fmt.Errorf("查询模型信封失败: %w", err)
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return result, nil
+}
@@ -0,0 +1,86 @@
+package invoke_fabric
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 任务托管账户前缀，托管账户只能通过转账增减余额
+const escrowPrefix = "escrow:"
+
+// 任务的托管账户。已结束任务的托管账户和罚金账户余额都转出到 0 时，链码在转账交易中同时记录任务的 escrowReleasedAt
+func EscrowAccount(taskID string) string {
+	return escrowPrefix + taskID
+}
+
+// 是否为托管账户
+func IsEscrowAccount(account string) bool {
+	return strings.HasPrefix(account, escrowPrefix)
+}
+
+// 托管账户或罚金账户所属的任务
+func EscrowTaskID(account string) (string, bool) {
+	taskID, ok := strings.CutPrefix(account, escrowPrefix)
+	if !ok {
+		return "", false
+	}
+	return strings.TrimSuffix(taskID, penaltySuffix), true
+}
+
+// 查询账户余额，用户账户和托管账户都适用
+func GetAccountBalance(contract *client.Contract, account string) (int, error) {
+	fmt.Printf("\n--> Evaluate Transaction: GetAccountBalance, 查询账户 %s 余额\n", account)
+
+	/*
+		GetAccountBalance(ctx contractapi.TransactionContextInterface,
+			account string
+		) (int, error)
+		账户不存在时返回 0
+	*/
+	result, err := contract.EvaluateTransaction("GetAccountBalance", account)
+	if err != nil {
+		return 0, fmt.Errorf("查询账户余额失败: %w", err)
+	}
+
+	balance, err := strconv.Atoi(strings.TrimSpace(string(result)))
+	if err != nil {
+		return 0, fmt.Errorf("解析账户余额失败: %w", err)
+	}
+	return balance, nil
+}
+
+// 将任务奖励从发布者账户锁定到托管账户
+func LockEscrow(contract *client.Contract, taskID, poster string, amount int) (*TokenTransfer, error) {
+	return TransferTokens(contract, poster, EscrowAccount(taskID), amount,
+		fmt.Sprintf("任务 %s 奖励托管", taskID))
+}
+
+// 从托管账户支付奖励
+func ReleaseEscrow(contract *client.Contract, taskID, receiver string, amount int, memo string) (*TokenTransfer, error) {
+	return TransferTokens(contract, EscrowAccount(taskID), receiver, amount, memo)
+}
+
+// 将托管账户余额转到另一个账户（退还发布者或结转到下一轮），余额为 0 时不做任何操作
+func DrainEscrow(contract *client.Contract, taskID, receiver, memo string) (*TokenTransfer, error) {
+	balance, err := GetAccountBalance(contract, EscrowAccount(taskID))
+	if err != nil {
+		return nil, err
+	}
+	if balance <= 0 {
+		return nil, nil
+	}
+	return TransferTokens(contract, EscrowAccount(taskID), receiver, balance, memo)
+}
+
+// 退还托管余额给任务发布者
+func RefundEscrow(contract *client.Contract, taskID, poster string) (*TokenTransfer, error) {
+	return DrainEscrow(contract, taskID, poster, fmt.Sprintf("任务 %s 托管余额退还", taskID))
+}
+
+// 从托管账户幂等地支付奖励
+func ReleaseEscrowOnce(contract *client.Contract, idempotencyKey, taskID, receiver string, amount int, memo string) (*TokenTransfer, error) {
+	return TransferTokensOnce(contract, idempotencyKey, EscrowAccount(taskID), receiver, amount, memo)
+}
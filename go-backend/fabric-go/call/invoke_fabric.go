@@ -10,6 +10,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/hyperledger/fabric-gateway/pkg/client"
 )
@@ -25,13 +26,83 @@ type User struct {
 	IsAdmin      bool     `json:"isAdmin"`
 	IsVerified   bool     `json:"isVerified"`
 	IsAccepted   bool     `json:"isAccepted"`
+	ReviewStatus string   `json:"reviewStatus"` // 注册审核状态：pending、approved、rejected
+	ReviewedBy   string   `json:"reviewedBy"`   // 审核人
+	ReviewedAt   string   `json:"reviewedAt"`   // 审核时间（交易时间戳）
+	ReviewReason string   `json:"reviewReason"` // 审核意见，拒绝时必填
+
+	Keys []PublicKeyRecord `json:"keys"` // 公钥历史，最后一个为当前公钥
+
+	Tombstone
+}
+
+// 注册审核状态
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// 获取注册审核状态，兼容没有审核记录的旧用户
+func (u *User) RegistrationStatus() string {
+	if u.ReviewStatus != "" {
+		return u.ReviewStatus
+	}
+	if u.IsVerified && u.IsAccepted {
+		return ReviewApproved
+	}
+	return ReviewPending
+}
+
+// 检查用户是否可以登录
+func (u *User) CheckActive() error {
+	if u.Deleted() {
+		return fmt.Errorf("账号已被删除")
+	}
+	switch u.RegistrationStatus() {
+	case ReviewPending:
+		return fmt.Errorf("账号正在等待管理员审核")
+	case ReviewRejected:
+		if u.ReviewReason != "" {
+			return fmt.Errorf("账号注册已被拒绝: %s", u.ReviewReason)
+		}
+		return fmt.Errorf("账号注册已被拒绝")
+	}
+	if !u.IsVerified || !u.IsAccepted {
+		return fmt.Errorf("用户未通过验证或未被接受")
+	}
+	return nil
 }
 
 type Model struct {
-	Modelid    string `json:"Modelid"`
-	Modelowner string `json:"Modelowner"`
-	Modelhash  string `json:"Modelhash"`
-	Modelsign  string `json:"Modelsign"`
+	Modelid        string `json:"Modelid"`
+	Modelowner     string `json:"Modelowner"`
+	Modelhash      string `json:"Modelhash"`
+	Modelsign      string `json:"Modelsign"`
+	SignAlg        string `json:"signAlg"`        // 签名算法，后端验证签名时确定
+	KeyFingerprint string `json:"keyFingerprint"` // 验证签名所用公钥的指纹
+	CreatedAt      string `json:"createdAt"`      // 上传时间（交易时间戳）
+	Storage        string `json:"storage"`        // 存储方式：ipfs、file 或 s3，为空表示 ipfs
+	StorageCID     string `json:"storageCid"`     // 迁移后在当前存储中的 CID，CIDv0 的旧模型迁移后以 CIDv1 保存，为空表示与 Modelhash 相同
+
+	ArchFormat      string `json:"archFormat"`      // 模型文件格式：safetensors 或 onnx，无法识别时为空
+	ArchFingerprint string `json:"archFingerprint"` // 模型结构指纹，提交到任务时须与初始模型一致
+}
+
+// 模型文件的存储方式，旧模型没有记录，都保存在 IPFS
+func (m *Model) StorageScheme() string {
+	if m.Storage == "" {
+		return "ipfs"
+	}
+	return m.Storage
+}
+
+// 从存储中读取文件使用的 CID；签名和对外展示仍使用 Modelhash
+func (m *Model) ContentCID() string {
+	if m.StorageCID != "" {
+		return m.StorageCID
+	}
+	return m.Modelhash
 }
 
 type Task struct {
@@ -44,6 +115,22 @@ type Task struct {
 	IsComplete      bool     `json:"isComplete"`
 	Round           int      `json:"round"`           // 新增：任务的轮次
 	NextRoundTaskID string   `json:"nextRoundTaskID"` // 新增：下一轮任务的 ID
+	Status          string   `json:"status"`          // 任务状态，见 task_state.go
+
+	CreatedAt      string `json:"createdAt"`      // 创建时间，链码在 CreateTask 时按交易时间戳记录
+	AcceptDeadline string `json:"acceptDeadline"` // 接受截止时间（RFC3339），为空表示不限
+	SubmitDeadline string `json:"submitDeadline"` // 提交模型截止时间（RFC3339），为空表示不限
+
+	AggregateDeadline string `json:"aggregateDeadline"` // 聚合截止时间（RFC3339），进入聚合阶段时由调度设置，超过后任务过期
+	EscrowReleasedAt  string `json:"escrowReleasedAt"`  // 任务结束后托管余额全部转出的时间，由链码记录
+
+	Rules TaskRules `json:"rules"` // 接受任务的限制条件
+
+	Tombstone
+
+	RewardStrategy string             `json:"rewardStrategy"` // 奖励分配策略，为空时每人获得完整 bonus
+	RewardTopK     int                `json:"rewardTopK"`     // top_k 策略的 K 值
+	Scores         map[string]float64 `json:"scores"`         // 参与者的评估分数
 }
 
 // 初始化用户账本
@@ -75,17 +162,11 @@ func QueryUser(contract *client.Contract, username string, password string) (*Us
 		return nil, fmt.Errorf("解析 JSON 失败: %w", err)
 	}
 
-	// 验证密码
+	// 验证密码，审核状态由调用方通过 CheckActive 检查
 	if user.Password != password {
 		return nil, fmt.Errorf("密码错误")
 	}
 
-	// 检查 IsVerified 和 IsAccepted 字段
-	if !user.IsVerified || !user.IsAccepted {
-		return nil, fmt.Errorf("用户未通过验证或未被接受")
-	}
-
-	// 如果所有条件都满足，返回用户信息
 	return &user, nil
 }
 
@@ -135,82 +216,88 @@ func Get_one_User(contract *client.Contract, username string) (*User, error) {
 	return &user, nil
 }
 
-// 上传公钥
-func UploadPublicKey(contract *client.Contract, username string, pubkeyhash string) error {
-	fmt.Printf("\n--> Evaluate Transaction: ReadUser, 查询用户 %s\n", username)
-
-	// 调用链码查询用户信息
-	result, err := contract.EvaluateTransaction("ReadUser", username)
-	if err != nil {
-		return fmt.Errorf("查询用户失败: %w", err)
-	}
+// 上传模型，返回模型 ID；签名已由后端验证，算法和公钥指纹随模型记录
+func CreateNewModel(contract *client.Contract, modelowner, modelhash, modelsign, signAlg, keyFingerprint, storage, archFormat, archFingerprint string) (string, error) {
+	fmt.Printf("\n--> Submit Transaction: CreateModel, 创建新模型 %s\n", modelhash)
 
-	// 将查询结果解析为 User 结构体
-	var user User
-	err = json.Unmarshal(result, &user)
-	if err != nil {
-		return fmt.Errorf("解析用户信息失败: %w", err)
-	}
-
-	// 更新用户的公钥哈希
-	user.Pubkeyhash = pubkeyhash
-	print(pubkeyhash)
-	print("test")
-	print(user.Pubkeyhash)
-	fmt.Printf("\n--> Submit Transaction: UpdateUser, 更新用户 %s 的公钥哈希\n", username)
-
-	// 调用链码更新用户信息
-	_, err = contract.SubmitTransaction(
-		"UpdateUser",
-		user.Username,
-		user.Password,
-		user.Organization,
-		user.Pubkeyhash,
-		fmt.Sprintf("%d", user.Token),
-		fmt.Sprintf("%t", user.IsAdmin),
-		fmt.Sprintf("%t", user.IsVerified),
-		fmt.Sprintf("%t", user.IsAccepted),
-	)
+	/*
+		CreateModel(ctx contractapi.TransactionContextInterface,
+			modelowner string,
+			modelhash string,
+			modelsign string,
+			signAlg string,
+			keyFingerprint string,
+			storage string,
+			archFormat string,
+			archFingerprint string
+		) (string, error)
+		在同一个交易中创建模型并加入上传者的 Posted 列表，以交易时间戳记录 createdAt，返回模型 ID；
+		同时维护 CID 到上传者的索引，CID 已由其他用户登记时返回错误
+	*/
+	result, err := contract.SubmitTransaction("CreateModel", modelowner, modelhash, modelsign, signAlg, keyFingerprint, storage, archFormat, archFingerprint)
 	if err != nil {
-		return fmt.Errorf("更新用户公钥哈希失败: %w", err)
+		return "", fmt.Errorf("创建模型失败: %w", err)
 	}
 
-	fmt.Printf("*** 用户 %s 的公钥哈希已成功更新\n", username)
-	return nil
+	modelID := string(result)
+	fmt.Printf("*** 模型创建成功, 模型ID: %s, 已加入用户 %s 的 Posted 列表\n", modelID, modelowner)
+	return modelID, nil
 }
 
-// 上传模型
-func CreateNewModel(contract *client.Contract, modelowner, modelhash, modelsign string) error {
-	fmt.Printf("\n--> Submit Transaction: CreateModel, 创建新模型 %s\n", modelhash)
+// 查询登记过该 CID 的用户，未登记时返回空字符串
+func GetModelCIDOwner(contract *client.Contract, cid string) (string, error) {
+	fmt.Printf("\n--> Evaluate Transaction: ReadModelCIDOwner, 查询 CID %s 的登记者\n", cid)
 
-	// 调用链码的 CreateModel 方法
-	result, err := contract.SubmitTransaction("CreateModel", modelowner, modelhash, modelsign)
+	/*
+		ReadModelCIDOwner(ctx contractapi.TransactionContextInterface, cid string) (string, error)
+	*/
+	result, err := contract.EvaluateTransaction("ReadModelCIDOwner", cid)
 	if err != nil {
-		return fmt.Errorf("创建模型失败: %w", err)
+		return "", fmt.Errorf("查询模型 CID 登记者失败: %w", err)
 	}
+	return string(result), nil
+}
 
-	modelID := string(result)
-	fmt.Printf("*** 模型创建成功, 模型ID: %s\n", modelID)
+// 模型文件迁移到其他存储后更新存储方式和新存储中的 CID，Modelhash 和签名不变
+func SetModelStorage(contract *client.Contract, modelID, storage, storageCID string) error {
+	fmt.Printf("\n--> Submit Transaction: SetModelStorage, 模型 %s 迁移到 %s\n", modelID, storage)
 
-	// 使用 add_2_posed 函数将模型 ID 添加到用户的 Posted 列表
-	fmt.Printf("\n--> Submit Transaction: AddToPosted, 更新用户 %s 的 Posted 列表\n", modelowner)
-	add_2_posed(contract, modelowner, modelID)
+	/*
+		SetModelStorage(ctx contractapi.TransactionContextInterface,
+			modelID string,
+			storage string,
+			storageCID string
+		)
+		storageCID 与 Modelhash 相同时保存为空
+	*/
+	_, err := contract.SubmitTransaction("SetModelStorage", modelID, storage, storageCID)
+	if err != nil {
+		return fmt.Errorf("更新模型存储方式失败: %w", err)
+	}
 
-	fmt.Printf("*** 用户 %s 的 Posted 列表已成功更新\n", modelowner)
+	fmt.Printf("*** 模型 %s 的存储方式已更新为 %s\n", modelID, storage)
 	return nil
 }
 
-// 添加到 Posted 列表
-func add_2_posed(contract *client.Contract, username, modelid string) {
-	result, err := contract.SubmitTransaction("AddToPosted", username, modelid)
-	if err != nil {
-		fmt.Printf("Failed to add to Posted: %v\n", err)
-		return
-	}
-	fmt.Printf("Successfully added to Posted: %s\n", string(result))
+// 记录旧模型的结构指纹，模型已有指纹时链码拒绝修改
+func SetModelArchitecture(contract *client.Contract, modelID, archFormat, archFingerprint string) error {
+	fmt.Printf("\n--> Submit Transaction: SetModelArchitecture, 模型 %s 的结构为 %s %s\n", modelID, archFormat, archFingerprint)
+
+	/*
+		SetModelArchitecture(ctx contractapi.TransactionContextInterface,
+			modelID string,
+			archFormat string,
+			archFingerprint string
+		)
+		archFingerprint 已不为空时交易失败
+	*/
+	_, err := contract.SubmitTransaction("SetModelArchitecture", modelID, archFormat, archFingerprint)
 	if err != nil {
-		fmt.Printf("Failed to add to Posted: %v\n", err)
+		return fmt.Errorf("记录模型结构失败: %w", err)
 	}
+
+	fmt.Printf("*** 模型 %s 的结构指纹已记录\n", modelID)
+	return nil
 }
 
 func GetAllTasks(contract *client.Contract) ([]map[string]interface{}, error) {
@@ -237,83 +324,70 @@ func GetAllTasks(contract *client.Contract) ([]map[string]interface{}, error) {
 	return tasks, nil
 }
 
-// 添加任务到用户的 Accepted 字段
-func AddToAccepted(contract *client.Contract, username, taskID string) error {
-	fmt.Printf("\n--> Submit Transaction: AddToAccepted, 将任务 %s 添加到用户 %s 的 Accepted 字段\n", taskID, username)
-
-	// 调用链码的 AddToAccepted 方法
-	_, err := contract.SubmitTransaction("AddToAccepted", username, taskID)
-	if err != nil {
-		return fmt.Errorf("添加任务到 Accepted 字段失败: %w", err)
-	}
-
-	fmt.Printf("*** 成功将任务 %s 添加到用户 %s 的 Accepted 字段\n", taskID, username)
-	return nil
-}
-
-// 将用户添加到任务的接受用户列表中
-func AddUserToTask(contract *client.Contract, taskID, username string) error {
-	fmt.Printf("\n--> Submit Transaction: AddUserToTask, 将用户 %s 添加到任务 %s 的接受用户列表中\n", username, taskID)
+// 用户接受任务，同时加入用户的 Accepted 和任务的接受用户列表；
+// from 为读取到的任务状态，任务为 open 时在同一个交易中变更为 in-progress
+func AcceptTask(contract *client.Contract, taskID, username, from string) error {
+	fmt.Printf("\n--> Submit Transaction: AcceptTask, 用户 %s 接受任务 %s\n", username, taskID)
 
-	// 调用链码的 AddUserToTask 方法
-	_, err := contract.SubmitTransaction("AddUserToTask", taskID, username)
+	/*
+		AcceptTask(ctx contractapi.TransactionContextInterface,
+			taskID string,
+			username string,
+			fromStatus string
+		)
+		在同一个交易中更新用户和任务，用户已在任务中、人数已达 maxParticipants 或当前状态与 fromStatus 不同时返回错误；
+		fromStatus 为 open 时任务变更为 in-progress，并以 system 角色记录状态变更
+	*/
+	_, err := contract.SubmitTransaction("AcceptTask", taskID, username, from)
 	if err != nil {
-		return fmt.Errorf("将用户添加到任务的接受用户列表失败: %w", err)
+		return fmt.Errorf("接受任务失败: %w", err)
 	}
 
-	fmt.Printf("*** 成功将用户 %s 添加到任务 %s 的接受用户列表中\n", username, taskID)
+	fmt.Printf("*** 用户 %s 已接受任务 %s\n", username, taskID)
 	return nil
 }
 
-// 删除用户
-func DeleteUser(contract *client.Contract, username string) error {
-	fmt.Printf("\n--> Submit Transaction: DeleteUser, 删除用户 %s\n", username)
+// 用户退出任务，同时从用户的 Accepted 和任务的接受用户列表中移除，并把罚金转入任务的罚金账户
+func WithdrawFromTask(contract *client.Contract, taskID, username string, penalty int) error {
+	fmt.Printf("\n--> Submit Transaction: WithdrawFromTask, 用户 %s 退出任务 %s\n", username, taskID)
 
-	// 调用链码的 DeleteUser 方法
-	_, err := contract.SubmitTransaction("DeleteUser", username)
+	/*
+		WithdrawFromTask(ctx contractapi.TransactionContextInterface,
+			taskID string,
+			username string,
+			penalty int
+		)
+		在同一个交易中更新用户和任务并从用户账户向 escrow:<taskID>:penalty 转入罚金，
+		进行中的任务最后一个参与者退出后同时变更为 open，
+		用户不在任务中或余额不足以支付罚金时返回错误
+	*/
+	_, err := contract.SubmitTransaction("WithdrawFromTask", taskID, username, fmt.Sprintf("%d", penalty))
 	if err != nil {
-		return fmt.Errorf("删除用户失败: %w", err)
+		return fmt.Errorf("退出任务失败: %w", err)
 	}
 
-	fmt.Printf("*** 用户 %s 已成功删除\n", username)
+	fmt.Printf("*** 用户 %s 已退出任务 %s\n", username, taskID)
 	return nil
 }
 
-// 管理用户
+// 管理用户，只修改管理员、验证和接受标记，其他字段由链码保留
 func ManageUser(contract *client.Contract, username string, isAdmin, isVerified, isAccepted bool) error {
-	fmt.Printf("\n--> Evaluate Transaction: ReadUser, 查询用户 %s\n", username)
-
-	// 调用链码查询用户信息
-	result, err := contract.EvaluateTransaction("ReadUser", username)
-	if err != nil {
-		return fmt.Errorf("查询用户失败: %w", err)
-	}
-
-	// 将查询结果解析为 User 结构体
-	var user User
-	err = json.Unmarshal(result, &user)
-	if err != nil {
-		return fmt.Errorf("解析用户信息失败: %w", err)
-	}
-
-	// 更新用户的状态
-	user.IsAdmin = isAdmin
-	user.IsVerified = isVerified
-	user.IsAccepted = isAccepted
-
-	fmt.Printf("\n--> Submit Transaction: UpdateUser, 更新用户 %s 的状态\n", username)
+	fmt.Printf("\n--> Submit Transaction: SetUserFlags, 更新用户 %s 的状态\n", username)
 
-	// 调用链码更新用户信息
-	_, err = contract.SubmitTransaction(
-		"UpdateUser",
-		user.Username,
-		user.Password,
-		user.Organization,
-		user.Pubkeyhash,
-		fmt.Sprintf("%d", user.Token),
-		fmt.Sprintf("%t", user.IsAdmin),
-		fmt.Sprintf("%t", user.IsVerified),
-		fmt.Sprintf("%t", user.IsAccepted),
+	/*
+		SetUserFlags(ctx contractapi.TransactionContextInterface,
+			username string,
+			isAdmin bool,
+			isVerified bool,
+			isAccepted bool
+		)
+		读取已有用户后只修改这三个字段，密码、公钥、审核状态和删除标记保持不变
+	*/
+	_, err := contract.SubmitTransaction("SetUserFlags",
+		username,
+		fmt.Sprintf("%t", isAdmin),
+		fmt.Sprintf("%t", isVerified),
+		fmt.Sprintf("%t", isAccepted),
 	)
 	if err != nil {
 		return fmt.Errorf("更新用户状态失败: %w", err)
@@ -323,10 +397,21 @@ func ManageUser(contract *client.Contract, username string, isAdmin, isVerified,
 	return nil
 }
 
-// 创建新任务
-func CreateNewTask(contract *client.Contract, bonus int, rootModelId, postedUser string, round int, nextRoundTaskID string) string {
+// 创建新任务，status 为初始状态（draft 或 open）
+func CreateNewTask(contract *client.Contract, bonus int, rootModelId, postedUser string, round int, nextRoundTaskID, status string) (string, error) {
 	fmt.Printf("\n--> Submit Transaction: CreateTask, 创建新任务\n")
 
+	/*
+		CreateTask(ctx contractapi.TransactionContextInterface,
+			taskID string,
+			bonus int,
+			rootModelId string,
+			postedUser string,
+			round int,
+			nextRoundTaskID string,
+			status string
+		)
+	*/
 	// 调用链码的 CreateTask 方法
 	result, err := contract.SubmitTransaction("CreateTask",
 		"", // taskID 由链码生成
@@ -334,19 +419,21 @@ func CreateNewTask(contract *client.Contract, bonus int, rootModelId, postedUser
 		rootModelId,
 		postedUser,
 		fmt.Sprintf("%d", round),
-		nextRoundTaskID)
+		nextRoundTaskID,
+		status)
 	if err != nil {
-		panic(fmt.Errorf("创建任务失败: %w", err))
+		return "", fmt.Errorf("创建任务失败: %w", err)
 	}
 
 	// 解析返回的 taskID
 	taskID := string(result)
 	fmt.Printf("*** 任务创建成功, 任务ID: %s\n", taskID)
-	return taskID
+	return taskID, nil
 }
 
+// 彻底删除任务，只用于回滚刚创建、尚未被引用的任务；其他情况使用 SoftDeleteTask
 func DeleteTask(contract *client.Contract, Taskid string) error {
-	fmt.Printf("\n--> Submit Transaction: DeleteUser, 删除任务 %s\n", Taskid)
+	fmt.Printf("\n--> Submit Transaction: DeleteTask, 删除任务 %s\n", Taskid)
 
 	_, err := contract.SubmitTransaction("DeleteTask", Taskid)
 
@@ -358,96 +445,125 @@ func DeleteTask(contract *client.Contract, Taskid string) error {
 	return nil
 }
 
-func Next_round(contract *client.Contract, taskID string, rootModelID string) error {
+// 结束本轮并创建下一轮任务，返回下一轮任务 ID
+// 下一轮先以草稿创建，本轮关闭前任何一步失败都会删除下一轮任务并恢复原任务
+func Next_round(contract *client.Contract, taskID string, rootModelID string, actor string, acceptDeadline, submitDeadline time.Time) (nexttaskid string, err error) {
 	// 调用链码读取任务信息
 	result, err := contract.EvaluateTransaction("ReadTask", taskID)
 	if err != nil {
-		return fmt.Errorf("读取任务失败: %v", err)
+		return "", fmt.Errorf("读取任务失败: %v", err)
 	}
 	var task Task
 	err = json.Unmarshal(result, &task)
 	if err != nil {
-		return fmt.Errorf("解析任务信息失败: %w", err)
+		return "", fmt.Errorf("解析任务信息失败: %w", err)
 	}
 	print(task.TaskID + "\n")
 	print(task.RootModelId + "\n")
 	print(task.PostedUser + "\n")
+
 	// 更新任务信息
 	newRound := task.Round + 1
 
 	// 创建新任务
-	nexttaskid := CreateNewTask(contract,
+	nexttaskid, err = CreateNewTask(contract,
 		task.Bonus,
 		rootModelID,
 		task.PostedUser,
 		newRound,
-		"")
+		"",
+		TaskDraft)
+	if err != nil {
+		return "", err
+	}
 	print(nexttaskid + "\n")
-	/*
-		UpdateTask(ctx contractapi.TransactionContextInterface,
-			taskID string,
-			bonus int,
-			rootModelId string,
-			postedUser string,
-			isComplete bool,
-			round int,
-			nextRoundTaskID string
-		)
-	*/
-	// 调用链码更新原任务
-	_, err = contract.SubmitTransaction("UpdateTask",
-		task.TaskID,
-		fmt.Sprintf("%d", task.Bonus),
-		task.RootModelId,
-		task.PostedUser,
-		fmt.Sprintf("%t", true),
-		fmt.Sprintf("%d", task.Round),
-		nexttaskid,
-	)
 
-	if err != nil {
-		return fmt.Errorf("更新原任务失败: %v", err)
+	// 本轮关闭前失败时回滚
+	closed := false
+	linked := false
+	defer func() {
+		if err == nil || closed {
+			return
+		}
+		if linked {
+			if rbErr := updateTaskLink(contract, &task, task.NextRoundTaskID); rbErr != nil {
+				fmt.Printf("回滚任务 %s 失败: %v\n", task.TaskID, rbErr)
+			}
+		}
+		if rbErr := DeleteTask(contract, nexttaskid); rbErr != nil {
+			fmt.Printf("回滚任务 %s 失败: %v\n", nexttaskid, rbErr)
+		}
+	}()
+
+	// 下一轮的截止时间
+	if !acceptDeadline.IsZero() || !submitDeadline.IsZero() {
+		if err = SetTaskDeadlines(contract, nexttaskid, acceptDeadline, submitDeadline); err != nil {
+			return "", err
+		}
 	}
 
-	return nil
-}
+	// 下一轮沿用原任务的奖励分配策略
+	if task.RewardStrategy != "" {
+		if err = SetRewardStrategy(contract, nexttaskid, task.RewardStrategy, task.RewardTopK); err != nil {
+			return "", err
+		}
+	}
 
-func TransferTokens(contract *client.Contract, sender, receiver string, amount int) error {
-	// 查询接收者信息
-	receiverResult, err := contract.EvaluateTransaction("ReadUser", receiver)
-	if err != nil {
-		return fmt.Errorf("查询接收者失败: %w", err)
+	// 下一轮沿用原任务的接受限制
+	if !task.Rules.IsZero() {
+		if err = SetTaskRules(contract, nexttaskid, task.Rules); err != nil {
+			return "", err
+		}
 	}
 
-	// 解析接收者数据
-	var receiverData User
-	if err := json.Unmarshal(receiverResult, &receiverData); err != nil {
-		return fmt.Errorf("解析接收者数据失败: %w", err)
+	// 记录原任务的下一轮任务 ID
+	if err = updateTaskLink(contract, &task, nexttaskid); err != nil {
+		return "", err
 	}
+	linked = true
 
-	// 增加接收者余额
-	receiverData.Token += amount
+	// 关闭本轮，状态已变化时失败，避免并发请求重复创建下一轮
+	if err = TransitionTask(contract, task.TaskID, task.State(), TaskRoundClosed, actor, "进入下一轮"); err != nil {
+		return "", err
+	}
+	closed = true
 
-	// 更新接收者信息
-	_, err = contract.SubmitTransaction("UpdateUser",
-		receiverData.Username,
-		receiverData.Password,
-		receiverData.Organization,
-		receiverData.Pubkeyhash,
-		fmt.Sprintf("%d", receiverData.Token),
-		fmt.Sprintf("%t", receiverData.IsAdmin),
-		fmt.Sprintf("%t", receiverData.IsVerified),
-		fmt.Sprintf("%t", receiverData.IsAccepted),
-	)
+	// 发布下一轮；失败时发布者可以手动发布草稿
+	if err = TransitionTask(contract, nexttaskid, TaskDraft, TaskOpen, TaskRoleSystem, "上一轮结束"); err != nil {
+		return nexttaskid, fmt.Errorf("下一轮任务 %s 发布失败，请手动发布: %w", nexttaskid, err)
+	}
+
+	// 本轮的罚金由留下的参与者平分，原任务托管的剩余奖励结转到下一轮；失败时由调度任务重试
+	if err = ReleasePenalties(contract, &task); err != nil {
+		return nexttaskid, fmt.Errorf("分配罚金失败，稍后自动重试: %w", err)
+	}
+	_, err = DrainEscrow(contract, task.TaskID, EscrowAccount(nexttaskid),
+		fmt.Sprintf("任务 %s 托管余额结转到第 %d 轮 %s", task.TaskID, newRound, nexttaskid))
 	if err != nil {
-		return fmt.Errorf("更新接收者信息失败: %w", err)
+		return nexttaskid, fmt.Errorf("结转托管余额失败，稍后自动重试: %w", err)
 	}
 
-	// 返回成功信息
+	return nexttaskid, nil
+}
+
+// 更新任务的下一轮任务 ID，其他字段保持不变
+func updateTaskLink(contract *client.Contract, task *Task, nextRoundTaskID string) error {
+	/*
+		SetNextRoundTask(ctx contractapi.TransactionContextInterface,
+			taskID string,
+			nextRoundTaskID string
+		)
+		读取已有任务后只修改 nextRoundTaskID，状态、规则、奖励设置和删除标记保持不变
+	*/
+	_, err := contract.SubmitTransaction("SetNextRoundTask", task.TaskID, nextRoundTaskID)
+	if err != nil {
+		return fmt.Errorf("更新任务 %s 失败: %v", task.TaskID, err)
+	}
 	return nil
 }
 
-func Finish_Task(contract *client.Contract, taskID string) ([]string, error) {
+// 将任务标记为完成，返回接受任务的用户
+func Finish_Task(contract *client.Contract, taskID string, actor string) ([]string, error) {
 	// 调用链码读取任务信息
 	result, err := contract.EvaluateTransaction("ReadTask", taskID)
 	if err != nil {
@@ -458,29 +574,9 @@ func Finish_Task(contract *client.Contract, taskID string) ([]string, error) {
 	if err != nil {
 		return nil, fmt.Errorf("解析任务信息失败: %w", err)
 	}
-	/*
-		UpdateTask(ctx contractapi.TransactionContextInterface,
-			taskID string,
-			bonus int,
-			rootModelId string,
-			postedUser string,
-			isComplete bool,
-			round int,
-			nextRoundTaskID string
-		)
-	*/
-	// 调用链码更新原任务
-	_, err = contract.SubmitTransaction("UpdateTask",
-		task.TaskID,
-		fmt.Sprintf("%d", task.Bonus),
-		task.RootModelId,
-		task.PostedUser,
-		fmt.Sprintf("%t", true),
-		fmt.Sprintf("%d", task.Round),
-		task.NextRoundTaskID,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("更新原任务失败: %v", err)
+
+	if err := TransitionTask(contract, task.TaskID, task.State(), TaskCompleted, actor, "任务完成"); err != nil {
+		return nil, err
 	}
 
 	return task.AcceptedUsers, nil
@@ -539,3 +635,53 @@ func ReadModel(contract *client.Contract, modelID string) (*Model, error) {
 	// 返回模型信息
 	return &model, nil
 }
+
+// 查询所有用户
+func GetAllUsers(contract *client.Contract) ([]User, error) {
+	fmt.Println("\n--> Evaluate Transaction: GetAllUsers, 查询所有用户")
+
+	result, err := contract.EvaluateTransaction("GetAllUsers")
+	if err != nil {
+		return nil, fmt.Errorf("查询所有用户失败: %w", err)
+	}
+
+	// 没有用户时返回空列表
+	if len(result) == 0 || string(result) == "null" {
+		return []User{}, nil
+	}
+
+	var users []User
+	err = json.Unmarshal(result, &users)
+	if err != nil {
+		return nil, fmt.Errorf("解析用户 JSON 失败: %w", err)
+	}
+	return users, nil
+}
+
+// 审核用户注册
+func ReviewUser(contract *client.Contract, username string, approved bool, reviewer, reason string) error {
+	fmt.Printf("\n--> Submit Transaction: ReviewUser, 审核用户 %s, 通过=%t\n", username, approved)
+
+	/*
+		ReviewUser(ctx contractapi.TransactionContextInterface,
+			username string,
+			approved bool,
+			reviewer string,
+			reason string
+		)
+		通过时 isVerified、isAccepted 置为 true，拒绝时置为 false，
+		reviewStatus、reviewedBy、reviewReason 一并写入，reviewedAt 取交易时间戳
+	*/
+	_, err := contract.SubmitTransaction("ReviewUser",
+		username,
+		fmt.Sprintf("%t", approved),
+		reviewer,
+		reason,
+	)
+	if err != nil {
+		return fmt.Errorf("审核用户失败: %w", err)
+	}
+
+	fmt.Printf("*** 用户 %s 审核完成\n", username)
+	return nil
+}
@@ -0,0 +1,93 @@
+package invoke_fabric
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 用户登记过的公钥，更换公钥后旧公钥保留，用于验证更换前上传的模型
+type PublicKeyRecord struct {
+	Fingerprint string `json:"fingerprint"` // PKIX DER 的 SHA-256
+	PublicKey   string `json:"publicKey"`   // PEM 格式
+	Algorithm   string `json:"algorithm"`   // 登记时持有证明使用的签名算法
+	ValidFrom   string `json:"validFrom"`   // 生效时间（交易时间戳）
+	ValidTo     string `json:"validTo"`     // 失效时间，为空表示当前有效
+}
+
+// t 时刻公钥是否有效，没有时间记录的旧公钥视为一直有效
+func (k *PublicKeyRecord) ActiveAt(t time.Time) bool {
+	if from, err := time.Parse(time.RFC3339, k.ValidFrom); err == nil && t.Before(from) {
+		return false
+	}
+	if to, err := time.Parse(time.RFC3339, k.ValidTo); err == nil && !t.Before(to) {
+		return false
+	}
+	return true
+}
+
+// 公钥历史，兼容只有 pubkeyhash 字段的旧用户
+func (u *User) KeyHistory() []PublicKeyRecord {
+	if len(u.Keys) > 0 || u.Pubkeyhash == "" {
+		return u.Keys
+	}
+	return []PublicKeyRecord{{PublicKey: u.Pubkeyhash}}
+}
+
+// 当前有效的公钥
+func (u *User) CurrentKey() *PublicKeyRecord {
+	keys := u.KeyHistory()
+	for i := len(keys) - 1; i >= 0; i-- {
+		if keys[i].ValidTo == "" {
+			return &keys[i]
+		}
+	}
+	return nil
+}
+
+// 按指纹查找公钥
+func (u *User) KeyByFingerprint(fingerprint string) *PublicKeyRecord {
+	keys := u.KeyHistory()
+	for i := range keys {
+		if keys[i].Fingerprint == fingerprint {
+			return &keys[i]
+		}
+	}
+	return nil
+}
+
+// t 时刻有效的公钥
+func (u *User) KeyAt(t time.Time) *PublicKeyRecord {
+	keys := u.KeyHistory()
+	for i := len(keys) - 1; i >= 0; i-- {
+		if keys[i].ActiveAt(t) {
+			return &keys[i]
+		}
+	}
+	return nil
+}
+
+// 更换用户公钥，公钥已由后端解析并验证持有证明
+func RotatePublicKey(contract *client.Contract, username, publicKey, fingerprint, algorithm string) error {
+	fmt.Printf("\n--> Submit Transaction: RotatePublicKey, 更换用户 %s 的公钥 %s\n", username, fingerprint)
+
+	/*
+		RotatePublicKey(ctx contractapi.TransactionContextInterface,
+			username string,
+			publicKey string,
+			fingerprint string,
+			algorithm string
+		)
+		以交易时间戳结束当前公钥的有效期并追加新公钥，同时把 pubkeyhash 更新为新公钥；
+		旧用户没有公钥历史时，先把原 pubkeyhash 作为第一条记录（validFrom 为空）保留；
+		指纹已在历史中出现时交易失败，旧公钥不能重新启用
+	*/
+	_, err := contract.SubmitTransaction("RotatePublicKey", username, publicKey, fingerprint, algorithm)
+	if err != nil {
+		return fmt.Errorf("更换公钥失败: %w", err)
+	}
+
+	fmt.Printf("*** 用户 %s 的公钥已更换为 %s\n", username, fingerprint)
+	return nil
+}
@@ -0,0 +1,25 @@
+package invoke_fabric
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 获取或续期一个链上租约，多个后端实例中同一时间只有一个持有者
+func AcquireLease(contract *client.Contract, name, holder string, ttl time.Duration) (bool, error) {
+	/*
+		AcquireLease(ctx contractapi.TransactionContextInterface,
+			name string,
+			holder string,
+			ttlSeconds int
+		) (bool, error)
+		租约空闲、已过期或已由 holder 持有时按交易时间戳续期并返回 true
+	*/
+	result, err := contract.SubmitTransaction("AcquireLease", name, holder, fmt.Sprintf("%d", int(ttl.Seconds())))
+	if err != nil {
+		return false, fmt.Errorf("获取租约 %s 失败: %w", name, err)
+	}
+	return string(result) == "true", nil
+}
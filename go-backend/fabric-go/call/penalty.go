@@ -0,0 +1,74 @@
+package invoke_fabric
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 罚金账户后缀，退出任务的罚金与发布者托管的奖励分开保存，不会随托管余额退还发布者
+const penaltySuffix = ":penalty"
+
+// 任务的罚金账户
+func PenaltyAccount(taskID string) string {
+	return EscrowAccount(taskID) + penaltySuffix
+}
+
+// 任务结束后分配罚金：由留在任务中的参与者平分，没有参与者时退还给缴纳者。
+// 金额按罚金账户收到的全部罚金计算，每人使用固定的幂等键，失败后重试不会重复支付
+func ReleasePenalties(contract *client.Contract, task *Task) error {
+	account := PenaltyAccount(task.TaskID)
+	transfers, err := GetTransferHistory(contract, account)
+	if err != nil {
+		return err
+	}
+
+	// 罚金账户只从退出任务的用户收款
+	total := 0
+	paid := make(map[string]int)
+	var payers []string
+	for _, t := range transfers {
+		credit, ok := t.PostingFor(account)
+		if !ok || credit.Amount <= 0 {
+			continue
+		}
+		for _, p := range t.Postings {
+			if p.Account != account && p.Amount < 0 {
+				if paid[p.Account] == 0 {
+					payers = append(payers, p.Account)
+				}
+				paid[p.Account] -= p.Amount
+				total -= p.Amount
+			}
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+
+	amounts := make(map[string]int)
+	recipients := task.AcceptedUsers
+	if len(recipients) == 0 {
+		recipients = payers
+		amounts = paid
+	} else {
+		for i, username := range recipients {
+			amounts[username] = total / len(recipients)
+			if i < total%len(recipients) {
+				amounts[username]++
+			}
+		}
+	}
+
+	for _, username := range recipients {
+		if amounts[username] <= 0 {
+			continue
+		}
+		key := fmt.Sprintf("penalty:%s:%s", task.TaskID, username)
+		memo := fmt.Sprintf("任务 %s 的退出罚金分配", task.TaskID)
+		if _, err := TransferTokensOnce(contract, key, account, username, amounts[username], memo); err != nil {
+			return fmt.Errorf("分配罚金给 %s 失败: %w", username, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package invoke_fabric
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 用户记录中引用其他对象的字段
+type UserRefs struct {
+	Accepted []string `json:"accepted"`
+	Posted   []string `json:"posted"`
+}
+
+// 任务记录中引用其他对象的字段
+type TaskRefs struct {
+	AcceptedUsers []string `json:"acceptedUsers"`
+	Models        []string `json:"models"`
+}
+
+// 查询所有模型
+func GetAllModels(contract *client.Contract) ([]Model, error) {
+	fmt.Println("\n--> Evaluate Transaction: GetAllModels, 查询所有模型")
+
+	/*
+		GetAllModels(ctx contractapi.TransactionContextInterface) ([]*Model, error)
+	*/
+	result, err := contract.EvaluateTransaction("GetAllModels")
+	if err != nil {
+		return nil, fmt.Errorf("查询所有模型失败: %w", err)
+	}
+	if len(result) == 0 || string(result) == "null" {
+		return []Model{}, nil
+	}
+
+	var models []Model
+	if err := json.Unmarshal(result, &models); err != nil {
+		return nil, fmt.Errorf("解析模型 JSON 失败: %w", err)
+	}
+	return models, nil
+}
+
+// 修复用户的引用字段，before 与链上当前值不一致时交易失败
+func RepairUserRefs(contract *client.Contract, username string, before, after UserRefs) error {
+	fmt.Printf("\n--> Submit Transaction: RepairUserRefs, 修复用户 %s 的引用\n", username)
+
+	/*
+		RepairUserRefs(ctx contractapi.TransactionContextInterface,
+			username string,
+			beforeJSON string,
+			afterJSON string
+		)
+	*/
+	return submitRepair(contract, "RepairUserRefs", username, before, after)
+}
+
+// 修复任务的引用字段，before 与链上当前值不一致时交易失败
+func RepairTaskRefs(contract *client.Contract, taskID string, before, after TaskRefs) error {
+	fmt.Printf("\n--> Submit Transaction: RepairTaskRefs, 修复任务 %s 的引用\n", taskID)
+
+	/*
+		RepairTaskRefs(ctx contractapi.TransactionContextInterface,
+			taskID string,
+			beforeJSON string,
+			afterJSON string
+		)
+	*/
+	return submitRepair(contract, "RepairTaskRefs", taskID, before, after)
+}
+
+func submitRepair(contract *client.Contract, function, id string, before, after any) error {
+	beforeJSON, err := json.Marshal(before)
+	if err != nil {
+		return fmt.Errorf("序列化修复数据失败: %w", err)
+	}
+	afterJSON, err := json.Marshal(after)
+	if err != nil {
+		return fmt.Errorf("序列化修复数据失败: %w", err)
+	}
+
+	_, err = contract.SubmitTransaction(function, id, string(beforeJSON), string(afterJSON))
+	if err != nil {
+		return fmt.Errorf("修复 %s 失败: %w", id, err)
+	}
+
+	fmt.Printf("*** %s 的引用已修复\n", id)
+	return nil
+}
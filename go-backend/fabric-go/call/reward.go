@@ -0,0 +1,56 @@
+package invoke_fabric
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 设置任务的奖励分配策略
+func SetRewardStrategy(contract *client.Contract, taskID, strategy string, topK int) error {
+	fmt.Printf("\n--> Submit Transaction: SetRewardStrategy, 任务 %s 使用策略 %s\n", taskID, strategy)
+
+	/*
+		SetRewardStrategy(ctx contractapi.TransactionContextInterface,
+			taskID string,
+			strategy string,
+			topK int
+		)
+	*/
+	_, err := contract.SubmitTransaction("SetRewardStrategy",
+		taskID,
+		strategy,
+		fmt.Sprintf("%d", topK),
+	)
+	if err != nil {
+		return fmt.Errorf("设置奖励分配策略失败: %w", err)
+	}
+
+	fmt.Printf("*** 任务 %s 的奖励分配策略已更新\n", taskID)
+	return nil
+}
+
+// 记录参与者的评估分数，后端只允许为没有分数的用户记录
+func SetTaskScores(contract *client.Contract, taskID string, scores map[string]float64) error {
+	fmt.Printf("\n--> Submit Transaction: SetTaskScores, 记录任务 %s 的评估分数\n", taskID)
+
+	payload, err := json.Marshal(scores)
+	if err != nil {
+		return fmt.Errorf("序列化评估分数失败: %w", err)
+	}
+
+	/*
+		SetTaskScores(ctx contractapi.TransactionContextInterface,
+			taskID string,
+			scoresJSON string
+		)
+	*/
+	_, err = contract.SubmitTransaction("SetTaskScores", taskID, string(payload))
+	if err != nil {
+		return fmt.Errorf("记录评估分数失败: %w", err)
+	}
+
+	fmt.Printf("*** 任务 %s 的评估分数已记录\n", taskID)
+	return nil
+}
@@ -0,0 +1,81 @@
+package invoke_fabric
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 创建任务的结算记录，job 为结算任务的 JSON；记录已存在时不覆盖并返回 false，
+// 多个后端实例同时结算时以最先写入的支付计划为准
+func CreateSettlement(contract *client.Contract, taskID string, job []byte) (bool, error) {
+	fmt.Printf("\n--> Submit Transaction: CreateSettlement, 创建任务 %s 的结算记录\n", taskID)
+
+	/*
+		CreateSettlement(ctx contractapi.TransactionContextInterface,
+			taskID string,
+			jobJSON string
+		) (bool, error)
+		记录已存在时返回 false，不修改已有记录
+	*/
+	result, err := contract.SubmitTransaction("CreateSettlement", taskID, string(job))
+	if err != nil {
+		return false, fmt.Errorf("创建结算记录失败: %w", err)
+	}
+
+	created := string(result) == "true"
+	if created {
+		fmt.Printf("*** 任务 %s 的结算记录已创建\n", taskID)
+	}
+	return created, nil
+}
+
+// 更新结算记录中的支付状态
+func UpdateSettlement(contract *client.Contract, taskID string, job []byte) error {
+	fmt.Printf("\n--> Submit Transaction: UpdateSettlement, 更新任务 %s 的结算记录\n", taskID)
+
+	/*
+		UpdateSettlement(ctx contractapi.TransactionContextInterface,
+			taskID string,
+			jobJSON string
+		)
+		只能修改状态、支付结果和重试次数；收款人、金额和幂等键与创建时不一致时失败，
+		已支付的记录不能改回未支付
+	*/
+	if _, err := contract.SubmitTransaction("UpdateSettlement", taskID, string(job)); err != nil {
+		return fmt.Errorf("更新结算记录失败: %w", err)
+	}
+	return nil
+}
+
+// 查询任务的结算记录，不存在时返回 nil
+func ReadSettlement(contract *client.Contract, taskID string) ([]byte, error) {
+	fmt.Printf("\n--> Evaluate Transaction: ReadSettlement, 查询任务 %s 的结算记录\n", taskID)
+
+	/*
+		ReadSettlement(ctx contractapi.TransactionContextInterface, taskID string) (string, error)
+		记录不存在时返回空字符串
+	*/
+	result, err := contract.EvaluateTransaction("ReadSettlement", taskID)
+	if err != nil {
+		return nil, fmt.Errorf("查询结算记录失败: %w", err)
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// 查询全部结算记录，返回 JSON 数组
+func GetAllSettlements(contract *client.Contract) ([]byte, error) {
+	fmt.Printf("\n--> Evaluate Transaction: GetAllSettlements, 查询全部结算记录\n")
+
+	/*
+		GetAllSettlements(ctx contractapi.TransactionContextInterface) (string, error)
+	*/
+	result, err := contract.EvaluateTransaction("GetAllSettlements")
+	if err != nil {
+		return nil, fmt.Errorf("查询结算记录失败: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,120 @@
+package invoke_fabric
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 解析截止时间，为空时返回 false
+func parseDeadline(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// 接受截止时间是否已过
+func (t *Task) AcceptClosed(now time.Time) bool {
+	deadline, ok := parseDeadline(t.AcceptDeadline)
+	return ok && !now.Before(deadline)
+}
+
+// 提交截止时间是否已过
+func (t *Task) SubmitClosed(now time.Time) bool {
+	deadline, ok := parseDeadline(t.SubmitDeadline)
+	return ok && !now.Before(deadline)
+}
+
+// 聚合截止时间是否已过
+func (t *Task) AggregateClosed(now time.Time) bool {
+	deadline, ok := parseDeadline(t.AggregateDeadline)
+	return ok && !now.Before(deadline)
+}
+
+// 截止时间相对创建时间的间隔，用于下一轮沿用相同的时长
+func (t *Task) DeadlineOffsets() (accept, submit time.Duration) {
+	created, ok := parseDeadline(t.CreatedAt)
+	if !ok {
+		return 0, 0
+	}
+	if deadline, ok := parseDeadline(t.AcceptDeadline); ok {
+		accept = deadline.Sub(created)
+	}
+	if deadline, ok := parseDeadline(t.SubmitDeadline); ok {
+		submit = deadline.Sub(created)
+	}
+	return accept, submit
+}
+
+// 设置任务的截止时间，零值表示不限
+func SetTaskDeadlines(contract *client.Contract, taskID string, acceptDeadline, submitDeadline time.Time) error {
+	fmt.Printf("\n--> Submit Transaction: SetTaskDeadlines, 设置任务 %s 的截止时间\n", taskID)
+
+	format := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	/*
+		SetTaskDeadlines(ctx contractapi.TransactionContextInterface,
+			taskID string,
+			acceptDeadline string,
+			submitDeadline string
+		)
+	*/
+	_, err := contract.SubmitTransaction("SetTaskDeadlines", taskID, format(acceptDeadline), format(submitDeadline))
+	if err != nil {
+		return fmt.Errorf("设置任务截止时间失败: %w", err)
+	}
+
+	fmt.Printf("*** 任务 %s 的截止时间已更新\n", taskID)
+	return nil
+}
+
+// 设置任务的聚合截止时间
+func SetAggregateDeadline(contract *client.Contract, taskID string, deadline time.Time) error {
+	fmt.Printf("\n--> Submit Transaction: SetAggregateDeadline, 设置任务 %s 的聚合截止时间\n", taskID)
+
+	/*
+		SetAggregateDeadline(ctx contractapi.TransactionContextInterface,
+			taskID string,
+			aggregateDeadline string
+		)
+		只能在 aggregating 状态设置，其他字段保持不变
+	*/
+	_, err := contract.SubmitTransaction("SetAggregateDeadline", taskID, deadline.UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("设置任务聚合截止时间失败: %w", err)
+	}
+
+	fmt.Printf("*** 任务 %s 的聚合截止时间已设置\n", taskID)
+	return nil
+}
+
+// 查询所有任务
+func ListTasks(contract *client.Contract) ([]Task, error) {
+	fmt.Println("\n--> Evaluate Transaction: GetAllTasks, 查询所有任务")
+
+	result, err := contract.EvaluateTransaction("GetAllTasks")
+	if err != nil {
+		return nil, fmt.Errorf("查询所有任务失败: %w", err)
+	}
+	if len(result) == 0 || string(result) == "null" {
+		return nil, nil
+	}
+
+	var tasks []Task
+	if err := json.Unmarshal(result, &tasks); err != nil {
+		return nil, fmt.Errorf("解析任务 JSON 失败: %w", err)
+	}
+	return tasks, nil
+}
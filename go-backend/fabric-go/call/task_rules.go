@@ -0,0 +1,69 @@
+package invoke_fabric
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 接受任务的限制条件，零值表示不限制
+type TaskRules struct {
+	MaxParticipants int      `json:"maxParticipants"` // 参与人数上限
+	MinReputation   int      `json:"minReputation"`   // 最低信誉（已获得奖励的任务轮数）
+	MinBalance      int      `json:"minBalance"`      // 接受时账户余额不少于该值，余额不会被锁定
+	AllowedOrgs     []string `json:"allowedOrgs"`     // 允许参与的组织，为空表示不限
+	InviteOnly      bool     `json:"inviteOnly"`      // 仅限邀请
+	Invitees        []string `json:"invitees"`        // 邀请名单
+	LeavePenalty    *int     `json:"leavePenalty"`    // 退出任务的罚金，创建任务时未填写则写入后端默认值，之后以记录的值为准
+}
+
+// 是否设置了任何限制
+func (r TaskRules) IsZero() bool {
+	return r.MaxParticipants == 0 && r.MinReputation == 0 && r.MinBalance == 0 &&
+		len(r.AllowedOrgs) == 0 && !r.InviteOnly && len(r.Invitees) == 0 && r.LeavePenalty == nil
+}
+
+// 退出任务的罚金，没有记录时为 0
+func (r TaskRules) Penalty() int {
+	if r.LeavePenalty == nil {
+		return 0
+	}
+	return *r.LeavePenalty
+}
+
+// 检查限制条件
+func (r TaskRules) Validate() error {
+	if r.MaxParticipants < 0 || r.MinReputation < 0 || r.MinBalance < 0 || r.Penalty() < 0 {
+		return fmt.Errorf("参与人数上限、最低信誉、最低余额和退出罚金不能为负数")
+	}
+	if r.InviteOnly && len(r.Invitees) == 0 {
+		return fmt.Errorf("仅限邀请的任务需要填写邀请名单")
+	}
+	return nil
+}
+
+// 设置任务的接受限制
+func SetTaskRules(contract *client.Contract, taskID string, rules TaskRules) error {
+	fmt.Printf("\n--> Submit Transaction: SetTaskRules, 设置任务 %s 的接受限制\n", taskID)
+
+	payload, err := json.Marshal(rules)
+	if err != nil {
+		return fmt.Errorf("序列化接受限制失败: %w", err)
+	}
+
+	/*
+		SetTaskRules(ctx contractapi.TransactionContextInterface,
+			taskID string,
+			rulesJSON string
+		)
+		AcceptTask 时链码按 maxParticipants 再次检查人数，避免并发接受超出上限
+	*/
+	_, err = contract.SubmitTransaction("SetTaskRules", taskID, string(payload))
+	if err != nil {
+		return fmt.Errorf("设置接受限制失败: %w", err)
+	}
+
+	fmt.Printf("*** 任务 %s 的接受限制已更新\n", taskID)
+	return nil
+}
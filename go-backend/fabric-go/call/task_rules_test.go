@@ -0,0 +1,46 @@
+package invoke_fabric
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// 退出罚金区分未填写和显式填写 0，记录的值在序列化后保持不变
+func TestTaskRulesLeavePenalty(t *testing.T) {
+	cases := []struct {
+		name     string
+		json     string
+		penalty  int
+		isZero   bool
+		stored   bool
+		validErr bool
+	}{
+		{"未填写", `{}`, 0, true, false, false},
+		{"显式填写 0", `{"leavePenalty":0}`, 0, false, true, false},
+		{"填写罚金", `{"leavePenalty":5}`, 5, false, true, false},
+		{"负数罚金", `{"leavePenalty":-1}`, -1, false, true, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var rules TaskRules
+			if err := json.Unmarshal([]byte(tc.json), &rules); err != nil {
+				t.Fatal(err)
+			}
+			if rules.Penalty() != tc.penalty || rules.IsZero() != tc.isZero || (rules.LeavePenalty != nil) != tc.stored {
+				t.Errorf("罚金 %d、IsZero %v，应为 %d、%v", rules.Penalty(), rules.IsZero(), tc.penalty, tc.isZero)
+			}
+			if err := rules.Validate(); (err != nil) != tc.validErr {
+				t.Errorf("Validate 错误为 %v", err)
+			}
+
+			data, _ := json.Marshal(rules)
+			var again TaskRules
+			if err := json.Unmarshal(data, &again); err != nil {
+				t.Fatal(err)
+			}
+			if (again.LeavePenalty != nil) != tc.stored || again.Penalty() != tc.penalty {
+				t.Errorf("序列化后罚金变为 %s", data)
+			}
+		})
+	}
+}
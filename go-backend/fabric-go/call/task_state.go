@@ -0,0 +1,129 @@
+package invoke_fabric
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 任务状态
+const (
+	TaskDraft       = "draft"        // 草稿，发布前不能接受
+	TaskOpen        = "open"         // 已发布，等待参与者接受
+	TaskInProgress  = "in-progress"  // 已有参与者，正在训练和提交模型
+	TaskAggregating = "aggregating"  // 停止提交，发布者正在聚合模型
+	TaskRoundClosed = "round-closed" // 本轮结束，已进入下一轮
+	TaskCompleted   = "completed"    // 任务完成，奖励已结算
+	TaskCancelled   = "cancelled"    // 任务被取消，托管奖励已退还
+	TaskExpired     = "expired"      // 超过截止时间未完成
+)
+
+// 触发状态变更的角色
+const (
+	TaskRolePoster      = "poster"      // 任务发布者
+	TaskRoleAdmin       = "admin"       // 完成二次验证的管理员
+	TaskRoleParticipant = "participant" // 接受了任务的用户
+	TaskRoleSystem      = "system"      // 后端自动触发
+)
+
+// 允许的状态变更以及可以触发的角色
+var taskTransitions = map[string]map[string][]string{
+	TaskDraft: {
+		TaskOpen:      {TaskRolePoster, TaskRoleAdmin, TaskRoleSystem},
+		TaskCancelled: {TaskRolePoster, TaskRoleAdmin},
+	},
+	TaskOpen: {
+		TaskInProgress: {TaskRoleSystem},
+		TaskCancelled:  {TaskRolePoster, TaskRoleAdmin},
+		TaskExpired:    {TaskRoleSystem},
+	},
+	TaskInProgress: {
+		TaskOpen:        {TaskRoleSystem}, // 所有参与者退出
+		TaskAggregating: {TaskRolePoster, TaskRoleAdmin, TaskRoleSystem},
+		TaskRoundClosed: {TaskRolePoster, TaskRoleAdmin},
+		TaskCompleted:   {TaskRoleAdmin},
+		TaskCancelled:   {TaskRoleAdmin},
+		TaskExpired:     {TaskRoleSystem},
+	},
+	TaskAggregating: {
+		TaskInProgress:  {TaskRolePoster, TaskRoleAdmin},
+		TaskRoundClosed: {TaskRolePoster, TaskRoleAdmin},
+		TaskCompleted:   {TaskRoleAdmin},
+		TaskCancelled:   {TaskRoleAdmin},
+		TaskExpired:     {TaskRoleSystem}, // 超过聚合截止时间
+	},
+}
+
+// 任务当前状态，兼容没有 status 字段的旧任务
+func (t *Task) State() string {
+	if t.Status != "" {
+		return t.Status
+	}
+	switch {
+	case t.IsComplete && t.NextRoundTaskID != "":
+		return TaskRoundClosed
+	case t.IsComplete:
+		return TaskCompleted
+	case len(t.AcceptedUsers) > 0:
+		return TaskInProgress
+	default:
+		return TaskOpen
+	}
+}
+
+// 任务是否已经结束，结束后不能再修改
+func TaskClosed(state string) bool {
+	switch state {
+	case TaskRoundClosed, TaskCompleted, TaskCancelled, TaskExpired:
+		return true
+	}
+	return false
+}
+
+// 任务是否接受新的参与者
+func TaskAcceptsParticipants(state string) bool {
+	return state == TaskOpen || state == TaskInProgress
+}
+
+// 检查状态变更是否允许，roles 中任意一个角色有权限即可
+func CheckTaskTransition(from, to string, roles ...string) error {
+	targets, ok := taskTransitions[from]
+	if !ok {
+		return fmt.Errorf("任务处于 %s 状态，不能再变更", from)
+	}
+	allowed, ok := targets[to]
+	if !ok {
+		return fmt.Errorf("任务不能从 %s 变更为 %s", from, to)
+	}
+	for _, role := range roles {
+		for _, a := range allowed {
+			if role == a {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("无权将任务从 %s 变更为 %s", from, to)
+}
+
+// 变更任务状态，from 为读取到的当前状态，链上状态已变化时交易失败
+func TransitionTask(contract *client.Contract, taskID, from, to, actor, reason string) error {
+	fmt.Printf("\n--> Submit Transaction: TransitionTask, 任务 %s: %s -> %s\n", taskID, from, to)
+
+	/*
+		TransitionTask(ctx contractapi.TransactionContextInterface,
+			taskID string,
+			fromStatus string,
+			toStatus string,
+			actor string,
+			reason string
+		)
+		链码比较 fromStatus 与当前状态，进入 round-closed、completed、cancelled、expired 时同时设置 isComplete
+	*/
+	_, err := contract.SubmitTransaction("TransitionTask", taskID, from, to, actor, reason)
+	if err != nil {
+		return fmt.Errorf("变更任务状态失败: %w", err)
+	}
+
+	fmt.Printf("*** 任务 %s 状态已变更为 %s\n", taskID, to)
+	return nil
+}
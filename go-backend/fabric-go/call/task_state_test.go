@@ -0,0 +1,74 @@
+package invoke_fabric
+
+import "testing"
+
+func TestCheckTaskTransition(t *testing.T) {
+	cases := []struct {
+		name    string
+		from    string
+		to      string
+		roles   []string
+		wantErr bool
+	}{
+		{"发布者发布草稿", TaskDraft, TaskOpen, []string{TaskRolePoster}, false},
+		{"参与者不能发布草稿", TaskDraft, TaskOpen, []string{TaskRoleParticipant}, true},
+		{"草稿不能直接进行中", TaskDraft, TaskInProgress, []string{TaskRoleSystem}, true},
+		{"接受任务后进入进行中", TaskOpen, TaskInProgress, []string{TaskRoleSystem}, false},
+		{"发布者不能手动进入进行中", TaskOpen, TaskInProgress, []string{TaskRolePoster}, true},
+		{"发布者取消开放的任务", TaskOpen, TaskCancelled, []string{TaskRolePoster}, false},
+		{"开放的任务过期", TaskOpen, TaskExpired, []string{TaskRoleSystem}, false},
+		{"所有参与者退出后重新开放", TaskInProgress, TaskOpen, []string{TaskRoleSystem}, false},
+		{"发布者停止提交", TaskInProgress, TaskAggregating, []string{TaskRolePoster}, false},
+		{"发布者不能完成任务", TaskInProgress, TaskCompleted, []string{TaskRolePoster}, true},
+		{"管理员完成任务", TaskInProgress, TaskCompleted, []string{TaskRoleAdmin}, false},
+		{"发布者不能取消进行中的任务", TaskInProgress, TaskCancelled, []string{TaskRolePoster}, true},
+		{"任意一个角色有权限即可", TaskInProgress, TaskCancelled, []string{TaskRolePoster, TaskRoleAdmin}, false},
+		{"没有角色", TaskInProgress, TaskAggregating, nil, true},
+		{"重新开放提交", TaskAggregating, TaskInProgress, []string{TaskRolePoster}, false},
+		{"聚合超时过期", TaskAggregating, TaskExpired, []string{TaskRoleSystem}, false},
+		{"发布者进入下一轮", TaskAggregating, TaskRoundClosed, []string{TaskRolePoster}, false},
+		{"聚合中不能回到开放", TaskAggregating, TaskOpen, []string{TaskRoleSystem}, true},
+		{"已完成的任务不能变更", TaskCompleted, TaskInProgress, []string{TaskRoleAdmin}, true},
+		{"已取消的任务不能变更", TaskCancelled, TaskOpen, []string{TaskRoleAdmin}, true},
+		{"已过期的任务不能变更", TaskExpired, TaskOpen, []string{TaskRoleSystem}, true},
+		{"本轮结束后不能变更", TaskRoundClosed, TaskCompleted, []string{TaskRoleAdmin}, true},
+		{"未知状态", "unknown", TaskOpen, []string{TaskRoleAdmin}, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckTaskTransition(tc.from, tc.to, tc.roles...)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("%s -> %s %v: 错误为 %v", tc.from, tc.to, tc.roles, err)
+			}
+		})
+	}
+}
+
+// 结束状态与状态机一致：结束的状态不能再变更，未结束的状态都有出口
+func TestTaskClosedMatchesTransitions(t *testing.T) {
+	for _, state := range []string{TaskDraft, TaskOpen, TaskInProgress, TaskAggregating, TaskRoundClosed, TaskCompleted, TaskCancelled, TaskExpired} {
+		_, hasTargets := taskTransitions[state]
+		if TaskClosed(state) == hasTargets {
+			t.Errorf("状态 %s 的结束标记与状态机不一致", state)
+		}
+	}
+}
+
+// 没有 status 字段的旧任务按其他字段推断状态
+func TestTaskState(t *testing.T) {
+	cases := []struct {
+		task Task
+		want string
+	}{
+		{Task{Status: TaskAggregating, IsComplete: true}, TaskAggregating},
+		{Task{IsComplete: true, NextRoundTaskID: "t2"}, TaskRoundClosed},
+		{Task{IsComplete: true}, TaskCompleted},
+		{Task{AcceptedUsers: []string{"alice"}}, TaskInProgress},
+		{Task{}, TaskOpen},
+	}
+	for _, tc := range cases {
+		if got := tc.task.State(); got != tc.want {
+			t.Errorf("%+v 的状态为 %s，应为 %s", tc.task, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package invoke_fabric
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 转账记录中的一条分录，Amount 为负表示借记（转出），为正表示贷记（转入）
+type LedgerPosting struct {
+	Account string `json:"account"`
+	Amount  int    `json:"amount"`
+	Balance int    `json:"balance"` // 记账后的账户余额
+}
+
+// 代币流水类型
+const (
+	TransferKindTransfer = "transfer" // 账户之间转账
+	TransferKindMint     = "mint"     // 管理员增发
+	TransferKindBurn     = "burn"     // 管理员销毁
+)
+
+// 系统账户前缀，用户名不能使用
+const systemPrefix = "system:"
+
+// 增发和销毁的对手方系统账户，余额可以为负，表示流通中的代币总量
+const MintAccount = systemPrefix + "mint"
+
+// 是否为托管账户或系统账户的保留名称，注册用户时不能使用
+func IsReservedAccount(name string) bool {
+	return IsEscrowAccount(name) || strings.HasPrefix(name, systemPrefix)
+}
+
+// 一笔转账，写入后不可修改
+type TokenTransfer struct {
+	TransferID string          `json:"transferId"` // 交易 ID
+	Kind       string          `json:"kind"`
+	Memo       string          `json:"memo"`
+	Timestamp  string          `json:"timestamp"` // 交易时间戳
+	Postings   []LedgerPosting `json:"postings"`
+}
+
+// 复式记账：所有分录之和必须为零
+func (t *TokenTransfer) Balanced() bool {
+	sum := 0
+	for _, p := range t.Postings {
+		sum += p.Amount
+	}
+	return sum == 0 && len(t.Postings) >= 2
+}
+
+// 某个账户在这笔转账中的分录
+func (t *TokenTransfer) PostingFor(account string) (*LedgerPosting, bool) {
+	for i := range t.Postings {
+		if t.Postings[i].Account == account {
+			return &t.Postings[i], true
+		}
+	}
+	return nil, false
+}
+
+// 转账：在同一笔交易中借记转出方、贷记转入方并写入转账记录
+func TransferTokens(contract *client.Contract, sender, receiver string, amount int, memo string) (*TokenTransfer, error) {
+	fmt.Printf("\n--> Submit Transaction: TransferTokens, %s 向 %s 转账 %d\n", sender, receiver, amount)
+
+	if amount <= 0 {
+		return nil, fmt.Errorf("转账金额必须大于 0")
+	}
+	if sender == receiver {
+		return nil, fmt.Errorf("不能向自己转账")
+	}
+
+	/*
+		TransferTokens(ctx contractapi.TransactionContextInterface,
+			sender string,
+			receiver string,
+			amount int,
+			memo string
+		) (*TokenTransfer, error)
+		余额不足时返回错误，整笔交易不写入任何状态
+	*/
+	result, err := contract.SubmitTransaction("TransferTokens",
+		sender,
+		receiver,
+		fmt.Sprintf("%d", amount),
+		memo,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("转账失败: %w", err)
+	}
+
+	var transfer TokenTransfer
+	if err := json.Unmarshal(result, &transfer); err != nil {
+		return nil, fmt.Errorf("解析转账记录失败: %w", err)
+	}
+
+	fmt.Printf("*** 转账成功, 交易ID: %s\n", transfer.TransferID)
+	return &transfer, nil
+}
+
+// 查询账户的全部转账记录
+func GetTransferHistory(contract *client.Contract, account string) ([]TokenTransfer, error) {
+	fmt.Printf("\n--> Evaluate Transaction: GetTransferHistory, 查询账户 %s 的转账记录\n", account)
+
+	result, err := contract.EvaluateTransaction("GetTransferHistory", account)
+	if err != nil {
+		return nil, fmt.Errorf("查询转账记录失败: %w", err)
+	}
+
+	if len(result) == 0 || string(result) == "null" {
+		return []TokenTransfer{}, nil
+	}
+
+	var transfers []TokenTransfer
+	if err := json.Unmarshal(result, &transfers); err != nil {
+		return nil, fmt.Errorf("解析转账记录失败: %w", err)
+	}
+
+	// 记录不平衡说明账本被篡改或链码有缺陷
+	for _, t := range transfers {
+		if !t.Balanced() {
+			return nil, fmt.Errorf("转账记录 %s 借贷不平衡", t.TransferID)
+		}
+	}
+	return transfers, nil
+}
+
+// 幂等转账：同一个幂等键只会转账一次，重复提交时返回第一次的转账记录
+func TransferTokensOnce(contract *client.Contract, idempotencyKey, sender, receiver string, amount int, memo string) (*TokenTransfer, error) {
+	fmt.Printf("\n--> Submit Transaction: TransferTokensOnce, %s 向 %s 转账 %d, 幂等键 %s\n", sender, receiver, amount, idempotencyKey)
+
+	if idempotencyKey == "" {
+		return nil, fmt.Errorf("幂等键不能为空")
+	}
+	if amount <= 0 {
+		return nil, fmt.Errorf("转账金额必须大于 0")
+	}
+
+	/*
+		TransferTokensOnce(ctx contractapi.TransactionContextInterface,
+			idempotencyKey string,
+			sender string,
+			receiver string,
+			amount int,
+			memo string
+		) (*TokenTransfer, error)
+		幂等键已存在时不再转账，直接返回该键对应的转账记录
+	*/
+	result, err := contract.SubmitTransaction("TransferTokensOnce",
+		idempotencyKey,
+		sender,
+		receiver,
+		fmt.Sprintf("%d", amount),
+		memo,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("转账失败: %w", err)
+	}
+
+	var transfer TokenTransfer
+	if err := json.Unmarshal(result, &transfer); err != nil {
+		return nil, fmt.Errorf("解析转账记录失败: %w", err)
+	}
+
+	fmt.Printf("*** 转账完成, 交易ID: %s\n", transfer.TransferID)
+	return &transfer, nil
+}
+
+// 管理员增发代币，幂等键不为空时同一个键只会增发一次
+func MintTokens(contract *client.Contract, idempotencyKey, account string, amount int, memo string) (*TokenTransfer, error) {
+	return mintOrBurn(contract, "MintTokens", idempotencyKey, account, amount, memo)
+}
+
+// 管理员销毁代币，账户余额不足时失败
+func BurnTokens(contract *client.Contract, idempotencyKey, account string, amount int, memo string) (*TokenTransfer, error) {
+	return mintOrBurn(contract, "BurnTokens", idempotencyKey, account, amount, memo)
+}
+
+func mintOrBurn(contract *client.Contract, function, idempotencyKey, account string, amount int, memo string) (*TokenTransfer, error) {
+	fmt.Printf("\n--> Submit Transaction: %s, 账户 %s 数量 %d\n", function, account, amount)
+
+	if amount <= 0 {
+		return nil, fmt.Errorf("数量必须大于 0")
+	}
+	if IsEscrowAccount(account) || account == MintAccount {
+		return nil, fmt.Errorf("不能对系统账户 %s 增发或销毁", account)
+	}
+
+	/*
+		MintTokens / BurnTokens(ctx contractapi.TransactionContextInterface,
+			idempotencyKey string,
+			account string,
+			amount int,
+			memo string
+		) (*TokenTransfer, error)
+		对手方为 system:mint 账户，记录类型分别为 mint 和 burn
+	*/
+	result, err := contract.SubmitTransaction(function,
+		idempotencyKey,
+		account,
+		fmt.Sprintf("%d", amount),
+		memo,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s 失败: %w", function, err)
+	}
+
+	var transfer TokenTransfer
+	if err := json.Unmarshal(result, &transfer); err != nil {
+		return nil, fmt.Errorf("解析转账记录失败: %w", err)
+	}
+
+	fmt.Printf("*** %s 成功, 交易ID: %s\n", function, transfer.TransferID)
+	return &transfer, nil
+}
@@ -0,0 +1,100 @@
+package invoke_fabric
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 软删除标记，删除后记录仍保留在账本中
+type Tombstone struct {
+	DeletedAt    string `json:"deletedAt,omitempty"` // 删除时间（交易时间戳），为空表示未删除
+	DeletedBy    string `json:"deletedBy,omitempty"`
+	DeleteReason string `json:"deleteReason,omitempty"`
+}
+
+// 是否已被删除
+func (t Tombstone) Deleted() bool {
+	return t.DeletedAt != ""
+}
+
+// 软删除用户
+func SoftDeleteUser(contract *client.Contract, username, actor, reason string) error {
+	fmt.Printf("\n--> Submit Transaction: SoftDeleteUser, 删除用户 %s\n", username)
+
+	/*
+		SoftDeleteUser(ctx contractapi.TransactionContextInterface,
+			username string,
+			actor string,
+			reason string
+		)
+		用户还在参与未结束的任务时返回错误，后端先取消或退出这些任务
+	*/
+	_, err := contract.SubmitTransaction("SoftDeleteUser", username, actor, reason)
+	if err != nil {
+		return fmt.Errorf("删除用户失败: %w", err)
+	}
+
+	fmt.Printf("*** 用户 %s 已标记为删除\n", username)
+	return nil
+}
+
+// 软删除任务
+func SoftDeleteTask(contract *client.Contract, taskID, actor, reason string) error {
+	fmt.Printf("\n--> Submit Transaction: SoftDeleteTask, 删除任务 %s\n", taskID)
+
+	/*
+		SoftDeleteTask(ctx contractapi.TransactionContextInterface,
+			taskID string,
+			actor string,
+			reason string
+		)
+		在同一个交易中把任务从参与者的 Accepted 列表移除，任务自身的 acceptedUsers 保留作为历史
+	*/
+	_, err := contract.SubmitTransaction("SoftDeleteTask", taskID, actor, reason)
+	if err != nil {
+		return fmt.Errorf("删除任务失败: %w", err)
+	}
+
+	fmt.Printf("*** 任务 %s 已标记为删除\n", taskID)
+	return nil
+}
+
+// 恢复已删除的用户
+func RestoreUser(contract *client.Contract, username, actor string) error {
+	fmt.Printf("\n--> Submit Transaction: RestoreUser, 恢复用户 %s\n", username)
+
+	/*
+		RestoreUser(ctx contractapi.TransactionContextInterface,
+			username string,
+			actor string
+		)
+	*/
+	_, err := contract.SubmitTransaction("RestoreUser", username, actor)
+	if err != nil {
+		return fmt.Errorf("恢复用户失败: %w", err)
+	}
+
+	fmt.Printf("*** 用户 %s 已恢复\n", username)
+	return nil
+}
+
+// 恢复已删除的任务
+func RestoreTask(contract *client.Contract, taskID, actor string) error {
+	fmt.Printf("\n--> Submit Transaction: RestoreTask, 恢复任务 %s\n", taskID)
+
+	/*
+		RestoreTask(ctx contractapi.TransactionContextInterface,
+			taskID string,
+			actor string
+		)
+		在同一个交易中把任务重新加入参与者的 Accepted 列表
+	*/
+	_, err := contract.SubmitTransaction("RestoreTask", taskID, actor)
+	if err != nil {
+		return fmt.Errorf("恢复任务失败: %w", err)
+	}
+
+	fmt.Printf("*** 任务 %s 已恢复\n", taskID)
+	return nil
+}
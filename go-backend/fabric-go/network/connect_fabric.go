@@ -88,7 +88,6 @@ func GenerateCertificateAndUpdateConfig(username, password, org string, config *
 
 	// 设置环境变量
 	fabricCAClientHome := fmt.Sprintf("./%s/%s/", org, username)
-	fmt.Sprintf("./%s/%s/", org, username)
 	tlsCertPath := fmt.Sprintf("/tmp/hyperledger/%s/peer1/assets/ca/%s-ca-cert.pem", org, org)
 	configtlsCertPath := fmt.Sprintf("/tmp/hyperledger/%s/peer1/tls-msp/tlscacerts/tls-0-0-0-0-7052.pem", org)
 	fmt.Printf("设置环境变量:\n")
@@ -0,0 +1,133 @@
+package inspect
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+)
+
+// 支持的模型格式
+const (
+	FormatSafetensors = "safetensors"
+	FormatONNX        = "onnx"
+)
+
+var ErrUnknownFormat = errors.New("无法识别的模型格式，仅支持 safetensors 和 ONNX")
+
+// 模型中的一个张量，只记录元数据
+type Tensor struct {
+	Name  string  `json:"name"`
+	DType string  `json:"dtype"`
+	Shape []int64 `json:"shape"`
+}
+
+// 元素个数，维度为负数或乘积超出 int64 时返回 false
+func (t Tensor) Elements() (int64, bool) {
+	n := int64(1)
+	for _, d := range t.Shape {
+		if d < 0 || d > 0 && n > math.MaxInt64/d {
+			return 0, false
+		}
+		n *= d
+	}
+	return n, true
+}
+
+// 模型结构：safetensors 的张量或 ONNX 的初始化器和计算图，指纹相同表示结构相同
+type Architecture struct {
+	Format      string            `json:"format"`
+	Fingerprint string            `json:"fingerprint"`
+	Parameters  int64             `json:"parameters"` // 参数总数
+	Tensors     []Tensor          `json:"tensors"`    // 按名称排序
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Graph       *Graph            `json:"graph,omitempty"` // 仅 ONNX
+}
+
+// 计算结构指纹：格式、每个张量的名称、类型和形状，ONNX 另加按顺序排列的算子。
+// 张量的元素个数或参数总数溢出时返回错误
+func (a *Architecture) finish() error {
+	slices.SortFunc(a.Tensors, func(x, y Tensor) int { return strings.Compare(x.Name, y.Name) })
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", a.Format)
+	a.Parameters = 0
+	for _, t := range a.Tensors {
+		fmt.Fprintf(h, "tensor %q %s %v\n", t.Name, t.DType, t.Shape)
+		n, ok := t.Elements()
+		if !ok || a.Parameters > math.MaxInt64-n {
+			return fmt.Errorf("张量 %s 的形状无效: %v", t.Name, t.Shape)
+		}
+		a.Parameters += n
+	}
+	if a.Graph != nil {
+		for _, op := range a.Graph.ops {
+			fmt.Fprintf(h, "op %s\n", op)
+		}
+	}
+	a.Fingerprint = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
+
+// 解析模型文件的结构，权重数据只读取并丢弃，不保存在内存中
+func Inspect(r io.Reader) (*Architecture, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	head, err := br.Peek(9)
+	if err != nil && len(head) == 0 {
+		return nil, ErrUnknownFormat
+	}
+	// safetensors 以 8 字节小端的头部长度开头，头部是 JSON 对象
+	if len(head) == 9 && head[8] == '{' && binary.LittleEndian.Uint64(head) <= maxHeaderSize {
+		return inspectSafetensors(br)
+	}
+	arch, err := inspectONNX(br)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownFormat, err)
+	}
+	return arch, nil
+}
+
+// 在 r 被读取的同时解析模型结构，读取结束后调用 result 取得结果；
+// 读取方没有读到末尾就调用 result 时返回错误
+func Tee(r io.Reader) (io.Reader, func() (*Architecture, error)) {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	var arch *Architecture
+	var err error
+	go func() {
+		defer close(done)
+		arch, err = Inspect(pr)
+		// 解析结束后继续读完，避免阻塞写入
+		io.Copy(io.Discard, pr)
+	}()
+
+	result := func() (*Architecture, error) {
+		pw.CloseWithError(io.ErrUnexpectedEOF)
+		<-done
+		return arch, err
+	}
+	return &teeReader{r: r, w: pw}, result
+}
+
+type teeReader struct {
+	r io.Reader
+	w *io.PipeWriter
+}
+
+func (t *teeReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.w.Write(p[:n])
+	}
+	if errors.Is(err, io.EOF) {
+		t.w.Close()
+	} else if err != nil {
+		t.w.CloseWithError(err)
+	}
+	return n, err
+}
@@ -0,0 +1,411 @@
+package inspect
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ONNX 计算图的元数据
+type Graph struct {
+	Name      string           `json:"name"`
+	IRVersion int64            `json:"irVersion"`
+	Producer  string           `json:"producer"`
+	Opsets    map[string]int64 `json:"opsets"` // 算子集域名到版本，默认域为 ai.onnx
+	Inputs    []ValueInfo      `json:"inputs"`
+	Outputs   []ValueInfo      `json:"outputs"`
+	OpCounts  map[string]int   `json:"opCounts"` // 各类算子的数量
+
+	ops []string // 按图中顺序排列的算子，参与指纹计算
+}
+
+// 计算图的输入或输出，动态维度以名称表示
+type ValueInfo struct {
+	Name  string   `json:"name"`
+	DType string   `json:"dtype"`
+	Shape []string `json:"shape"`
+}
+
+// onnx.proto 中 TensorProto.DataType 的名称
+var onnxDTypes = map[uint64]string{
+	1: "FLOAT", 2: "UINT8", 3: "INT8", 4: "UINT16", 5: "INT16", 6: "INT32", 7: "INT64", 8: "STRING",
+	9: "BOOL", 10: "FLOAT16", 11: "DOUBLE", 12: "UINT32", 13: "UINT64", 14: "COMPLEX64", 15: "COMPLEX128",
+	16: "BFLOAT16", 17: "FLOAT8E4M3FN", 18: "FLOAT8E4M3FNUZ", 19: "FLOAT8E5M2", 20: "FLOAT8E5M2FNUZ",
+	21: "UINT4", 22: "INT4",
+}
+
+func onnxDType(v uint64) string {
+	if name, ok := onnxDTypes[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("TYPE%d", v)
+}
+
+// protobuf 的线类型
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireLen    = 2
+	wireI32    = 5
+)
+
+// 字符串和小消息的长度上限，超过时视为文件损坏
+const maxFieldSize = 16 << 20
+
+// 流式 protobuf 解码，记录已读取的字节数，长度字段内容可以直接跳过而不读入内存
+type pbReader struct {
+	r   *bufio.Reader
+	pos int64
+}
+
+func (p *pbReader) ReadByte() (byte, error) {
+	b, err := p.r.ReadByte()
+	if err == nil {
+		p.pos++
+	}
+	return b, err
+}
+
+func (p *pbReader) varint() (uint64, error) {
+	v, err := binary.ReadUvarint(p)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return v, err
+}
+
+// 读取字段标签，输入结束时返回 io.EOF
+func (p *pbReader) tag() (int, int, error) {
+	start := p.pos
+	v, err := binary.ReadUvarint(p)
+	if err != nil {
+		if errors.Is(err, io.EOF) && p.pos == start {
+			return 0, 0, io.EOF
+		}
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	num, wire := int(v>>3), int(v&7)
+	if num == 0 {
+		return 0, 0, fmt.Errorf("字段编号无效")
+	}
+	return num, wire, nil
+}
+
+func (p *pbReader) length() (int64, error) {
+	n, err := p.varint()
+	if err != nil {
+		return 0, err
+	}
+	if n > 1<<62 {
+		return 0, fmt.Errorf("字段长度无效")
+	}
+	return int64(n), nil
+}
+
+func (p *pbReader) discard(n int64) error {
+	m, err := io.CopyN(io.Discard, p.r, n)
+	p.pos += m
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func (p *pbReader) bytes() ([]byte, error) {
+	n, err := p.length()
+	if err != nil {
+		return nil, err
+	}
+	if n > maxFieldSize {
+		return nil, fmt.Errorf("字段长度 %d 超过上限", n)
+	}
+	b := make([]byte, n)
+	m, err := io.ReadFull(p.r, b)
+	p.pos += int64(m)
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return b, nil
+}
+
+func (p *pbReader) string() (string, error) {
+	b, err := p.bytes()
+	return string(b), err
+}
+
+func (p *pbReader) skip(wire int) error {
+	switch wire {
+	case wireVarint:
+		_, err := p.varint()
+		return err
+	case wireI64:
+		return p.discard(8)
+	case wireI32:
+		return p.discard(4)
+	case wireLen:
+		n, err := p.length()
+		if err != nil {
+			return err
+		}
+		return p.discard(n)
+	default:
+		return fmt.Errorf("不支持的线类型 %d", wire)
+	}
+}
+
+// 逐个处理长度为 n 的嵌套消息中的字段，field 必须读取或跳过字段内容
+func (p *pbReader) message(n int64, field func(num, wire int) error) error {
+	end := p.pos + n
+	for p.pos < end {
+		num, wire, err := p.tag()
+		if errors.Is(err, io.EOF) {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if err := field(num, wire); err != nil {
+			return err
+		}
+	}
+	if p.pos != end {
+		return fmt.Errorf("嵌套消息长度不符")
+	}
+	return nil
+}
+
+// 读取长度前缀后处理嵌套消息
+func (p *pbReader) nested(wire int, field func(num, wire int) error) error {
+	if wire != wireLen {
+		return fmt.Errorf("字段类型错误")
+	}
+	n, err := p.length()
+	if err != nil {
+		return err
+	}
+	return p.message(n, field)
+}
+
+// 读取 repeated int64，兼容打包和未打包两种编码
+func (p *pbReader) int64s(wire int, dst *[]int64) error {
+	if wire == wireVarint {
+		v, err := p.varint()
+		*dst = append(*dst, int64(v))
+		return err
+	}
+	if wire != wireLen {
+		return fmt.Errorf("字段类型错误")
+	}
+	n, err := p.length()
+	if err != nil {
+		return err
+	}
+	end := p.pos + n
+	for p.pos < end {
+		v, err := p.varint()
+		if err != nil {
+			return err
+		}
+		*dst = append(*dst, int64(v))
+	}
+	// 最后一个 varint 越过字段末尾时后续字段会错位
+	if p.pos != end {
+		return fmt.Errorf("打包字段长度不符")
+	}
+	return nil
+}
+
+// 解析 ONNX ModelProto，初始化器的权重数据直接跳过
+func inspectONNX(r *bufio.Reader) (*Architecture, error) {
+	p := &pbReader{r: r}
+	arch := &Architecture{Format: FormatONNX, Tensors: []Tensor{}}
+	graph := &Graph{Opsets: map[string]int64{}, OpCounts: map[string]int{}, Inputs: []ValueInfo{}, Outputs: []ValueInfo{}}
+	hasGraph := false
+
+	for {
+		num, wire, err := p.tag()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case num == 1 && wire == wireVarint: // ir_version
+			v, err := p.varint()
+			if err != nil {
+				return nil, err
+			}
+			graph.IRVersion = int64(v)
+		case num == 2 && wire == wireLen: // producer_name
+			if graph.Producer, err = p.string(); err != nil {
+				return nil, err
+			}
+		case num == 7: // graph
+			if err := p.nested(wire, func(num, wire int) error { return graphField(p, arch, graph, num, wire) }); err != nil {
+				return nil, fmt.Errorf("解析计算图失败: %w", err)
+			}
+			hasGraph = true
+		case num == 8: // opset_import
+			var domain string
+			var version uint64
+			err := p.nested(wire, func(num, wire int) error {
+				var err error
+				switch {
+				case num == 1 && wire == wireLen:
+					domain, err = p.string()
+				case num == 2 && wire == wireVarint:
+					version, err = p.varint()
+				default:
+					err = p.skip(wire)
+				}
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+			if domain == "" {
+				domain = "ai.onnx"
+			}
+			graph.Opsets[domain] = int64(version)
+		default:
+			if err := p.skip(wire); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !hasGraph || graph.IRVersion == 0 {
+		return nil, fmt.Errorf("不是 ONNX 模型")
+	}
+	arch.Graph = graph
+	if err := arch.finish(); err != nil {
+		return nil, err
+	}
+	return arch, nil
+}
+
+// GraphProto 的字段
+func graphField(p *pbReader, arch *Architecture, graph *Graph, num, wire int) error {
+	switch num {
+	case 1: // node
+		var domain, op string
+		err := p.nested(wire, func(num, wire int) error {
+			var err error
+			switch {
+			case num == 4 && wire == wireLen:
+				op, err = p.string()
+			case num == 7 && wire == wireLen:
+				domain, err = p.string()
+			default:
+				err = p.skip(wire) // 属性中可能有常量张量，直接跳过
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if domain != "" {
+			op = domain + "." + op
+		}
+		graph.ops = append(graph.ops, op)
+		graph.OpCounts[op]++
+	case 2: // name
+		if wire != wireLen {
+			return p.skip(wire)
+		}
+		var err error
+		graph.Name, err = p.string()
+		return err
+	case 5: // initializer
+		tensor := Tensor{Shape: []int64{}}
+		err := p.nested(wire, func(num, wire int) error {
+			switch {
+			case num == 1: // dims
+				return p.int64s(wire, &tensor.Shape)
+			case num == 2 && wire == wireVarint: // data_type
+				v, err := p.varint()
+				tensor.DType = onnxDType(v)
+				return err
+			case num == 8 && wire == wireLen: // name
+				var err error
+				tensor.Name, err = p.string()
+				return err
+			default: // raw_data 等权重数据
+				return p.skip(wire)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		arch.Tensors = append(arch.Tensors, tensor)
+	case 11, 12: // input, output
+		info, err := valueInfo(p, wire)
+		if err != nil {
+			return err
+		}
+		if num == 11 {
+			graph.Inputs = append(graph.Inputs, info)
+		} else {
+			graph.Outputs = append(graph.Outputs, info)
+		}
+	default:
+		return p.skip(wire)
+	}
+	return nil
+}
+
+// ValueInfoProto：name 和 TypeProto.tensor_type 中的元素类型与形状
+func valueInfo(p *pbReader, wire int) (ValueInfo, error) {
+	info := ValueInfo{Shape: []string{}}
+	err := p.nested(wire, func(num, wire int) error {
+		if num == 1 && wire == wireLen {
+			var err error
+			info.Name, err = p.string()
+			return err
+		}
+		if num != 2 {
+			return p.skip(wire)
+		}
+		return p.nested(wire, func(num, wire int) error { // TypeProto
+			if num != 1 {
+				return p.skip(wire)
+			}
+			return p.nested(wire, func(num, wire int) error { // TypeProto.Tensor
+				switch {
+				case num == 1 && wire == wireVarint:
+					v, err := p.varint()
+					info.DType = onnxDType(v)
+					return err
+				case num == 2:
+					return p.nested(wire, func(num, wire int) error { // TensorShapeProto
+						if num != 1 {
+							return p.skip(wire)
+						}
+						dim := "?"
+						err := p.nested(wire, func(num, wire int) error {
+							var err error
+							switch {
+							case num == 1 && wire == wireVarint:
+								var v uint64
+								v, err = p.varint()
+								dim = fmt.Sprint(int64(v))
+							case num == 2 && wire == wireLen:
+								dim, err = p.string()
+							default:
+								err = p.skip(wire)
+							}
+							return err
+						})
+						info.Shape = append(info.Shape, dim)
+						return err
+					})
+				default:
+					return p.skip(wire)
+				}
+			})
+		})
+	})
+	return info, err
+}
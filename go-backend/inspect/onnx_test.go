@@ -0,0 +1,145 @@
+package inspect
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+// 按 protobuf 线格式拼接字段
+type pb []byte
+
+func (b pb) tag(num, wire int) pb {
+	return binary.AppendUvarint(b, uint64(num<<3|wire))
+}
+
+func (b pb) varint(num int, v uint64) pb {
+	return binary.AppendUvarint(b.tag(num, wireVarint), v)
+}
+
+func (b pb) bytes(num int, data []byte) pb {
+	b = binary.AppendUvarint(b.tag(num, wireLen), uint64(len(data)))
+	return append(b, data...)
+}
+
+func (b pb) str(num int, s string) pb {
+	return b.bytes(num, []byte(s))
+}
+
+func packed(values ...uint64) []byte {
+	var b []byte
+	for _, v := range values {
+		b = binary.AppendUvarint(b, v)
+	}
+	return b
+}
+
+func bufioReader(data []byte) *bufio.Reader {
+	return bufio.NewReader(bytes.NewReader(data))
+}
+
+// 最小的 ONNX 模型：一个 MatMul 节点、一个初始化器、一个输入和一个输出；dims 为初始化器编码后的维度字段
+func onnxModel(dims []byte) []byte {
+	dim := func(v uint64) []byte { return pb(nil).varint(1, v) }
+	input := pb(nil).str(1, "x").bytes(2, pb(nil).bytes(1, pb(nil).varint(1, 1).bytes(2,
+		pb(nil).bytes(1, pb(nil).str(2, "batch")).bytes(1, dim(2)))))
+	output := pb(nil).str(1, "y").bytes(2, pb(nil).bytes(1, pb(nil).varint(1, 1)))
+	initializer := pb(dims).varint(2, 1).str(8, "w").bytes(9, make([]byte, 24))
+	graph := pb(nil).
+		bytes(1, pb(nil).str(1, "x").str(1, "w").str(2, "y").str(4, "MatMul")).
+		str(2, "main").
+		bytes(5, initializer).
+		bytes(11, input).
+		bytes(12, output)
+	return pb(nil).
+		varint(1, 8).
+		str(2, "pytorch").
+		bytes(7, graph).
+		bytes(8, pb(nil).varint(2, 17))
+}
+
+func TestInspectONNX(t *testing.T) {
+	arch, err := Inspect(bytes.NewReader(onnxModel(pb(nil).bytes(1, packed(2, 3)))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := arch.Graph
+	if arch.Format != FormatONNX || arch.Parameters != 6 || len(arch.Tensors) != 1 || g == nil {
+		t.Fatalf("解析结果 %+v", arch)
+	}
+	if tensor := arch.Tensors[0]; tensor.Name != "w" || tensor.DType != "FLOAT" || len(tensor.Shape) != 2 {
+		t.Errorf("初始化器 %+v", tensor)
+	}
+	if g.Name != "main" || g.IRVersion != 8 || g.Producer != "pytorch" || g.Opsets["ai.onnx"] != 17 || g.OpCounts["MatMul"] != 1 {
+		t.Errorf("计算图 %+v", g)
+	}
+	if len(g.Inputs) != 1 || g.Inputs[0].Name != "x" || g.Inputs[0].DType != "FLOAT" ||
+		len(g.Inputs[0].Shape) != 2 || g.Inputs[0].Shape[0] != "batch" || g.Inputs[0].Shape[1] != "2" {
+		t.Errorf("输入 %+v", g.Inputs)
+	}
+	if len(g.Outputs) != 1 || g.Outputs[0].Name != "y" {
+		t.Errorf("输出 %+v", g.Outputs)
+	}
+
+	// 未打包的维度与打包的维度得到相同的指纹
+	same := onnxModel(pb(nil).varint(1, 2).varint(1, 3))
+	other, err := Inspect(bytes.NewReader(same))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Fingerprint != arch.Fingerprint {
+		t.Error("打包和未打包的维度指纹不同")
+	}
+}
+
+// 损坏的文件返回错误而不是读到错位的字段
+func TestInspectONNXCorrupted(t *testing.T) {
+	valid := onnxModel(pb(nil).bytes(1, packed(2, 3)))
+	truncatedVarint := append(pb(nil).varint(1, 8), 0x12, 0x80) // producer_name 的长度只有续位字节
+	oversized := pb(nil).varint(1, 8).tag(2, wireLen)
+	oversized = binary.AppendUvarint(oversized, maxFieldSize+1)
+	oversized = append(oversized, "pytorch"...)
+	beyondInput := pb(nil).varint(1, 8).tag(7, wireLen)
+	beyondInput = binary.AppendUvarint(beyondInput, 1000)
+	// 打包的维度声明 1 字节，但 varint 有 2 字节，越过字段末尾
+	overrun := append(pb(nil).tag(1, wireLen), 1, 0x80, 0x01)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"截断", valid[:len(valid)-5]},
+		{"截断的 varint", truncatedVarint},
+		{"字符串超过上限", oversized},
+		{"长度超出文件", beyondInput},
+		{"打包字段越界", onnxModel(overrun)},
+		{"缺少计算图", pb(nil).varint(1, 8)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			arch, err := Inspect(bytes.NewReader(tt.data))
+			if err == nil {
+				t.Fatalf("应返回错误，得到 %+v", arch)
+			}
+			if !errors.Is(err, ErrUnknownFormat) {
+				t.Errorf("错误 %v 应包装 ErrUnknownFormat", err)
+			}
+		})
+	}
+
+	p := &pbReader{r: bufioReader(overrun)}
+	num, wire, err := p.tag()
+	if err != nil || num != 1 {
+		t.Fatal(num, err)
+	}
+	var dims []int64
+	if err := p.int64s(wire, &dims); err == nil {
+		t.Errorf("打包字段越界应返回错误，得到 %v", dims)
+	}
+	if _, err := (&pbReader{r: bufioReader([]byte{0x80})}).varint(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("截断的 varint 应返回 io.ErrUnexpectedEOF，实际 %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package inspect
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// safetensors 规定头部不超过 100MB，用于识别格式
+const maxHeaderSize = 100 << 20
+
+// 实际解析的头部上限：头部只有张量描述，大模型通常只有几百 KB
+const maxInspectHeaderSize = 16 << 20
+
+// 已知数据类型的字节数，用于校验数据偏移
+var safetensorsDTypeSize = map[string]int64{
+	"BOOL": 1, "U8": 1, "I8": 1, "F8_E4M3": 1, "F8_E5M2": 1,
+	"U16": 2, "I16": 2, "F16": 2, "BF16": 2,
+	"U32": 4, "I32": 4, "F32": 4,
+	"U64": 8, "I64": 8, "F64": 8,
+}
+
+type safetensorsEntry struct {
+	DType       string   `json:"dtype"`
+	Shape       []int64  `json:"shape"`
+	DataOffsets [2]int64 `json:"data_offsets"`
+}
+
+// 解析 safetensors 头部，权重数据不读取
+func inspectSafetensors(r *bufio.Reader) (*Architecture, error) {
+	var size uint64
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return nil, fmt.Errorf("读取 safetensors 头部长度失败: %w", err)
+	}
+	if size > maxInspectHeaderSize {
+		return nil, fmt.Errorf("safetensors 头部过大: %d 字节，最多解析 %d 字节", size, maxInspectHeaderSize)
+	}
+	// 按实际读到的数据分配，不按声明的长度预先分配
+	header, err := io.ReadAll(io.LimitReader(r, int64(size)))
+	if err != nil {
+		return nil, fmt.Errorf("读取 safetensors 头部失败: %w", err)
+	}
+	if uint64(len(header)) != size {
+		return nil, fmt.Errorf("读取 safetensors 头部失败: %w", io.ErrUnexpectedEOF)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(header, &raw); err != nil {
+		return nil, fmt.Errorf("解析 safetensors 头部失败: %w", err)
+	}
+	arch := &Architecture{Format: FormatSafetensors, Tensors: []Tensor{}}
+	for name, value := range raw {
+		if name == "__metadata__" {
+			if err := json.Unmarshal(value, &arch.Metadata); err != nil {
+				return nil, fmt.Errorf("safetensors 元数据无效: %w", err)
+			}
+			continue
+		}
+		var entry safetensorsEntry
+		if err := json.Unmarshal(value, &entry); err != nil {
+			return nil, fmt.Errorf("张量 %s 的描述无效: %w", name, err)
+		}
+		tensor := Tensor{Name: name, DType: entry.DType, Shape: entry.Shape}
+		if tensor.Shape == nil {
+			tensor.Shape = []int64{}
+		}
+		begin, end := entry.DataOffsets[0], entry.DataOffsets[1]
+		if begin < 0 || end < begin {
+			return nil, fmt.Errorf("张量 %s 的数据偏移无效", name)
+		}
+		elements, ok := tensor.Elements()
+		if !ok {
+			return nil, fmt.Errorf("张量 %s 的形状无效", name)
+		}
+		if width, ok := safetensorsDTypeSize[entry.DType]; ok && (elements > math.MaxInt64/width || elements*width != end-begin) {
+			return nil, fmt.Errorf("张量 %s 的数据长度与形状不符", name)
+		}
+		arch.Tensors = append(arch.Tensors, tensor)
+	}
+	if err := arch.finish(); err != nil {
+		return nil, err
+	}
+	return arch, nil
+}
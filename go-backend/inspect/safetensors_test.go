@@ -0,0 +1,58 @@
+package inspect
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func safetensorsFile(header string) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint64(len(header)))
+	buf.WriteString(header)
+	return buf.Bytes()
+}
+
+func TestInspectSafetensors(t *testing.T) {
+	arch, err := Inspect(bytes.NewReader(safetensorsFile(`{"w":{"dtype":"F32","shape":[2,3],"data_offsets":[0,24]}}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arch.Format != FormatSafetensors || arch.Parameters != 6 || len(arch.Tensors) != 1 {
+		t.Errorf("解析结果 %+v", arch)
+	}
+}
+
+// 形状的乘积溢出时拒绝，不能绕过数据长度检查
+func TestInspectSafetensorsRejectsOverflow(t *testing.T) {
+	headers := []string{
+		`{"w":{"dtype":"F32","shape":[4294967296,4294967296],"data_offsets":[0,0]}}`,
+		`{"w":{"dtype":"F64","shape":[1152921504606846976,2],"data_offsets":[0,0]}}`,
+		`{"w":{"dtype":"F32","shape":[2305843009213693952],"data_offsets":[0,0]}}`,
+		`{"w":{"dtype":"X","shape":[9223372036854775807],"data_offsets":[0,0]},"v":{"dtype":"X","shape":[1],"data_offsets":[0,0]}}`,
+		`{"w":{"dtype":"F32","shape":[-1,-4],"data_offsets":[0,16]}}`,
+	}
+	for _, header := range headers {
+		if arch, err := Inspect(bytes.NewReader(safetensorsFile(header))); err == nil {
+			t.Errorf("%s 应返回错误，得到 %+v", header, arch)
+		}
+	}
+}
+
+// 声明的头部超过解析上限时直接拒绝；未超过上限但数据不足时按实际读到的长度报错
+func TestInspectSafetensorsHeaderSize(t *testing.T) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint64(maxInspectHeaderSize+1))
+	buf.WriteString(`{"w":`)
+	if _, err := Inspect(bytes.NewReader(buf.Bytes())); err == nil || !strings.Contains(err.Error(), "头部过大") {
+		t.Errorf("头部过大时返回 %v", err)
+	}
+
+	buf.Reset()
+	binary.Write(&buf, binary.LittleEndian, uint64(maxInspectHeaderSize))
+	buf.WriteString(`{"w":`)
+	if _, err := Inspect(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Error("头部不完整时应返回错误")
+	}
+}
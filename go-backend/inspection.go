@@ -0,0 +1,129 @@
+package main
+
+import (
+	"backend/envelope"
+	invoke_fabric "backend/fabric-go/call"
+	connect_fabric "backend/fabric-go/network"
+	"backend/inspect"
+	"backend/middleware"
+	"backend/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 解析模型结构的审计标记
+const downloadInspect = "inspect"
+
+// 写入存储的同时解析模型结构，套在加密存储外层时解析的是明文
+type inspectingBackend struct {
+	storage.Backend
+}
+
+func inspecting(backend storage.Backend) storage.Backend {
+	return inspectingBackend{Backend: backend}
+}
+
+func (b inspectingBackend) Put(ctx context.Context, r io.Reader, name string) (*storage.Object, error) {
+	tee, result := inspect.Tee(r)
+	obj, err := b.Backend.Put(ctx, tee, name)
+	arch, inspectErr := result()
+	if err != nil {
+		return nil, err
+	}
+	if inspectErr != nil {
+		fmt.Printf("模型文件 %s 的结构无法解析: %v\n", obj.CID, inspectErr)
+		return obj, nil
+	}
+	obj.Architecture = arch
+	return obj, nil
+}
+
+// 查询模型文件的结构：加密模型使用上传时解析、随信封保存在账本上的结果，明文文件从存储中读取解析
+func modelArchitecture(ctx context.Context, backend storage.Backend, cid string) (*inspect.Architecture, error) {
+	env, err := modelKeys.Get(cid)
+	if err == nil {
+		if env.Architecture == nil {
+			return nil, fmt.Errorf("加密模型只能在上传时解析结构，上传时未能识别该模型的格式")
+		}
+		return env.Architecture, nil
+	}
+	if !errors.Is(err, envelope.ErrNotFound) {
+		return nil, err
+	}
+
+	body, err := backend.Get(ctx, cid)
+	if err != nil {
+		return nil, fmt.Errorf("读取模型文件失败: %w", err)
+	}
+	defer body.Close()
+	return inspect.Inspect(body)
+}
+
+// 检查提交的模型与任务初始模型的结构是否一致，初始模型没有结构指纹时不检查
+func checkModelArchitecture(root, model *invoke_fabric.Model) error {
+	if root.ArchFingerprint == "" {
+		return nil
+	}
+	if model.ArchFingerprint == "" {
+		return fmt.Errorf("无法识别模型 %s 的结构，任务要求 %s 格式且结构与初始模型一致", model.Modelid, root.ArchFormat)
+	}
+	if model.ArchFingerprint != root.ArchFingerprint {
+		return fmt.Errorf("模型 %s 的结构与任务初始模型 %s 不一致", model.Modelid, root.Modelid)
+	}
+	return nil
+}
+
+// 查看模型结构：张量名称、类型和形状以及 ONNX 计算图信息。
+// 旧模型没有结构指纹时，由上传者或管理员查看后记录到链上
+func inspect_model(ctx *gin.Context) {
+	var request struct {
+		ModelID string `json:"modelID"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	model, relation, ok := authorizeModel(ctx, request.ModelID, downloadInspect)
+	if !ok {
+		return
+	}
+	backend, err := modelStorage.Get(model.Storage)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	inspectCtx, cancel := context.WithTimeout(ctx.Request.Context(), modelTransfer.Timeout)
+	defer cancel()
+	arch, err := modelArchitecture(inspectCtx, backend, model.ContentCID())
+	if err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("解析模型结构失败: %s", err.Error())})
+		return
+	}
+
+	recorded := model.ArchFingerprint != ""
+	if !recorded && (relation == relationOwner || relation == relationAdmin) {
+		fmt.Printf("用户 %s 记录模型 %s 的结构\n", middleware.CurrentUser(ctx).User.Username, model.Modelid)
+		if err := invoke_fabric.SetModelArchitecture(contract, model.Modelid, arch.Format, arch.Fingerprint); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recorded = true
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":      "模型结构解析成功",
+		"modelId":      model.Modelid,
+		"architecture": arch,
+		"recorded":     recorded,
+		"matches":      model.ArchFingerprint == "" || model.ArchFingerprint == arch.Fingerprint,
+	})
+}
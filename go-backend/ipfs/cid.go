@@ -0,0 +1,283 @@
+package ipfs
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 与 kubo 的 `ipfs add --cid-version=1 --raw-leaves` 默认参数一致：
+// 256 KiB 定长分块，balanced 布局，每个节点最多 174 个子节点。
+// 旧模型的 CIDv0 对应不加参数的 `ipfs add`，分块和布局相同，叶子节点为 dag-pb
+const (
+	ChunkSize = 256 * 1024
+	maxLinks  = 174
+
+	codecRaw   = 0x55
+	codecDagPB = 0x70
+	hashSHA256 = 0x12
+)
+
+var cidEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// 计算内容的 CIDv1，同时返回内容长度和整体 SHA-256，便于在上传时校验节点返回的 CID
+type Hasher struct {
+	v0    bool // 计算 CIDv0
+	r     io.Reader
+	next  []byte
+	err   error
+	size  int64
+	whole hashState
+}
+
+type hashState interface {
+	io.Writer
+	Sum([]byte) []byte
+}
+
+// DAG 中的一个节点
+type dagNode struct {
+	cid      []byte // 二进制 CID
+	fileSize uint64 // 节点下的文件数据长度
+	tSize    uint64 // 节点及其子节点编码后的总长度
+}
+
+func NewHasher(r io.Reader) *Hasher {
+	h := &Hasher{r: r, whole: sha256.New()}
+	h.read()
+	return h
+}
+
+// 按 cid 的版本计算，用于校验已有的 CID（包括旧模型的 CIDv0）
+func NewHasherFor(cid string, r io.Reader) *Hasher {
+	h := NewHasher(r)
+	h.v0 = isCIDv0(cid)
+	return h
+}
+
+func isCIDv0(cid string) bool {
+	return strings.HasPrefix(cid, "Qm")
+}
+
+// 预读下一个分块，用于判断是否已经读完
+func (h *Hasher) read() {
+	buf := make([]byte, ChunkSize)
+	n, err := io.ReadFull(h.r, buf)
+	h.next = buf[:n]
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		h.err = io.EOF
+	case err != nil:
+		h.err = err
+	}
+}
+
+func (h *Hasher) done() bool {
+	return len(h.next) == 0 && h.err != nil
+}
+
+// 读取一个分块作为叶子节点，CIDv1 为 raw 节点，CIDv0 为 UnixFS 文件节点
+func (h *Hasher) leaf() dagNode {
+	chunk := h.next
+	if h.err == nil {
+		h.read()
+	} else {
+		h.next = nil
+	}
+	h.size += int64(len(chunk))
+	h.whole.Write(chunk)
+	if h.v0 {
+		// UnixFS Data: Type=File, Data, filesize；空文件不写 Data 字段
+		var data []byte
+		data = appendVarintField(data, 1, 2)
+		if len(chunk) > 0 {
+			data = appendBytesField(data, 2, chunk)
+		}
+		data = appendVarintField(data, 3, uint64(len(chunk)))
+		node := appendBytesField(nil, 1, data)
+		return dagNode{cid: h.nodeCID(codecDagPB, node), fileSize: uint64(len(chunk)), tSize: uint64(len(node))}
+	}
+	return dagNode{cid: h.nodeCID(codecRaw, chunk), fileSize: uint64(len(chunk)), tSize: uint64(len(chunk))}
+}
+
+// 读完全部内容并计算根节点的 CID
+func (h *Hasher) Sum() (string, error) {
+	// 与 go-unixfs 的 balanced builder 相同：第一个叶子作为初始根，每一轮把旧根作为新根的第一个子节点
+	root := h.leaf()
+	for depth := 1; !h.done(); depth++ {
+		children := []dagNode{root}
+		children = h.fill(children, depth)
+		root = h.fileNode(children)
+	}
+	if h.err != nil && !errors.Is(h.err, io.EOF) {
+		return "", h.err
+	}
+	if h.v0 {
+		return base58Encode(root.cid), nil
+	}
+	return "b" + strings.ToLower(cidEncoding.EncodeToString(root.cid)), nil
+}
+
+func (h *Hasher) fill(children []dagNode, depth int) []dagNode {
+	for len(children) < maxLinks && !h.done() {
+		if depth == 1 {
+			children = append(children, h.leaf())
+		} else {
+			children = append(children, h.fileNode(h.fill(nil, depth-1)))
+		}
+	}
+	return children
+}
+
+// 已读取的内容长度
+func (h *Hasher) Size() int64 {
+	return h.size
+}
+
+// 已读取内容的 SHA-256
+func (h *Hasher) SHA256() []byte {
+	return h.whole.Sum(nil)
+}
+
+// 计算 r 中全部内容的 CID
+func ComputeCID(r io.Reader) (string, int64, error) {
+	h := NewHasher(r)
+	cid, err := h.Sum()
+	return cid, h.Size(), err
+}
+
+// 由子节点构造 UnixFS 文件节点（dag-pb 编码）
+func (h *Hasher) fileNode(children []dagNode) dagNode {
+	// UnixFS Data: Type=File, filesize, blocksizes
+	var data []byte
+	data = appendVarintField(data, 1, 2)
+	var fileSize uint64
+	for _, c := range children {
+		fileSize += c.fileSize
+	}
+	data = appendVarintField(data, 3, fileSize)
+	for _, c := range children {
+		data = appendVarintField(data, 4, c.fileSize)
+	}
+
+	// PBNode: Links 在前，Data 在后
+	var node []byte
+	tSize := uint64(0)
+	for _, c := range children {
+		var link []byte
+		link = appendBytesField(link, 1, c.cid)
+		link = appendBytesField(link, 2, nil) // Name 为空字符串
+		link = appendVarintField(link, 3, c.tSize)
+		node = appendBytesField(node, 2, link)
+		tSize += c.tSize
+	}
+	node = appendBytesField(node, 1, data)
+
+	return dagNode{cid: h.nodeCID(codecDagPB, node), fileSize: fileSize, tSize: tSize + uint64(len(node))}
+}
+
+// 节点的二进制 CID，CIDv0 只有 multihash
+func (h *Hasher) nodeCID(codec uint64, node []byte) []byte {
+	sum := sha256.Sum256(node)
+	if h.v0 {
+		return multihash(sum[:])
+	}
+	return rawCID(codec, sum[:])
+}
+
+func multihash(digest []byte) []byte {
+	mh := binary.AppendUvarint(nil, hashSHA256)
+	mh = binary.AppendUvarint(mh, uint64(len(digest)))
+	return append(mh, digest...)
+}
+
+func rawCID(codec uint64, digest []byte) []byte {
+	cid := binary.AppendUvarint(nil, 1)
+	cid = binary.AppendUvarint(cid, codec)
+	cid = binary.AppendUvarint(cid, hashSHA256)
+	cid = binary.AppendUvarint(cid, uint64(len(digest)))
+	return append(cid, digest...)
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(field<<3))
+	return binary.AppendUvarint(b, v)
+}
+
+func appendBytesField(b []byte, field int, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field<<3|2))
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// 检查 CID 格式：CIDv0（Qm 开头的 base58）或 base32 编码的 CIDv1
+func ValidCID(s string) error {
+	switch {
+	case len(s) == 46 && strings.HasPrefix(s, "Qm"):
+		for _, c := range s {
+			if !strings.ContainsRune(base58Alphabet, c) {
+				return fmt.Errorf("CID 包含非法字符")
+			}
+		}
+		return nil
+	case strings.HasPrefix(s, "b"):
+		raw, err := cidEncoding.DecodeString(strings.ToUpper(s[1:]))
+		if err != nil {
+			return fmt.Errorf("CID 不是有效的 base32 编码")
+		}
+		version, n := binary.Uvarint(raw)
+		if n <= 0 || version != 1 {
+			return fmt.Errorf("不支持的 CID 版本")
+		}
+		raw = raw[n:]
+		if _, n = binary.Uvarint(raw); n <= 0 {
+			return fmt.Errorf("CID 编码错误")
+		}
+		raw = raw[n:]
+		if _, n = binary.Uvarint(raw); n <= 0 {
+			return fmt.Errorf("CID 编码错误")
+		}
+		raw = raw[n:]
+		length, n := binary.Uvarint(raw)
+		if n <= 0 || uint64(len(raw)-n) != length {
+			return fmt.Errorf("CID 摘要长度错误")
+		}
+		return nil
+	default:
+		return fmt.Errorf("不支持的 CID 格式")
+	}
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58btc 编码，用于 CIDv0
+func base58Encode(b []byte) string {
+	digits := []byte{}
+	for _, c := range b {
+		carry := int(c)
+		for i := range digits {
+			carry += int(digits[i]) << 8
+			digits[i] = byte(carry % 58)
+			carry /= 58
+		}
+		for carry > 0 {
+			digits = append(digits, byte(carry%58))
+			carry /= 58
+		}
+	}
+	out := make([]byte, 0, len(b)+len(digits))
+	for _, c := range b {
+		if c != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	for i := len(digits) - 1; i >= 0; i-- {
+		out = append(out, base58Alphabet[digits[i]])
+	}
+	return string(out)
+}
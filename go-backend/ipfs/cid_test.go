@@ -0,0 +1,115 @@
+package ipfs
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// 内容为 0, 1, ..., 250 循环，长度为 n
+type patternReader struct {
+	n, off int64
+}
+
+func (r *patternReader) Read(p []byte) (int, error) {
+	if r.off >= r.n {
+		return 0, io.EOF
+	}
+	if rest := r.n - r.off; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+	for i := range p {
+		p[i] = byte((r.off + int64(i)) % 251)
+	}
+	r.off += int64(len(p))
+	return len(p), nil
+}
+
+func pattern(n int64) io.Reader {
+	return &patternReader{n: n}
+}
+
+// 期望值由 kubo 使用的 boxo unixfs importer 计算：chunker=size-262144、balanced 布局、每个节点最多 174 个子节点；
+// CIDv1 使用 raw-leaves，CIDv0 与 kubo 默认参数相同
+func TestComputeCIDMatchesKubo(t *testing.T) {
+	cases := []struct {
+		name string
+		size int64
+		cid  string
+		v0   string
+	}{
+		{"空文件", 0, "bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku", "QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH"},
+		{"小文件", 11, "bafkreidyuyttca6rpq42bnqsnyrgz3dq4mztp5f4ni4am5abwvfdhz4ovu", "QmVygzXjZeGrQn1X9rw3f3TQWRvFvhgHcLa8CopS6TdV55"},
+		{"正好一个分块", ChunkSize, "bafkreibruh455iawsviqslif5c7uurdcfdemh22mtnytyzvnzn75kpejxy", "QmeqfRyS3vkku7n6krqC3DgGMex3x2sCpSeKMDmrG13QQq"},
+		{"一个分块加一字节", ChunkSize + 1, "bafybeiexg2oqkfnj56l7fcmawswqbijt5shq4b5rg6a546uwpkqqzwjioi", "QmUSjGawaz4ptvREcMKSMJneWCa5j8dAz2wSAAvHtW2rnB"},
+		{"175 个分块", (maxLinks + 1) * ChunkSize, "bafybeie73j3heycdgkdsehpoe6cxh2y3iywtf6djpi3dzqrywevvjmazny", "Qmbp67kThKoJFnWu7pUgwCu81WttemMnD13oG4uj9DiY5E"},
+		{"175 个分块加 7 字节", (maxLinks+1)*ChunkSize + 7, "bafybeihh7afuh5inawukv67gg6vxlpvb3zgw6rkpw7tymous2idoydpxpi", "QmTLwG5PUVY7iYFEKSgQzzTeqk4H3xMBYcme3oCKeQZNL4"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			h := NewHasher(pattern(c.size))
+			cid, err := h.Sum()
+			if err != nil {
+				t.Fatalf("计算 CID 失败: %v", err)
+			}
+			if cid != c.cid {
+				t.Errorf("CID = %s, 期望 %s", cid, c.cid)
+			}
+			v0, err := NewHasherFor(c.v0, pattern(c.size)).Sum()
+			if err != nil {
+				t.Fatalf("计算 CIDv0 失败: %v", err)
+			}
+			if v0 != c.v0 {
+				t.Errorf("CIDv0 = %s, 期望 %s", v0, c.v0)
+			}
+			if h.Size() != c.size {
+				t.Errorf("Size = %d, 期望 %d", h.Size(), c.size)
+			}
+			want := sha256.New()
+			io.Copy(want, pattern(c.size))
+			if got := fmt.Sprintf("%x", h.SHA256()); got != fmt.Sprintf("%x", want.Sum(nil)) {
+				t.Errorf("SHA256 = %s, 期望 %x", got, want.Sum(nil))
+			}
+			if err := ValidCID(cid); err != nil {
+				t.Errorf("计算出的 CID 未通过格式检查: %v", err)
+			}
+		})
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, fmt.Errorf("读取失败")
+}
+
+func TestComputeCIDReadError(t *testing.T) {
+	if _, _, err := ComputeCID(io.MultiReader(pattern(ChunkSize+1), failingReader{})); err == nil {
+		t.Fatal("读取出错时应返回错误")
+	}
+}
+
+func TestValidCID(t *testing.T) {
+	valid := []string{
+		"bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku",
+		"QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH",
+	}
+	for _, cid := range valid {
+		if err := ValidCID(cid); err != nil {
+			t.Errorf("ValidCID(%q) = %v", cid, err)
+		}
+	}
+	invalid := []string{
+		"",
+		"QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1Aw0l",
+		"bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyk",
+		"b!!!",
+		"../etc/passwd",
+	}
+	for _, cid := range invalid {
+		if err := ValidCID(cid); err == nil {
+			t.Errorf("ValidCID(%q) 应返回错误", cid)
+		}
+	}
+}
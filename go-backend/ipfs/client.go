@@ -0,0 +1,164 @@
+package ipfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// IPFS HTTP API（kubo /api/v0）客户端
+type Client struct {
+	apiURL string
+	http   *http.Client
+}
+
+// 上传结果
+type AddResult struct {
+	CID    string `json:"cid"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"` // 内容的 SHA-256，十六进制
+}
+
+func NewClient(apiURL string) *Client {
+	return &Client{apiURL: strings.TrimRight(apiURL, "/"), http: &http.Client{}}
+}
+
+func (c *Client) call(ctx context.Context, path string, args url.Values, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiURL+"/api/v0/"+path+"?"+args.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("连接 IPFS 节点失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Message string
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(msg, &apiErr) == nil && apiErr.Message != "" {
+			msg = []byte(apiErr.Message)
+		}
+		return nil, fmt.Errorf("IPFS 节点返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// 流式上传并固定内容；上传的同时在本地计算 CID，与节点返回的 CID 不一致时报错
+func (c *Client) Add(ctx context.Context, r io.Reader, name string) (*AddResult, error) {
+	pr, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+
+	// 本地计算 CID 的同时把内容写入 multipart 请求体
+	type sum struct {
+		cid    string
+		size   int64
+		sha256 []byte
+		err    error
+	}
+	local := make(chan sum, 1)
+	go func() {
+		part, err := form.CreateFormFile("file", name)
+		if err != nil {
+			pw.CloseWithError(err)
+			local <- sum{err: err}
+			return
+		}
+		hasher := NewHasher(io.TeeReader(r, part))
+		cid, err := hasher.Sum()
+		if err == nil {
+			err = form.Close()
+		}
+		pw.CloseWithError(err)
+		local <- sum{cid, hasher.Size(), hasher.SHA256(), err}
+	}()
+
+	args := url.Values{
+		"pin":         {"true"},
+		"cid-version": {"1"},
+		"raw-leaves":  {"true"},
+		"chunker":     {fmt.Sprintf("size-%d", ChunkSize)},
+		"hash":        {"sha2-256"},
+		"progress":    {"false"},
+	}
+	resp, err := c.call(ctx, "add", args, form.FormDataContentType(), pr)
+	if err != nil {
+		pr.CloseWithError(err)
+		<-local
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// 每个文件返回一行 JSON，只上传了一个文件
+	var added struct {
+		Name string
+		Hash string
+	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&added)
+	pr.CloseWithError(io.ErrClosedPipe)
+	computed := <-local
+	if computed.err != nil {
+		return nil, fmt.Errorf("读取上传内容失败: %w", computed.err)
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("解析 IPFS 返回结果失败: %w", decodeErr)
+	}
+	if added.Hash != computed.cid {
+		return nil, fmt.Errorf("IPFS 节点返回的 CID %s 与本地计算的 %s 不一致", added.Hash, computed.cid)
+	}
+	return &AddResult{CID: computed.cid, Size: computed.size, SHA256: fmt.Sprintf("%x", computed.sha256)}, nil
+}
+
+// 读取内容，调用方负责关闭
+func (c *Client) Cat(ctx context.Context, cid string) (io.ReadCloser, error) {
+	if err := ValidCID(cid); err != nil {
+		return nil, err
+	}
+	resp, err := c.call(ctx, "cat", url.Values{"arg": {cid}}, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// 固定已有内容，防止被节点垃圾回收
+func (c *Client) Pin(ctx context.Context, cid string) error {
+	if err := ValidCID(cid); err != nil {
+		return err
+	}
+	resp, err := c.call(ctx, "pin/add", url.Values{"arg": {cid}}, "", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// 查询内容大小，内容不存在时返回错误
+func (c *Client) Stat(ctx context.Context, cid string) (int64, error) {
+	if err := ValidCID(cid); err != nil {
+		return 0, err
+	}
+	resp, err := c.call(ctx, "files/stat", url.Values{"arg": {"/ipfs/" + cid}}, "", nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	var stat struct {
+		Size int64
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&stat); err != nil {
+		return 0, fmt.Errorf("解析 IPFS 返回结果失败: %w", err)
+	}
+	return stat.Size, nil
+}
@@ -0,0 +1,139 @@
+package ipfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 内存中的 IPFS 节点，实现上传、下载和固定用到的 HTTP API，用于离线开发和联调
+type FakeNode struct {
+	mu      sync.RWMutex
+	content map[string][]byte
+	pinned  map[string]bool
+}
+
+func NewFakeNode() *FakeNode {
+	return &FakeNode{content: make(map[string][]byte), pinned: make(map[string]bool)}
+}
+
+func (n *FakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		fakeError(w, http.StatusMethodNotAllowed, "405 - Method Not Allowed")
+		return
+	}
+	switch r.URL.Path {
+	case "/api/v0/add":
+		n.add(w, r)
+	case "/api/v0/cat":
+		n.cat(w, r)
+	case "/api/v0/pin/add":
+		n.pin(w, r)
+	case "/api/v0/files/stat":
+		n.stat(w, r)
+	case "/api/v0/id":
+		json.NewEncoder(w).Encode(map[string]string{"ID": "fake-ipfs", "AgentVersion": "fake-ipfs"})
+	default:
+		fakeError(w, http.StatusNotFound, "404 page not found")
+	}
+}
+
+func (n *FakeNode) add(w http.ResponseWriter, r *http.Request) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		fakeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	pin := r.URL.Query().Get("pin") != "false"
+	enc := json.NewEncoder(w)
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			fakeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			fakeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		cid, size, err := ComputeCID(bytes.NewReader(data))
+		if err != nil {
+			fakeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		n.mu.Lock()
+		n.content[cid] = data
+		if pin {
+			n.pinned[cid] = true
+		}
+		n.mu.Unlock()
+
+		enc.Encode(map[string]string{"Name": part.FileName(), "Hash": cid, "Size": strconv.FormatInt(size, 10)})
+	}
+}
+
+func (n *FakeNode) cat(w http.ResponseWriter, r *http.Request) {
+	cid := r.URL.Query().Get("arg")
+	n.mu.RLock()
+	data, ok := n.content[cid]
+	n.mu.RUnlock()
+	if !ok {
+		fakeError(w, http.StatusInternalServerError, fmt.Sprintf("block was not found locally (offline): %s", cid))
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("X-Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+func (n *FakeNode) pin(w http.ResponseWriter, r *http.Request) {
+	cid := r.URL.Query().Get("arg")
+	n.mu.Lock()
+	_, ok := n.content[cid]
+	if ok {
+		n.pinned[cid] = true
+	}
+	n.mu.Unlock()
+	if !ok {
+		fakeError(w, http.StatusInternalServerError, fmt.Sprintf("pin: block was not found locally (offline): %s", cid))
+		return
+	}
+	json.NewEncoder(w).Encode(map[string][]string{"Pins": {cid}})
+}
+
+func (n *FakeNode) stat(w http.ResponseWriter, r *http.Request) {
+	cid := strings.TrimPrefix(r.URL.Query().Get("arg"), "/ipfs/")
+	n.mu.RLock()
+	data, ok := n.content[cid]
+	n.mu.RUnlock()
+	if !ok {
+		fakeError(w, http.StatusInternalServerError, fmt.Sprintf("block was not found locally (offline): %s", cid))
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"Hash": cid, "Size": len(data), "Type": "file"})
+}
+
+// 内容是否已固定
+func (n *FakeNode) Pinned(cid string) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.pinned[cid]
+}
+
+// 与 kubo 相同的错误格式
+func fakeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"Message": message, "Code": 0, "Type": "error"})
+}
@@ -0,0 +1,114 @@
+package jsonfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 以一个 JSON 对象保存在单个文件中的键值存储。
+// 文件内容缓存在内存中，只有文件的修改时间或大小变化（其他进程写入）后才重新读取
+type Store[T any] struct {
+	mu   sync.Mutex
+	path string
+	name string // 错误信息中的数据名称
+
+	records map[string]json.RawMessage
+	modTime time.Time
+	size    int64
+}
+
+func New[T any](path, name string) *Store[T] {
+	return &Store[T]{path: path, name: name}
+}
+
+// 文件变化后重新读取
+func (s *Store[T]) refresh() error {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.records, s.modTime, s.size = map[string]json.RawMessage{}, time.Time{}, 0
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取%s失败: %w", s.name, err)
+	}
+	if s.records != nil && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("读取%s失败: %w", s.name, err)
+	}
+	records := make(map[string]json.RawMessage)
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &records); err != nil {
+			return fmt.Errorf("解析%s失败: %w", s.name, err)
+		}
+	}
+	s.records, s.modTime, s.size = records, info.ModTime(), info.Size()
+	return nil
+}
+
+// 返回记录的副本，调用方修改后需要 Put 才会保存
+func (s *Store[T]) Get(key string) (*T, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.refresh(); err != nil {
+		return nil, false, err
+	}
+	data, ok := s.records[key]
+	if !ok {
+		return nil, false, nil
+	}
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, false, fmt.Errorf("解析%s失败: %w", s.name, err)
+	}
+	return &value, true, nil
+}
+
+func (s *Store[T]) Put(key string, value *T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.refresh(); err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("序列化%s失败: %w", s.name, err)
+	}
+	records := make(map[string]json.RawMessage, len(s.records)+1)
+	for k, v := range s.records {
+		records[k] = v
+	}
+	records[key] = encoded
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化%s失败: %w", s.name, err)
+	}
+	// 先写临时文件再改名，避免写到一半时文件损坏
+	tmp := s.path + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("写入%s失败: %w", s.name, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("写入%s失败: %w", s.name, err)
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.records, s.modTime, s.size = records, info.ModTime(), info.Size()
+	} else {
+		s.records = nil
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"backend/auth"
+	invoke_fabric "backend/fabric-go/call"
+	connect_fabric "backend/fabric-go/network"
+	"backend/middleware"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 登记公钥的持有证明挑战
+var keyChallenges = auth.NewChallengeStore(5 * time.Minute)
+
+// 解析公钥并计算指纹
+func parseKey(publicKey string) (string, string, error) {
+	pub, err := auth.ParsePublicKey(publicKey)
+	if err != nil {
+		return "", "", err
+	}
+	fingerprint, err := auth.KeyFingerprint(pub)
+	if err != nil {
+		return "", "", err
+	}
+	pemKey, err := auth.EncodePublicKeyPEM(pub)
+	if err != nil {
+		return "", "", err
+	}
+	return pemKey, fingerprint, nil
+}
+
+// 补全公钥历史中的指纹，旧用户的公钥登记时没有计算指纹
+func fillKeyFingerprints(user *invoke_fabric.User) {
+	keys := user.KeyHistory()
+	for i := range keys {
+		if keys[i].Fingerprint == "" {
+			if _, fingerprint, err := parseKey(keys[i].PublicKey); err == nil {
+				keys[i].Fingerprint = fingerprint
+			}
+		}
+	}
+	user.Keys = keys
+}
+
+// 为待登记的公钥签发持有证明挑战
+func key_challenge(ctx *gin.Context) {
+	var request struct {
+		PublicKey string `json:"publicKey"` // PEM 或 JWK
+	}
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	_, fingerprint, err := parseKey(request.PublicKey)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	challenge, err := keyChallenges.Issue(middleware.CurrentUser(ctx).User.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 返回待签名内容，需要使用新公钥对应的私钥签名
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":     "挑战已生成",
+		"nonce":       challenge.Nonce,
+		"fingerprint": fingerprint,
+		"payload":     string(challenge.KeyProofMessage(fingerprint)),
+		"expiresAt":   challenge.ExpiresAt,
+	})
+}
+
+// 登记或更换公钥，需要用新公钥签名挑战证明持有私钥
+func upload_public_key(ctx *gin.Context) {
+	var request struct {
+		Username   string `json:"username"`
+		PublicKey  string `json:"publicKey"`
+		Pubkeyhash string `json:"pubkeyhash"` // 旧版前端使用的字段名
+		Nonce      string `json:"nonce"`
+		Signature  string `json:"signature"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+	username := middleware.CurrentUser(ctx).User.Username
+	if request.Username != "" && request.Username != username {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "只能登记自己的公钥"})
+		return
+	}
+	if request.PublicKey == "" {
+		request.PublicKey = request.Pubkeyhash
+	}
+
+	pemKey, fingerprint, err := parseKey(request.PublicKey)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 挑战只能使用一次，无论验证结果如何
+	challenge, err := keyChallenges.Consume(username, request.Nonce)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	pub, _ := auth.ParsePublicKey(pemKey)
+	sig, err := auth.DecodeSignature(pub, request.Signature)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	algorithm, err := auth.VerifySignature(pub, challenge.KeyProofMessage(fingerprint), sig)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("持有证明无效: %s", err.Error())})
+		return
+	}
+
+	user, err := invoke_fabric.Get_one_User(contract, username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("查询用户信息失败: %s", err.Error())})
+		return
+	}
+	fillKeyFingerprints(user)
+	if old := user.KeyByFingerprint(fingerprint); old != nil {
+		if old.ValidTo == "" {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "该公钥已是当前公钥"})
+		} else {
+			ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("该公钥已于 %s 停用，不能重新启用", old.ValidTo)})
+		}
+		return
+	}
+
+	fmt.Printf("上传公钥: 用户名=%s, 指纹=%s, 算法=%s\n", username, fingerprint, algorithm)
+	if err := invoke_fabric.RotatePublicKey(contract, username, pemKey, fingerprint, algorithm); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("上传公钥失败: %s", err.Error())})
+		return
+	}
+
+	// 返回成功信息到前端
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":     "公钥上传成功",
+		"pubkeyhash":  pemKey,
+		"fingerprint": fingerprint,
+		"algorithm":   algorithm,
+	})
+}
+
+// 查询用户的公钥历史
+func get_key_history(ctx *gin.Context) {
+	var request struct {
+		Username string `json:"username"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+	if request.Username == "" {
+		request.Username = middleware.CurrentUser(ctx).User.Username
+	}
+
+	user, err := invoke_fabric.Get_one_User(contract, request.Username)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("查询用户信息失败: %s", err.Error())})
+		return
+	}
+
+	fillKeyFingerprints(user)
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "公钥历史查询成功",
+		"keys":    user.Keys,
+	})
+}
+
+// 使用公钥记录验证模型签名，签名内容为模型 CID，返回签名算法和公钥指纹
+func verifyModelSignature(key *invoke_fabric.PublicKeyRecord, cid, signature string) (string, string, error) {
+	pub, err := auth.ParsePublicKey(key.PublicKey)
+	if err != nil {
+		return "", "", fmt.Errorf("用户未登记有效公钥: %w", err)
+	}
+	sig, err := auth.DecodeSignature(pub, signature)
+	if err != nil {
+		return "", "", err
+	}
+	alg, err := auth.VerifySignature(pub, []byte(cid), sig)
+	if err != nil {
+		return "", "", fmt.Errorf("模型签名与登记的公钥不匹配: %w", err)
+	}
+	fingerprint, err := auth.KeyFingerprint(pub)
+	if err != nil {
+		return "", "", err
+	}
+	return alg, fingerprint, nil
+}
+
+// 找出上传模型时上传者的有效公钥：优先按记录的指纹，其次按上传时间，都没有时使用当前公钥
+func modelSigningKey(owner *invoke_fabric.User, model *invoke_fabric.Model) *invoke_fabric.PublicKeyRecord {
+	if model.KeyFingerprint != "" {
+		if key := owner.KeyByFingerprint(model.KeyFingerprint); key != nil {
+			return key
+		}
+	}
+	if createdAt, err := time.Parse(time.RFC3339, model.CreatedAt); err == nil {
+		return owner.KeyAt(createdAt)
+	}
+	return owner.CurrentKey()
+}
+
+// 使用上传时有效的公钥重新验证模型签名
+func verify_model(ctx *gin.Context) {
+	var request struct {
+		ModelID string `json:"modelID"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	model, err := invoke_fabric.ReadModel(contract, request.ModelID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("获取模型失败: %s", err.Error())})
+		return
+	}
+	owner, err := invoke_fabric.Get_one_User(contract, model.Modelowner)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("查询上传者信息失败: %s", err.Error())})
+		return
+	}
+	fillKeyFingerprints(owner)
+
+	key := modelSigningKey(owner, model)
+	if key == nil {
+		ctx.JSON(http.StatusOK, gin.H{"valid": false, "error": "找不到上传模型时有效的公钥"})
+		return
+	}
+	alg, fingerprint, err := verifyModelSignature(key, model.Modelhash, model.Modelsign)
+	if err != nil {
+		ctx.JSON(http.StatusOK, gin.H{"valid": false, "error": err.Error(), "keyFingerprint": key.Fingerprint})
+		return
+	}
+	if model.KeyFingerprint != "" && model.KeyFingerprint != fingerprint {
+		ctx.JSON(http.StatusOK, gin.H{"valid": false, "error": "验证所用公钥与模型记录的公钥指纹不一致"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"valid":          true,
+		"modelId":        model.Modelid,
+		"signAlg":        alg,
+		"keyFingerprint": fingerprint,
+		"validFrom":      key.ValidFrom,
+		"validTo":        key.ValidTo,
+	})
+}
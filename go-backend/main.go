@@ -1,15 +1,28 @@
 package main
 
 import (
+	"backend/auth"
 	invoke_fabric "backend/fabric-go/call"
 	connect_fabric "backend/fabric-go/network"
+	"backend/ipfs"
+	"backend/middleware"
+	"backend/settlement"
+	"backend/upload"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
 )
 
 // 默认配置
@@ -33,6 +46,29 @@ var userConfig = connect_fabric.FabricConfig{
 	GatewayPeer:  "",
 }
 
+// 登录、注册和写接口的限流器
+var rateLimiter = middleware.NewRateLimiter(middleware.LoadRateLimitConfig(), middleware.NewMemoryRateLimitStore())
+
+// 公钥登录挑战，5 分钟内有效且只能使用一次
+var loginChallenges = auth.NewChallengeStore(5 * time.Minute)
+
+// 登录挑战、公钥持有证明挑战和二次验证会话默认保存在各实例的内存中，多实例部署时需要会话保持（sticky session），
+// 或者通过 CHALLENGE_DIR 指定各实例共享的目录
+func loadChallengeStores() error {
+	dir := os.Getenv("CHALLENGE_DIR")
+	if dir == "" {
+		return nil
+	}
+	for name, store := range map[string]**auth.ChallengeStore{"login": &loginChallenges, "key": &keyChallenges, "mfa": &mfaSessions} {
+		shared, err := auth.NewSharedChallengeStore(filepath.Join(dir, name), 5*time.Minute)
+		if err != nil {
+			return err
+		}
+		*store = shared
+	}
+	return nil
+}
+
 type User struct {
 	Username     string   `json:"username"`
 	Password     string   `json:"password"`
@@ -63,43 +99,143 @@ type Task struct {
 	IsComplete      bool     `json:"isComplete"`
 	Round           int      `json:"round"`           // 新增：任务的轮次
 	NextRoundTaskID string   `json:"nextRoundTaskID"` // 新增：下一轮任务的 ID
+	Status          string   `json:"status"`          // 任务状态
 }
 
 func main() {
+	// 命令行子命令
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "reconcile":
+			os.Exit(runReconcileCommand(os.Args[2:]))
+		case "fake-ipfs":
+			os.Exit(runFakeIPFSCommand(os.Args[2:]))
+		case "decrypt-model":
+			os.Exit(runDecryptModelCommand(os.Args[2:]))
+		}
+	}
+
+	// 令牌和下载链接的签名密钥必须显式配置
+	if err := auth.CheckSecrets(); err != nil {
+		panic(fmt.Errorf("密钥配置错误: %w", err))
+	}
+
+	if err := loadChallengeStores(); err != nil {
+		panic(fmt.Errorf("挑战目录配置错误: %w", err))
+	}
+
+	// 部署的链码必须实现后端调用的全部函数
+	if err := invoke_fabric.CheckChaincode(connect_fabric.GetContract(defaultConfig)); err != nil {
+		panic(fmt.Errorf("链码检查失败: %w", err))
+	}
+
 	r := gin.Default()
 
-	// 配置跨域
-	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-		c.Next()
-	})
+	serverConfig := loadServerConfig()
+
+	storageRegistry, err := newModelStorage(modelTransfer)
+	if err != nil {
+		panic(fmt.Errorf("模型存储配置错误: %w", err))
+	}
+	modelStorage = storageRegistry
+	if modelUploads, err = upload.NewStore(modelTransfer.UploadDir, modelTransfer.UploadTTL, modelTransfer.MaxBytes, modelTransfer.UploadQuota); err != nil {
+		panic(fmt.Errorf("上传目录配置错误: %w", err))
+	}
+
+	// 只信任显式配置的反向代理，避免伪造 X-Forwarded-For 绕过按 IP 限流
+	if err := r.SetTrustedProxies(serverConfig.TrustedProxies); err != nil {
+		panic(fmt.Errorf("代理配置错误: %w", err))
+	}
+
+	// 配置跨域、安全响应头和请求体大小限制
+	r.Use(middleware.CORS(middleware.LoadCORSConfig()))
+	r.Use(middleware.SecurityHeaders(serverConfig.tlsEnabled()))
+	r.Use(middleware.BodyLimit(serverConfig.MaxBodyBytes, "/upload_model_file", "/download_model", "/download/:modelID", "/migrate_model", "/uploads", "/uploads/:id"))
+
+	// 写接口使用令牌桶限流
+	writeLimit := rateLimiter.WriteLimit()
+
+	// 模型文件上传和下载放宽大小和超时限制
+	streaming := middleware.StreamingBody(modelTransfer.MaxBytes, modelTransfer.Timeout)
 
 	// 路由配置
-	r.POST("/register", register)
+	r.POST("/register", writeLimit, register)
 	r.POST("/login", login)
+	r.POST("/login_challenge", login_challenge)
+	r.POST("/login_with_key", login_with_key)
+	r.POST("/login_mfa", login_mfa)
+	r.POST("/mfa_enroll", mfa_enroll)
+	r.POST("/mfa_confirm", mfa_confirm)
 	r.POST("/log_out", log_out)
-	r.POST("/get_user_info", get_user_info)
-	r.POST("/upload_public_key", upload_public_key)
-	r.POST("/upload_model", upload_model)
-	r.POST("/get_all_task", get_all_task)
-	r.POST("/accept_task", accept_task)
-	r.POST("/get_all_users", get_all_users)
-	r.POST("/delete_user", delete_user)
-	r.POST("/verify_user", verify_user)
-	r.POST("/new_task", new_task)
-	r.POST("/next_task_round", next_task_round)
-	r.POST("/delete_task", delete_task)
-	r.POST("/finish_task", finish_task)
-	r.POST("/model_to_task", model_to_task)
-	r.POST("/get_model_cid", get_model_cid) // 新增路由
-
-	r.Run(":8089")
+	r.POST("/get_all_task", middleware.OptionalAuth(checkUserActive), get_all_task)
+	r.OPTIONS("/uploads", tus_options)
+	r.GET("/download/:modelID", streaming, download_signed)
+
+	// 登录用户路由
+	active := middleware.RequireActive(checkUserActive)
+	authed := r.Group("/", middleware.RequireAuth(), active)
+	authed.POST("/get_user_info", get_user_info)
+	authed.POST("/get_statement", get_statement)
+	authed.POST("/new_task", writeLimit, new_task)
+	authed.POST("/publish_task", writeLimit, publish_task)
+	authed.POST("/accept_task", writeLimit, accept_task)
+	authed.POST("/leave_task", writeLimit, leave_task)
+	authed.POST("/key_challenge", key_challenge)
+	authed.POST("/upload_public_key", writeLimit, upload_public_key)
+	authed.POST("/get_key_history", get_key_history)
+	authed.POST("/upload_model_file", writeLimit, streaming, upload_model_file)
+	authed.POST("/uploads", writeLimit, streaming, create_upload)
+	authed.HEAD("/uploads/:id", head_upload)
+	authed.PATCH("/uploads/:id", streaming, patch_upload)
+	authed.DELETE("/uploads/:id", delete_upload)
+	authed.GET("/uploads/:id", get_upload)
+	authed.POST("/upload_model", writeLimit, upload_model)
+	authed.POST("/download_model", streaming, download_model)
+	authed.POST("/download_url", download_url)
+	authed.POST("/get_model_cid", get_model_cid)
+	authed.POST("/get_model_key", get_model_key)
+	authed.POST("/inspect_model", inspect_model)
+	authed.POST("/verify_model", verify_model)
+	authed.POST("/model_to_task", writeLimit, model_to_task)
+	authed.POST("/close_submissions", writeLimit, close_submissions)
+	authed.POST("/reopen_submissions", writeLimit, reopen_submissions)
+	authed.POST("/next_task_round", writeLimit, next_task_round)
+	authed.POST("/cancel_task", writeLimit, cancel_task)
+	authed.POST("/set_task_rules", writeLimit, set_task_rules)
+	authed.POST("/get_task_escrow", get_task_escrow)
+	authed.POST("/get_settlement", get_settlement)
+	authed.POST("/set_reward_strategy", writeLimit, set_reward_strategy)
+	authed.POST("/set_task_scores", writeLimit, set_task_scores)
+	authed.POST("/transfer_tokens", writeLimit, transfer_tokens)
+	authed.POST("/fund_task_escrow", writeLimit, fund_task_escrow)
+
+	// 管理员路由，需要登录令牌并完成二次验证
+	admin := r.Group("/", middleware.RequireAuth(), active, middleware.RequireAdmin())
+	admin.POST("/get_all_users", get_all_users)
+	admin.POST("/delete_user", writeLimit, delete_user)
+	admin.POST("/verify_user", writeLimit, verify_user)
+	admin.POST("/delete_task", writeLimit, delete_task)
+	admin.POST("/restore_user", writeLimit, restore_user)
+	admin.POST("/restore_task", writeLimit, restore_task)
+	admin.POST("/finish_task", writeLimit, finish_task)
+	admin.POST("/resume_settlement", writeLimit, resume_settlement)
+	admin.POST("/mint_tokens", writeLimit, mint_tokens)
+	admin.POST("/burn_tokens", writeLimit, burn_tokens)
+	admin.POST("/grant_tokens", writeLimit, grant_tokens)
+	admin.POST("/reconcile_ledger", writeLimit, reconcile_ledger)
+	admin.POST("/migrate_model", writeLimit, streaming, migrate_model)
+	admin.POST("/get_download_audit", get_download_audit)
+	admin.POST("/get_pending_users", get_pending_users)
+	admin.POST("/approve_user", writeLimit, approve_user)
+	admin.POST("/reject_user", writeLimit, reject_user)
+	admin.POST("/approve_users", writeLimit, approve_users)
+
+	// 截止时间调度
+	startTaskScheduler(loadSchedulerConfig())
+
+	if err := runServer(r, serverConfig); err != nil {
+		panic(fmt.Errorf("服务启动失败: %w", err))
+	}
 }
 
 // 注册逻辑
@@ -112,20 +248,24 @@ func register(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
 		return
 	}
-	print(user.Username)
-	print(user.Password)
-	print(user.Organization)
+	// 按 IP 和用户名限流
+	if ok, wait := rateLimiter.AllowRegister(ctx.ClientIP(), user.Username); !ok {
+		middleware.TooManyRequests(ctx, wait)
+		return
+	}
+	// 托管账户和系统账户的前缀保留给代币账本使用
+	if invoke_fabric.IsReservedAccount(user.Username) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "用户名不能以 escrow: 或 system: 开头"})
+		return
+	}
 	// 调用 CreateUser 并处理返回值
 	err := invoke_fabric.CreateNewUser(contract, user.Username, user.Password, user.Organization, "test", 0, false, false, false)
 	if err != nil {
 		// 返回错误信息到前端
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 	//err = connect_fabric.RegisterIdentity(user.Username, user.Password, "client", user.Organization)
-	if err != nil {
-		// 返回错误信息到前端
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-	}
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "注册成功",
 	})
@@ -142,41 +282,186 @@ func login(ctx *gin.Context) {
 		return
 	}
 
+	// 按 IP 和用户名限流，连续失败的用户名会被锁定
+	if ok, wait := rateLimiter.AllowLogin(ctx.ClientIP(), user.Username); !ok {
+		middleware.TooManyRequests(ctx, wait)
+		return
+	}
+
 	// 调用 QueryUser 并处理返回值
 	queriedUser, err := invoke_fabric.QueryUser(contract, user.Username, user.Password)
 	if err != nil {
+		rateLimiter.LoginFailed(user.Username)
 		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 检查 IsAccepted 和 IsVerified 状态
-	if !queriedUser.IsAccepted || !queriedUser.IsVerified {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "用户未被接受或未验证"})
+	// 检查删除、审核状态以及 IsAccepted 和 IsVerified 状态，密码正确时不计入登录失败
+	if err := queriedUser.CheckActive(); err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 	//connect_fabric.GenerateCertificateAndUpdateConfig(queriedUser.Username, queriedUser.Password, queriedUser.Organization, &userConfig)
-	// 模拟生成一个令牌（实际应使用 JWT 或其他方式）
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user": User{
-			Username:     queriedUser.Username,
-			Organization: queriedUser.Organization,
-			IsAdmin:      queriedUser.IsAdmin,
-		},
-		"exp": time.Now().Add(time.Hour * 8).Unix(),
-	})
+	respondLogin(ctx, queriedUser)
+}
+
+// 第一步验证通过后的处理，管理员还需要二次验证
+func respondLogin(ctx *gin.Context, queriedUser *invoke_fabric.User) {
+	if queriedUser.IsAdmin {
+		requireMFA(ctx, queriedUser)
+		return
+	}
+	completeLogin(ctx, queriedUser, false)
+}
+
+// 签发令牌并返回登录结果
+func completeLogin(ctx *gin.Context, queriedUser *invoke_fabric.User, mfa bool) {
+	token, err := issueLoginToken(queriedUser, mfa)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rateLimiter.LoginSucceeded(queriedUser.Username)
 
 	// 返回用户信息和令牌
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "登录成功",
 		"token":   token,
-		"user": gin.H{
-			"username":     queriedUser.Username,
-			"organization": queriedUser.Organization,
-			"isadmin":      queriedUser.IsAdmin,
-		},
+		"user":    loginUserInfo(queriedUser),
+	})
+}
+
+func issueLoginToken(queriedUser *invoke_fabric.User, mfa bool) (string, error) {
+	return auth.IssueToken(auth.TokenUser{
+		Username:     queriedUser.Username,
+		Organization: queriedUser.Organization,
+		IsAdmin:      queriedUser.IsAdmin,
+	}, mfa)
+}
+
+func loginUserInfo(queriedUser *invoke_fabric.User) gin.H {
+	return gin.H{
+		"username":     queriedUser.Username,
+		"organization": queriedUser.Organization,
+		"isadmin":      queriedUser.IsAdmin,
+	}
+}
+
+// 登录后的请求按链上记录检查账号状态，与登录时的检查一致
+func checkUserActive(username string) error {
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+	user, err := invoke_fabric.Get_one_User(contract, username)
+	if err != nil {
+		return fmt.Errorf("查询用户失败: %w", err)
+	}
+	return user.CheckActive()
+}
+
+// 获取公钥登录挑战
+func login_challenge(ctx *gin.Context) {
+	var request struct {
+		Username string `json:"username"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	// 与登录使用同一个限流，避免枚举用户和无限签发挑战
+	if ok, wait := rateLimiter.AllowLogin(ctx.ClientIP(), request.Username); !ok {
+		middleware.TooManyRequests(ctx, wait)
+		return
+	}
+
+	// 用户必须已登记公钥
+	user, err := invoke_fabric.Get_one_User(contract, request.Username)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+		return
+	}
+	if _, err := auth.ParsePublicKey(user.Pubkeyhash); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("用户未登记有效公钥: %s", err.Error())})
+		return
+	}
+
+	challenge, err := loginChallenges.Issue(user.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 返回待签名内容
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":   "挑战已生成",
+		"nonce":     challenge.Nonce,
+		"payload":   string(challenge.Message()),
+		"expiresAt": challenge.ExpiresAt,
 	})
 }
 
+// 使用私钥签名挑战登录
+func login_with_key(ctx *gin.Context) {
+	var request struct {
+		Username  string `json:"username"`
+		Nonce     string `json:"nonce"`
+		Signature string `json:"signature"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	if ok, wait := rateLimiter.AllowLogin(ctx.ClientIP(), request.Username); !ok {
+		middleware.TooManyRequests(ctx, wait)
+		return
+	}
+
+	// 取出挑战，挑战只能使用一次
+	challenge, err := loginChallenges.Consume(request.Username, request.Nonce)
+	if err != nil {
+		rateLimiter.LoginFailed(request.Username)
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	queriedUser, err := invoke_fabric.Get_one_User(contract, request.Username)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	// 检查审核状态以及 IsAccepted 和 IsVerified 状态
+	if err := queriedUser.CheckActive(); err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 使用登记的公钥验证签名
+	pub, err := auth.ParsePublicKey(queriedUser.Pubkeyhash)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("用户公钥无效: %s", err.Error())})
+		return
+	}
+	sig, err := auth.DecodeSignature(pub, request.Signature)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := auth.VerifySignature(pub, challenge.Message(), sig); err != nil {
+		rateLimiter.LoginFailed(request.Username)
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondLogin(ctx, queriedUser)
+}
+
 func log_out(ctx *gin.Context) {
 	var user User
 
@@ -199,6 +484,13 @@ func get_user_info(ctx *gin.Context) {
 		return
 	}
 
+	// 只能查询自己的信息，管理员需要完成二次验证
+	claims := middleware.CurrentUser(ctx)
+	if user.Username != claims.User.Username && !(claims.User.IsAdmin && claims.MFA) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "只能查询自己的用户信息"})
+		return
+	}
+
 	// 打印接收到的用户信息
 	fmt.Printf("查询用户信息: 用户名=%s, 组织=%s\n", user.Username, user.Organization)
 
@@ -224,74 +516,167 @@ func get_user_info(ctx *gin.Context) {
 	})
 }
 
-// 上传公钥
-func upload_public_key(ctx *gin.Context) {
-	var user User
+// 上传模型，签名验证通过后才记录到链上
+func upload_model(ctx *gin.Context) {
+	var model struct {
+		Username  string `json:"username"`
+		Signature string `json:"signature"`
+		CID       string `json:"cid"`
+		Storage   string `json:"storage"`  // upload_model_file 返回的存储方式，为空表示 ipfs
+		UploadID  string `json:"uploadId"` // 使用已完成的可续传上传，CID 和存储方式取自上传结果
+	}
 	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
 
 	// 绑定 JSON 数据
-	if err := ctx.BindJSON(&user); err != nil {
+	if err := ctx.BindJSON(&model); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
 		return
 	}
+	if model.Username != middleware.CurrentUser(ctx).User.Username {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "只能以自己的身份上传模型"})
+		return
+	}
+	var claimed *upload.Info
+	if model.UploadID != "" {
+		if _, ok := ownUpload(ctx, model.UploadID); !ok {
+			return
+		}
+		// 在上传的锁内占用，并发登记同一个上传时只有一个请求继续；登记失败时释放
+		info, err := modelUploads.Claim(model.UploadID)
+		if err != nil {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		claimed = info
+		defer func() {
+			if claimed == nil {
+				return
+			}
+			if err := modelUploads.Release(claimed.ID); err != nil {
+				fmt.Printf("释放上传 %s 失败: %v\n", claimed.ID, err)
+			}
+		}()
+		model.CID, model.Storage = info.Object.CID, info.Object.Scheme
+	}
+	model.CID = strings.TrimSpace(model.CID)
+	if err := ipfs.ValidCID(model.CID); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("模型 CID 无效: %s", err.Error())})
+		return
+	}
 
-	// 打印接收到的用户信息
-	fmt.Printf("上传公钥: 用户名=%s, 公钥=%s\n", user.Username, user.Pubkeyhash)
+	// 打印接收到的 JSON 数据
+	fmt.Printf("接收到的模型数据: 用户名=%s, 签名=%s, CID=%s\n", model.Username, model.Signature, model.CID)
 
-	//调用链码上传公钥
-	err := invoke_fabric.UploadPublicKey(contract, user.Username, user.Pubkeyhash)
+	// 同一个模型文件只能由最先登记的用户使用，链码在创建模型时再次检查
+	owner, err := invoke_fabric.GetModelCIDOwner(contract, model.CID)
 	if err != nil {
-		// 返回错误信息到前端
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("上传公钥失败: %s", err.Error())})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if owner != "" && owner != model.Username {
+		ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("CID 为 %s 的模型文件已由其他用户登记", model.CID)})
 		return
 	}
 
-	// 返回成功信息到前端
-	ctx.JSON(http.StatusOK, gin.H{
-		"message": "公钥上传成功",
-	})
-}
-
-// 上传模型
-func upload_model(ctx *gin.Context) {
-	var model struct {
-		Username  string `json:"username"`
-		Signature string `json:"signature"`
-		CID       string `json:"cid"`
+	// 模型文件必须已保存在对应的存储中
+	backend, err := modelStorage.Get(model.Storage)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	statCtx, cancel := context.WithTimeout(ctx.Request.Context(), 10*time.Second)
+	defer cancel()
+	if _, err := backend.Stat(statCtx, model.CID); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s 存储中找不到 CID 为 %s 的模型文件: %s", backend.Scheme(), model.CID, err.Error())})
+		return
 	}
-	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
 
-	// 绑定 JSON 数据
-	if err := ctx.BindJSON(&model); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+	user, err := invoke_fabric.Get_one_User(contract, model.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("查询用户信息失败: %s", err.Error())})
+		return
+	}
+	key := user.CurrentKey()
+	if key == nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "用户未登记公钥"})
+		return
+	}
+	alg, fingerprint, err := verifyModelSignature(key, model.CID, model.Signature)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 打印接收到的 JSON 数据
-	fmt.Printf("接收到的模型数据: 用户名=%s, 签名=%s, CID=%s\n", model.Username, model.Signature, model.CID)
+	// 加密模型只能由上传者本人登记
+	encrypted := false
+	if env, err := modelKeys.Get(model.CID); err == nil {
+		if env.Owner != model.Username {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "该加密模型文件不是你上传的"})
+			return
+		}
+		encrypted = true
+	}
 
-	// 注释掉调用链码及其后续逻辑
+	// 解析模型结构，无法识别的格式也允许登记，但不能提交到要求结构一致的任务；
+	// 可续传上传在完成时已经解析，结果保存在上传记录中
+	var archFormat, archFingerprint string
+	if claimed != nil && claimed.Object.Architecture != nil {
+		archFormat, archFingerprint = claimed.Object.Architecture.Format, claimed.Object.Architecture.Fingerprint
+	} else {
+		inspectCtx, cancelInspect := context.WithTimeout(ctx.Request.Context(), modelTransfer.Timeout)
+		defer cancelInspect()
+		if arch, err := modelArchitecture(inspectCtx, backend, model.CID); err != nil {
+			fmt.Printf("模型 %s 的结构无法解析: %v\n", model.CID, err)
+		} else {
+			archFormat, archFingerprint = arch.Format, arch.Fingerprint
+		}
+	}
 
 	// 调用链码上传模型
-	err := invoke_fabric.CreateNewModel(contract, model.Username, model.CID, model.Signature)
+	modelID, err := invoke_fabric.CreateNewModel(contract, model.Username, model.CID, model.Signature, alg, fingerprint, backend.Scheme(), archFormat, archFingerprint)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("上传模型失败: %s", err.Error())})
 		return
 	}
 
-	// 返回成功信息到前端
-	ctx.JSON(http.StatusOK, gin.H{
-		"message": "模型上传成功",
-	})
+	if claimed != nil {
+		if err := modelUploads.MarkRegistered(claimed.ID, modelID); err != nil {
+			fmt.Printf("记录上传 %s 已登记失败: %v\n", claimed.ID, err)
+		}
+		claimed = nil
+	}
 
-	// 返回调试信息到前端
+	// 返回成功信息到前端
 	ctx.JSON(http.StatusOK, gin.H{
-		"message": "接收到模型数据，已打印到终端供调试",
+		"message":         "模型上传成功",
+		"modelId":         modelID,
+		"storage":         backend.Scheme(),
+		"signAlg":         alg,
+		"keyFingerprint":  fingerprint,
+		"encrypted":       encrypted,
+		"archFormat":      archFormat,
+		"archFingerprint": archFingerprint,
 	})
 }
 
 // 获取所有任务
 func get_all_task(ctx *gin.Context) {
+	var request struct {
+		IncludeDeleted bool `json:"includeDeleted"` // 是否包含已删除的任务
+	}
+	// 请求体可以为空
+	if err := ctx.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+	// 只有完成二次验证的管理员可以查看已删除的任务
+	if request.IncludeDeleted {
+		claims := middleware.CurrentUser(ctx)
+		if claims == nil || !claims.User.IsAdmin || !claims.MFA {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "只有完成二次验证的管理员可以查看已删除的任务"})
+			return
+		}
+	}
 	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
 
 	// 调用链码获取所有任务
@@ -302,6 +687,22 @@ func get_all_task(ctx *gin.Context) {
 		return
 	}
 
+	if !request.IncludeDeleted {
+		tasks = slices.DeleteFunc(tasks, isDeletedRecord)
+	}
+
+	// 旧任务没有 status 字段，按原有字段推算
+	for _, item := range tasks {
+		if status, _ := item["status"].(string); status != "" {
+			continue
+		}
+		data, _ := json.Marshal(item)
+		var task invoke_fabric.Task
+		if err := json.Unmarshal(data, &task); err == nil {
+			item["status"] = task.State()
+		}
+	}
+
 	// 返回任务信息到前端
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "任务获取成功",
@@ -322,33 +723,39 @@ func accept_task(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
 		return
 	}
+	if request.Username != middleware.CurrentUser(ctx).User.Username {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "只能以自己的身份接受任务"})
+		return
+	}
 
-	// 检查用户是否已经接受了该任务
+	task, ok := loadTask(ctx, contract, request.TaskID)
+	if !ok {
+		return
+	}
 	user, err := invoke_fabric.Get_one_User(contract, request.Username)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("查询用户信息失败: %s", err.Error())})
 		return
 	}
 
-	for _, acceptedTask := range user.Accepted {
-		if acceptedTask == request.TaskID {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("用户 %s 已经接受了任务 %s", request.Username, request.TaskID)})
-			return
-		}
-	}
-
-	// 调用链码将任务添加到用户的 Accepted 字段
-	fmt.Printf("接受任务: 用户名=%s, 任务ID=%s\n", request.Username, request.TaskID)
-	err = invoke_fabric.AddToAccepted(contract, request.Username, request.TaskID)
+	// 检查任务状态和接受限制，返回所有不满足的条件
+	reasons, err := acceptRejections(contract, task, user, time.Now())
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("添加任务到用户的 Accepted 字段失败: %s", err.Error())})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(reasons) > 0 {
+		ctx.JSON(http.StatusForbidden, gin.H{
+			"error":   fmt.Sprintf("不能接受任务 %s: %s", request.TaskID, strings.Join(reasons, "；")),
+			"reasons": reasons,
+		})
 		return
 	}
 
-	// 调用链码将用户添加到任务的接受用户列表中
-	err = invoke_fabric.AddUserToTask(contract, request.TaskID, request.Username)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("将用户添加到任务的接受用户列表失败: %s", err.Error())})
+	// 调用链码接受任务，用户和任务在同一个交易中更新，第一个参与者加入时任务同时进入进行中状态
+	fmt.Printf("接受任务: 用户名=%s, 任务ID=%s\n", request.Username, request.TaskID)
+	if err := invoke_fabric.AcceptTask(contract, request.TaskID, request.Username, task.State()); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -360,6 +767,14 @@ func accept_task(ctx *gin.Context) {
 
 // 获取所有用户信息
 func get_all_users(ctx *gin.Context) {
+	var request struct {
+		IncludeDeleted bool `json:"includeDeleted"` // 是否包含已删除的用户
+	}
+	// 请求体可以为空
+	if err := ctx.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
 
 	contract := connect_fabric.GetContract(defaultConfig)
 	// 调用链码获取所有用户
@@ -378,16 +793,26 @@ func get_all_users(ctx *gin.Context) {
 		return
 	}
 
+	var users []map[string]interface{}
+	if err := json.Unmarshal(result, &users); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("解析用户失败: %s", err.Error())})
+		return
+	}
+	if !request.IncludeDeleted {
+		users = slices.DeleteFunc(users, isDeletedRecord)
+	}
+
 	// 返回用户信息到前端
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "用户获取成功",
-		"users":   json.RawMessage(result),
+		"users":   users,
 	})
 }
 
 func delete_user(ctx *gin.Context) {
 	var request struct {
 		Username string `json:"username"`
+		Reason   string `json:"reason"`
 	}
 	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
 
@@ -397,16 +822,34 @@ func delete_user(ctx *gin.Context) {
 		return
 	}
 
-	// 调用链码删除用户
-	err := invoke_fabric.DeleteUser(contract, request.Username)
+	user, err := invoke_fabric.Get_one_User(contract, request.Username)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("查询用户失败: %s", err.Error())})
+		return
+	}
+	if user.Deleted() {
+		ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("用户 %s 已于 %s 被删除", user.Username, user.DeletedAt)})
+		return
+	}
+
+	// 先取消该用户发布的任务并退出参与的任务，全部成功后再删除用户
+	admin := middleware.CurrentUser(ctx).User.Username
+	cascade, err := cascadeUserDeletion(contract, user.Username, admin)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("删除用户失败: %s", err.Error())})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("清理用户关联失败，可重新删除继续: %s", err.Error()), "cascade": cascade})
+		return
+	}
+
+	// 调用链码删除用户
+	if err := invoke_fabric.SoftDeleteUser(contract, user.Username, admin, request.Reason); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "cascade": cascade})
 		return
 	}
 
 	// 返回成功信息到前端
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": fmt.Sprintf("用户 %s 已成功删除", request.Username),
+		"cascade": cascade,
 	})
 }
 
@@ -414,6 +857,7 @@ func verify_user(ctx *gin.Context) {
 	var request struct {
 		Username   string `json:"username"`
 		IsAdmin    bool   `json:"isAdmin"`
+		IsVerified *bool  `json:"isVerified"` // 不传时保持原值
 		IsAccepted bool   `json:"isAccepted"`
 	}
 	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
@@ -424,12 +868,29 @@ func verify_user(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
 		return
 	}
-	print("test2")
-	print(request.Username)
-	print(request.IsAdmin)
-	print(request.IsAccepted)
-	// 调用链码更新用户的 isAdmin 和 isAccepted 状态
-	err := invoke_fabric.ManageUser(contract, request.Username, request.IsAdmin, true, request.IsAccepted)
+	// 未指定 isVerified 时保持用户当前的验证状态
+	user, err := invoke_fabric.Get_one_User(contract, request.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("查询用户失败: %s", err.Error())})
+		return
+	}
+	isVerified := user.IsVerified
+	if request.IsVerified != nil {
+		isVerified = *request.IsVerified
+	}
+
+	// 管理员接受用户时同时把注册审核状态改为通过，否则之前被拒绝或仍在待审核的用户仍然无法登录；
+	// 审核通过会把 isVerified 和 isAccepted 置为 true，随后按请求设置的标记覆盖
+	if request.IsAccepted && user.RegistrationStatus() != invoke_fabric.ReviewApproved {
+		reviewer := middleware.CurrentUser(ctx).User.Username
+		if err := invoke_fabric.ReviewUser(contract, request.Username, true, reviewer, "管理员接受用户"); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新用户审核状态失败: %s", err.Error())})
+			return
+		}
+	}
+
+	// 调用链码更新用户的 isAdmin、isVerified 和 isAccepted 状态
+	err = invoke_fabric.ManageUser(contract, request.Username, request.IsAdmin, isVerified, request.IsAccepted)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新用户状态失败: %s", err.Error())})
 		return
@@ -443,9 +904,18 @@ func verify_user(ctx *gin.Context) {
 
 func new_task(c *gin.Context) {
 	var requestBody struct {
-		Username    string `json:"username"`
-		Bonus       int    `json:"bonus"`
-		RootModelId string `json:"rootModelId"`
+		Username             string `json:"username"`
+		Bonus                int    `json:"bonus"`
+		RootModelId          string `json:"rootModelId"`
+		ExpectedParticipants int    `json:"expectedParticipants"` // 预计参与人数
+		Budget               int    `json:"budget"`               // 任务预算，填写后优先于 bonus × 预计参与人数
+		RewardStrategy       string `json:"rewardStrategy"`       // 奖励分配策略
+		TopK                 int    `json:"topK"`                 // top_k 策略的 K 值
+		Draft                bool   `json:"draft"`                // 保存为草稿，稍后发布
+		AcceptDeadline       string `json:"acceptDeadline"`       // 接受截止时间（RFC3339）
+		SubmitDeadline       string `json:"submitDeadline"`       // 提交模型截止时间（RFC3339）
+
+		Rules invoke_fabric.TaskRules `json:"rules"` // 接受任务的限制条件
 	}
 	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
 
@@ -454,20 +924,203 @@ func new_task(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
 		return
 	}
+	if requestBody.Username != middleware.CurrentUser(c).User.Username {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只能以自己的身份发布任务"})
+		return
+	}
+
+	// 计算需要托管的奖励总额
+	if requestBody.Bonus < 0 || requestBody.Budget < 0 || requestBody.ExpectedParticipants < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "奖励、预算和参与人数不能为负数"})
+		return
+	}
+	escrowAmount := requestBody.Budget
+	if escrowAmount == 0 {
+		if requestBody.Bonus > 0 && requestBody.ExpectedParticipants == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请填写预计参与人数或任务预算"})
+			return
+		}
+		// 先检查再相乘，避免溢出后得到较小的托管金额
+		if requestBody.ExpectedParticipants > 0 && requestBody.Bonus > math.MaxInt/requestBody.ExpectedParticipants {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "奖励与预计参与人数的乘积过大"})
+			return
+		}
+		escrowAmount = requestBody.Bonus * requestBody.ExpectedParticipants
+	}
+	if escrowAmount < requestBody.Bonus {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "任务预算不能少于单人奖励"})
+		return
+	}
+	if err := settlement.ValidateStrategy(requestBody.RewardStrategy, requestBody.TopK); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	acceptDeadline, submitDeadline, err := parseTaskDeadlines(requestBody.AcceptDeadline, requestBody.SubmitDeadline, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := requestBody.Rules.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 未填写退出罚金时写入当前的默认值，可以显式填写 0
+	if requestBody.Rules.LeavePenalty == nil {
+		penalty := defaultLeavePenalty()
+		requestBody.Rules.LeavePenalty = &penalty
+	}
+
+	// 初始模型必须已登记，提交的模型按它检查结构
+	if requestBody.RootModelId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请填写初始模型 ID"})
+		return
+	}
+	if _, err := invoke_fabric.ReadModel(contract, requestBody.RootModelId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("初始模型 %s 不存在: %s", requestBody.RootModelId, err.Error())})
+		return
+	}
+
+	// 发布者余额必须足够支付托管金额
+	poster, err := invoke_fabric.Get_one_User(contract, requestBody.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("查询发布者失败: %s", err.Error())})
+		return
+	}
+	if poster.Token < escrowAmount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("余额不足: 需要托管 %d，当前余额 %d", escrowAmount, poster.Token)})
+		return
+	}
 
 	// 调用 createNewTask 函数
 	round := 1            // 初始轮数为 1
 	nextRoundTaskID := "" // 初始任务没有下一轮任务 ID
-	invoke_fabric.CreateNewTask(contract, requestBody.Bonus, requestBody.RootModelId, requestBody.Username, round, nextRoundTaskID)
+	status := invoke_fabric.TaskOpen
+	if requestBody.Draft {
+		status = invoke_fabric.TaskDraft
+	}
+	taskID, err := invoke_fabric.CreateNewTask(contract, requestBody.Bonus, requestBody.RootModelId, requestBody.Username, round, nextRoundTaskID, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 后续任何一步失败都删除刚创建的任务，托管放在最后，删除时无需退款
+	rollback := func(err error) {
+		if delErr := invoke_fabric.DeleteTask(contract, taskID); delErr != nil {
+			fmt.Printf("回滚任务 %s 失败: %v\n", taskID, delErr)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+
+	// 设置截止时间
+	if !acceptDeadline.IsZero() || !submitDeadline.IsZero() {
+		if err := invoke_fabric.SetTaskDeadlines(contract, taskID, acceptDeadline, submitDeadline); err != nil {
+			rollback(err)
+			return
+		}
+	}
+
+	// 设置接受限制
+	if !requestBody.Rules.IsZero() {
+		if err := invoke_fabric.SetTaskRules(contract, taskID, requestBody.Rules); err != nil {
+			rollback(err)
+			return
+		}
+	}
+
+	// 设置奖励分配策略
+	if requestBody.RewardStrategy != "" && requestBody.RewardStrategy != settlement.StrategyFullBonus {
+		if err := invoke_fabric.SetRewardStrategy(contract, taskID, requestBody.RewardStrategy, requestBody.TopK); err != nil {
+			rollback(err)
+			return
+		}
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "任务创建成功"})
+	// 锁定奖励
+	if escrowAmount > 0 {
+		if _, err := invoke_fabric.LockEscrow(contract, taskID, requestBody.Username, escrowAmount); err != nil {
+			rollback(fmt.Errorf("托管任务奖励失败: %w", err))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "任务创建成功",
+		"taskId":  taskID,
+		"escrow":  escrowAmount,
+		"status":  status,
+	})
+}
+
+// 查询任务托管账户余额和流水
+func get_task_escrow(ctx *gin.Context) {
+	var request struct {
+		TaskID string `json:"taskId"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	account := invoke_fabric.EscrowAccount(request.TaskID)
+	balance, err := invoke_fabric.GetAccountBalance(contract, account)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	transfers, err := invoke_fabric.GetTransferHistory(contract, account)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":   "托管信息查询成功",
+		"account":   account,
+		"balance":   balance,
+		"transfers": transfers,
+	})
+}
+
+// 任务发布者追加托管奖励
+func fund_task_escrow(ctx *gin.Context) {
+	var request struct {
+		TaskID string `json:"taskId"`
+		Amount int    `json:"amount"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	task, ok := posterTask(ctx, contract, request.TaskID)
+	if !ok {
+		return
+	}
+
+	if _, err := invoke_fabric.LockEscrow(contract, task.TaskID, task.PostedUser, request.Amount); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("已向任务 %s 追加托管 %d", task.TaskID, request.Amount),
+	})
 }
 
 func next_task_round(c *gin.Context) {
 	var requestBody struct {
-		TaskID      string `json:"taskId"`
-		RootModelId string `json:"rootModelId"`
-		Username    string `json:"username"`
+		TaskID         string `json:"taskId"`
+		RootModelId    string `json:"rootModelId"`
+		Username       string `json:"username"`
+		AcceptDeadline string `json:"acceptDeadline"` // 下一轮的截止时间，为空时沿用本轮的时长
+		SubmitDeadline string `json:"submitDeadline"`
 	}
 	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
 
@@ -478,19 +1131,77 @@ func next_task_round(c *gin.Context) {
 		return
 	}
 
+	// 只有发布者或管理员可以结束本轮
+	task, ok := loadTask(c, contract, requestBody.TaskID)
+	if !ok {
+		return
+	}
+	if !checkTaskTransition(c, task, invoke_fabric.TaskRoundClosed) {
+		return
+	}
+	now := time.Now()
+	acceptDeadline, submitDeadline, err := parseTaskDeadlines(requestBody.AcceptDeadline, requestBody.SubmitDeadline, now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	acceptOffset, submitOffset := task.DeadlineOffsets()
+	if acceptDeadline.IsZero() && acceptOffset > 0 {
+		acceptDeadline = now.Add(acceptOffset)
+	}
+	if submitDeadline.IsZero() && submitOffset > 0 {
+		submitDeadline = now.Add(submitOffset)
+	}
+
 	// 调用 next_round 函数
-	err := invoke_fabric.Next_round(contract, requestBody.TaskID, requestBody.RootModelId)
+	nextTaskID, err := invoke_fabric.Next_round(contract, requestBody.TaskID, requestBody.RootModelId, middleware.CurrentUser(c).User.Username, acceptDeadline, submitDeadline)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("任务轮次更新失败: %v", err)})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("任务轮次更新失败: %v", err), "nextTaskId": nextTaskID})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "任务轮次更新成功"})
+	c.JSON(http.StatusOK, gin.H{"message": "任务轮次更新成功", "nextTaskId": nextTaskID})
+}
+
+// 检查已结束的任务能否删除：已完成的任务须结算完成，进入下一轮的任务须已把托管余额结转到下一轮
+func checkTaskDeletable(contract *client.Contract, task *invoke_fabric.Task) error {
+	switch task.State() {
+	case invoke_fabric.TaskCompleted:
+		job, err := settlements.Store().Get(task.TaskID)
+		if errors.Is(err, settlement.ErrJobNotFound) {
+			return fmt.Errorf("任务 %s 尚未结算，不能删除", task.TaskID)
+		}
+		if err != nil {
+			return err
+		}
+		if job.Status != settlement.JobCompleted {
+			return fmt.Errorf("任务 %s 的奖励尚未全部发放，请先继续结算", task.TaskID)
+		}
+	case invoke_fabric.TaskRoundClosed:
+		balance, err := invoke_fabric.GetAccountBalance(contract, invoke_fabric.EscrowAccount(task.TaskID))
+		if err != nil {
+			return err
+		}
+		if balance > 0 {
+			return fmt.Errorf("任务 %s 的托管余额 %d 尚未结转到下一轮", task.TaskID, balance)
+		}
+	}
+	return nil
+}
+
+// 删除任务时只有取消和过期的任务退还托管余额（罚金分给参与者），已完成任务的剩余余额由结算退还
+func refundDeletedTask(contract *client.Contract, task *invoke_fabric.Task) error {
+	switch task.State() {
+	case invoke_fabric.TaskCancelled, invoke_fabric.TaskExpired:
+		return refundClosed(contract, task)
+	}
+	return nil
 }
 
 func delete_task(ctx *gin.Context) {
 	var request struct {
 		TaskID string `json:"taskId"`
+		Reason string `json:"reason"`
 	}
 	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
 
@@ -500,10 +1211,32 @@ func delete_task(ctx *gin.Context) {
 		return
 	}
 
-	// 调用链码删除任务
-	err := invoke_fabric.DeleteTask(contract, request.TaskID)
+	task, ok := loadTask(ctx, contract, request.TaskID)
+	if !ok {
+		return
+	}
+
+	// 已完成和进入下一轮的任务必须先结清托管余额
+	if err := checkTaskDeletable(contract, task); err != nil {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": task.State()})
+		return
+	}
+
+	// 未结束的任务先取消，取消和过期的任务把托管余额退还给发布者
+	if !invoke_fabric.TaskClosed(task.State()) {
+		if !transitionTask(ctx, contract, task, invoke_fabric.TaskCancelled, "删除任务") {
+			return
+		}
+	}
+	if err := refundDeletedTask(contract, task); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 调用链码删除任务，任务记录保留，参与者的 Accepted 中移除该任务
+	err := invoke_fabric.SoftDeleteTask(contract, task.TaskID, middleware.CurrentUser(ctx).User.Username, request.Reason)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("删除任务失败: %s", err.Error())})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -525,37 +1258,46 @@ func finish_task(ctx *gin.Context) {
 		return
 	}
 
-	get_task, err := invoke_fabric.QueryTask(contract, request.TaskID)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取任务数据失败: %s", err.Error())})
+	get_task, ok := loadTask(ctx, contract, request.TaskID)
+	if !ok {
 		return
 	}
-	// 调用链码读取任务信息
-	acceptedUsers, err := invoke_fabric.Finish_Task(contract, request.TaskID)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("修改任务时失败;%s", err.Error())})
+
+	// 已完成的任务只继续结算，其余状态必须允许变更为完成
+	completed := get_task.State() == invoke_fabric.TaskCompleted
+	if !completed && !checkTaskTransition(ctx, get_task, invoke_fabric.TaskCompleted) {
 		return
 	}
-	// 提取接受任务的用户名单
 
-	if len(acceptedUsers) == 0 {
-		ctx.JSON(http.StatusOK, gin.H{"success": "没有用户接受该任务"})
+	// 已有结算记录时直接继续，避免重复支付
+	job, err := settlements.Store().Get(get_task.TaskID)
+	if errors.Is(err, settlement.ErrJobNotFound) {
+		// 按任务的奖励分配策略计算每个人的奖励
+		amounts, err := planPayouts(contract, get_task)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("计算奖励失败: %s", err.Error())})
+			return
+		}
+		// 其他实例已创建结算时沿用已有的支付计划
+		job, err = settlements.Store().Create(settlement.NewJob(get_task.TaskID, get_task.Round, amounts, get_task.AcceptedUsers))
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建结算任务失败: %s", err.Error())})
+			return
+		}
+	} else if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 向接受任务的用户转账
-	for _, user := range acceptedUsers {
-		err := invoke_fabric.TransferTokens(contract, "task_owner", user, get_task.Bonus)
-		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("向用户 %s 转账失败: %s", user, err.Error())})
+	// 调用链码将任务标记为完成
+	if !completed {
+		if _, err := invoke_fabric.Finish_Task(contract, request.TaskID, middleware.CurrentUser(ctx).User.Username); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("修改任务时失败;%s", err.Error())})
 			return
 		}
 	}
 
-	// 返回成功信息到前端
-	ctx.JSON(http.StatusOK, gin.H{
-		"message": fmt.Sprintf("任务 %s 已成功完成，奖励已发放:", request.TaskID),
-	})
+	respondSettlement(ctx, contract, get_task, job)
 }
 
 func model_to_task(ctx *gin.Context) {
@@ -571,43 +1313,53 @@ func model_to_task(ctx *gin.Context) {
 		return
 	}
 
-	// 调用链码将模型添加到任务
-	fmt.Printf("将模型添加到任务: 模型ID=%s, 任务ID=%s\n", request.ModelID, request.TaskID)
-	err := invoke_fabric.AddModelToTask(contract, request.TaskID, request.ModelID)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("将模型添加到任务失败: %s", err.Error())})
+	// 只有参与者可以在进行中的任务提交模型
+	task, ok := loadTask(ctx, contract, request.TaskID)
+	if !ok {
+		return
+	}
+	if task.State() != invoke_fabric.TaskInProgress {
+		ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("任务处于 %s 状态，不能提交模型", task.State()), "status": task.State()})
+		return
+	}
+	if task.SubmitClosed(time.Now()) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("任务已于 %s 截止提交", task.SubmitDeadline)})
+		return
+	}
+	if !slices.Contains(taskRoles(ctx, task), invoke_fabric.TaskRoleParticipant) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "只有接受了任务的用户可以提交模型"})
 		return
 	}
 
-	// 返回成功信息到前端
-	ctx.JSON(http.StatusOK, gin.H{
-		"message": fmt.Sprintf("模型 %s 已成功添加到任务 %s", request.ModelID, request.TaskID),
-	})
-}
-
-// 获取模型的 CID
-func get_model_cid(ctx *gin.Context) {
-	var request struct {
-		ModelID string `json:"modelID"`
+	model, err := invoke_fabric.ReadModel(contract, request.ModelID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("获取模型失败: %s", err.Error())})
+		return
+	}
+	// 只能提交自己上传的模型，结算按模型上传者确定提交者
+	if model.Modelowner != middleware.CurrentUser(ctx).User.Username {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("模型 %s 不属于你，不能提交", model.Modelid)})
+		return
 	}
-	contract := invoke_fabric.GetContract(defaultConfig) // 使用 defaultConfig
 
-	// 绑定 JSON 数据
-	if err := ctx.BindJSON(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+	// 提交的模型须与任务初始模型结构一致；旧任务的初始模型没有登记时没有可比较的结构，跳过检查
+	if root, err := invoke_fabric.ReadModel(contract, task.RootModelId); err != nil {
+		fmt.Printf("读取任务 %s 的初始模型 %s 失败，跳过结构检查: %v\n", task.TaskID, task.RootModelId, err)
+	} else if err := checkModelArchitecture(root, model); err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 调用链码函数 ReadModel
-	model, err := invoke_fabric.ReadModel(contract, request.ModelID)
+	// 调用链码将模型添加到任务
+	fmt.Printf("将模型添加到任务: 模型ID=%s, 任务ID=%s\n", request.ModelID, request.TaskID)
+	err = invoke_fabric.AddModelToTask(contract, request.TaskID, request.ModelID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取模型失败: %s", err.Error())})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("将模型添加到任务失败: %s", err.Error())})
 		return
 	}
 
-	// 返回模型的 CID
+	// 返回成功信息到前端
 	ctx.JSON(http.StatusOK, gin.H{
-		"message": "模型获取成功",
-		"cid":     model.Modelhash,
+		"message": fmt.Sprintf("模型 %s 已成功添加到任务 %s", request.ModelID, request.TaskID),
 	})
 }
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"backend/auth"
+	invoke_fabric "backend/fabric-go/call"
+	connect_fabric "backend/fabric-go/network"
+	"backend/middleware"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 认证器中显示的签发方名称
+const mfaIssuer = "fabric-vue"
+
+// 密码或公钥验证通过、等待二次验证的登录会话
+var mfaSessions = auth.NewChallengeStore(5 * time.Minute)
+
+// 管理员的 TOTP 密钥和恢复码
+var mfaStore auth.MFAStore = auth.NewFileMFAStore(mfaStorePath())
+
+func mfaStorePath() string {
+	if p := os.Getenv("MFA_STORE_PATH"); p != "" {
+		return p
+	}
+	return "./mfa.json"
+}
+
+// 管理员登录需要二次验证，返回中间状态而不是令牌
+func requireMFA(ctx *gin.Context, queriedUser *invoke_fabric.User) {
+	session, err := mfaSessions.Issue(queriedUser.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := "mfa_required"
+	record, err := mfaStore.Get(queriedUser.Username)
+	if errors.Is(err, auth.ErrMFANotEnrolled) || (err == nil && !record.Confirmed) {
+		// 管理员尚未绑定认证器，必须先完成绑定
+		status = "mfa_enrollment_required"
+	} else if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":   "需要二次验证",
+		"status":    status,
+		"mfaToken":  session.Nonce,
+		"expiresAt": session.ExpiresAt,
+	})
+}
+
+// 绑定认证器：生成 TOTP 密钥和 otpauth URI
+func mfa_enroll(ctx *gin.Context) {
+	var request struct {
+		Username string `json:"username"`
+		MFAToken string `json:"mfaToken"`
+	}
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	if err := mfaSessions.Check(request.Username, request.MFAToken); err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 已经确认过的认证器不能通过登录会话覆盖
+	record, err := mfaStore.Get(request.Username)
+	if err == nil && record.Confirmed {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "已绑定二次验证"})
+		return
+	}
+	if err != nil && !errors.Is(err, auth.ErrMFANotEnrolled) {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	err = mfaStore.Put(&auth.MFARecord{
+		Username:   request.Username,
+		Secret:     secret,
+		EnrolledAt: time.Now(),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "请使用认证器扫描并输入验证码确认",
+		"secret":  secret,
+		"uri":     auth.TOTPURI(mfaIssuer, request.Username, secret),
+	})
+}
+
+// 确认绑定：校验第一个验证码，生成恢复码并完成登录
+func mfa_confirm(ctx *gin.Context) {
+	var request struct {
+		Username string `json:"username"`
+		MFAToken string `json:"mfaToken"`
+		Code     string `json:"code"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	if ok, wait := rateLimiter.AllowLogin(ctx.ClientIP(), request.Username); !ok {
+		middleware.TooManyRequests(ctx, wait)
+		return
+	}
+	if err := mfaSessions.Check(request.Username, request.MFAToken); err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	record, err := mfaStore.Get(request.Username)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if record.Confirmed {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "已绑定二次验证"})
+		return
+	}
+
+	step, err := auth.VerifyTOTP(record.Secret, request.Code, time.Now(), record.LastStep)
+	if err != nil {
+		rateLimiter.LoginFailed(request.Username)
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	queriedUser, ok := mfaUser(ctx, contract, request.Username)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, hashed, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	record.Confirmed = true
+	record.LastStep = step
+	record.RecoveryCodes = hashed
+	if err := mfaStore.Put(record); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 绑定完成，作废登录会话并签发令牌
+	if _, err := mfaSessions.Consume(request.Username, request.MFAToken); err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	token, err := issueLoginToken(queriedUser, true)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rateLimiter.LoginSucceeded(queriedUser.Username)
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":       "二次验证绑定成功，请妥善保存恢复码",
+		"token":         token,
+		"recoveryCodes": recoveryCodes,
+		"user":          loginUserInfo(queriedUser),
+	})
+}
+
+// 登录第二步：校验 TOTP 验证码或恢复码
+func login_mfa(ctx *gin.Context) {
+	var request struct {
+		Username     string `json:"username"`
+		MFAToken     string `json:"mfaToken"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	if ok, wait := rateLimiter.AllowLogin(ctx.ClientIP(), request.Username); !ok {
+		middleware.TooManyRequests(ctx, wait)
+		return
+	}
+
+	// 每个登录会话只能尝试一次，验证失败需要重新输入密码
+	if _, err := mfaSessions.Consume(request.Username, request.MFAToken); err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	record, err := mfaStore.Get(request.Username)
+	if err != nil || !record.Confirmed {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未绑定二次验证"})
+		return
+	}
+
+	if request.RecoveryCode != "" {
+		err = record.UseRecoveryCode(request.RecoveryCode)
+	} else {
+		var step int64
+		step, err = auth.VerifyTOTP(record.Secret, request.Code, time.Now(), record.LastStep)
+		if err == nil {
+			record.LastStep = step
+		}
+	}
+	if err != nil {
+		rateLimiter.LoginFailed(request.Username)
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err := mfaStore.Put(record); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	queriedUser, ok := mfaUser(ctx, contract, request.Username)
+	if !ok {
+		return
+	}
+	completeLogin(ctx, queriedUser, true)
+}
+
+// 签发令牌前重新读取用户，第一步验证之后被删除、停用或取消管理员权限的用户不能完成二次验证
+func mfaUser(ctx *gin.Context, contract *client.Contract, username string) (*invoke_fabric.User, bool) {
+	queriedUser, err := invoke_fabric.Get_one_User(contract, username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("查询用户失败: %s", err.Error())})
+		return nil, false
+	}
+	if err := queriedUser.CheckActive(); err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if !queriedUser.IsAdmin {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "用户已不是管理员，请重新登录"})
+		return nil, false
+	}
+	return queriedUser, true
+}
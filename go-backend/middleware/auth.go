@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"backend/auth"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const claimsKey = "claims"
+
+// 校验 Authorization: Bearer 令牌
+func RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		tokenString, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || tokenString == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "缺少登录令牌"})
+			return
+		}
+
+		claims, err := auth.ParseToken(tokenString)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Set(claimsKey, claims)
+		c.Next()
+	}
+}
+
+// 公开接口的可选登录：带有有效令牌且账号可用时记录当前用户，否则按未登录处理
+func OptionalAuth(check func(username string) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if ok && tokenString != "" {
+			if claims, err := auth.ParseToken(tokenString); err == nil && check(claims.User.Username) == nil {
+				c.Set(claimsKey, claims)
+			}
+		}
+		c.Next()
+	}
+}
+
+// 每次请求重新检查账号状态，令牌签发后被删除或停用的用户不能继续操作
+func RequireActive(check func(username string) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := CurrentUser(c)
+		if claims == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+			return
+		}
+		if err := check(claims.User.Username); err != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.Next()
+	}
+}
+
+// 要求管理员身份，且登录时通过了二次验证
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := CurrentUser(c)
+		if claims == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+			return
+		}
+		if !claims.User.IsAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
+			return
+		}
+		if !claims.MFA {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "管理员操作需要完成二次验证"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// 获取当前请求的登录信息，未经过 RequireAuth 时返回 nil
+func CurrentUser(c *gin.Context) *auth.Claims {
+	value, ok := c.Get(claimsKey)
+	if !ok {
+		return nil
+	}
+	claims, _ := value.(*auth.Claims)
+	return claims
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"backend/auth"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 可选登录：有效令牌记录当前用户，缺少、无效令牌或账号不可用时按未登录处理
+func TestOptionalAuth(t *testing.T) {
+	t.Setenv("JWT_SECRET", "0123456789abcdef0123456789abcdef")
+	gin.SetMode(gin.TestMode)
+
+	admin, err := auth.IssueToken(auth.TokenUser{Username: "root", IsAdmin: true}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := auth.IssueToken(auth.TokenUser{Username: "gone"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(username string) error {
+		if username == "gone" {
+			return errors.New("账号已被删除")
+		}
+		return nil
+	}
+
+	cases := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"未登录", "", ""},
+		{"有效令牌", "Bearer " + admin, "root"},
+		{"无效令牌", "Bearer invalid", ""},
+		{"账号不可用", "Bearer " + deleted, ""},
+		{"格式错误", admin, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := gin.New()
+			var got string
+			r.POST("/", OptionalAuth(check), func(c *gin.Context) {
+				if claims := CurrentUser(c); claims != nil {
+					got = claims.User.Username
+				}
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("状态码为 %d，公开接口不应拒绝请求", w.Code)
+			}
+			if got != tc.want {
+				t.Errorf("当前用户为 %q，应为 %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 跨域配置
+type CORSConfig struct {
+	AllowedOrigins   []string // 允许的来源，支持 https://*.example.com 形式的子域名通配
+	AllowCredentials bool
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string // 允许前端读取的响应头
+	MaxAge           time.Duration
+}
+
+// 从环境变量读取跨域配置
+func LoadCORSConfig() CORSConfig {
+	cfg := CORSConfig{
+		AllowedOrigins:   []string{"http://localhost:5173"},
+		AllowCredentials: true,
+		AllowedMethods:   []string{"GET", "POST", "PATCH", "HEAD", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Content-Type", "Authorization",
+			"Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata", "Upload-Checksum"},
+		ExposedHeaders: []string{"Location", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size",
+			"Upload-Offset", "Upload-Length", "Upload-Expires", "Content-Disposition", "X-Model-CID", "X-Model-Storage", "X-Model-Encryption"},
+		MaxAge: 10 * time.Minute,
+	}
+	if v := os.Getenv("CORS_ALLOWED_ORIGINS"); v != "" {
+		cfg.AllowedOrigins = splitList(v)
+	}
+	if v, err := strconv.ParseBool(os.Getenv("CORS_ALLOW_CREDENTIALS")); err == nil {
+		cfg.AllowCredentials = v
+	}
+	if v := os.Getenv("CORS_ALLOWED_HEADERS"); v != "" {
+		cfg.AllowedHeaders = splitList(v)
+	}
+	return cfg
+}
+
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// 判断来源是否在白名单中
+func (cfg CORSConfig) allowOrigin(origin string) bool {
+	for _, allowed := range cfg.AllowedOrigins {
+		if allowed == origin {
+			return true
+		}
+		// 携带凭据时不允许任意来源
+		if allowed == "*" && !cfg.AllowCredentials {
+			return true
+		}
+		if scheme, suffix, ok := strings.Cut(allowed, "://*."); ok {
+			if rest, ok := strings.CutPrefix(origin, scheme+"://"); ok && strings.HasSuffix(rest, "."+suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 跨域中间件
+func CORS(cfg CORSConfig) gin.HandlerFunc {
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Origin")
+		if !cfg.allowOrigin(origin) {
+			if c.Request.Method == http.MethodOptions {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			// 非预检请求不带跨域头，由浏览器拦截响应
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", origin)
+		if cfg.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+		if c.Request.Method == http.MethodOptions {
+			c.Header("Access-Control-Allow-Methods", methods)
+			c.Header("Access-Control-Allow-Headers", headers)
+			c.Header("Access-Control-Max-Age", maxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		if exposed != "" {
+			c.Header("Access-Control-Expose-Headers", exposed)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,299 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 限流配置，可通过环境变量覆盖
+type RateLimitConfig struct {
+	LoginIPRate      float64       // 每个 IP 每秒允许的登录请求数
+	LoginIPBurst     int           // 每个 IP 的登录突发上限
+	LoginUserRate    float64       // 每个用户名每秒允许的登录请求数
+	LoginUserBurst   int           // 每个用户名的登录突发上限
+	LockoutThreshold int           // 连续失败多少次后开始锁定
+	LockoutBase      time.Duration // 首次锁定时长，之后每次失败翻倍
+	LockoutMax       time.Duration // 最长锁定时长
+	WriteRate        float64       // 写接口每秒补充的令牌数
+	WriteBurst       int           // 写接口令牌桶容量
+}
+
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		LoginIPRate:      1,
+		LoginIPBurst:     10,
+		LoginUserRate:    0.2,
+		LoginUserBurst:   5,
+		LockoutThreshold: 5,
+		LockoutBase:      30 * time.Second,
+		LockoutMax:       time.Hour,
+		WriteRate:        2,
+		WriteBurst:       20,
+	}
+}
+
+// 从环境变量读取限流配置，未设置的项使用默认值
+func LoadRateLimitConfig() RateLimitConfig {
+	cfg := DefaultRateLimitConfig()
+	envFloat("RATE_LIMIT_LOGIN_IP_RATE", &cfg.LoginIPRate)
+	envInt("RATE_LIMIT_LOGIN_IP_BURST", &cfg.LoginIPBurst)
+	envFloat("RATE_LIMIT_LOGIN_USER_RATE", &cfg.LoginUserRate)
+	envInt("RATE_LIMIT_LOGIN_USER_BURST", &cfg.LoginUserBurst)
+	envInt("RATE_LIMIT_LOCKOUT_THRESHOLD", &cfg.LockoutThreshold)
+	envDuration("RATE_LIMIT_LOCKOUT_BASE", &cfg.LockoutBase)
+	envDuration("RATE_LIMIT_LOCKOUT_MAX", &cfg.LockoutMax)
+	envFloat("RATE_LIMIT_WRITE_RATE", &cfg.WriteRate)
+	envInt("RATE_LIMIT_WRITE_BURST", &cfg.WriteBurst)
+	return cfg
+}
+
+func envFloat(name string, target *float64) {
+	if v, err := strconv.ParseFloat(os.Getenv(name), 64); err == nil {
+		*target = v
+	}
+}
+
+func envInt(name string, target *int) {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil {
+		*target = v
+	}
+}
+
+func envDuration(name string, target *time.Duration) {
+	if v, err := time.ParseDuration(os.Getenv(name)); err == nil {
+		*target = v
+	}
+}
+
+// 限流状态存储，默认使用内存实现，多副本部署时可替换为共享存储
+type RateLimitStore interface {
+	// 从令牌桶中取一个令牌，失败时返回需要等待的时间
+	Take(key string, rate float64, burst int, now time.Time) (bool, time.Duration)
+	// 记录一次失败，返回累计失败次数
+	AddFailure(key string, now time.Time) int
+	// 设置锁定截止时间
+	Lock(key string, until time.Time)
+	// 查询锁定截止时间
+	LockedUntil(key string) time.Time
+	// 清除失败记录和锁定
+	Reset(key string)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // 不再取令牌时桶重新装满的时间，之后可以清除
+}
+
+type failure struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// 清理过期记录的间隔，以及最后一次失败后失败记录的保留时间
+const (
+	rateLimitSweepInterval = time.Minute
+	failureTTL             = 24 * time.Hour
+)
+
+// 内存限流存储，装满的令牌桶和过期的失败记录定期清除，避免按 IP 和用户名无限增长
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	failures  map[string]*failure
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:  make(map[string]*bucket),
+		failures: make(map[string]*failure),
+	}
+}
+
+// 清除已装满的令牌桶（与新建的桶等价）和已解锁且过期的失败记录，调用方需持有锁
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+	for key, f := range s.failures {
+		if !now.Before(f.lockedUntil) && now.Sub(f.last) > failureTTL {
+			delete(s.failures, key)
+		}
+	}
+}
+
+func (s *MemoryRateLimitStore) Take(key string, rate float64, burst int, now time.Time) (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		s.buckets[key] = b
+	}
+	defer b.refillAt(rate, burst)
+
+	// 按经过的时间补充令牌
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if rate <= 0 {
+		return false, time.Hour
+	}
+	wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+// 计算桶重新装满的时间，速率为 0 时桶不会补充，按失败记录的保留时间清除
+func (b *bucket) refillAt(rate float64, burst int) {
+	missing := float64(burst) - b.tokens
+	if rate <= 0 {
+		b.full = b.last.Add(failureTTL)
+		return
+	}
+	b.full = b.last.Add(time.Duration(missing / rate * float64(time.Second)))
+}
+
+func (s *MemoryRateLimitStore) AddFailure(key string, now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+	f, ok := s.failures[key]
+	if !ok {
+		f = &failure{}
+		s.failures[key] = f
+	}
+	f.count++
+	f.last = now
+	return f.count
+}
+
+func (s *MemoryRateLimitStore) Lock(key string, until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f, ok := s.failures[key]; ok {
+		f.lockedUntil = until
+	}
+}
+
+func (s *MemoryRateLimitStore) LockedUntil(key string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f, ok := s.failures[key]; ok {
+		return f.lockedUntil
+	}
+	return time.Time{}
+}
+
+func (s *MemoryRateLimitStore) Reset(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.failures, key)
+}
+
+// 限流器
+type RateLimiter struct {
+	config RateLimitConfig
+	store  RateLimitStore
+}
+
+func NewRateLimiter(config RateLimitConfig, store RateLimitStore) *RateLimiter {
+	return &RateLimiter{config: config, store: store}
+}
+
+// 检查登录请求是否允许，被拒绝时返回需要等待的时间
+func (l *RateLimiter) AllowLogin(ip, username string) (bool, time.Duration) {
+	now := time.Now()
+
+	// 用户名处于锁定期
+	if until := l.store.LockedUntil("login-fail:" + username); now.Before(until) {
+		return false, until.Sub(now)
+	}
+	return l.takeIPAndUser("login", ip, username, now)
+}
+
+// 检查注册请求是否允许
+func (l *RateLimiter) AllowRegister(ip, username string) (bool, time.Duration) {
+	return l.takeIPAndUser("register", ip, username, time.Now())
+}
+
+func (l *RateLimiter) takeIPAndUser(scope, ip, username string, now time.Time) (bool, time.Duration) {
+	if ok, wait := l.store.Take(scope+"-ip:"+ip, l.config.LoginIPRate, l.config.LoginIPBurst, now); !ok {
+		return false, wait
+	}
+	if ok, wait := l.store.Take(scope+"-user:"+username, l.config.LoginUserRate, l.config.LoginUserBurst, now); !ok {
+		return false, wait
+	}
+	return true, 0
+}
+
+// 记录一次登录失败，超过阈值后按指数退避锁定用户名
+func (l *RateLimiter) LoginFailed(username string) {
+	now := time.Now()
+	key := "login-fail:" + username
+	count := l.store.AddFailure(key, now)
+	if count < l.config.LockoutThreshold {
+		return
+	}
+
+	lockout := l.config.LockoutBase << (count - l.config.LockoutThreshold)
+	if lockout <= 0 || lockout > l.config.LockoutMax {
+		lockout = l.config.LockoutMax
+	}
+	l.store.Lock(key, now.Add(lockout))
+}
+
+// 登录成功后清除失败记录
+func (l *RateLimiter) LoginSucceeded(username string) {
+	l.store.Reset("login-fail:" + username)
+}
+
+// 写接口令牌桶限流，已登录用户按用户名计，否则按 IP 计
+func (l *RateLimiter) WriteLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := "write-ip:" + c.ClientIP()
+		if claims := CurrentUser(c); claims != nil {
+			key = "write-user:" + claims.User.Username
+		}
+		if ok, wait := l.store.Take(key, l.config.WriteRate, l.config.WriteBurst, time.Now()); !ok {
+			TooManyRequests(c, wait)
+			return
+		}
+		c.Next()
+	}
+}
+
+// 返回 429 并设置 Retry-After
+func TooManyRequests(c *gin.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error": fmt.Sprintf("请求过于频繁，请 %d 秒后重试", seconds),
+	})
+}
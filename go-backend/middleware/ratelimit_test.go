@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+// 令牌桶：突发上限内放行，之后按速率补充并返回需要等待的时间
+func TestMemoryRateLimitStoreTake(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	now := time.Unix(1700000000, 0)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := store.Take("k", 1, 3, now); !ok {
+			t.Fatalf("第 %d 个请求被拒绝", i+1)
+		}
+	}
+	ok, wait := store.Take("k", 1, 3, now)
+	if ok || wait != time.Second {
+		t.Fatalf("超过突发上限时返回 %t, 等待 %v", ok, wait)
+	}
+
+	// 半秒只补充半个令牌
+	if ok, wait := store.Take("k", 1, 3, now.Add(500*time.Millisecond)); ok || wait != 500*time.Millisecond {
+		t.Errorf("补充不足一个令牌时返回 %t, 等待 %v", ok, wait)
+	}
+	if ok, _ := store.Take("k", 1, 3, now.Add(time.Second)); !ok {
+		t.Error("补充一个令牌后应放行")
+	}
+	// 补充不超过突发上限
+	later := now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if ok, _ := store.Take("k", 1, 3, later); !ok {
+			t.Fatalf("长时间未请求后第 %d 个请求被拒绝", i+1)
+		}
+	}
+	if ok, _ := store.Take("k", 1, 3, later); ok {
+		t.Error("补充的令牌超过了突发上限")
+	}
+
+	// 速率为 0 的桶不会补充
+	if ok, _ := store.Take("zero", 0, 1, now); !ok {
+		t.Fatal("第一个请求被拒绝")
+	}
+	if ok, wait := store.Take("zero", 0, 1, now.Add(time.Hour)); ok || wait <= 0 {
+		t.Errorf("速率为 0 时返回 %t, 等待 %v", ok, wait)
+	}
+}
+
+// 装满的令牌桶和过期的失败记录被清除，未装满的保留
+func TestMemoryRateLimitStoreSweep(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	now := time.Unix(1700000000, 0)
+
+	store.Take("full", 10, 10, now)
+	store.Take("slow", 1e-6, 10, now)
+	store.AddFailure("old", now)
+	store.AddFailure("locked", now)
+	store.Lock("locked", now.Add(48*time.Hour))
+
+	store.Take("other", 1, 1, now.Add(failureTTL+time.Hour))
+	if _, ok := store.buckets["full"]; ok {
+		t.Error("已装满的令牌桶没有清除")
+	}
+	if _, ok := store.buckets["slow"]; !ok {
+		t.Error("未装满的令牌桶被清除")
+	}
+	if _, ok := store.failures["old"]; ok {
+		t.Error("过期的失败记录没有清除")
+	}
+	if _, ok := store.failures["locked"]; !ok {
+		t.Error("仍在锁定期的失败记录被清除")
+	}
+}
+
+// 连续失败达到阈值后锁定，之后每次失败锁定时长翻倍，不超过上限；登录成功后清除
+func TestRateLimiterLockout(t *testing.T) {
+	cfg := DefaultRateLimitConfig()
+	cfg.LockoutThreshold = 3
+	cfg.LockoutBase = time.Minute
+	cfg.LockoutMax = 5 * time.Minute
+	store := NewMemoryRateLimitStore()
+	limiter := NewRateLimiter(cfg, store)
+
+	lockout := func() time.Duration {
+		until := store.LockedUntil("login-fail:alice")
+		if until.IsZero() {
+			return 0
+		}
+		return time.Until(until).Round(time.Minute)
+	}
+
+	want := []time.Duration{0, 0, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, w := range want {
+		limiter.LoginFailed("alice")
+		if got := lockout(); got != w {
+			t.Errorf("第 %d 次失败后锁定 %v, 期望 %v", i+1, got, w)
+		}
+	}
+	if ok, wait := limiter.AllowLogin("127.0.0.1", "alice"); ok || wait <= 0 {
+		t.Errorf("锁定期内登录返回 %t, 等待 %v", ok, wait)
+	}
+	if ok, _ := limiter.AllowLogin("127.0.0.1", "bob"); !ok {
+		t.Error("其他用户不应被锁定")
+	}
+
+	limiter.LoginSucceeded("alice")
+	if lockout() != 0 {
+		t.Error("登录成功后没有清除锁定")
+	}
+}
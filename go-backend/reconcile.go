@@ -0,0 +1,86 @@
+package main
+
+import (
+	connect_fabric "backend/fabric-go/network"
+	"backend/reconcile"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 检查账本引用一致性，apply 为 true 时执行修复
+func reconcile_ledger(ctx *gin.Context) {
+	var request struct {
+		Apply bool `json:"apply"` // 默认只检查不修复
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	report, err := reconcile.Scan(contract)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("检查账本失败: %s", err.Error())})
+		return
+	}
+	if !request.Apply {
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": fmt.Sprintf("发现 %d 处不一致，%d 条记录待修复", len(report.Issues), len(report.Fixes)),
+			"report":  report,
+		})
+		return
+	}
+
+	applied, failed := reconcile.Apply(contract, report.Fixes)
+	status := http.StatusOK
+	if len(failed) > 0 {
+		status = http.StatusMultiStatus
+	}
+	ctx.JSON(status, gin.H{
+		"message": fmt.Sprintf("已修复 %d 条记录，失败 %d 条", len(applied), len(failed)),
+		"report":  report,
+		"applied": applied,
+		"failed":  failed,
+	})
+}
+
+// 命令行执行：backend reconcile [-apply]
+func runReconcileCommand(args []string) int {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	apply := flags.Bool("apply", false, "执行修复，默认只检查")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+	report, err := reconcile.Scan(contract)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "检查账本失败: %v\n", err)
+		return 1
+	}
+
+	out := map[string]any{"report": report}
+	code := 0
+	if *apply {
+		applied, failed := reconcile.Apply(contract, report.Fixes)
+		out["applied"] = applied
+		out["failed"] = failed
+		if len(failed) > 0 {
+			code = 1
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return 1
+	}
+	return code
+}
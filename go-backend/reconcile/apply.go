@@ -0,0 +1,50 @@
+package reconcile
+
+import (
+	invoke_fabric "backend/fabric-go/call"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 修复失败的记录
+type Failure struct {
+	Entity string `json:"entity"`
+	ID     string `json:"id"`
+	Error  string `json:"error"`
+}
+
+// 读取链上的用户、任务和模型并检查
+func Scan(contract *client.Contract) (*Report, error) {
+	users, err := invoke_fabric.GetAllUsers(contract)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := invoke_fabric.ListTasks(contract)
+	if err != nil {
+		return nil, err
+	}
+	models, err := invoke_fabric.GetAllModels(contract)
+	if err != nil {
+		return nil, err
+	}
+	return Check(users, tasks, models), nil
+}
+
+// 执行修复；每条记录单独提交，扫描后被修改过的记录会失败，重新扫描后再修复即可
+func Apply(contract *client.Contract, fixes []Fix) (applied []Fix, failed []Failure) {
+	for _, fix := range fixes {
+		var err error
+		switch fix.Entity {
+		case "user":
+			err = invoke_fabric.RepairUserRefs(contract, fix.ID, fix.Before.(invoke_fabric.UserRefs), fix.After.(invoke_fabric.UserRefs))
+		case "task":
+			err = invoke_fabric.RepairTaskRefs(contract, fix.ID, fix.Before.(invoke_fabric.TaskRefs), fix.After.(invoke_fabric.TaskRefs))
+		}
+		if err != nil {
+			failed = append(failed, Failure{Entity: fix.Entity, ID: fix.ID, Error: err.Error()})
+			continue
+		}
+		applied = append(applied, fix)
+	}
+	return applied, failed
+}
@@ -0,0 +1,203 @@
+package reconcile
+
+import (
+	invoke_fabric "backend/fabric-go/call"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// 不一致的类型
+const (
+	AcceptedTaskMissing = "accepted_task_missing" // 用户接受的任务不存在
+	AcceptedTaskDeleted = "accepted_task_deleted" // 用户接受的任务已被删除
+	AcceptedOneSided    = "accepted_one_sided"    // 用户记录接受了任务，但任务中没有该用户
+	MemberUserMissing   = "member_user_missing"   // 任务的参与者不存在
+	MemberUserDeleted   = "member_user_deleted"   // 未结束任务的参与者已被删除
+	MemberOneSided      = "member_one_sided"      // 任务中有该用户，但用户记录中没有该任务
+	TaskModelMissing    = "task_model_missing"    // 任务引用的模型不存在
+	PostedModelMissing  = "posted_model_missing"  // 用户上传列表中的模型不存在
+	ModelNotPosted      = "model_not_posted"      // 模型不在上传者的 Posted 列表中
+	ModelOwnerMissing   = "model_owner_missing"   // 模型的上传者不存在
+	TaskPosterMissing   = "task_poster_missing"   // 任务发布者不存在
+	NextRoundMissing    = "next_round_missing"    // 下一轮任务不存在
+)
+
+// 一条不一致记录
+type Issue struct {
+	Kind    string `json:"kind"`
+	Entity  string `json:"entity"` // user、task 或 model
+	ID      string `json:"id"`
+	Ref     string `json:"ref"` // 出问题的引用
+	Detail  string `json:"detail"`
+	Fixable bool   `json:"fixable"` // 能否自动修复，不能修复的需要人工处理
+}
+
+// 对一个用户或任务的修复
+type Fix struct {
+	Entity string `json:"entity"` // user 或 task
+	ID     string `json:"id"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// 检查结果
+type Report struct {
+	Users  int            `json:"users"`
+	Tasks  int            `json:"tasks"`
+	Models int            `json:"models"`
+	Issues []Issue        `json:"issues"`
+	Counts map[string]int `json:"counts"`
+	Fixes  []Fix          `json:"fixes"`
+}
+
+// 扫描用户、任务和模型之间的引用关系
+// 以任务的参与者列表为准：结算按任务支付，只在用户记录中出现的接受视为未完成的操作
+func Check(users []invoke_fabric.User, tasks []invoke_fabric.Task, models []invoke_fabric.Model) *Report {
+	userByName := make(map[string]*invoke_fabric.User)
+	for i := range users {
+		userByName[users[i].Username] = &users[i]
+	}
+	taskByID := make(map[string]*invoke_fabric.Task)
+	for i := range tasks {
+		taskByID[tasks[i].TaskID] = &tasks[i]
+	}
+	modelByID := make(map[string]*invoke_fabric.Model)
+	for i := range models {
+		modelByID[models[i].Modelid] = &models[i]
+	}
+
+	report := &Report{Users: len(users), Tasks: len(tasks), Models: len(models), Counts: map[string]int{}}
+	add := func(issue Issue) {
+		report.Issues = append(report.Issues, issue)
+		report.Counts[issue.Kind]++
+	}
+
+	// 修复后的引用列表
+	userAfter := make(map[string]*invoke_fabric.UserRefs)
+	for _, u := range users {
+		userAfter[u.Username] = &invoke_fabric.UserRefs{Accepted: slices.Clone(u.Accepted), Posted: slices.Clone(u.Posted)}
+	}
+	taskAfter := make(map[string]*invoke_fabric.TaskRefs)
+	for _, t := range tasks {
+		taskAfter[t.TaskID] = &invoke_fabric.TaskRefs{AcceptedUsers: slices.Clone(t.AcceptedUsers), Models: slices.Clone(t.Models)}
+	}
+
+	for _, u := range users {
+		refs := userAfter[u.Username]
+		for _, taskID := range u.Accepted {
+			task, ok := taskByID[taskID]
+			switch {
+			case !ok:
+				add(Issue{Kind: AcceptedTaskMissing, Entity: "user", ID: u.Username, Ref: taskID,
+					Detail: fmt.Sprintf("用户 %s 接受的任务 %s 不存在", u.Username, taskID), Fixable: true})
+				refs.Accepted = remove(refs.Accepted, taskID)
+			case task.Deleted():
+				add(Issue{Kind: AcceptedTaskDeleted, Entity: "user", ID: u.Username, Ref: taskID,
+					Detail: fmt.Sprintf("用户 %s 接受的任务 %s 已被删除", u.Username, taskID), Fixable: true})
+				refs.Accepted = remove(refs.Accepted, taskID)
+			case !slices.Contains(task.AcceptedUsers, u.Username):
+				add(Issue{Kind: AcceptedOneSided, Entity: "user", ID: u.Username, Ref: taskID,
+					Detail: fmt.Sprintf("用户 %s 记录接受了任务 %s，但任务中没有该用户", u.Username, taskID), Fixable: true})
+				refs.Accepted = remove(refs.Accepted, taskID)
+			}
+		}
+		for _, modelID := range u.Posted {
+			if _, ok := modelByID[modelID]; !ok {
+				add(Issue{Kind: PostedModelMissing, Entity: "user", ID: u.Username, Ref: modelID,
+					Detail: fmt.Sprintf("用户 %s 上传的模型 %s 不存在", u.Username, modelID), Fixable: true})
+				refs.Posted = remove(refs.Posted, modelID)
+			}
+		}
+	}
+
+	for _, t := range tasks {
+		refs := taskAfter[t.TaskID]
+		if _, ok := userByName[t.PostedUser]; !ok {
+			add(Issue{Kind: TaskPosterMissing, Entity: "task", ID: t.TaskID, Ref: t.PostedUser,
+				Detail: fmt.Sprintf("任务 %s 的发布者 %s 不存在", t.TaskID, t.PostedUser)})
+		}
+		if t.NextRoundTaskID != "" {
+			if _, ok := taskByID[t.NextRoundTaskID]; !ok {
+				add(Issue{Kind: NextRoundMissing, Entity: "task", ID: t.TaskID, Ref: t.NextRoundTaskID,
+					Detail: fmt.Sprintf("任务 %s 的下一轮任务 %s 不存在", t.TaskID, t.NextRoundTaskID)})
+			}
+		}
+		for _, username := range t.AcceptedUsers {
+			user, ok := userByName[username]
+			switch {
+			case !ok:
+				add(Issue{Kind: MemberUserMissing, Entity: "task", ID: t.TaskID, Ref: username,
+					Detail: fmt.Sprintf("任务 %s 的参与者 %s 不存在", t.TaskID, username), Fixable: true})
+				refs.AcceptedUsers = remove(refs.AcceptedUsers, username)
+			case t.Deleted():
+				// 已删除任务的参与者列表作为历史保留
+			case user.Deleted() && !invoke_fabric.TaskClosed(t.State()):
+				add(Issue{Kind: MemberUserDeleted, Entity: "task", ID: t.TaskID, Ref: username,
+					Detail: fmt.Sprintf("未结束的任务 %s 中仍有已删除的用户 %s", t.TaskID, username), Fixable: true})
+				refs.AcceptedUsers = remove(refs.AcceptedUsers, username)
+				userAfter[username].Accepted = remove(userAfter[username].Accepted, t.TaskID)
+			case !slices.Contains(user.Accepted, t.TaskID):
+				add(Issue{Kind: MemberOneSided, Entity: "task", ID: t.TaskID, Ref: username,
+					Detail: fmt.Sprintf("任务 %s 中有用户 %s，但用户记录中没有该任务", t.TaskID, username), Fixable: true})
+				userAfter[username].Accepted = append(userAfter[username].Accepted, t.TaskID)
+			}
+		}
+		for _, modelID := range t.Models {
+			if _, ok := modelByID[modelID]; !ok {
+				add(Issue{Kind: TaskModelMissing, Entity: "task", ID: t.TaskID, Ref: modelID,
+					Detail: fmt.Sprintf("任务 %s 引用的模型 %s 不存在", t.TaskID, modelID), Fixable: true})
+				refs.Models = remove(refs.Models, modelID)
+			}
+		}
+	}
+
+	for _, m := range models {
+		owner, ok := userByName[m.Modelowner]
+		switch {
+		case !ok:
+			add(Issue{Kind: ModelOwnerMissing, Entity: "model", ID: m.Modelid, Ref: m.Modelowner,
+				Detail: fmt.Sprintf("模型 %s 的上传者 %s 不存在", m.Modelid, m.Modelowner)})
+		case !slices.Contains(owner.Posted, m.Modelid):
+			add(Issue{Kind: ModelNotPosted, Entity: "model", ID: m.Modelid, Ref: m.Modelowner,
+				Detail: fmt.Sprintf("模型 %s 不在上传者 %s 的 Posted 列表中", m.Modelid, m.Modelowner), Fixable: true})
+			userAfter[m.Modelowner].Posted = append(userAfter[m.Modelowner].Posted, m.Modelid)
+		}
+	}
+
+	// 只保留有变化的记录
+	for _, u := range users {
+		after := userAfter[u.Username]
+		if !slices.Equal(after.Accepted, u.Accepted) || !slices.Equal(after.Posted, u.Posted) {
+			report.Fixes = append(report.Fixes, Fix{Entity: "user", ID: u.Username,
+				Before: invoke_fabric.UserRefs{Accepted: u.Accepted, Posted: u.Posted}, After: *after})
+		}
+	}
+	for _, t := range tasks {
+		after := taskAfter[t.TaskID]
+		if !slices.Equal(after.AcceptedUsers, t.AcceptedUsers) || !slices.Equal(after.Models, t.Models) {
+			report.Fixes = append(report.Fixes, Fix{Entity: "task", ID: t.TaskID,
+				Before: invoke_fabric.TaskRefs{AcceptedUsers: t.AcceptedUsers, Models: t.Models}, After: *after})
+		}
+	}
+
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		a, b := report.Issues[i], report.Issues[j]
+		if a.Entity != b.Entity {
+			return a.Entity < b.Entity
+		}
+		return a.ID < b.ID
+	})
+	sort.SliceStable(report.Fixes, func(i, j int) bool {
+		if report.Fixes[i].Entity != report.Fixes[j].Entity {
+			return report.Fixes[i].Entity < report.Fixes[j].Entity
+		}
+		return report.Fixes[i].ID < report.Fixes[j].ID
+	})
+	return report
+}
+
+// 删除列表中所有等于 value 的元素
+func remove(list []string, value string) []string {
+	return slices.DeleteFunc(list, func(s string) bool { return s == value })
+}
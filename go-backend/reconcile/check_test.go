@@ -0,0 +1,165 @@
+package reconcile
+
+import (
+	invoke_fabric "backend/fabric-go/call"
+	"maps"
+	"testing"
+)
+
+func user(name string, accepted, posted []string) invoke_fabric.User {
+	return invoke_fabric.User{Username: name, Accepted: accepted, Posted: posted}
+}
+
+func task(id, poster string, members, models []string) invoke_fabric.Task {
+	return invoke_fabric.Task{TaskID: id, PostedUser: poster, AcceptedUsers: members, Models: models}
+}
+
+func model(id, owner string) invoke_fabric.Model {
+	return invoke_fabric.Model{Modelid: id, Modelowner: owner}
+}
+
+func deletedUser(u invoke_fabric.User) invoke_fabric.User {
+	u.DeletedAt = "2026-01-01T00:00:00Z"
+	return u
+}
+
+func deletedTask(t invoke_fabric.Task) invoke_fabric.Task {
+	t.DeletedAt = "2026-01-01T00:00:00Z"
+	return t
+}
+
+func TestCheck(t *testing.T) {
+	completed := task("t1", "alice", []string{"bob"}, nil)
+	completed.Status = invoke_fabric.TaskCompleted
+
+	cases := []struct {
+		name   string
+		users  []invoke_fabric.User
+		tasks  []invoke_fabric.Task
+		models []invoke_fabric.Model
+		want   map[string]int
+		fixes  int
+	}{
+		{
+			name:   "引用一致",
+			users:  []invoke_fabric.User{user("alice", nil, []string{"m1"}), user("bob", []string{"t1"}, nil)},
+			tasks:  []invoke_fabric.Task{task("t1", "alice", []string{"bob"}, []string{"m1"})},
+			models: []invoke_fabric.Model{model("m1", "alice")},
+			want:   map[string]int{},
+		},
+		{
+			name:  "接受的任务不存在",
+			users: []invoke_fabric.User{user("bob", []string{"t9"}, nil)},
+			want:  map[string]int{AcceptedTaskMissing: 1},
+			fixes: 1,
+		},
+		{
+			name:  "接受的任务已删除",
+			users: []invoke_fabric.User{user("alice", nil, nil), user("bob", []string{"t1"}, nil)},
+			tasks: []invoke_fabric.Task{deletedTask(task("t1", "alice", []string{"bob"}, nil))},
+			want:  map[string]int{AcceptedTaskDeleted: 1},
+			fixes: 1,
+		},
+		{
+			name:  "只有用户记录接受了任务",
+			users: []invoke_fabric.User{user("alice", nil, nil), user("bob", []string{"t1"}, nil)},
+			tasks: []invoke_fabric.Task{task("t1", "alice", nil, nil)},
+			want:  map[string]int{AcceptedOneSided: 1},
+			fixes: 1,
+		},
+		{
+			name:  "只有任务记录有参与者",
+			users: []invoke_fabric.User{user("alice", nil, nil), user("bob", nil, nil)},
+			tasks: []invoke_fabric.Task{task("t1", "alice", []string{"bob"}, nil)},
+			want:  map[string]int{MemberOneSided: 1},
+			fixes: 1,
+		},
+		{
+			name:  "参与者不存在",
+			users: []invoke_fabric.User{user("alice", nil, nil)},
+			tasks: []invoke_fabric.Task{task("t1", "alice", []string{"bob"}, nil)},
+			want:  map[string]int{MemberUserMissing: 1},
+			fixes: 1,
+		},
+		{
+			name:  "未结束任务的参与者已删除",
+			users: []invoke_fabric.User{user("alice", nil, nil), deletedUser(user("bob", []string{"t1"}, nil))},
+			tasks: []invoke_fabric.Task{task("t1", "alice", []string{"bob"}, nil)},
+			want:  map[string]int{MemberUserDeleted: 1},
+			fixes: 2,
+		},
+		{
+			name:  "已结束任务保留已删除的参与者",
+			users: []invoke_fabric.User{user("alice", nil, nil), deletedUser(user("bob", []string{"t1"}, nil))},
+			tasks: []invoke_fabric.Task{completed},
+			want:  map[string]int{},
+		},
+		{
+			name:  "任务引用的模型不存在，发布者不存在",
+			users: []invoke_fabric.User{},
+			tasks: []invoke_fabric.Task{task("t1", "alice", nil, []string{"m9"})},
+			want:  map[string]int{TaskModelMissing: 1, TaskPosterMissing: 1},
+			fixes: 1,
+		},
+		{
+			name:  "下一轮任务不存在",
+			users: []invoke_fabric.User{user("alice", nil, nil)},
+			tasks: []invoke_fabric.Task{{TaskID: "t1", PostedUser: "alice", NextRoundTaskID: "t2"}},
+			want:  map[string]int{NextRoundMissing: 1},
+		},
+		{
+			name:   "上传列表与模型不一致",
+			users:  []invoke_fabric.User{user("alice", nil, []string{"m9"})},
+			models: []invoke_fabric.Model{model("m1", "alice"), model("m2", "carol")},
+			want:   map[string]int{PostedModelMissing: 1, ModelNotPosted: 1, ModelOwnerMissing: 1},
+			fixes:  1,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			report := Check(tc.users, tc.tasks, tc.models)
+			if !maps.Equal(report.Counts, tc.want) {
+				t.Errorf("问题统计为 %v，应为 %v", report.Counts, tc.want)
+			}
+			if len(report.Fixes) != tc.fixes {
+				t.Errorf("修复 %d 条记录，应为 %d: %+v", len(report.Fixes), tc.fixes, report.Fixes)
+			}
+			for _, issue := range report.Issues {
+				if issue.Kind == TaskPosterMissing || issue.Kind == NextRoundMissing || issue.Kind == ModelOwnerMissing {
+					if issue.Fixable {
+						t.Errorf("%s 不能自动修复", issue.Kind)
+					}
+				}
+			}
+		})
+	}
+}
+
+// 修复结果：移除悬空引用，补全单边引用，修复前的值为原记录
+func TestCheckFixes(t *testing.T) {
+	users := []invoke_fabric.User{
+		user("alice", nil, []string{"m9"}),
+		user("bob", []string{"t9"}, nil),
+	}
+	tasks := []invoke_fabric.Task{task("t1", "alice", []string{"bob"}, nil)}
+	models := []invoke_fabric.Model{model("m1", "alice")}
+
+	report := Check(users, tasks, models)
+	if len(report.Fixes) != 2 {
+		t.Fatalf("应修复 2 个用户，实际 %+v", report.Fixes)
+	}
+	alice, bob := report.Fixes[0], report.Fixes[1]
+	if after := alice.After.(invoke_fabric.UserRefs); len(after.Posted) != 1 || after.Posted[0] != "m1" {
+		t.Errorf("alice 修复后的上传列表不正确: %+v", after)
+	}
+	if before := alice.Before.(invoke_fabric.UserRefs); len(before.Posted) != 1 || before.Posted[0] != "m9" {
+		t.Errorf("alice 修复前的值不正确: %+v", before)
+	}
+	if after := bob.After.(invoke_fabric.UserRefs); len(after.Accepted) != 1 || after.Accepted[0] != "t1" {
+		t.Errorf("bob 修复后的接受列表不正确: %+v", after)
+	}
+	// 原记录不被修改
+	if users[1].Accepted[0] != "t9" {
+		t.Errorf("Check 修改了传入的记录")
+	}
+}
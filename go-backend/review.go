@@ -0,0 +1,145 @@
+package main
+
+import (
+	invoke_fabric "backend/fabric-go/call"
+	connect_fabric "backend/fabric-go/network"
+	"backend/middleware"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 获取待审核的注册申请
+func get_pending_users(ctx *gin.Context) {
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	users, err := invoke_fabric.GetAllUsers(contract)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取用户失败: %s", err.Error())})
+		return
+	}
+
+	// 只返回审核所需的信息，不返回密码
+	pending := []gin.H{}
+	for _, user := range users {
+		if user.Deleted() || user.RegistrationStatus() != invoke_fabric.ReviewPending {
+			continue
+		}
+		pending = append(pending, gin.H{
+			"username":     user.Username,
+			"organization": user.Organization,
+			"pubkeyhash":   user.Pubkeyhash,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "待审核用户获取成功",
+		"users":   pending,
+	})
+}
+
+// 通过注册申请
+func approve_user(ctx *gin.Context) {
+	var request struct {
+		Username string `json:"username"`
+		Reason   string `json:"reason"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	reviewer := middleware.CurrentUser(ctx).User.Username
+	if err := reviewRegistration(contract, request.Username, true, reviewer, request.Reason); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("用户 %s 的注册申请已通过", request.Username),
+	})
+}
+
+// 拒绝注册申请，必须填写原因
+func reject_user(ctx *gin.Context) {
+	var request struct {
+		Username string `json:"username"`
+		Reason   string `json:"reason"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	if strings.TrimSpace(request.Reason) == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "拒绝注册必须填写原因"})
+		return
+	}
+
+	reviewer := middleware.CurrentUser(ctx).User.Username
+	if err := reviewRegistration(contract, request.Username, false, reviewer, request.Reason); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("用户 %s 的注册申请已拒绝", request.Username),
+	})
+}
+
+// 批量通过注册申请，逐个返回处理结果
+func approve_users(ctx *gin.Context) {
+	var request struct {
+		Usernames []string `json:"usernames"`
+		Reason    string   `json:"reason"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+	if len(request.Usernames) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "用户列表为空"})
+		return
+	}
+
+	reviewer := middleware.CurrentUser(ctx).User.Username
+	approved := []string{}
+	failed := gin.H{}
+	for _, username := range request.Usernames {
+		if err := reviewRegistration(contract, username, true, reviewer, request.Reason); err != nil {
+			failed[username] = err.Error()
+			continue
+		}
+		approved = append(approved, username)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":  fmt.Sprintf("已通过 %d 个注册申请，失败 %d 个", len(approved), len(failed)),
+		"approved": approved,
+		"failed":   failed,
+	})
+}
+
+// 审核单个注册申请，只能审核待审核状态的用户
+func reviewRegistration(contract *client.Contract, username string, approved bool, reviewer, reason string) error {
+	user, err := invoke_fabric.Get_one_User(contract, username)
+	if err != nil {
+		return fmt.Errorf("查询用户失败: %w", err)
+	}
+	if status := user.RegistrationStatus(); status != invoke_fabric.ReviewPending {
+		return fmt.Errorf("用户 %s 不在待审核状态（当前状态: %s）", username, status)
+	}
+	return invoke_fabric.ReviewUser(contract, username, approved, reviewer, reason)
+}
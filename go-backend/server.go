@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// HTTP 服务配置
+type ServerConfig struct {
+	Addr              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxBodyBytes      int64
+	TLSCertFile       string // 同时配置证书和私钥时启用 HTTPS
+	TLSKeyFile        string
+	TrustedProxies    []string
+}
+
+// 从环境变量读取服务配置
+func loadServerConfig() ServerConfig {
+	cfg := ServerConfig{
+		Addr:              ":8089",
+		ReadTimeout:       15 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		MaxBodyBytes:      1 << 20,
+		TLSCertFile:       os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:        os.Getenv("TLS_KEY_FILE"),
+	}
+	if v := os.Getenv("SERVER_ADDR"); v != "" {
+		cfg.Addr = v
+	}
+	for name, target := range map[string]*time.Duration{
+		"SERVER_READ_TIMEOUT":        &cfg.ReadTimeout,
+		"SERVER_READ_HEADER_TIMEOUT": &cfg.ReadHeaderTimeout,
+		"SERVER_WRITE_TIMEOUT":       &cfg.WriteTimeout,
+		"SERVER_IDLE_TIMEOUT":        &cfg.IdleTimeout,
+	} {
+		if v, err := time.ParseDuration(os.Getenv(name)); err == nil {
+			*target = v
+		}
+	}
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			cfg.TrustedProxies = append(cfg.TrustedProxies, proxy)
+		}
+	}
+	if v, err := strconv.ParseInt(os.Getenv("SERVER_MAX_BODY_BYTES"), 10, 64); err == nil && v > 0 {
+		cfg.MaxBodyBytes = v
+	}
+	return cfg
+}
+
+func (cfg ServerConfig) tlsEnabled() bool {
+	return cfg.TLSCertFile != "" && cfg.TLSKeyFile != ""
+}
+
+// 启动 HTTP 服务
+func runServer(handler http.Handler, cfg ServerConfig) error {
+	server := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    1 << 20,
+	}
+
+	if !cfg.tlsEnabled() {
+		fmt.Printf("HTTP 服务启动: %s\n", cfg.Addr)
+		return server.ListenAndServe()
+	}
+
+	reloader, err := newCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return err
+	}
+	server.TLSConfig = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	fmt.Printf("HTTPS 服务启动: %s\n", cfg.Addr)
+	return server.ListenAndServeTLS("", "")
+}
+
+// 证书热加载：证书文件更新或收到 SIGHUP 时重新读取
+type certReloader struct {
+	mu       sync.RWMutex
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := r.reload(); err != nil {
+				fmt.Printf("重新加载证书失败: %v\n", err)
+				continue
+			}
+			fmt.Printf("*** 证书已重新加载\n")
+		}
+	}()
+	return r, nil
+}
+
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("加载 TLS 证书失败: %w", err)
+	}
+	info, err := os.Stat(r.certFile)
+	if err != nil {
+		return fmt.Errorf("读取证书文件信息失败: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.modTime = info.ModTime()
+	return nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	// 证书文件有更新时自动重新加载，加载失败则继续使用旧证书
+	if info, err := os.Stat(r.certFile); err == nil {
+		r.mu.RLock()
+		changed := info.ModTime().After(r.modTime)
+		r.mu.RUnlock()
+		if changed {
+			if err := r.reload(); err != nil {
+				fmt.Printf("重新加载证书失败: %v\n", err)
+			}
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	invoke_fabric "backend/fabric-go/call"
+	connect_fabric "backend/fabric-go/network"
+	"backend/middleware"
+	"backend/settlement"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 任务奖励结算，支付计划和进度保存在链上
+var settlements = settlement.NewRunner(settlement.NewLedgerStore(func() *client.Contract {
+	return connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+}))
+
+// 按任务的奖励分配策略计算每个参与者的奖励
+func planPayouts(contract *client.Contract, task *invoke_fabric.Task) (map[string]int, error) {
+	strategy, err := settlement.LookupStrategy(task.RewardStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := invoke_fabric.GetAccountBalance(contract, invoke_fabric.EscrowAccount(task.TaskID))
+	if err != nil {
+		return nil, err
+	}
+
+	submitters, err := taskSubmitters(contract, task)
+	if err != nil {
+		return nil, err
+	}
+
+	// 奖励总额不能超过托管余额
+	return settlement.Plan(strategy, settlement.SplitInput{
+		Bonus:        task.Bonus,
+		Pool:         pool,
+		Participants: task.AcceptedUsers,
+		Submitters:   submitters,
+		Scores:       task.Scores,
+		TopK:         task.RewardTopK,
+	})
+}
+
+// 提交了模型的用户
+func taskSubmitters(contract *client.Contract, task *invoke_fabric.Task) (map[string]bool, error) {
+	submitters := make(map[string]bool)
+	for _, modelID := range task.Models {
+		model, err := invoke_fabric.ReadModel(contract, modelID)
+		if err != nil {
+			return nil, err
+		}
+		submitters[model.Modelowner] = true
+	}
+	return submitters, nil
+}
+
+// 从托管账户支付奖励，全部支付完成后分配罚金并退还剩余托管余额
+func runSettlement(contract *client.Contract, task *invoke_fabric.Task, job *settlement.Job) (*settlement.Job, error) {
+	job, err := settlements.Run(job, func(p *settlement.Payout) (string, error) {
+		memo := fmt.Sprintf("任务 %s 第 %d 轮奖励", task.TaskID, task.Round)
+		transfer, err := invoke_fabric.ReleaseEscrowOnce(contract, p.IdempotencyKey, task.TaskID, p.Username, p.Amount, memo)
+		if err != nil {
+			return "", err
+		}
+		return transfer.TransferID, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if job.Status == settlement.JobCompleted {
+		if err := invoke_fabric.ReleasePenalties(contract, task); err != nil {
+			return job, fmt.Errorf("分配退出罚金失败: %w", err)
+		}
+		if _, err := invoke_fabric.RefundEscrow(contract, task.TaskID, task.PostedUser); err != nil {
+			return job, fmt.Errorf("退还托管奖励失败: %w", err)
+		}
+	}
+	return job, nil
+}
+
+// 执行结算并返回结果
+func respondSettlement(ctx *gin.Context, contract *client.Contract, task *invoke_fabric.Task, job *settlement.Job) {
+	job, err := runSettlement(contract, task, job)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "settlement": job})
+		return
+	}
+
+	if job.Status != settlement.JobCompleted {
+		counts := job.Counts()
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":      fmt.Sprintf("任务 %s 部分奖励发放失败（成功 %d，失败 %d），可稍后继续结算", task.TaskID, counts[settlement.PayoutPaid], counts[settlement.PayoutFailed]),
+			"settlement": job,
+		})
+		return
+	}
+
+	if len(job.Payouts) == 0 {
+		ctx.JSON(http.StatusOK, gin.H{"success": "没有用户接受该任务", "settlement": job})
+		return
+	}
+
+	// 返回成功信息到前端
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":    fmt.Sprintf("任务 %s 已成功完成，奖励已发放:", task.TaskID),
+		"settlement": job,
+	})
+}
+
+// 查询任务的结算进度
+func get_settlement(ctx *gin.Context) {
+	var request struct {
+		TaskID string `json:"taskId"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	task, err := invoke_fabric.QueryTask(contract, request.TaskID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取任务数据失败: %s", err.Error())})
+		return
+	}
+	job, err := settlements.Store().Get(request.TaskID)
+	if errors.Is(err, settlement.ErrJobNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 只有发布者、参与者（包括收款人）和管理员可以查看支付明细
+	if len(taskRoles(ctx, task)) == 0 && !slices.ContainsFunc(job.Payouts, func(p *settlement.Payout) bool {
+		return p.Username == middleware.CurrentUser(ctx).User.Username
+	}) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "只有任务发布者、参与者和管理员可以查看结算信息"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":    "结算信息查询成功",
+		"settlement": job,
+		"counts":     job.Counts(),
+	})
+}
+
+// 继续未完成的结算
+func resume_settlement(ctx *gin.Context) {
+	var request struct {
+		TaskID string `json:"taskId"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	job, err := settlements.Store().Get(request.TaskID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	task, err := invoke_fabric.QueryTask(contract, request.TaskID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取任务数据失败: %s", err.Error())})
+		return
+	}
+
+	respondSettlement(ctx, contract, task, job)
+}
+
+// 任务发布者修改奖励分配策略，任务完成前有效
+func set_reward_strategy(ctx *gin.Context) {
+	var request struct {
+		TaskID         string `json:"taskId"`
+		RewardStrategy string `json:"rewardStrategy"`
+		TopK           int    `json:"topK"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	if err := settlement.ValidateStrategy(request.RewardStrategy, request.TopK); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	task, ok := posterTask(ctx, contract, request.TaskID)
+	if !ok {
+		return
+	}
+	// 参与者按接受时的策略参与任务，之后不能再修改
+	if len(task.AcceptedUsers) > 0 {
+		ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("任务 %s 已有用户接受，奖励分配策略不能再修改", task.TaskID)})
+		return
+	}
+
+	if err := invoke_fabric.SetRewardStrategy(contract, task.TaskID, request.RewardStrategy, request.TopK); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("任务 %s 的奖励分配策略已更新为 %s", task.TaskID, request.RewardStrategy),
+	})
+}
+
+// 任务发布者记录参与者的评估分数
+func set_task_scores(ctx *gin.Context) {
+	var request struct {
+		TaskID string             `json:"taskId"`
+		Scores map[string]float64 `json:"scores"` // 用户名 -> 分数
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	task, ok := posterTask(ctx, contract, request.TaskID)
+	if !ok {
+		return
+	}
+
+	// 提交截止后才能评分，已记录的分数不能修改
+	if task.State() != invoke_fabric.TaskAggregating {
+		ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("任务处于 %s 状态，停止提交后才能评分", task.State()), "status": task.State()})
+		return
+	}
+
+	// 只能给提交了模型的参与者打分
+	submitters, err := taskSubmitters(contract, task)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for user, score := range request.Scores {
+		if !slices.Contains(task.AcceptedUsers, user) || !submitters[user] {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("用户 %s 没有在任务 %s 中提交模型", user, task.TaskID)})
+			return
+		}
+		if _, ok := task.Scores[user]; ok {
+			ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("用户 %s 的分数已记录，不能修改", user)})
+			return
+		}
+		if score < 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "评估分数不能为负数"})
+			return
+		}
+	}
+
+	if err := invoke_fabric.SetTaskScores(contract, task.TaskID, request.Scores); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("任务 %s 的评估分数已记录", task.TaskID),
+	})
+}
+
+// 读取任务并确认当前用户是发布者且任务未结束，失败时已写入响应
+func posterTask(ctx *gin.Context, contract *client.Contract, taskID string) (*invoke_fabric.Task, bool) {
+	task, ok := loadTask(ctx, contract, taskID)
+	if !ok {
+		return nil, false
+	}
+	if task.PostedUser != middleware.CurrentUser(ctx).User.Username {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "只有任务发布者可以执行此操作"})
+		return nil, false
+	}
+	if invoke_fabric.TaskClosed(task.State()) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("任务处于 %s 状态，不能修改", task.State()), "status": task.State()})
+		return nil, false
+	}
+	return task, true
+}
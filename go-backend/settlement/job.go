@@ -0,0 +1,163 @@
+package settlement
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 单个收款人的支付状态
+const (
+	PayoutPending = "pending"
+	PayoutPaid    = "paid"
+	PayoutFailed  = "failed"
+)
+
+// 结算任务状态
+const (
+	JobRunning   = "running"   // 正在支付
+	JobPartial   = "partial"   // 部分支付失败，等待重试
+	JobCompleted = "completed" // 全部支付完成
+)
+
+// 一笔待支付的奖励
+type Payout struct {
+	Username       string    `json:"username"`
+	Amount         int       `json:"amount"`
+	IdempotencyKey string    `json:"idempotencyKey"`
+	State          string    `json:"state"`
+	TransferID     string    `json:"transferId,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	Attempts       int       `json:"attempts"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// 一个任务轮次的结算
+type Job struct {
+	TaskID    string    `json:"taskId"`
+	Round     int       `json:"round"`
+	Status    string    `json:"status"`
+	Payouts   []*Payout `json:"payouts"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// 每个 (任务, 轮次, 用户) 的幂等键
+func IdempotencyKey(taskID string, round int, username string) string {
+	return fmt.Sprintf("payout:%s:%d:%s", taskID, round, username)
+}
+
+// 创建结算任务
+func NewJob(taskID string, round int, amounts map[string]int, order []string) *Job {
+	now := time.Now()
+	job := &Job{
+		TaskID:    taskID,
+		Round:     round,
+		Status:    JobRunning,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for _, username := range order {
+		amount, ok := amounts[username]
+		if !ok || amount <= 0 {
+			continue
+		}
+		job.Payouts = append(job.Payouts, &Payout{
+			Username:       username,
+			Amount:         amount,
+			IdempotencyKey: IdempotencyKey(taskID, round, username),
+			State:          PayoutPending,
+			UpdatedAt:      now,
+		})
+	}
+	return job
+}
+
+// 统计各状态的数量
+func (j *Job) Counts() map[string]int {
+	counts := map[string]int{PayoutPending: 0, PayoutPaid: 0, PayoutFailed: 0}
+	for _, p := range j.Payouts {
+		counts[p.State]++
+	}
+	return counts
+}
+
+// 实际执行支付的函数，返回转账交易 ID
+type PayFunc func(p *Payout) (string, error)
+
+// 执行结算。同一实例内同一任务的结算串行执行；多个实例同时结算时，
+// 支付使用幂等键，同一笔奖励在链上只会转账一次
+type Runner struct {
+	store Store
+	locks sync.Map
+}
+
+func NewRunner(store Store) *Runner {
+	return &Runner{store: store}
+}
+
+func (r *Runner) Store() Store {
+	return r.store
+}
+
+func (r *Runner) lock(taskID string) func() {
+	value, _ := r.locks.LoadOrStore(taskID, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// 支付所有未完成的奖励，每一步都持久化，失败后可以重新调用继续
+func (r *Runner) Run(job *Job, pay PayFunc) (*Job, error) {
+	unlock := r.lock(job.TaskID)
+	defer unlock()
+
+	// 以存储中的最新状态为准，避免并发请求重复支付
+	if stored, err := r.store.Get(job.TaskID); err == nil {
+		job = stored
+	} else if !errors.Is(err, ErrJobNotFound) {
+		return nil, err
+	}
+
+	job.Status = JobRunning
+	if err := r.save(job); err != nil {
+		return nil, err
+	}
+
+	for _, p := range job.Payouts {
+		if p.State == PayoutPaid {
+			continue
+		}
+
+		p.Attempts++
+		transferID, err := pay(p)
+		p.UpdatedAt = time.Now()
+		if err != nil {
+			p.State = PayoutFailed
+			p.Error = err.Error()
+		} else {
+			p.State = PayoutPaid
+			p.TransferID = transferID
+			p.Error = ""
+		}
+		if err := r.save(job); err != nil {
+			return nil, err
+		}
+	}
+
+	if job.Counts()[PayoutPaid] == len(job.Payouts) {
+		job.Status = JobCompleted
+	} else {
+		job.Status = JobPartial
+	}
+	if err := r.save(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (r *Runner) save(job *Job) error {
+	job.UpdatedAt = time.Now()
+	return r.store.Put(job)
+}
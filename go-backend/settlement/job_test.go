@@ -0,0 +1,150 @@
+package settlement
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// 内存中的结算存储，保存序列化后的副本，与链上存储一样不共享指针
+type memoryStore struct {
+	jobs map[string][]byte
+	puts int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{jobs: make(map[string][]byte)}
+}
+
+func (s *memoryStore) Get(taskID string) (*Job, error) {
+	data, ok := s.jobs[taskID]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	var job Job
+	err := json.Unmarshal(data, &job)
+	return &job, err
+}
+
+func (s *memoryStore) Create(job *Job) (*Job, error) {
+	if _, ok := s.jobs[job.TaskID]; ok {
+		return s.Get(job.TaskID)
+	}
+	return job, s.Put(job)
+}
+
+func (s *memoryStore) Put(job *Job) error {
+	data, err := json.Marshal(job)
+	s.jobs[job.TaskID] = data
+	s.puts++
+	return err
+}
+
+func (s *memoryStore) List() ([]*Job, error) {
+	return nil, nil
+}
+
+func TestNewJob(t *testing.T) {
+	job := NewJob("t1", 2, map[string]int{"a": 5, "b": 0, "c": 3}, []string{"c", "b", "a", "d"})
+	if len(job.Payouts) != 2 || job.Payouts[0].Username != "c" || job.Payouts[1].Username != "a" {
+		t.Fatalf("支付计划应按顺序且跳过为 0 和没有金额的用户: %+v", job.Payouts)
+	}
+	if job.Payouts[0].IdempotencyKey != "payout:t1:2:c" || job.Payouts[0].State != PayoutPending {
+		t.Errorf("支付记录不正确: %+v", job.Payouts[0])
+	}
+}
+
+func TestRunner(t *testing.T) {
+	cases := []struct {
+		name       string
+		amounts    map[string]int
+		order      []string
+		failing    map[string]bool // 第一次运行时支付失败的用户
+		wantStatus string
+		wantPaid   int
+	}{
+		{"全部成功", map[string]int{"a": 3, "b": 3}, []string{"a", "b"}, nil, JobCompleted, 6},
+		{"单个参与者", map[string]int{"a": 10}, []string{"a"}, nil, JobCompleted, 10},
+		{"没有奖励", map[string]int{}, nil, nil, JobCompleted, 0},
+		{"部分失败", map[string]int{"a": 3, "b": 3, "c": 4}, []string{"a", "b", "c"}, map[string]bool{"b": true}, JobPartial, 7},
+		{"全部失败", map[string]int{"a": 3}, []string{"a"}, map[string]bool{"a": true}, JobPartial, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newMemoryStore()
+			runner := NewRunner(store)
+			paid := make(map[string]int)
+			pay := func(p *Payout) (string, error) {
+				if tc.failing[p.Username] {
+					return "", errors.New("余额不足")
+				}
+				paid[p.IdempotencyKey] += p.Amount
+				return "tx-" + p.Username, nil
+			}
+
+			job, err := runner.Run(NewJob("t1", 1, tc.amounts, tc.order), pay)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if job.Status != tc.wantStatus {
+				t.Errorf("结算状态为 %s，应为 %s", job.Status, tc.wantStatus)
+			}
+			if total := sum(paid); total != tc.wantPaid {
+				t.Errorf("支付了 %d，应为 %d", total, tc.wantPaid)
+			}
+			stored, err := store.Get("t1")
+			if err != nil || stored.Status != job.Status {
+				t.Fatalf("存储中的状态与结果不一致: %v", err)
+			}
+
+			// 恢复后重新运行只支付失败的部分，已支付的不会重复支付
+			tc.failing = nil
+			job, err = runner.Run(NewJob("t1", 1, tc.amounts, tc.order), pay)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if job.Status != JobCompleted {
+				t.Errorf("重试后结算状态为 %s", job.Status)
+			}
+			for key, amount := range paid {
+				if amount > tc.amounts[key[len("payout:t1:1:"):]] {
+					t.Errorf("%s 被重复支付: %d", key, amount)
+				}
+			}
+			for _, p := range job.Payouts {
+				if p.State != PayoutPaid || p.Error != "" || p.TransferID == "" {
+					t.Errorf("支付记录不正确: %+v", p)
+				}
+			}
+		})
+	}
+}
+
+// 以存储中的支付计划为准，传入的新计划不会覆盖已有记录
+func TestRunnerUsesStoredJob(t *testing.T) {
+	store := newMemoryStore()
+	runner := NewRunner(store)
+	if _, err := store.Create(NewJob("t1", 1, map[string]int{"a": 5}, []string{"a"})); err != nil {
+		t.Fatal(err)
+	}
+
+	var paid []string
+	job, err := runner.Run(NewJob("t1", 1, map[string]int{"b": 50}, []string{"b"}), func(p *Payout) (string, error) {
+		paid = append(paid, p.Username)
+		return "tx", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paid) != 1 || paid[0] != "a" || job.Payouts[0].Attempts != 1 {
+		t.Errorf("应按存储中的计划支付给 a，实际 %v", paid)
+	}
+}
+
+func sum(amounts map[string]int) int {
+	total := 0
+	for _, amount := range amounts {
+		total += amount
+	}
+	return total
+}
@@ -0,0 +1,83 @@
+package settlement
+
+import (
+	invoke_fabric "backend/fabric-go/call"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+var ErrJobNotFound = errors.New("结算任务不存在")
+
+// 结算任务存储
+type Store interface {
+	Get(taskID string) (*Job, error)
+	// 保存新的结算任务，已存在时返回已有的任务，支付计划创建后不再改变
+	Create(job *Job) (*Job, error)
+	Put(job *Job) error
+	List() ([]*Job, error)
+}
+
+// 保存在链上的结算记录，多个后端实例共享同一份支付计划和支付进度
+type LedgerStore struct {
+	contract func() *client.Contract
+}
+
+func NewLedgerStore(contract func() *client.Contract) *LedgerStore {
+	return &LedgerStore{contract: contract}
+}
+
+func (s *LedgerStore) Get(taskID string) (*Job, error) {
+	data, err := invoke_fabric.ReadSettlement(s.contract(), taskID)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, ErrJobNotFound
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("解析结算数据失败: %w", err)
+	}
+	return &job, nil
+}
+
+func (s *LedgerStore) Create(job *Job) (*Job, error) {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return nil, fmt.Errorf("序列化结算数据失败: %w", err)
+	}
+	created, err := invoke_fabric.CreateSettlement(s.contract(), job.TaskID, data)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		return job, nil
+	}
+	return s.Get(job.TaskID)
+}
+
+func (s *LedgerStore) Put(job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("序列化结算数据失败: %w", err)
+	}
+	return invoke_fabric.UpdateSettlement(s.contract(), job.TaskID, data)
+}
+
+func (s *LedgerStore) List() ([]*Job, error) {
+	data, err := invoke_fabric.GetAllSettlements(s.contract())
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*Job
+	if len(data) == 0 {
+		return jobs, nil
+	}
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, fmt.Errorf("解析结算数据失败: %w", err)
+	}
+	return jobs, nil
+}
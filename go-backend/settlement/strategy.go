@@ -0,0 +1,178 @@
+package settlement
+
+import (
+	"fmt"
+	"sort"
+)
+
+// 奖励分配策略名称
+const (
+	StrategyFullBonus     = "full_bonus"     // 每个参与者获得完整的 bonus（默认）
+	StrategyEqualSplit    = "equal_split"    // 托管奖励池平均分给所有参与者
+	StrategySubmittedOnly = "submitted_only" // 只有提交了模型的参与者获得 bonus
+	StrategyScoreWeighted = "score_weighted" // 按评估分数加权分配奖励池
+	StrategyTopK          = "top_k"          // 分数最高的 K 名平分奖励池
+)
+
+// 计算奖励时需要的任务信息
+type SplitInput struct {
+	Bonus        int                // 单人奖励
+	Pool         int                // 托管账户当前余额
+	Participants []string           // 接受任务的用户，按接受顺序
+	Submitters   map[string]bool    // 提交了模型的用户
+	Scores       map[string]float64 // 评估分数
+	TopK         int
+}
+
+// 奖励分配策略，返回每个用户应得的奖励，未分配的部分退还发布者
+type Strategy interface {
+	Name() string
+	Split(in SplitInput) (map[string]int, error)
+}
+
+var strategies = map[string]Strategy{
+	StrategyFullBonus:     fullBonus{},
+	StrategyEqualSplit:    equalSplit{},
+	StrategySubmittedOnly: submittedOnly{},
+	StrategyScoreWeighted: scoreWeighted{},
+	StrategyTopK:          topK{},
+}
+
+// 根据名称查找策略，名称为空时使用默认策略
+func LookupStrategy(name string) (Strategy, error) {
+	if name == "" {
+		name = StrategyFullBonus
+	}
+	strategy, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的奖励分配策略: %s", name)
+	}
+	return strategy, nil
+}
+
+// 检查策略参数
+func ValidateStrategy(name string, topK int) error {
+	if _, err := LookupStrategy(name); err != nil {
+		return err
+	}
+	if name == StrategyTopK && topK <= 0 {
+		return fmt.Errorf("top_k 策略需要指定大于 0 的 K 值")
+	}
+	return nil
+}
+
+// 按策略计算奖励，奖励总额超过托管余额时返回错误
+func Plan(strategy Strategy, in SplitInput) (map[string]int, error) {
+	amounts, err := strategy.Split(in)
+	if err != nil {
+		return nil, err
+	}
+	total := 0
+	for _, amount := range amounts {
+		total += amount
+	}
+	if total > in.Pool {
+		return nil, fmt.Errorf("奖励总额 %d 超过托管余额 %d，请先追加托管", total, in.Pool)
+	}
+	return amounts, nil
+}
+
+type fullBonus struct{}
+
+func (fullBonus) Name() string { return StrategyFullBonus }
+
+func (fullBonus) Split(in SplitInput) (map[string]int, error) {
+	amounts := make(map[string]int)
+	for _, user := range in.Participants {
+		amounts[user] = in.Bonus
+	}
+	return amounts, nil
+}
+
+type equalSplit struct{}
+
+func (equalSplit) Name() string { return StrategyEqualSplit }
+
+func (equalSplit) Split(in SplitInput) (map[string]int, error) {
+	return splitEvenly(in.Pool, in.Participants), nil
+}
+
+type submittedOnly struct{}
+
+func (submittedOnly) Name() string { return StrategySubmittedOnly }
+
+func (submittedOnly) Split(in SplitInput) (map[string]int, error) {
+	amounts := make(map[string]int)
+	for _, user := range in.Participants {
+		if in.Submitters[user] {
+			amounts[user] = in.Bonus
+		}
+	}
+	return amounts, nil
+}
+
+type scoreWeighted struct{}
+
+func (scoreWeighted) Name() string { return StrategyScoreWeighted }
+
+func (scoreWeighted) Split(in SplitInput) (map[string]int, error) {
+	total := 0.0
+	for _, user := range in.Participants {
+		if score := in.Scores[user]; score > 0 {
+			total += score
+		}
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("没有有效的评估分数，无法按分数分配")
+	}
+
+	// 向下取整，零头留在托管账户中退还发布者
+	amounts := make(map[string]int)
+	for _, user := range in.Participants {
+		if score := in.Scores[user]; score > 0 {
+			amounts[user] = int(float64(in.Pool) * score / total)
+		}
+	}
+	return amounts, nil
+}
+
+type topK struct{}
+
+func (topK) Name() string { return StrategyTopK }
+
+func (topK) Split(in SplitInput) (map[string]int, error) {
+	if in.TopK <= 0 {
+		return nil, fmt.Errorf("top_k 策略需要指定大于 0 的 K 值")
+	}
+
+	ranked := make([]string, 0, len(in.Participants))
+	for _, user := range in.Participants {
+		if _, ok := in.Scores[user]; ok {
+			ranked = append(ranked, user)
+		}
+	}
+	if len(ranked) == 0 {
+		return nil, fmt.Errorf("没有评估分数，无法选出前 %d 名", in.TopK)
+	}
+	// 分数相同时先接受任务的用户排在前面
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return in.Scores[ranked[i]] > in.Scores[ranked[j]]
+	})
+	if len(ranked) > in.TopK {
+		ranked = ranked[:in.TopK]
+	}
+	return splitEvenly(in.Pool, ranked), nil
+}
+
+// 平均分配，零头留在托管账户中
+func splitEvenly(pool int, users []string) map[string]int {
+	amounts := make(map[string]int)
+	if len(users) == 0 || pool <= 0 {
+		return amounts
+	}
+	share := pool / len(users)
+	for _, user := range users {
+		amounts[user] = share
+	}
+	return amounts
+}
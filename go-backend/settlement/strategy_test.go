@@ -0,0 +1,110 @@
+package settlement
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestStrategies(t *testing.T) {
+	users := []string{"a", "b", "c"}
+	cases := []struct {
+		name     string
+		strategy string
+		in       SplitInput
+		want     map[string]int
+		wantErr  bool
+	}{
+		{"完整奖励", StrategyFullBonus, SplitInput{Bonus: 5, Pool: 15, Participants: users},
+			map[string]int{"a": 5, "b": 5, "c": 5}, false},
+		{"完整奖励超过托管余额", StrategyFullBonus, SplitInput{Bonus: 5, Pool: 14, Participants: users},
+			nil, true},
+		{"平分的零头留在托管账户", StrategyEqualSplit, SplitInput{Pool: 10, Participants: users},
+			map[string]int{"a": 3, "b": 3, "c": 3}, false},
+		{"平分时奖励池不足每人 1", StrategyEqualSplit, SplitInput{Pool: 2, Participants: users},
+			map[string]int{"a": 0, "b": 0, "c": 0}, false},
+		{"平分时单个参与者拿到全部", StrategyEqualSplit, SplitInput{Pool: 7, Participants: []string{"a"}},
+			map[string]int{"a": 7}, false},
+		{"没有参与者", StrategyEqualSplit, SplitInput{Pool: 7},
+			map[string]int{}, false},
+		{"只奖励提交者", StrategySubmittedOnly, SplitInput{Bonus: 4, Pool: 10, Participants: users, Submitters: map[string]bool{"b": true}},
+			map[string]int{"b": 4}, false},
+		{"按分数加权向下取整", StrategyScoreWeighted, SplitInput{Pool: 10, Participants: users, Scores: map[string]float64{"a": 1, "b": 1, "c": 1}},
+			map[string]int{"a": 3, "b": 3, "c": 3}, false},
+		{"零分不参与加权", StrategyScoreWeighted, SplitInput{Pool: 10, Participants: users, Scores: map[string]float64{"a": 3, "b": 1, "c": 0}},
+			map[string]int{"a": 7, "b": 2}, false},
+		{"全部为零分时无法加权", StrategyScoreWeighted, SplitInput{Pool: 10, Participants: users, Scores: map[string]float64{"a": 0, "b": 0}},
+			nil, true},
+		{"加权时单个参与者拿到全部", StrategyScoreWeighted, SplitInput{Pool: 10, Participants: []string{"a"}, Scores: map[string]float64{"a": 0.3}},
+			map[string]int{"a": 10}, false},
+		{"前 K 名平分", StrategyTopK, SplitInput{Pool: 10, Participants: users, Scores: map[string]float64{"a": 1, "b": 3, "c": 2}, TopK: 2},
+			map[string]int{"b": 5, "c": 5}, false},
+		{"同分时先接受的排前", StrategyTopK, SplitInput{Pool: 9, Participants: users, Scores: map[string]float64{"a": 0, "b": 0, "c": 0}, TopK: 2},
+			map[string]int{"a": 4, "b": 4}, false},
+		{"K 大于有分数的人数", StrategyTopK, SplitInput{Pool: 10, Participants: users, Scores: map[string]float64{"c": 1}, TopK: 5},
+			map[string]int{"c": 10}, false},
+		{"前 K 名没有分数", StrategyTopK, SplitInput{Pool: 10, Participants: users, TopK: 1},
+			nil, true},
+		{"K 为 0", StrategyTopK, SplitInput{Pool: 10, Participants: users, Scores: map[string]float64{"a": 1}},
+			nil, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			strategy, err := LookupStrategy(tc.strategy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Plan(strategy, tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("应返回错误，实际 %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(got, tc.want) {
+				t.Errorf("分配结果 %v，应为 %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// 按奖励池分配的策略在任何输入下都不超过托管余额
+func TestPoolStrategiesNeverExceedPool(t *testing.T) {
+	users := []string{"a", "b", "c", "d", "e", "f", "g"}
+	scores := map[string]float64{"a": 0.1, "b": 0.2, "c": 0.3, "d": 1.0 / 3, "e": 2.0 / 3, "f": 7, "g": 1e-9}
+	for _, name := range []string{StrategyEqualSplit, StrategyScoreWeighted, StrategyTopK} {
+		strategy, _ := LookupStrategy(name)
+		for pool := 0; pool <= 200; pool++ {
+			for n := 1; n <= len(users); n++ {
+				amounts, err := strategy.Split(SplitInput{Pool: pool, Participants: users[:n], Scores: scores, TopK: 3})
+				if err != nil {
+					t.Fatalf("%s 奖励池 %d、%d 人: %v", name, pool, n, err)
+				}
+				total := 0
+				for _, amount := range amounts {
+					if amount < 0 {
+						t.Fatalf("%s 分配了负数奖励: %v", name, amounts)
+					}
+					total += amount
+				}
+				if total > pool {
+					t.Fatalf("%s 奖励池 %d、%d 人时分配了 %d", name, pool, n, total)
+				}
+			}
+		}
+	}
+}
+
+func TestValidateStrategy(t *testing.T) {
+	if err := ValidateStrategy("", 0); err != nil {
+		t.Errorf("空策略应使用默认策略: %v", err)
+	}
+	if err := ValidateStrategy("unknown", 0); err == nil {
+		t.Error("未知策略应返回错误")
+	}
+	if err := ValidateStrategy(StrategyTopK, 0); err == nil {
+		t.Error("top_k 策略的 K 值为 0 时应返回错误")
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	invoke_fabric "backend/fabric-go/call"
+	connect_fabric "backend/fabric-go/network"
+	"backend/ipfs"
+	"backend/middleware"
+	"backend/storage"
+	"backend/upload"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 模型文件传输配置
+type ModelTransferConfig struct {
+	Storage   string // 新上传模型使用的存储：ipfs、file 或 s3
+	IPFSURL   string // IPFS HTTP API 地址
+	FileDir   string // 本地文件存储目录
+	S3        storage.S3Config
+	MaxBytes  int64         // 单个模型文件的大小上限
+	Timeout   time.Duration // 单次上传或下载的读写超时
+	UploadDir string        // 可续传上传的临时目录，多个实例时必须使用共享目录
+	UploadTTL time.Duration // 未完成的上传保留时间
+
+	UploadQuota upload.Quota // 每个用户进行中的上传数和字节数上限
+
+	DownloadURLTTL time.Duration // 签名下载链接的有效期
+}
+
+// 从环境变量读取模型文件传输配置
+func loadModelTransferConfig() ModelTransferConfig {
+	cfg := ModelTransferConfig{
+		Storage:   storage.SchemeIPFS,
+		IPFSURL:   "http://localhost:5001",
+		FileDir:   "./model_store",
+		MaxBytes:  1 << 30,
+		Timeout:   30 * time.Minute,
+		UploadDir: "./uploads",
+		UploadTTL: 24 * time.Hour,
+
+		UploadQuota: upload.Quota{MaxOpen: 5, MaxBytes: 4 << 30},
+		S3: storage.S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			Region:    os.Getenv("S3_REGION"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			Prefix:    os.Getenv("S3_PREFIX"),
+		},
+		DownloadURLTTL: 5 * time.Minute,
+	}
+	if v := os.Getenv("MODEL_STORAGE"); v != "" {
+		cfg.Storage = v
+	}
+	if v := os.Getenv("IPFS_API_URL"); v != "" {
+		cfg.IPFSURL = v
+	}
+	if v := os.Getenv("MODEL_STORAGE_DIR"); v != "" {
+		cfg.FileDir = v
+	}
+	if v, err := strconv.ParseInt(os.Getenv("MODEL_MAX_BYTES"), 10, 64); err == nil && v > 0 {
+		cfg.MaxBytes = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("MODEL_TRANSFER_TIMEOUT")); err == nil && v > 0 {
+		cfg.Timeout = v
+	}
+	if v := os.Getenv("UPLOAD_DIR"); v != "" {
+		cfg.UploadDir = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("UPLOAD_TTL")); err == nil && v > 0 {
+		cfg.UploadTTL = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("UPLOAD_MAX_OPEN")); err == nil && v >= 0 {
+		cfg.UploadQuota.MaxOpen = v
+	}
+	if v, err := strconv.ParseInt(os.Getenv("UPLOAD_MAX_USER_BYTES"), 10, 64); err == nil && v >= 0 {
+		cfg.UploadQuota.MaxBytes = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("DOWNLOAD_URL_TTL")); err == nil && v > 0 {
+		cfg.DownloadURLTTL = v
+	}
+	return cfg
+}
+
+// 按配置创建存储：IPFS 和本地文件始终可用于读取，S3 配置了 endpoint 时启用
+func newModelStorage(cfg ModelTransferConfig) (*storage.Registry, error) {
+	backends := []storage.Backend{storage.NewIPFS(ipfs.NewClient(cfg.IPFSURL))}
+	fs, err := storage.NewFilesystem(cfg.FileDir)
+	if err != nil {
+		return nil, err
+	}
+	backends = append(backends, fs)
+	if cfg.S3.Endpoint != "" {
+		s3, err := storage.NewS3(cfg.S3)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, s3)
+	}
+	return storage.NewRegistry(cfg.Storage, backends...)
+}
+
+var (
+	modelTransfer = loadModelTransferConfig()
+	modelStorage  *storage.Registry
+)
+
+// 上传模型文件到默认存储，返回后端计算的 CID，再用该 CID 签名调用 upload_model 登记。
+// 查询参数 encryptTask 不为空时加密给该任务的发布者，aggregators 为逗号分隔的额外接收者
+func upload_model_file(ctx *gin.Context) {
+	username := middleware.CurrentUser(ctx).User.Username
+
+	// 只有加密上传需要从链上查询接收者
+	var contract *client.Contract
+	enc := parseModelEncryption(ctx.Query("encryptTask"), ctx.Query("aggregators"))
+	if enc != nil {
+		contract = connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+		if _, err := modelRecipients(contract, username, enc); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	reader, err := ctx.Request.MultipartReader()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请使用 multipart/form-data 上传文件"})
+		return
+	}
+	backend, err := modelStorage.Get(modelStorage.Default)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 流式读取，不在内存中缓存整个文件
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "缺少上传文件 file"})
+			return
+		}
+		if err != nil {
+			respondBodyError(ctx, err, http.StatusBadRequest)
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		// 写入时解析模型结构，加密时解析的是加密前的明文
+		store := func(b storage.Backend) (*storage.Object, error) {
+			return inspecting(b).Put(ctx.Request.Context(), part, part.FileName())
+		}
+		var obj *storage.Object
+		if enc != nil {
+			obj, err = putEncrypted(contract, username, enc, backend, store)
+		} else {
+			obj, err = store(backend)
+		}
+		if err != nil {
+			respondBodyError(ctx, err, http.StatusBadGateway)
+			return
+		}
+		fmt.Printf("用户 %s 上传模型文件: 存储=%s, CID=%s, 大小=%d\n", username, obj.Scheme, obj.CID, obj.Size)
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"message":   "模型文件上传成功",
+			"storage":   obj.Scheme,
+			"cid":       obj.CID,
+			"size":      obj.Size,
+			"sha256":    obj.SHA256,
+			"encrypted": enc != nil,
+		})
+		return
+	}
+}
+
+// 把模型文件复制到另一种存储，复制时重新计算 CID 校验内容，原存储中的文件保留
+func migrate_model(ctx *gin.Context) {
+	var request struct {
+		ModelID string `json:"modelID"`
+		Storage string `json:"storage"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	model, err := invoke_fabric.ReadModel(contract, request.ModelID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("获取模型失败: %s", err.Error())})
+		return
+	}
+	from, err := modelStorage.Get(model.Storage)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := modelStorage.Get(request.Storage)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "storages": modelStorage.Schemes()})
+		return
+	}
+	if from.Scheme() == to.Scheme() {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("模型 %s 已在 %s 存储中", model.Modelid, to.Scheme())})
+		return
+	}
+
+	obj, err := storage.Copy(ctx.Request.Context(), from, to, model.ContentCID())
+	if errors.Is(err, storage.ErrNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("模型 %s 的文件不在 %s 存储中", model.Modelid, from.Scheme())})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("迁移模型文件失败: %s", err.Error())})
+		return
+	}
+	if err := invoke_fabric.SetModelStorage(contract, model.Modelid, to.Scheme(), obj.CID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("模型 %s 已从 %s 迁移到 %s", model.Modelid, from.Scheme(), to.Scheme()),
+		"cid":     obj.CID,
+		"size":    obj.Size,
+		"sha256":  obj.SHA256,
+	})
+}
+
+// 请求体超出大小限制时返回 413，其他错误返回 status
+func respondBodyError(ctx *gin.Context, err error, status int) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("模型文件不能超过 %d 字节", tooLarge.Limit)})
+		return
+	}
+	ctx.JSON(status, gin.H{"error": fmt.Sprintf("上传模型文件失败: %s", err.Error())})
+}
+
+// 启动内存中的 IPFS 节点，用于没有 IPFS 的环境下联调上传和下载
+func runFakeIPFSCommand(args []string) int {
+	flags := flag.NewFlagSet("fake-ipfs", flag.ContinueOnError)
+	addr := flags.String("addr", "127.0.0.1:5001", "监听地址")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	fmt.Printf("模拟 IPFS 节点监听 %s\n", *addr)
+	if err := http.ListenAndServe(*addr, ipfs.NewFakeNode()); err != nil {
+		fmt.Fprintf(os.Stderr, "模拟 IPFS 节点退出: %v\n", err)
+		return 1
+	}
+	return 0
+}
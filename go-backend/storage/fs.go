@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"backend/ipfs"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// 本地文件系统存储，文件名为内容的 CID
+type Filesystem struct {
+	root string
+}
+
+func NewFilesystem(root string) (*Filesystem, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("创建模型存储目录失败: %w", err)
+	}
+	return &Filesystem{root: root}, nil
+}
+
+func (s *Filesystem) Scheme() string { return SchemeFile }
+
+func (s *Filesystem) path(cid string) (string, error) {
+	if err := ipfs.ValidCID(cid); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, cid), nil
+}
+
+// 先写入临时文件并计算 CID，完成后改名，读取方不会看到写了一半的文件
+func (s *Filesystem) Put(ctx context.Context, r io.Reader, name string) (*Object, error) {
+	tmp, err := os.CreateTemp(s.root, ".upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	obj, err := hashAll(io.TeeReader(contextReader{ctx, r}, tmp))
+	if err != nil {
+		return nil, err
+	}
+	if err := tmp.Sync(); err != nil {
+		return nil, fmt.Errorf("写入模型文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("写入模型文件失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.root, obj.CID)); err != nil {
+		return nil, fmt.Errorf("保存模型文件失败: %w", err)
+	}
+	obj.Scheme = SchemeFile
+	return obj, nil
+}
+
+func (s *Filesystem) Get(ctx context.Context, cid string) (io.ReadCloser, error) {
+	path, err := s.path(cid)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (s *Filesystem) Stat(ctx context.Context, cid string) (int64, error) {
+	path, err := s.path(cid)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// 请求取消后停止读取
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
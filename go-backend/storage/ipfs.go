@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"backend/ipfs"
+	"context"
+	"io"
+	"strings"
+)
+
+// IPFS 存储，上传时固定内容
+type IPFS struct {
+	client *ipfs.Client
+}
+
+func NewIPFS(client *ipfs.Client) *IPFS {
+	return &IPFS{client: client}
+}
+
+func (s *IPFS) Scheme() string { return SchemeIPFS }
+
+func (s *IPFS) Put(ctx context.Context, r io.Reader, name string) (*Object, error) {
+	result, err := s.client.Add(ctx, r, name)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{Scheme: SchemeIPFS, CID: result.CID, Size: result.Size, SHA256: result.SHA256}, nil
+}
+
+func (s *IPFS) Get(ctx context.Context, cid string) (io.ReadCloser, error) {
+	body, err := s.client.Cat(ctx, cid)
+	return body, notFound(err)
+}
+
+func (s *IPFS) Stat(ctx context.Context, cid string) (int64, error) {
+	size, err := s.client.Stat(ctx, cid)
+	return size, notFound(err)
+}
+
+// 节点本地没有内容时 kubo 返回 "not found" 类错误
+func notFound(err error) error {
+	if err != nil && strings.Contains(err.Error(), "not found") {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 兼容存储（MinIO 等）的配置，使用路径风格的地址 endpoint/bucket/key
+type S3Config struct {
+	Endpoint  string // 例如 http://localhost:9000
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Prefix    string // 对象键前缀
+}
+
+// S3 兼容存储，对象键为内容的 CID
+type S3 struct {
+	cfg  S3Config
+	http *http.Client
+}
+
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 存储需要配置 endpoint 和 bucket")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	return &S3{cfg: cfg, http: &http.Client{}}, nil
+}
+
+func (s *S3) Scheme() string { return SchemeS3 }
+
+func (s *S3) url(cid string) string {
+	return s.cfg.Endpoint + "/" + awsEscape(s.cfg.Bucket) + "/" + awsEscape(s.cfg.Prefix+cid)
+}
+
+// 单次 PUT 需要事先知道长度，先写入临时文件并计算 CID 和 SHA-256，SHA-256 同时作为签名的载荷摘要由 S3 校验
+func (s *S3) Put(ctx context.Context, r io.Reader, name string) (*Object, error) {
+	tmp, err := os.CreateTemp("", "model-upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	obj, err := hashAll(io.TeeReader(contextReader{ctx, r}, tmp))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.url(obj.CID), tmp)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = obj.Size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := s.do(req, obj.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	obj.Scheme = SchemeS3
+	return obj, nil
+}
+
+func (s *S3) Get(ctx context.Context, cid string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url(cid), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req, emptySHA256)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3) Stat(ctx context.Context, cid string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.url(cid), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := s.do(req, emptySHA256)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.ContentLength, nil
+}
+
+func (s *S3) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	signV4(req, s.cfg, payloadHash, time.Now())
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("连接 S3 存储失败: %w", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	case resp.StatusCode/100 != 2:
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("S3 存储返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+var emptySHA256 = hex.EncodeToString(sha256.New().Sum(nil))
+
+// AWS Signature Version 4，签名 Host 和已设置的 x-amz-* 请求头
+func signV4(req *http.Request, cfg S3Config, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "range" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + cfg.Region + "/s3/aws4_request"
+	digest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(digest[:])
+
+	key := hmacSHA256([]byte("AWS4"+cfg.SecretKey), date)
+	key = hmacSHA256(key, cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// 按 AWS 的规则编码，只保留 A-Z a-z 0-9 - _ . ~ 和路径分隔符
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"backend/inspect"
+	"backend/ipfs"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// 存储方式
+const (
+	SchemeIPFS = "ipfs"
+	SchemeFile = "file"
+	SchemeS3   = "s3"
+)
+
+var ErrNotFound = errors.New("模型文件不存在")
+
+// 已保存的模型文件，所有存储方式都以内容的 CID 作为键，迁移后仍可用 CID 校验内容
+type Object struct {
+	Scheme string `json:"storage"`
+	CID    string `json:"cid"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"` // 十六进制
+
+	Architecture *inspect.Architecture `json:"architecture,omitempty"` // 写入时解析的模型结构，无法识别的格式为空
+}
+
+// 模型文件存储
+type Backend interface {
+	Scheme() string
+	// 保存内容，返回计算出的 CID
+	Put(ctx context.Context, r io.Reader, name string) (*Object, error)
+	// 读取内容，调用方负责关闭；内容不存在时返回 ErrNotFound
+	Get(ctx context.Context, cid string) (io.ReadCloser, error)
+	// 查询内容大小；内容不存在时返回 ErrNotFound
+	Stat(ctx context.Context, cid string) (int64, error)
+}
+
+// 已配置的存储，Default 用于新上传的模型
+type Registry struct {
+	Default  string
+	backends map[string]Backend
+}
+
+func NewRegistry(defaultScheme string, backends ...Backend) (*Registry, error) {
+	r := &Registry{Default: defaultScheme, backends: make(map[string]Backend)}
+	for _, b := range backends {
+		r.backends[b.Scheme()] = b
+	}
+	if _, ok := r.backends[defaultScheme]; !ok {
+		return nil, fmt.Errorf("默认存储 %s 未配置", defaultScheme)
+	}
+	return r, nil
+}
+
+// 按存储方式查找，旧模型没有记录存储方式，都保存在 IPFS
+func (r *Registry) Get(scheme string) (Backend, error) {
+	if scheme == "" {
+		scheme = SchemeIPFS
+	}
+	b, ok := r.backends[scheme]
+	if !ok {
+		return nil, fmt.Errorf("存储 %s 未配置", scheme)
+	}
+	return b, nil
+}
+
+// 已配置的存储方式
+func (r *Registry) Schemes() []string {
+	schemes := make([]string, 0, len(r.backends))
+	for scheme := range r.backends {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// 把内容从 from 复制到 to，复制时按 cid 的版本重新计算 CID 校验内容。
+// 目标存储以 CIDv1 保存，旧模型的 CIDv0 复制后返回的 obj.CID 与 cid 不同
+func Copy(ctx context.Context, from, to Backend, cid string) (*Object, error) {
+	src, err := from.Get(ctx, cid)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	// 写入目标存储的同时计算原 CID
+	pr, pw := io.Pipe()
+	computed := make(chan string, 1)
+	go func() {
+		sum, err := ipfs.NewHasherFor(cid, pr).Sum()
+		pr.CloseWithError(err)
+		computed <- sum
+	}()
+	obj, err := to.Put(ctx, io.TeeReader(src, pw), cid)
+	pw.CloseWithError(err)
+	sum := <-computed
+	if err != nil {
+		return nil, err
+	}
+	if sum != cid {
+		return nil, fmt.Errorf("%s 中的内容 CID 为 %s，与记录的 %s 不一致", from.Scheme(), sum, cid)
+	}
+	return obj, nil
+}
+
+// 读完 r 后返回内容的 CID
+func hashAll(r io.Reader) (*Object, error) {
+	h := ipfs.NewHasher(r)
+	cid, err := h.Sum()
+	if err != nil {
+		return nil, err
+	}
+	return &Object{CID: cid, Size: h.Size(), SHA256: fmt.Sprintf("%x", h.SHA256())}, nil
+}
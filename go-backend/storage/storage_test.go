@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"backend/ipfs"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 旧模型以 CIDv0 记录，复制时按 CIDv0 校验，目标存储以 CIDv1 保存
+func TestCopyLegacyCIDv0(t *testing.T) {
+	content := bytes.Repeat([]byte("legacy model "), 50000)
+	v0, err := ipfs.NewHasherFor("Qm", bytes.NewReader(content)).Sum()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1, _, err := ipfs.ComputeCID(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from, err := NewFilesystem(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := NewFilesystem(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(from.root, v0), content, 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := Copy(context.Background(), from, to, v0)
+	if err != nil {
+		t.Fatalf("复制失败: %v", err)
+	}
+	if obj.CID != v1 {
+		t.Errorf("目标存储中的 CID = %s, 期望 %s", obj.CID, v1)
+	}
+	body, err := to.Get(context.Background(), obj.CID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	if got, _ := io.ReadAll(body); !bytes.Equal(got, content) {
+		t.Error("复制后的内容不一致")
+	}
+
+	// 内容与记录的 CID 不符时拒绝
+	if err := os.WriteFile(filepath.Join(from.root, v0), append(content, '!'), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Copy(context.Background(), from, to, v0); err == nil {
+		t.Error("内容被篡改时应返回错误")
+	}
+}
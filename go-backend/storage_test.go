@@ -0,0 +1,154 @@
+package main
+
+import (
+	"backend/audit"
+	"backend/auth"
+	"backend/envelope"
+	invoke_fabric "backend/fabric-go/call"
+	"backend/ipfs"
+	"backend/middleware"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 内存中的下载审计日志，代替账本
+type memoryAuditLog struct {
+	entries []audit.Download
+}
+
+func (l *memoryAuditLog) Append(entry audit.Download) error {
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+func (l *memoryAuditLog) Query(audit.Filter) ([]audit.Download, error) {
+	return l.entries, nil
+}
+
+// 内存中的信封存储，代替账本
+type memoryEnvelopes map[string]*envelope.Envelope
+
+func (m memoryEnvelopes) Get(cid string) (*envelope.Envelope, error) {
+	if env, ok := m[cid]; ok {
+		return env, nil
+	}
+	return nil, envelope.ErrNotFound
+}
+
+func (m memoryEnvelopes) Put(env *envelope.Envelope) error {
+	if _, ok := m[env.CID]; ok {
+		return envelope.ErrExists
+	}
+	m[env.CID] = env
+	return nil
+}
+
+// 使用内存 IPFS 节点上传模型文件再下载，检查 CID 与内容
+func TestUploadAndDownloadModelFile(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-0123456789abcdef0123456789")
+	node := ipfs.NewFakeNode()
+	srv := httptest.NewServer(node)
+	defer srv.Close()
+
+	registry, err := newModelStorage(ModelTransferConfig{Storage: "ipfs", IPFSURL: srv.URL, FileDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldStorage, oldAudit, oldKeys := modelStorage, downloadAudit, modelKeys
+	log := &memoryAuditLog{}
+	modelStorage, downloadAudit, modelKeys = registry, log, memoryEnvelopes{}
+	defer func() { modelStorage, downloadAudit, modelKeys = oldStorage, oldAudit, oldKeys }()
+
+	// 下载只测试读取存储和写入响应，模型记录和权限检查需要链码
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/upload_model_file", middleware.RequireAuth(), upload_model_file)
+	r.GET("/download/:cid", middleware.RequireAuth(), func(ctx *gin.Context) {
+		model := &invoke_fabric.Model{Modelid: "model-1", Modelowner: "alice", Modelhash: ctx.Param("cid"), Storage: "ipfs"}
+		streamModel(ctx, model, audit.Download{Username: "alice", Relation: relationOwner, Via: downloadDirect})
+	})
+	token, err := auth.IssueToken(auth.TokenUser{Username: "alice"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 超过一个分块，CID 使用 dag-pb 根节点
+	content := make([]byte, ipfs.ChunkSize*2+123)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+	wantCID, _, err := ipfs.ComputeCID(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "model.bin")
+	part.Write(content)
+	form.Close()
+	req := httptest.NewRequest(http.MethodPost, "/upload_model_file", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("上传返回 %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var uploaded struct {
+		Storage string `json:"storage"`
+		CID     string `json:"cid"`
+		Size    int64  `json:"size"`
+		SHA256  string `json:"sha256"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &uploaded); err != nil {
+		t.Fatal(err)
+	}
+	if uploaded.CID != wantCID || uploaded.Storage != "ipfs" || uploaded.Size != int64(len(content)) {
+		t.Fatalf("上传结果 %+v, 期望 CID %s 大小 %d", uploaded, wantCID, len(content))
+	}
+	if uploaded.SHA256 != fmt.Sprintf("%x", sha256.Sum256(content)) {
+		t.Errorf("SHA256 = %s", uploaded.SHA256)
+	}
+	if !node.Pinned(uploaded.CID) {
+		t.Error("上传的内容没有固定")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/download/"+uploaded.CID, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("下载返回 %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("X-Model-CID"); got != uploaded.CID {
+		t.Errorf("X-Model-CID = %s", got)
+	}
+	if got, _ := io.ReadAll(rec.Body); !bytes.Equal(got, content) {
+		t.Errorf("下载内容与上传不一致: %d 字节", len(got))
+	}
+
+	if len(log.entries) != 1 || log.entries[0].Result != audit.DownloadOK || log.entries[0].Bytes != int64(len(content)) {
+		t.Errorf("下载审计记录 %+v", log.entries)
+	}
+
+	// 节点上不存在的内容返回 404
+	missing, _, _ := ipfs.ComputeCID(bytes.NewReader([]byte("missing")))
+	req = httptest.NewRequest(http.MethodGet, "/download/"+missing, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("下载不存在的内容返回 %d", rec.Code)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	invoke_fabric "backend/fabric-go/call"
+	connect_fabric "backend/fabric-go/network"
+	"backend/middleware"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 当前用户在任务中的角色
+func taskRoles(ctx *gin.Context, task *invoke_fabric.Task) []string {
+	claims := middleware.CurrentUser(ctx)
+	if claims == nil {
+		return nil
+	}
+	var roles []string
+	if task.PostedUser == claims.User.Username {
+		roles = append(roles, invoke_fabric.TaskRolePoster)
+	}
+	if claims.User.IsAdmin && claims.MFA {
+		roles = append(roles, invoke_fabric.TaskRoleAdmin)
+	}
+	for _, user := range task.AcceptedUsers {
+		if user == claims.User.Username {
+			roles = append(roles, invoke_fabric.TaskRoleParticipant)
+			break
+		}
+	}
+	return roles
+}
+
+// 读取未删除的任务，失败时已写入响应
+func loadTask(ctx *gin.Context, contract *client.Contract, taskID string) (*invoke_fabric.Task, bool) {
+	task, err := invoke_fabric.QueryTask(contract, taskID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取任务数据失败: %s", err.Error())})
+		return nil, false
+	}
+	if task.Deleted() {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("任务 %s 已于 %s 被删除", task.TaskID, task.DeletedAt)})
+		return nil, false
+	}
+	return task, true
+}
+
+// 检查当前用户能否把任务变更为 to 状态，失败时已写入响应
+func checkTaskTransition(ctx *gin.Context, task *invoke_fabric.Task, to string) bool {
+	if err := invoke_fabric.CheckTaskTransition(task.State(), to, taskRoles(ctx, task)...); err != nil {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": task.State()})
+		return false
+	}
+	return true
+}
+
+// 按状态机变更任务状态，失败时已写入响应
+func transitionTask(ctx *gin.Context, contract *client.Contract, task *invoke_fabric.Task, to, reason string) bool {
+	if !checkTaskTransition(ctx, task, to) {
+		return false
+	}
+	actor := middleware.CurrentUser(ctx).User.Username
+	if err := invoke_fabric.TransitionTask(contract, task.TaskID, task.State(), to, actor, reason); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	task.Status = to
+	return true
+}
+
+// 后端自动触发的状态变更
+func systemTransition(contract *client.Contract, task *invoke_fabric.Task, to, reason string) error {
+	if err := invoke_fabric.CheckTaskTransition(task.State(), to, invoke_fabric.TaskRoleSystem); err != nil {
+		return err
+	}
+	if err := invoke_fabric.TransitionTask(contract, task.TaskID, task.State(), to, invoke_fabric.TaskRoleSystem, reason); err != nil {
+		return err
+	}
+	task.Status = to
+	return nil
+}
+
+type taskRequest struct {
+	TaskID string `json:"taskId"`
+	Reason string `json:"reason"`
+}
+
+// 发布草稿任务
+func publish_task(ctx *gin.Context) {
+	var request taskRequest
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	task, ok := loadTask(ctx, contract, request.TaskID)
+	if !ok {
+		return
+	}
+	if !transitionTask(ctx, contract, task, invoke_fabric.TaskOpen, "发布任务") {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("任务 %s 已发布", task.TaskID), "status": task.Status})
+}
+
+// 停止接收模型，进入聚合阶段
+func close_submissions(ctx *gin.Context) {
+	var request taskRequest
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	task, ok := loadTask(ctx, contract, request.TaskID)
+	if !ok {
+		return
+	}
+	if !transitionTask(ctx, contract, task, invoke_fabric.TaskAggregating, "停止提交模型") {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("任务 %s 已停止接收模型", task.TaskID), "status": task.Status})
+}
+
+// 聚合阶段重新开放提交
+func reopen_submissions(ctx *gin.Context) {
+	var request taskRequest
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	task, ok := loadTask(ctx, contract, request.TaskID)
+	if !ok {
+		return
+	}
+	if !transitionTask(ctx, contract, task, invoke_fabric.TaskInProgress, "重新开放提交") {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("任务 %s 已重新开放提交", task.TaskID), "status": task.Status})
+}
+
+// 取消任务并退还托管奖励
+func cancel_task(ctx *gin.Context) {
+	var request taskRequest
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	task, ok := loadTask(ctx, contract, request.TaskID)
+	if !ok {
+		return
+	}
+	reason := request.Reason
+	if reason == "" {
+		reason = "取消任务"
+	}
+	if !transitionTask(ctx, contract, task, invoke_fabric.TaskCancelled, reason) {
+		return
+	}
+
+	// 罚金分给留下的参与者，不随托管余额退还发布者
+	if err := invoke_fabric.ReleasePenalties(contract, task); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("任务已取消，但分配退出罚金失败，稍后自动重试: %s", err.Error())})
+		return
+	}
+	refund, err := invoke_fabric.RefundEscrow(contract, task.TaskID, task.PostedUser)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("任务已取消，但退还托管奖励失败，稍后自动重试: %s", err.Error())})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("任务 %s 已取消", task.TaskID),
+		"status":  task.Status,
+		"refund":  refund,
+	})
+}
+
+// 后端默认的退出罚金 LEAVE_PENALTY，只在创建任务时写入任务的接受限制
+func defaultLeavePenalty() int {
+	if v, err := strconv.Atoi(os.Getenv("LEAVE_PENALTY")); err == nil && v > 0 {
+		return v
+	}
+	return 0
+}
+
+// 退出任务的罚金，以任务记录的值为准，之后修改 LEAVE_PENALTY 不影响已创建的任务
+func leavePenalty(task *invoke_fabric.Task) int {
+	return task.Rules.Penalty()
+}
+
+// 参与者退出任务，罚金转入任务的罚金账户由其他参与者分配
+func leave_task(ctx *gin.Context) {
+	var request taskRequest
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	task, ok := loadTask(ctx, contract, request.TaskID)
+	if !ok {
+		return
+	}
+	username := middleware.CurrentUser(ctx).User.Username
+	if !slices.Contains(task.AcceptedUsers, username) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("用户 %s 没有参与任务 %s", username, task.TaskID)})
+		return
+	}
+
+	// 进行中可以退出；停止提交后，已提交模型的用户不能退出
+	state := task.State()
+	switch state {
+	case invoke_fabric.TaskInProgress:
+	case invoke_fabric.TaskAggregating:
+		submitters, err := taskSubmitters(contract, task)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if submitters[username] {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "本轮已停止提交，已提交模型的用户不能退出", "status": state})
+			return
+		}
+	default:
+		ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("任务处于 %s 状态，不能退出", state), "status": state})
+		return
+	}
+
+	// 罚金在退出交易中转入任务的罚金账户，任务结束后由留下的参与者平分
+	penalty := leavePenalty(task)
+	if penalty > 0 {
+		user, err := invoke_fabric.Get_one_User(contract, username)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("查询用户信息失败: %s", err.Error())})
+			return
+		}
+		if user.Token < penalty {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("余额不足以支付退出罚金 %d", penalty)})
+			return
+		}
+	}
+
+	if err := invoke_fabric.WithdrawFromTask(contract, task.TaskID, username, penalty); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("用户 %s 已退出任务 %s", username, task.TaskID),
+		"penalty": penalty,
+	})
+}
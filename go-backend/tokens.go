@@ -0,0 +1,332 @@
+package main
+
+import (
+	invoke_fabric "backend/fabric-go/call"
+	connect_fabric "backend/fabric-go/network"
+	"backend/middleware"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 查询账户对账单：当前余额和每笔收支
+func get_statement(ctx *gin.Context) {
+	var request struct {
+		Username string `json:"username"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	// 只能查询自己的对账单，管理员可以查询所有人
+	claims := middleware.CurrentUser(ctx)
+	if request.Username == "" {
+		request.Username = claims.User.Username
+	}
+	if request.Username != claims.User.Username && !(claims.User.IsAdmin && claims.MFA) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "无权查询其他用户的对账单"})
+		return
+	}
+
+	user, err := invoke_fabric.Get_one_User(contract, request.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("查询用户失败: %s", err.Error())})
+		return
+	}
+	transfers, err := invoke_fabric.GetTransferHistory(contract, request.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	entries := []gin.H{}
+	for _, t := range transfers {
+		posting, ok := t.PostingFor(request.Username)
+		if !ok {
+			continue
+		}
+		// 对方账户
+		counterparties := []string{}
+		for _, p := range t.Postings {
+			if p.Account != request.Username {
+				counterparties = append(counterparties, p.Account)
+			}
+		}
+		entries = append(entries, gin.H{
+			"transferId":     t.TransferID,
+			"timestamp":      t.Timestamp,
+			"memo":           t.Memo,
+			"amount":         posting.Amount,
+			"balance":        posting.Balance,
+			"counterparties": counterparties,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "对账单查询成功",
+		"account": request.Username,
+		"balance": user.Token,
+		"entries": entries,
+	})
+}
+
+// 管理员增发或销毁代币的请求
+type mintRequest struct {
+	Username string `json:"username"`
+	Amount   int    `json:"amount"`
+	Reason   string `json:"reason"`
+}
+
+// 管理员增发代币
+func mint_tokens(ctx *gin.Context) {
+	adjustTokens(ctx, invoke_fabric.TransferKindMint)
+}
+
+// 管理员销毁代币
+func burn_tokens(ctx *gin.Context) {
+	adjustTokens(ctx, invoke_fabric.TransferKindBurn)
+}
+
+func adjustTokens(ctx *gin.Context, kind string) {
+	var request mintRequest
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+	if strings.TrimSpace(request.Reason) == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "必须填写原因"})
+		return
+	}
+	user, err := invoke_fabric.Get_one_User(contract, request.Username)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("用户 %s 不存在", request.Username)})
+		return
+	}
+	// 已删除的用户不能再收到代币，销毁仍然允许
+	if kind == invoke_fabric.TransferKindMint && user.Deleted() {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("用户 %s 已被删除", request.Username)})
+		return
+	}
+
+	admin := middleware.CurrentUser(ctx).User.Username
+	memo := fmt.Sprintf("%s（操作人 %s）", request.Reason, admin)
+	var transfer *invoke_fabric.TokenTransfer
+	if kind == invoke_fabric.TransferKindMint {
+		transfer, err = invoke_fabric.MintTokens(contract, "", request.Username, request.Amount, memo)
+	} else {
+		transfer, err = invoke_fabric.BurnTokens(contract, "", request.Username, request.Amount, memo)
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":  fmt.Sprintf("已为用户 %s %s %d 代币", request.Username, map[string]string{invoke_fabric.TransferKindMint: "增发", invoke_fabric.TransferKindBurn: "销毁"}[kind], request.Amount),
+		"transfer": transfer,
+	})
+}
+
+// 一行发放记录
+type grantRow struct {
+	Line     int
+	Username string
+	Amount   int
+	Reason   string
+}
+
+// 解析发放 CSV：username,amount,reason，第一行可以是表头
+func parseGrantCSV(r io.Reader, defaultReason string) ([]grantRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var rows []grantRow
+	seen := make(map[string]int)
+	line := 0
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行格式错误: %w", line, err)
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("第 %d 行至少需要用户名和数量两列", line)
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "username") {
+			continue
+		}
+
+		amount, err := strconv.Atoi(strings.TrimSpace(record[1]))
+		if err != nil || amount <= 0 {
+			return nil, fmt.Errorf("第 %d 行数量无效: %s", line, record[1])
+		}
+		row := grantRow{Line: line, Username: strings.TrimSpace(record[0]), Amount: amount, Reason: defaultReason}
+		if len(record) > 2 && strings.TrimSpace(record[2]) != "" {
+			row.Reason = strings.TrimSpace(record[2])
+		}
+		if row.Username == "" {
+			return nil, fmt.Errorf("第 %d 行用户名为空", line)
+		}
+		if first, ok := seen[row.Username]; ok {
+			return nil, fmt.Errorf("第 %d 行的用户 %s 与第 %d 行重复", line, row.Username, first)
+		}
+		seen[row.Username] = line
+		if row.Reason == "" {
+			return nil, fmt.Errorf("第 %d 行缺少发放原因", line)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("CSV 中没有发放记录")
+	}
+	return rows, nil
+}
+
+// 批量发放的批次号
+var grantBatchID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// 管理员通过 CSV 批量发放代币，同一批次重复上传不会重复发放
+func grant_tokens(ctx *gin.Context) {
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	file, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请上传 CSV 文件"})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("读取文件失败: %s", err.Error())})
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("读取文件失败: %s", err.Error())})
+		return
+	}
+
+	// 批次号由管理员指定，同一批次中每个用户只发放一次，重新上传修改过的文件也不会重复发放
+	batchID := strings.TrimSpace(ctx.PostForm("batchId"))
+	if !grantBatchID.MatchString(batchID) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请填写批次号，只能包含字母、数字、点、下划线和连字符，最长 64 个字符"})
+		return
+	}
+
+	rows, err := parseGrantCSV(strings.NewReader(string(data)), ctx.PostForm("reason"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	admin := middleware.CurrentUser(ctx).User.Username
+
+	granted := []gin.H{}
+	failed := []gin.H{}
+	for _, row := range rows {
+		key := fmt.Sprintf("grant:%s:%s", batchID, row.Username)
+		if user, err := invoke_fabric.Get_one_User(contract, row.Username); err != nil {
+			failed = append(failed, gin.H{"line": row.Line, "username": row.Username, "error": fmt.Sprintf("用户 %s 不存在", row.Username)})
+			continue
+		} else if user.Deleted() {
+			failed = append(failed, gin.H{"line": row.Line, "username": row.Username, "error": fmt.Sprintf("用户 %s 已被删除", row.Username)})
+			continue
+		}
+		memo := fmt.Sprintf("%s（批量发放 %s，操作人 %s）", row.Reason, batchID, admin)
+		transfer, err := invoke_fabric.MintTokens(contract, key, row.Username, row.Amount, memo)
+		if err != nil {
+			failed = append(failed, gin.H{"line": row.Line, "username": row.Username, "error": err.Error()})
+			continue
+		}
+		granted = append(granted, gin.H{"line": row.Line, "username": row.Username, "amount": row.Amount, "transferId": transfer.TransferID})
+	}
+
+	status := http.StatusOK
+	if len(failed) > 0 {
+		status = http.StatusMultiStatus
+	}
+	ctx.JSON(status, gin.H{
+		"message": fmt.Sprintf("批次 %s: 成功 %d 行，失败 %d 行", batchID, len(granted), len(failed)),
+		"batchId": batchID,
+		"granted": granted,
+		"failed":  failed,
+	})
+}
+
+// 用户之间转账
+func transfer_tokens(ctx *gin.Context) {
+	var request struct {
+		To     string `json:"to"`
+		Amount int    `json:"amount"`
+		Memo   string `json:"memo"`
+	}
+	contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+
+	// 绑定 JSON 数据
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 JSON 数据"})
+		return
+	}
+
+	sender := middleware.CurrentUser(ctx).User.Username
+	if request.Amount <= 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "转账金额必须大于 0"})
+		return
+	}
+	if request.To == sender {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "不能向自己转账"})
+		return
+	}
+
+	// 只能转给普通用户账户
+	receiver, err := invoke_fabric.Get_one_User(contract, request.To)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("收款用户 %s 不存在", request.To)})
+		return
+	}
+	if receiver.Deleted() {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("收款用户 %s 已被删除", request.To)})
+		return
+	}
+	from, err := invoke_fabric.Get_one_User(contract, sender)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("查询用户失败: %s", err.Error())})
+		return
+	}
+	if from.Token < request.Amount {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("余额不足: 当前余额 %d", from.Token)})
+		return
+	}
+
+	memo := request.Memo
+	if memo == "" {
+		memo = fmt.Sprintf("%s 转账给 %s", sender, request.To)
+	}
+	transfer, err := invoke_fabric.TransferTokens(contract, sender, request.To, request.Amount, memo)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":  fmt.Sprintf("已向 %s 转账 %d", request.To, request.Amount),
+		"transfer": transfer,
+	})
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package upload
+
+import "sync"
+
+// 不支持 flock 的平台只在进程内互斥，上传目录不能由多个实例共享
+var fileLocks sync.Map
+
+func lockFile(path string) (func(), error) {
+	l, _ := fileLocks.LoadOrStore(path, &sync.Mutex{})
+	l.(*sync.Mutex).Lock()
+	return l.(*sync.Mutex).Unlock, nil
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package upload
+
+import (
+	"os"
+	"syscall"
+)
+
+// 对锁文件加排他锁，其他进程（包括共享上传目录的其他实例）会等待；进程退出时由系统释放
+func lockFile(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() { f.Close() }, nil
+}
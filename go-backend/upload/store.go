@@ -0,0 +1,388 @@
+package upload
+
+import (
+	"backend/storage"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	ErrNotFound         = errors.New("上传不存在或已过期")
+	ErrOffsetMismatch   = errors.New("上传偏移量与服务端不一致")
+	ErrChecksumMismatch = errors.New("分块校验和不匹配")
+	ErrTooLarge         = errors.New("超出上传长度")
+	ErrCompleted        = errors.New("上传已完成")
+	ErrQuotaExceeded    = errors.New("未完成的上传超出配额")
+	ErrRegistered       = errors.New("上传已登记为模型")
+	ErrClaimed          = errors.New("上传正在登记为模型")
+)
+
+// 登记模型时占用上传的时限，超时后视为登记中断，可以重新登记
+const claimTTL = 10 * time.Minute
+
+// 上传状态
+const (
+	StatusUploading = "uploading" // 正在上传
+	StatusCompleted = "completed" // 已保存到模型存储，可以登记模型
+)
+
+// 一次可续传的上传
+type Info struct {
+	ID        string            `json:"id"`
+	Owner     string            `json:"owner"`
+	Length    int64             `json:"length"`
+	Offset    int64             `json:"offset"`
+	Metadata  map[string]string `json:"metadata"`
+	CreatedAt time.Time         `json:"createdAt"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+	Status    string            `json:"status"`
+	Object    *storage.Object   `json:"object,omitempty"`   // 完成后的存储位置和内容哈希
+	ModelID   string            `json:"modelId,omitempty"`  // 已登记的模型
+	ClaimedAt time.Time         `json:"claimedAt,omitzero"` // 开始登记模型的时间，登记完成或失败后清除
+	Error     string            `json:"error,omitempty"`    // 最近一次保存到存储失败的原因
+}
+
+// 上传进度，0 到 1
+func (i *Info) Progress() float64 {
+	if i.Length == 0 {
+		return 1
+	}
+	return float64(i.Offset) / float64(i.Length)
+}
+
+// 每个用户未完成的上传限制，为 0 表示不限
+type Quota struct {
+	MaxOpen  int   // 同时进行的上传数
+	MaxBytes int64 // 进行中的上传声明长度之和
+}
+
+// 基于目录的上传存储：<id>.bin 保存已接收的数据，<id>.json 保存上传信息，<id>.lock 用于加锁。
+// 多个后端实例共享同一个目录（如 NFS）时，任一实例都可以续传，文件锁保证同一个上传的操作串行执行
+type Store struct {
+	dir     string
+	ttl     time.Duration
+	maxSize int64
+	quota   Quota
+}
+
+func NewStore(dir string, ttl time.Duration, maxSize int64, quota Quota) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("创建上传目录失败: %w", err)
+	}
+	return &Store{dir: dir, ttl: ttl, maxSize: maxSize, quota: quota}, nil
+}
+
+func (s *Store) MaxSize() int64 { return s.maxSize }
+
+// 同一个上传的操作串行执行
+func (s *Store) lock(id string) (func(), error) {
+	unlock, err := lockFile(s.lockPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("锁定上传失败: %w", err)
+	}
+	return unlock, nil
+}
+
+// 同一个用户创建上传串行执行，保证配额检查准确
+func (s *Store) lockOwner(owner string) (func(), error) {
+	return s.lock("owner-" + hex.EncodeToString([]byte(owner)))
+}
+
+func (s *Store) dataPath(id string) string { return filepath.Join(s.dir, id+".bin") }
+func (s *Store) infoPath(id string) string { return filepath.Join(s.dir, id+".json") }
+func (s *Store) lockPath(id string) string { return filepath.Join(s.dir, id+".lock") }
+
+// 创建上传，同时清理已过期的上传；超出用户配额时返回 ErrQuotaExceeded
+func (s *Store) Create(owner string, length int64, metadata map[string]string) (*Info, error) {
+	if length < 0 {
+		return nil, fmt.Errorf("上传长度无效")
+	}
+	if s.maxSize > 0 && length > s.maxSize {
+		return nil, fmt.Errorf("%w: 最大 %d 字节", ErrTooLarge, s.maxSize)
+	}
+	unlock, err := s.lockOwner(owner)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	open, bytes := s.scan(owner)
+	if s.quota.MaxOpen > 0 && open >= s.quota.MaxOpen {
+		return nil, fmt.Errorf("%w: 最多同时进行 %d 个上传", ErrQuotaExceeded, s.quota.MaxOpen)
+	}
+	if s.quota.MaxBytes > 0 && bytes+length > s.quota.MaxBytes {
+		return nil, fmt.Errorf("%w: 进行中的上传最多 %d 字节", ErrQuotaExceeded, s.quota.MaxBytes)
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("生成上传 ID 失败: %w", err)
+	}
+	now := time.Now().UTC()
+	info := &Info{
+		ID:        hex.EncodeToString(buf),
+		Owner:     owner,
+		Length:    length,
+		Metadata:  metadata,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+		Status:    StatusUploading,
+	}
+	f, err := os.OpenFile(s.dataPath(info.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("创建上传文件失败: %w", err)
+	}
+	f.Close()
+	if err := s.save(info); err != nil {
+		os.Remove(s.dataPath(info.ID))
+		return nil, err
+	}
+	return info, nil
+}
+
+func (s *Store) Get(id string) (*Info, error) {
+	if _, err := hex.DecodeString(id); err != nil || len(id) != 32 {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(s.infoPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取上传信息失败: %w", err)
+	}
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("解析上传信息失败: %w", err)
+	}
+	if time.Now().After(info.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	return &info, nil
+}
+
+func (s *Store) save(info *Info) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	tmp := s.infoPath(info.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return fmt.Errorf("保存上传信息失败: %w", err)
+	}
+	return os.Rename(tmp, s.infoPath(info.ID))
+}
+
+// 从 offset 开始追加一个分块；checksum 不为空时校验分块，不匹配则丢弃该分块
+func (s *Store) WriteChunk(id string, offset int64, r io.Reader, checksum *Checksum) (*Info, error) {
+	unlock, err := s.lock(id)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	info, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if info.Status == StatusCompleted {
+		return info, ErrCompleted
+	}
+	if offset != info.Offset {
+		return info, ErrOffsetMismatch
+	}
+
+	f, err := os.OpenFile(s.dataPath(id), os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("打开上传文件失败: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	// 最多读到声明的长度，多出的数据视为错误
+	remaining := info.Length - offset
+	src := io.LimitReader(r, remaining+1)
+	var w io.Writer = f
+	if checksum != nil {
+		w = io.MultiWriter(f, checksum.hash)
+	}
+	n, copyErr := io.Copy(w, src)
+	switch {
+	case n > remaining:
+		copyErr = ErrTooLarge
+	case copyErr == nil && checksum != nil && !checksum.Match():
+		copyErr = ErrChecksumMismatch
+	}
+	// 校验失败或无法校验的分块整块丢弃；没有校验和时保留连接中断前收到的部分，客户端按 HEAD 返回的偏移量续传
+	if errors.Is(copyErr, ErrTooLarge) || copyErr != nil && checksum != nil {
+		n = 0
+	}
+	if err := f.Truncate(offset + n); err != nil {
+		return nil, fmt.Errorf("写入上传文件失败: %w", err)
+	}
+	info.Offset = offset + n
+	if err := s.save(info); err != nil {
+		return nil, err
+	}
+	return info, copyErr
+}
+
+// 数据接收完整后保存到模型存储，计算 CID 和 SHA-256；保存失败时保留数据，可以重试
+func (s *Store) Finish(ctx context.Context, id string, backend storage.Backend) (*Info, error) {
+	unlock, err := s.lock(id)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	info, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if info.Status == StatusCompleted {
+		return info, nil
+	}
+	if info.Offset != info.Length {
+		return info, fmt.Errorf("上传尚未完成: %d/%d", info.Offset, info.Length)
+	}
+
+	f, err := os.Open(s.dataPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("打开上传文件失败: %w", err)
+	}
+	name := info.Metadata["filename"]
+	if name == "" {
+		name = id
+	}
+	obj, err := backend.Put(ctx, f, name)
+	f.Close()
+	if err != nil {
+		info.Error = err.Error()
+		s.save(info)
+		return info, err
+	}
+
+	info.Status = StatusCompleted
+	info.Object = obj
+	info.Error = ""
+	if err := s.save(info); err != nil {
+		return nil, err
+	}
+	os.Remove(s.dataPath(id))
+	return info, nil
+}
+
+// 开始把已完成的上传登记为模型，同一个上传只能登记一次；
+// 登记成功后调用 MarkRegistered，失败时调用 Release
+func (s *Store) Claim(id string) (*Info, error) {
+	unlock, err := s.lock(id)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	info, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if info.Status != StatusCompleted {
+		return info, fmt.Errorf("上传尚未完成: %d/%d", info.Offset, info.Length)
+	}
+	if info.ModelID != "" {
+		return info, fmt.Errorf("%w: %s", ErrRegistered, info.ModelID)
+	}
+	if !info.ClaimedAt.IsZero() && time.Since(info.ClaimedAt) < claimTTL {
+		return info, ErrClaimed
+	}
+	info.ClaimedAt = time.Now().UTC()
+	if err := s.save(info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// 登记失败，释放上传以便重试
+func (s *Store) Release(id string) error {
+	return s.update(id, func(info *Info) { info.ClaimedAt = time.Time{} })
+}
+
+// 记录上传已登记为模型
+func (s *Store) MarkRegistered(id, modelID string) error {
+	return s.update(id, func(info *Info) {
+		info.ModelID = modelID
+		info.ClaimedAt = time.Time{}
+	})
+}
+
+func (s *Store) update(id string, apply func(*Info)) error {
+	unlock, err := s.lock(id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	info, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	apply(info)
+	return s.save(info)
+}
+
+// 取消上传并删除数据
+func (s *Store) Delete(id string) error {
+	unlock, err := s.lock(id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := s.Get(id); err != nil {
+		return err
+	}
+	s.remove(id)
+	return nil
+}
+
+// 删除上传的全部文件；等待锁的操作之后读取上传信息时按不存在处理
+func (s *Store) remove(id string) {
+	os.Remove(s.dataPath(id))
+	os.Remove(s.infoPath(id))
+	os.Remove(s.lockPath(id))
+}
+
+// 清理已过期的上传，同时统计 owner 进行中的上传数和声明长度之和
+func (s *Store) scan(owner string) (int, int64) {
+	matches, _ := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	now := time.Now()
+	open, bytes := 0, int64(0)
+	for _, path := range matches {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var info Info
+		if json.Unmarshal(data, &info) != nil || info.ID == "" {
+			continue
+		}
+		if now.After(info.ExpiresAt) {
+			s.remove(info.ID)
+			continue
+		}
+		if info.Owner == owner && info.Status == StatusUploading {
+			open++
+			bytes += info.Length
+		}
+	}
+	return open, bytes
+}
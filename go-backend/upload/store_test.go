@@ -0,0 +1,189 @@
+package upload
+
+import (
+	"backend/storage"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T, quota Quota) *Store {
+	t.Helper()
+	s, err := NewStore(t.TempDir(), time.Hour, 1<<20, quota)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func checksumOf(t *testing.T, data []byte) *Checksum {
+	t.Helper()
+	sum := sha256.Sum256(data)
+	c, err := ParseChecksum("sha256 " + base64.StdEncoding.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// 连接中途断开的读取方
+type brokenReader struct {
+	data []byte
+}
+
+func (r *brokenReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// 分块按偏移量顺序写入，偏移量不一致、校验和不匹配和超出长度的分块都不改变偏移量
+func TestWriteChunk(t *testing.T) {
+	s := newTestStore(t, Quota{})
+	content := []byte("0123456789abcdef")
+	info, err := s.Create("alice", int64(len(content)), map[string]string{"filename": "model.onnx"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name       string
+		offset     int64
+		data       []byte
+		checksum   *Checksum
+		wantErr    error
+		wantOffset int64
+	}{
+		{"第一个分块", 0, content[:4], checksumOf(t, content[:4]), nil, 4},
+		{"偏移量落后", 0, content[:4], nil, ErrOffsetMismatch, 4},
+		{"偏移量超前", 8, content[8:], nil, ErrOffsetMismatch, 4},
+		{"校验和不匹配", 4, content[4:8], checksumOf(t, []byte("xxxx")), ErrChecksumMismatch, 4},
+		{"超出声明长度", 4, append(content[4:len(content):len(content)], 'x'), nil, ErrTooLarge, 4},
+		{"校验和匹配", 4, content[4:8], checksumOf(t, content[4:8]), nil, 8},
+		{"剩余部分", 8, content[8:], nil, nil, 16},
+		{"已完成", 16, nil, nil, nil, 16},
+	}
+	for _, step := range steps {
+		got, err := s.WriteChunk(info.ID, step.offset, bytes.NewReader(step.data), step.checksum)
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: 错误 %v，期望 %v", step.name, err, step.wantErr)
+		}
+		if got.Offset != step.wantOffset {
+			t.Fatalf("%s: 偏移量 %d，期望 %d", step.name, got.Offset, step.wantOffset)
+		}
+		stored, err := s.Get(info.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Offset != step.wantOffset {
+			t.Fatalf("%s: 保存的偏移量 %d，期望 %d", step.name, stored.Offset, step.wantOffset)
+		}
+	}
+
+	backend, err := storage.NewFilesystem(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := s.Finish(context.Background(), info.ID, backend)
+	if err != nil {
+		t.Fatalf("保存上传失败: %v", err)
+	}
+	sum := sha256.Sum256(content)
+	if done.Status != StatusCompleted || done.Object.SHA256 != fmt.Sprintf("%x", sum) {
+		t.Errorf("保存的内容与上传的分块不一致: %+v", done.Object)
+	}
+	if _, err := s.WriteChunk(info.ID, 16, bytes.NewReader(nil), nil); !errors.Is(err, ErrCompleted) {
+		t.Errorf("已完成的上传继续写入应返回 ErrCompleted，实际 %v", err)
+	}
+}
+
+// 连接中断时，没有校验和的分块保留已收到的部分，有校验和的分块整块丢弃
+func TestWriteChunkInterrupted(t *testing.T) {
+	s := newTestStore(t, Quota{})
+	content := []byte("0123456789abcdef")
+
+	tests := []struct {
+		name       string
+		checksum   *Checksum
+		wantOffset int64
+	}{
+		{"没有校验和", nil, 6},
+		{"有校验和", checksumOf(t, content[:6]), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := s.Create("alice", int64(len(content)), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.WriteChunk(info.ID, 0, &brokenReader{data: content[:6]}, tt.checksum)
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Fatalf("错误 %v，期望 %v", err, io.ErrUnexpectedEOF)
+			}
+			if got.Offset != tt.wantOffset {
+				t.Fatalf("偏移量 %d，期望 %d", got.Offset, tt.wantOffset)
+			}
+			// 按返回的偏移量续传后内容完整
+			rest := content[got.Offset:]
+			got, err = s.WriteChunk(info.ID, got.Offset, bytes.NewReader(rest), checksumOf(t, rest))
+			if err != nil {
+				t.Fatalf("续传失败: %v", err)
+			}
+			if got.Offset != got.Length || got.Progress() != 1 {
+				t.Errorf("续传后偏移量 %d/%d", got.Offset, got.Length)
+			}
+		})
+	}
+}
+
+// 进度按已接收的字节数计算，长度为 0 的上传视为已完成
+func TestProgress(t *testing.T) {
+	tests := []struct {
+		offset, length int64
+		want           float64
+	}{
+		{0, 0, 1},
+		{0, 4, 0},
+		{1, 4, 0.25},
+		{4, 4, 1},
+	}
+	for _, tt := range tests {
+		info := &Info{Offset: tt.offset, Length: tt.length}
+		if got := info.Progress(); got != tt.want {
+			t.Errorf("%d/%d: 进度 %v，期望 %v", tt.offset, tt.length, got, tt.want)
+		}
+	}
+}
+
+// 声明长度超过上限或超出用户配额时不能创建上传
+func TestCreateLimits(t *testing.T) {
+	s := newTestStore(t, Quota{MaxOpen: 2, MaxBytes: 100})
+
+	if _, err := s.Create("alice", 1<<20+1, nil); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("超过上传上限应返回 ErrTooLarge，实际 %v", err)
+	}
+	if _, err := s.Create("alice", 60, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create("alice", 60, nil); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("超出字节配额应返回 ErrQuotaExceeded，实际 %v", err)
+	}
+	if _, err := s.Create("alice", 40, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create("alice", 0, nil); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("超出上传数配额应返回 ErrQuotaExceeded，实际 %v", err)
+	}
+	if _, err := s.Create("bob", 60, nil); err != nil {
+		t.Errorf("配额按用户计算: %v", err)
+	}
+}
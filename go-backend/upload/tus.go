@@ -0,0 +1,74 @@
+package upload
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// tus 协议版本和支持的扩展
+const (
+	TusVersion    = "1.0.0"
+	TusExtensions = "creation,creation-with-upload,termination,checksum,expiration"
+	TusChecksums  = "sha256,sha1,md5"
+)
+
+// 分块校验和，对应 Upload-Checksum 请求头
+type Checksum struct {
+	hash     hash.Hash
+	expected []byte
+}
+
+// 解析 "<算法> <Base64 摘要>"
+func ParseChecksum(header string) (*Checksum, error) {
+	alg, value, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok {
+		return nil, fmt.Errorf("Upload-Checksum 格式错误")
+	}
+	expected, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("Upload-Checksum 不是有效的 Base64")
+	}
+	var h hash.Hash
+	switch alg {
+	case "sha256":
+		h = sha256.New()
+	case "sha1":
+		h = sha1.New()
+	case "md5":
+		h = md5.New()
+	default:
+		return nil, fmt.Errorf("不支持的校验算法 %s", alg)
+	}
+	if len(expected) != h.Size() {
+		return nil, fmt.Errorf("Upload-Checksum 摘要长度错误")
+	}
+	return &Checksum{hash: h, expected: expected}, nil
+}
+
+func (c *Checksum) Match() bool {
+	return bytes.Equal(c.hash.Sum(nil), c.expected)
+}
+
+// 解析 Upload-Metadata："key base64value,key2 base64value2"，值可以省略
+func ParseMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, " ")
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("Upload-Metadata 中 %s 的值不是有效的 Base64", key)
+		}
+		metadata[key] = string(decoded)
+	}
+	return metadata, nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	connect_fabric "backend/fabric-go/network"
+	"backend/middleware"
+	"backend/storage"
+	"backend/upload"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 可续传上传（tus 1.0.0），完成后文件保存到默认模型存储，再用 uploadId 调用 upload_model 登记
+var modelUploads *upload.Store
+
+// tus 没有定义的状态码，表示分块校验和不匹配
+const statusChecksumMismatch = 460
+
+func setTusHeaders(ctx *gin.Context, info *upload.Info) {
+	ctx.Header("Tus-Resumable", upload.TusVersion)
+	ctx.Header("Cache-Control", "no-store")
+	if info != nil {
+		ctx.Header("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+		ctx.Header("Upload-Length", strconv.FormatInt(info.Length, 10))
+		ctx.Header("Upload-Expires", info.ExpiresAt.Format(http.TimeFormat))
+	}
+}
+
+// 检查客户端的 tus 版本，失败时已写入响应
+func checkTusVersion(ctx *gin.Context) bool {
+	if ctx.GetHeader("Tus-Resumable") != upload.TusVersion {
+		ctx.Header("Tus-Version", upload.TusVersion)
+		ctx.AbortWithStatusJSON(http.StatusPreconditionFailed, gin.H{"error": fmt.Sprintf("仅支持 tus %s", upload.TusVersion)})
+		return false
+	}
+	return true
+}
+
+// 读取当前用户的上传，其他用户的上传按不存在处理，失败时已写入响应
+func ownUpload(ctx *gin.Context, id string) (*upload.Info, bool) {
+	info, err := modelUploads.Get(id)
+	if err == nil && info.Owner != middleware.CurrentUser(ctx).User.Username {
+		err = upload.ErrNotFound
+	}
+	if errors.Is(err, upload.ErrNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return info, true
+}
+
+// 服务端支持的 tus 版本和扩展
+func tus_options(ctx *gin.Context) {
+	ctx.Header("Tus-Resumable", upload.TusVersion)
+	ctx.Header("Tus-Version", upload.TusVersion)
+	ctx.Header("Tus-Extension", upload.TusExtensions)
+	ctx.Header("Tus-Checksum-Algorithm", upload.TusChecksums)
+	ctx.Header("Tus-Max-Size", strconv.FormatInt(modelUploads.MaxSize(), 10))
+	ctx.Status(http.StatusNoContent)
+}
+
+// 创建上传，请求体带有数据时同时写入第一个分块
+func create_upload(ctx *gin.Context) {
+	if !checkTusVersion(ctx) {
+		return
+	}
+	length, err := strconv.ParseInt(ctx.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "缺少有效的 Upload-Length"})
+		return
+	}
+	metadata, err := upload.ParseMetadata(ctx.GetHeader("Upload-Metadata"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 元数据 encryptTask 不为空时，上传完成后加密给该任务的发布者和 aggregators 中的接收者
+	username := middleware.CurrentUser(ctx).User.Username
+	if enc := parseModelEncryption(metadata["encryptTask"], metadata["aggregators"]); enc != nil {
+		contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+		if _, err := modelRecipients(contract, username, enc); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	info, err := modelUploads.Create(username, length, metadata)
+	if errors.Is(err, upload.ErrTooLarge) {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, upload.ErrQuotaExceeded) {
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.Header("Location", "/uploads/"+info.ID)
+
+	if ctx.ContentType() == "application/offset+octet-stream" && ctx.Request.ContentLength != 0 {
+		writeChunk(ctx, info, http.StatusCreated)
+		return
+	}
+	setTusHeaders(ctx, info)
+	ctx.Status(http.StatusCreated)
+}
+
+// 查询上传偏移量，客户端据此续传
+func head_upload(ctx *gin.Context) {
+	if !checkTusVersion(ctx) {
+		return
+	}
+	info, ok := ownUpload(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+	setTusHeaders(ctx, info)
+	ctx.Status(http.StatusOK)
+}
+
+// 追加分块
+func patch_upload(ctx *gin.Context) {
+	if !checkTusVersion(ctx) {
+		return
+	}
+	if ctx.ContentType() != "application/offset+octet-stream" {
+		ctx.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type 必须为 application/offset+octet-stream"})
+		return
+	}
+	info, ok := ownUpload(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+	writeChunk(ctx, info, http.StatusNoContent)
+}
+
+// 按 Upload-Offset 写入分块，数据完整后保存到模型存储
+func writeChunk(ctx *gin.Context, info *upload.Info, status int) {
+	offset := info.Offset
+	if v := ctx.GetHeader("Upload-Offset"); v != "" || status == http.StatusNoContent {
+		var err error
+		if offset, err = strconv.ParseInt(v, 10, 64); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "缺少有效的 Upload-Offset"})
+			return
+		}
+	}
+	var checksum *upload.Checksum
+	if v := ctx.GetHeader("Upload-Checksum"); v != "" {
+		var err error
+		if checksum, err = upload.ParseChecksum(v); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	info, err := modelUploads.WriteChunk(info.ID, offset, ctx.Request.Body, checksum)
+	setTusHeaders(ctx, info)
+	switch {
+	case errors.Is(err, upload.ErrCompleted):
+	case errors.Is(err, upload.ErrOffsetMismatch):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, upload.ErrChecksumMismatch):
+		ctx.JSON(statusChecksumMismatch, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, upload.ErrTooLarge):
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		respondBodyError(ctx, err, http.StatusInternalServerError)
+		return
+	}
+
+	// 最后一个分块写入后保存到模型存储；失败时客户端可以在末尾偏移量发送空分块重试
+	if info.Offset == info.Length && info.Status != upload.StatusCompleted {
+		backend, err := modelStorage.Get(modelStorage.Default)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if enc := parseModelEncryption(info.Metadata["encryptTask"], info.Metadata["aggregators"]); enc != nil {
+			contract := connect_fabric.GetContract(defaultConfig) // 使用 defaultConfig
+			_, err = putEncrypted(contract, info.Owner, enc, backend, func(b storage.Backend) (*storage.Object, error) {
+				finished, err := modelUploads.Finish(ctx.Request.Context(), info.ID, inspecting(b))
+				if err != nil {
+					return nil, err
+				}
+				info = finished
+				return finished.Object, nil
+			})
+		} else {
+			info, err = modelUploads.Finish(ctx.Request.Context(), info.ID, inspecting(backend))
+		}
+		if err != nil {
+			ctx.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("保存模型文件失败: %s", err.Error())})
+			return
+		}
+		fmt.Printf("上传 %s 已完成: 存储=%s, CID=%s\n", info.ID, info.Object.Scheme, info.Object.CID)
+	}
+	ctx.Status(status)
+}
+
+// 取消上传
+func delete_upload(ctx *gin.Context) {
+	if !checkTusVersion(ctx) {
+		return
+	}
+	info, ok := ownUpload(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+	if err := modelUploads.Delete(info.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.Header("Tus-Resumable", upload.TusVersion)
+	ctx.Status(http.StatusNoContent)
+}
+
+// 查询上传进度，完成后返回 CID 和内容哈希
+func get_upload(ctx *gin.Context) {
+	info, ok := ownUpload(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":  "上传信息查询成功",
+		"upload":   info,
+		"progress": info.Progress(),
+	})
+}
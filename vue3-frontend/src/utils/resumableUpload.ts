@@ -0,0 +1,76 @@
+import axios from 'axios'
+
+// 可续传上传（tus 1.0.0），中断后再次上传同一个文件会从服务端记录的偏移量继续
+const API = 'http://localhost:8089'
+const CHUNK_SIZE = 8 * 1024 * 1024
+const TUS_HEADERS = { 'Tus-Resumable': '1.0.0' }
+
+const toBase64 = (bytes: Uint8Array) => btoa(String.fromCharCode(...bytes))
+
+const sha256Base64 = async (data: ArrayBuffer) =>
+  toBase64(new Uint8Array(await crypto.subtle.digest('SHA-256', data)))
+
+const encodeMetadata = (metadata: Record<string, string>) =>
+  Object.entries(metadata)
+    .filter(([, value]) => value)
+    .map(([name, value]) => `${name} ${toBase64(new TextEncoder().encode(value))}`)
+    .join(',')
+
+// 上传文件，返回上传 ID，用于调用 upload_model 登记模型。
+// metadata 中的 encryptTask 和 aggregators 用于加密给任务发布者和聚合方
+export async function resumableUpload(
+  file: File,
+  onProgress?: (sent: number, total: number) => void,
+  metadata: Record<string, string> = {},
+): Promise<string> {
+  const uploadMetadata = encodeMetadata({ filename: file.name, ...metadata })
+  const key = `upload:${file.name}:${file.size}:${file.lastModified}:${uploadMetadata}`
+  let location = localStorage.getItem(key)
+  let offset = 0
+
+  // 之前上传过同一个文件时查询已接收的偏移量
+  if (location) {
+    try {
+      const response = await axios.head(API + location, { headers: TUS_HEADERS })
+      offset = Number(response.headers['upload-offset'])
+    } catch {
+      location = null
+    }
+  }
+  if (!location) {
+    const response = await axios.post(`${API}/uploads`, null, {
+      headers: {
+        ...TUS_HEADERS,
+        'Upload-Length': String(file.size),
+        'Upload-Metadata': uploadMetadata,
+      },
+    })
+    location = response.headers['location'] as string
+    localStorage.setItem(key, location)
+  }
+
+  // 每个分块附带 SHA-256，服务端校验失败时丢弃该分块
+  onProgress?.(offset, file.size)
+  do {
+    const chunk = await file.slice(offset, offset + CHUNK_SIZE).arrayBuffer()
+    const response = await axios.patch(API + location, chunk, {
+      headers: {
+        ...TUS_HEADERS,
+        'Content-Type': 'application/offset+octet-stream',
+        'Upload-Offset': String(offset),
+        'Upload-Checksum': `sha256 ${await sha256Base64(chunk)}`,
+      },
+    })
+    offset = Number(response.headers['upload-offset'])
+    onProgress?.(offset, file.size)
+  } while (offset < file.size)
+
+  localStorage.removeItem(key)
+  return location.split('/').pop() as string
+}
+
+// 查询上传结果，加密上传的 CID 只有上传完成后才能确定
+export async function getUpload(uploadId: string) {
+  const response = await axios.get(`${API}/uploads/${uploadId}`, { headers: TUS_HEADERS })
+  return response.data
+}